@@ -39,6 +39,21 @@ func NewHistoryValue(generation int, value int64) *HistoryValue {
 	return &HistoryValue{Generation: generation, Value: value}
 }
 
+// HistoryState is the serializable state of a HistoryValue, used to
+// checkpoint a model in training
+type HistoryState struct {
+	Generation, PrevGeneration int
+	Value, Total               int64
+}
+
+func (h *HistoryValue) State() HistoryState {
+	return HistoryState{h.Generation, h.PrevGeneration, h.Value, h.Total}
+}
+
+func NewHistoryValueFromState(state HistoryState) *HistoryValue {
+	return &HistoryValue{Generation: state.Generation, PrevGeneration: state.PrevGeneration, Value: state.Value, Total: state.Total}
+}
+
 type TransitionScoreKVFunc func(key int, value *HistoryValue)
 
 type TransitionScoreStore interface {
@@ -171,14 +186,17 @@ func (l *LockedMap) Each(f TransitionScoreKVFunc) {
 
 type AvgSparse struct {
 	sync.RWMutex
-	Dense bool
-	Vals  map[Feature]TransitionScoreStore
+	Dense   bool
+	Vals    map[Feature]TransitionScoreStore
+	Updates map[Feature]int // number of updates per feature, nil if not tracked
 }
 
 func (v *AvgSparse) Value(transition int, featureRaw interface{}) int64 {
 	feature := fmt.Sprintf("%v", featureRaw)
 	transitions, exists := v.Vals[feature]
-	if exists && transition < transitions.Len() {
+	// stores return nil for transitions they don't have; the length of a
+	// map store is its number of transitions, not a bound on them
+	if exists {
 		if histValue := transitions.GetValue(transition); histValue != nil {
 			return histValue.Value
 		}
@@ -190,6 +208,9 @@ func (v *AvgSparse) Add(generation, transition int, featureRaw interface{}, amou
 	v.Lock()
 	defer v.Unlock()
 	feature := fmt.Sprintf("%v", featureRaw)
+	if v.Updates != nil {
+		v.Updates[feature]++
+	}
 	transitions, exists := v.Vals[feature]
 	if exists {
 		// wg.Add(1)
@@ -281,6 +302,113 @@ func (v *AvgSparse) UpdateScalarDivide(byValue int64) *AvgSparse {
 	return v
 }
 
+// ScalarDivideValues divides the feature values, keeping their averaging
+// totals at generation
+func (v *AvgSparse) ScalarDivideValues(byValue int64, generation int) *AvgSparse {
+	if byValue == 0.0 {
+		panic("Divide by 0")
+	}
+	v.RLock()
+	defer v.RUnlock()
+	for _, val := range v.Vals {
+		val.Each(func(i int, histValue *HistoryValue) {
+			if histValue != nil {
+				histValue.Total = histValue.IntegratedValue(generation)
+				histValue.Value = histValue.Value / byValue
+				histValue.Generation, histValue.PrevGeneration = generation, generation-1
+			}
+		})
+	}
+	return v
+}
+
+// RestartHistory restarts the averaging of the feature values at
+// generation 0, as if they were set then
+func (v *AvgSparse) RestartHistory() *AvgSparse {
+	v.RLock()
+	defer v.RUnlock()
+	for _, val := range v.Vals {
+		val.Each(func(i int, histValue *HistoryValue) {
+			if histValue != nil {
+				histValue.Total, histValue.Generation, histValue.PrevGeneration = 0, 0, -1
+			}
+		})
+	}
+	return v
+}
+
+// AddHistory adds the feature values and averaging totals of other (at
+// otherGeneration) to those of v (at generation), restamping all values
+// to sumGeneration
+func (v *AvgSparse) AddHistory(other *AvgSparse, generation, otherGeneration, sumGeneration int) *AvgSparse {
+	v.Lock()
+	defer v.Unlock()
+	restamp := func(h *HistoryValue, total int64) {
+		h.Total, h.Generation, h.PrevGeneration = total, sumGeneration, sumGeneration-1
+	}
+	for _, store := range v.Vals {
+		store.Each(func(i int, histValue *HistoryValue) {
+			if histValue != nil {
+				restamp(histValue, histValue.IntegratedValue(generation))
+			}
+		})
+	}
+	other.RLock()
+	defer other.RUnlock()
+	for k, otherStore := range other.Vals {
+		store, exists := v.Vals[k]
+		if !exists {
+			store = v.newTransitionScoreStore(otherStore.Len())
+			v.Vals[k] = store
+		}
+		otherStore.Each(func(i int, otherValue *HistoryValue) {
+			if otherValue == nil {
+				return
+			}
+			if histValue := store.GetValue(i); histValue != nil {
+				histValue.Value += otherValue.Value
+				histValue.Total += otherValue.IntegratedValue(otherGeneration)
+			} else {
+				histValue = &HistoryValue{Value: otherValue.Value}
+				restamp(histValue, otherValue.IntegratedValue(otherGeneration))
+				if array, isArray := store.(*LockedArray); isArray && i >= len(array.Vals) {
+					array.ExtendFor(sumGeneration, i)
+				}
+				store.SetValue(i, histValue)
+			}
+		})
+	}
+	if v.Updates != nil && other.Updates != nil {
+		for k, updates := range other.Updates {
+			v.Updates[k] += updates
+		}
+	}
+	return v
+}
+
+// Copy returns a deep copy, including the averaging state and update counts
+func (v *AvgSparse) Copy() *AvgSparse {
+	v.RLock()
+	defer v.RUnlock()
+	retval := &AvgSparse{Dense: v.Dense, Vals: make(map[Feature]TransitionScoreStore, len(v.Vals))}
+	for k, store := range v.Vals {
+		newStore := retval.newTransitionScoreStore(store.Len())
+		store.Each(func(i int, histValue *HistoryValue) {
+			if histValue != nil {
+				newStore.SetValue(i, NewHistoryValueFromState(histValue.State()))
+			}
+		})
+		retval.Vals[k] = newStore
+	}
+	if v.Updates != nil {
+		retval.Updates = make(map[Feature]int, len(v.Updates))
+		for k, updates := range v.Updates {
+			retval.Updates[k] = updates
+		}
+	}
+	return retval
+}
+
 func (v *AvgSparse) String() string {
 	strs := make([]string, 0, len(v.Vals))
 	v.RLock()
@@ -342,6 +470,132 @@ func (v *AvgSparse) Deserialize(serialized interface{}, generation int) {
 	}
 }
 
+// SerializeHistory returns the complete averaging state of the feature
+// values, from which training can be resumed
+func (v *AvgSparse) SerializeHistory() map[interface{}]map[int]HistoryState {
+	retval := make(map[interface{}]map[int]HistoryState, len(v.Vals))
+	for k, store := range v.Vals {
+		states := make(map[int]HistoryState, store.Len())
+		store.Each(func(i int, value *HistoryValue) {
+			if value != nil {
+				states[i] = value.State()
+			}
+		})
+		retval[k] = states
+	}
+	return retval
+}
+
+func (v *AvgSparse) DeserializeHistory(data map[interface{}]map[int]HistoryState) {
+	v.Vals = make(map[Feature]TransitionScoreStore, len(data))
+	for k, states := range data {
+		size := len(states)
+		if v.Dense {
+			size = 0
+			for i, _ := range states {
+				if i >= size {
+					size = i + 1
+				}
+			}
+		}
+		scoreStore := v.newTransitionScoreStore(size)
+		for i, state := range states {
+			scoreStore.SetValue(i, NewHistoryValueFromState(state))
+		}
+		v.Vals[k] = scoreStore
+	}
+}
+
+// CountUpdates starts counting the number of updates per feature
+func (v *AvgSparse) CountUpdates() {
+	if v.Updates == nil {
+		v.Updates = make(map[Feature]int, len(v.Vals))
+	}
+}
+
+// SerializeUpdates returns the number of updates per feature, or nil if
+// updates are not tracked
+func (v *AvgSparse) SerializeUpdates() map[interface{}]int {
+	if v.Updates == nil {
+		return nil
+	}
+	retval := make(map[interface{}]int, len(v.Updates))
+	for k, updates := range v.Updates {
+		retval[k] = updates
+	}
+	return retval
+}
+
+func (v *AvgSparse) DeserializeUpdates(data map[interface{}]int) {
+	v.Updates = make(map[Feature]int, len(data))
+	for k, updates := range data {
+		v.Updates[k] = updates
+	}
+}
+
+// Prune removes feature values with a weight magnitude below minWeight,
+// and features updated fewer than minUpdates times (if updates are
+// tracked), re-packing the transition stores of the remaining features
+func (v *AvgSparse) Prune(minWeight int64, minUpdates int) (removedValues, removedFeatures int) {
+	v.Lock()
+	defer v.Unlock()
+	keep := func(value *HistoryValue) bool {
+		return value.Value >= minWeight || value.Value <= -minWeight
+	}
+	for feature, store := range v.Vals {
+		var kept, values, maxKept int
+		store.Each(func(i int, value *HistoryValue) {
+			if value == nil {
+				return
+			}
+			values++
+			if keep(value) {
+				kept++
+				if i > maxKept {
+					maxKept = i
+				}
+			}
+		})
+		if kept == 0 || (v.Updates != nil && v.Updates[feature] < minUpdates) {
+			delete(v.Vals, feature)
+			if v.Updates != nil {
+				delete(v.Updates, feature)
+			}
+			removedValues += values
+			removedFeatures++
+			continue
+		}
+		var packed TransitionScoreStore
+		if _, isArray := store.(*LockedArray); isArray {
+			packed = &LockedArray{Vals: make([]*HistoryValue, maxKept+1)}
+		} else {
+			packed = &LockedMap{Vals: make(map[int]*HistoryValue, kept)}
+		}
+		store.Each(func(i int, value *HistoryValue) {
+			if value != nil && keep(value) {
+				packed.SetValue(i, value)
+			}
+		})
+		v.Vals[feature] = packed
+		removedValues += values - kept
+	}
+	return
+}
+
+// Size returns the number of features and of (non-nil) feature values
+func (v *AvgSparse) Size() (features, values int) {
+	v.RLock()
+	defer v.RUnlock()
+	for _, store := range v.Vals {
+		store.Each(func(_ int, value *HistoryValue) {
+			if value != nil {
+				values++
+			}
+		})
+	}
+	return len(v.Vals), values
+}
+
 func (v *AvgSparse) newTransitionScoreStore(size int) TransitionScoreStore {
 	if v.Dense {
 		return &LockedArray{Vals: make([]*HistoryValue, size)}
@@ -1,9 +1,15 @@
 package featurevector
 
-import "testing"
+import (
+	"sync"
+	"testing"
+)
 
 func TestHistoryValue(t *testing.T) {
 	var h *HistoryValue
+	// values are integrated to their sum over generations, the average
+	// times the number of generations
+
 	// test average of single occurence (integration of 1)
 	h = NewHistoryValue(0, 1.0)
 	h.Integrate(1)
@@ -14,10 +20,10 @@ func TestHistoryValue(t *testing.T) {
 	// should be 0
 	h = NewHistoryValue(0, 0.0)
 	// value of 4, generation 2
-	h.Increment(2)
-	h.Increment(2)
-	h.Increment(2)
-	h.Increment(2)
+	h.Add(2, 1)
+	h.Add(2, 1)
+	h.Add(2, 1)
+	h.Add(2, 1)
 	// value of 4 remains, integrate generation 4
 	// should be average of 2
 	h.Integrate(2)
@@ -29,31 +35,89 @@ func TestHistoryValue(t *testing.T) {
 	// test average of same value multiple occurences
 	h = NewHistoryValue(0, 0.0)
 	// value of 4, generation 2
-	h.Increment(2)
-	h.Increment(2)
-	h.Increment(2)
-	h.Increment(2)
+	h.Add(2, 1)
+	h.Add(2, 1)
+	h.Add(2, 1)
+	h.Add(2, 1)
 	// value of 4 remains, integrate generation 4
 	// should be average of 2
 	h.Integrate(4)
 	// test average of two values same number of occurences
-	if h.Value != 2.0 {
-		t.Errorf("Expected 2.0 average, got %v", h.Value)
+	if h.Value != 2*4 {
+		t.Errorf("Expected 2.0 average (sum 8), got %v", h.Value)
 	}
 
 	// test average with ratio occurence:
 	// [0 x4, 20 x2, 40 x2] = 15
 	h = NewHistoryValue(0, 0.0)
-	h.Increment(4)
+	h.Add(4, 1)
 	// shortcut to set value
 	h.Value = 20.0
-	h.Increment(6)
+	h.Add(6, 0)
 	h.Value = 40.0
 	h.Integrate(8)
 
-	if h.Value != 15.0 {
-		t.Errorf("Expected 15.0 average, got %v", h.Value)
+	if h.Value != 15*8 {
+		t.Errorf("Expected 15.0 average (sum 120), got %v", h.Value)
 	}
 
 	// test various
 }
+
+func addUpdates(v *AvgSparse, generation int, features ...string) {
+	var wg sync.WaitGroup
+	for _, feature := range features {
+		wg.Add(1)
+		v.Add(generation, 0, feature, 1, &wg)
+	}
+	wg.Wait()
+}
+
+func TestAvgSparseCountUpdates(t *testing.T) {
+	v := MakeAvgSparse(false)
+	addUpdates(v, 1, "a", "a", "b")
+	if v.Updates != nil || v.SerializeUpdates() != nil {
+		t.Errorf("Expected no update counts without counting, got %v", v.Updates)
+	}
+
+	v.CountUpdates()
+	addUpdates(v, 2, "a", "b", "b", "c")
+	if v.Updates["a"] != 1 || v.Updates["b"] != 2 || v.Updates["c"] != 1 {
+		t.Errorf("Got wrong update counts %v", v.Updates)
+	}
+	copied := v.Copy()
+	copied.CountUpdates()
+	if copied.Updates["b"] != 2 {
+		t.Errorf("Expected CountUpdates to keep the copied counts, got %v", copied.Updates)
+	}
+
+	// features updated fewer than twice are removed
+	removedValues, removedFeatures := v.Prune(0, 2)
+	if removedFeatures != 2 || removedValues != 2 {
+		t.Errorf("Expected 2 removed features and values, got %d %d", removedFeatures, removedValues)
+	}
+	if _, exists := v.Vals["b"]; !exists || len(v.Vals) != 1 {
+		t.Errorf("Expected only feature b after pruning, got %v", v.Vals)
+	}
+}
+
+func TestAvgSparseValue(t *testing.T) {
+	for _, dense := range []bool{false, true} {
+		v := MakeAvgSparse(dense)
+		var wg sync.WaitGroup
+		// transitions beyond the number of transitions of a map store
+		for _, transition := range []int{5, 2} {
+			wg.Add(1)
+			v.Add(0, transition, "a", int64(transition), &wg)
+			wg.Wait()
+		}
+		for transition, expected := range map[int]int64{5: 5, 2: 2, 3: 0, 7: 0} {
+			if value := v.Value(transition, "a"); value != expected {
+				t.Errorf("Dense %v: got value %d of transition %d, expected %d", dense, value, transition, expected)
+			}
+		}
+		if value := v.Value(5, "b"); value != 0 {
+			t.Errorf("Dense %v: got value %d of a missing feature", dense, value)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"testing"
 )
 
+// weights are integers, the test values are scaled by 4 so that the
+// divisions remain exact
 type SparseTest struct {
 	t    *testing.T
 	vec1 Sparse
@@ -12,156 +14,156 @@ type SparseTest struct {
 
 func (v *SparseTest) Init() {
 	v.vec1, v.vec2 = make(Sparse), make(Sparse)
-	v.vec1[Feature("only1")] = 1.0
-	v.vec1[Feature("a")] = 1.0
-	v.vec1[Feature("b")] = 0.5
-	v.vec1[Feature("c")] = -0.5
+	v.vec1[Feature("only1")] = 4
+	v.vec1[Feature("a")] = 4
+	v.vec1[Feature("b")] = 2
+	v.vec1[Feature("c")] = -2
 
-	v.vec2[Feature("a")] = 1.0
-	v.vec2[Feature("b")] = 2.0
-	v.vec2[Feature("c")] = 0.0
-	v.vec2[Feature("only2")] = 3.0
+	v.vec2[Feature("a")] = 4
+	v.vec2[Feature("b")] = 8
+	v.vec2[Feature("c")] = 0
+	v.vec2[Feature("only2")] = 12
 }
 
 func (v *SparseTest) Add() {
 	vec := v.vec1.Add(v.vec2)
-	if vec[Feature("only1")] != 1.0 {
-		v.t.Error("Got", vec[Feature("only1")], "expected", 1.0)
+	if vec[Feature("only1")] != 4 {
+		v.t.Error("Got", vec[Feature("only1")], "expected", 4)
 	}
-	if vec[Feature("a")] != 2.0 {
-		v.t.Error("Got", vec[Feature("a")], "expected", 2.0)
+	if vec[Feature("a")] != 8 {
+		v.t.Error("Got", vec[Feature("a")], "expected", 8)
 	}
-	if vec[Feature("b")] != 2.5 {
-		v.t.Error("Got", vec[Feature("b")], "expected", 2.5)
+	if vec[Feature("b")] != 10 {
+		v.t.Error("Got", vec[Feature("b")], "expected", 10)
 	}
-	if vec[Feature("c")] != -0.5 {
-		v.t.Error("Got", vec[Feature("c")], "expected", -0.5)
+	if vec[Feature("c")] != -2 {
+		v.t.Error("Got", vec[Feature("c")], "expected", -2)
 	}
-	if vec[Feature("only2")] != 3.0 {
-		v.t.Error("Got", vec[Feature("only2")], "expected", 3.0)
+	if vec[Feature("only2")] != 12 {
+		v.t.Error("Got", vec[Feature("only2")], "expected", 12)
 	}
 }
 
 func (v *SparseTest) Subtract() {
 	vec := v.vec1.Subtract(v.vec2)
-	if vec[Feature("only1")] != 1.0 {
-		v.t.Error("Got", vec[Feature("only1")], "expected", 1.0)
+	if vec[Feature("only1")] != 4 {
+		v.t.Error("Got", vec[Feature("only1")], "expected", 4)
 	}
-	if vec[Feature("a")] != 0.0 {
-		v.t.Error("Got", vec[Feature("a")], "expected", 0.0)
+	if vec[Feature("a")] != 0 {
+		v.t.Error("Got", vec[Feature("a")], "expected", 0)
 	}
-	if vec[Feature("b")] != -1.5 {
-		v.t.Error("Got", vec[Feature("b")], "expected", -1.5)
+	if vec[Feature("b")] != -6 {
+		v.t.Error("Got", vec[Feature("b")], "expected", -6)
 	}
-	if vec[Feature("c")] != -0.5 {
-		v.t.Error("Got", vec[Feature("c")], "expected", -0.5)
+	if vec[Feature("c")] != -2 {
+		v.t.Error("Got", vec[Feature("c")], "expected", -2)
 	}
-	if vec[Feature("only2")] != -3.0 {
-		v.t.Error("Got", vec[Feature("only2")], "expected", -3.0)
+	if vec[Feature("only2")] != -12 {
+		v.t.Error("Got", vec[Feature("only2")], "expected", -12)
 	}
 
 }
 
 func (v *SparseTest) DotProduct() {
 	dot := v.vec1.DotProduct(v.vec2)
-	if dot != 2.0 {
-		v.t.Error("Expected dot product", 2.0, "got", dot)
+	if dot != 32 {
+		v.t.Error("Expected dot product", 32, "got", dot)
 	}
 }
 
 func (v *SparseTest) FeatureWeights() {
 	features := []Feature{"only1", "a", "b"}
 	weights := v.vec1.FeatureWeights(features)
-	if weights[Feature("only1")] != 1.0 {
-		v.t.Error("Got", weights[Feature("only1")], "expected", 1.0)
+	if weights[Feature("only1")] != 4 {
+		v.t.Error("Got", weights[Feature("only1")], "expected", 4)
 	}
-	if weights[Feature("a")] != 1.0 {
-		v.t.Error("Got", weights[Feature("a")], "expected", 1.0)
+	if weights[Feature("a")] != 4 {
+		v.t.Error("Got", weights[Feature("a")], "expected", 4)
 	}
-	if weights[Feature("b")] != 0.5 {
-		v.t.Error("Got", weights[Feature("b")], "expected", 0.5)
+	if weights[Feature("b")] != 2 {
+		v.t.Error("Got", weights[Feature("b")], "expected", 2)
 	}
 }
 
 func (v *SparseTest) DotProductFeatures() {
 	features := []Feature{"only1", "a", "b", "c"}
 	dot := v.vec1.DotProductFeatures(features)
-	if dot != 2.0 {
-		v.t.Error("Expected dot product", 2.0, "got", dot)
+	if dot != 8 {
+		v.t.Error("Expected dot product", 8, "got", dot)
 	}
 }
 
 func (v *SparseTest) UpdateSubtract() {
 	v.vec1.UpdateSubtract(v.vec2)
-	if v.vec1[Feature("only1")] != 1.0 {
-		v.t.Error("Got", v.vec1[Feature("only1")], "expected", 1.0)
+	if v.vec1[Feature("only1")] != 4 {
+		v.t.Error("Got", v.vec1[Feature("only1")], "expected", 4)
 	}
-	if v.vec1[Feature("a")] != 0.0 {
-		v.t.Error("Got", v.vec1[Feature("a")], "expected", 0.0)
+	if v.vec1[Feature("a")] != 0 {
+		v.t.Error("Got", v.vec1[Feature("a")], "expected", 0)
 	}
-	if v.vec1[Feature("b")] != -1.5 {
-		v.t.Error("Got", v.vec1[Feature("b")], "expected", -1.5)
+	if v.vec1[Feature("b")] != -6 {
+		v.t.Error("Got", v.vec1[Feature("b")], "expected", -6)
 	}
-	if v.vec1[Feature("c")] != -0.5 {
-		v.t.Error("Got", v.vec1[Feature("c")], "expected", -0.5)
+	if v.vec1[Feature("c")] != -2 {
+		v.t.Error("Got", v.vec1[Feature("c")], "expected", -2)
 	}
-	if v.vec1[Feature("only2")] != -3.0 {
-		v.t.Error("Got", v.vec1[Feature("only2")], "expected", -3.0)
+	if v.vec1[Feature("only2")] != -12 {
+		v.t.Error("Got", v.vec1[Feature("only2")], "expected", -12)
 	}
 
 }
 
 func (v *SparseTest) UpdateAdd() {
 	v.vec1.UpdateAdd(v.vec2)
-	if v.vec1[Feature("only1")] != 1.0 {
-		v.t.Error("Got", v.vec1[Feature("only1")], "expected", 1.0)
+	if v.vec1[Feature("only1")] != 4 {
+		v.t.Error("Got", v.vec1[Feature("only1")], "expected", 4)
 	}
-	if v.vec1[Feature("a")] != 1.0 {
-		v.t.Error("Got", v.vec1[Feature("a")], "expected", 1.0)
+	if v.vec1[Feature("a")] != 4 {
+		v.t.Error("Got", v.vec1[Feature("a")], "expected", 4)
 	}
-	if v.vec1[Feature("b")] != 0.5 {
-		v.t.Error("Got", v.vec1[Feature("b")], "expected", 0.5)
+	if v.vec1[Feature("b")] != 2 {
+		v.t.Error("Got", v.vec1[Feature("b")], "expected", 2)
 	}
-	if v.vec1[Feature("c")] != -0.5 {
-		v.t.Error("Got", v.vec1[Feature("c")], "expected", -0.5)
+	if v.vec1[Feature("c")] != -2 {
+		v.t.Error("Got", v.vec1[Feature("c")], "expected", -2)
 	}
-	if v.vec1[Feature("only2")] != 0.0 {
-		v.t.Error("Got", v.vec1[Feature("only2")], "expected", 0.0)
+	if v.vec1[Feature("only2")] != 0 {
+		v.t.Error("Got", v.vec1[Feature("only2")], "expected", 0)
 	}
 }
 
 func (v *SparseTest) UpdateScalarDivide() {
-	v.vec1.UpdateScalarDivide(1.0)
-	if v.vec1[Feature("only1")] != 1.0 {
-		v.t.Error("Got", v.vec1[Feature("only1")], "expected", 1.0)
+	v.vec1.UpdateScalarDivide(1)
+	if v.vec1[Feature("only1")] != 4 {
+		v.t.Error("Got", v.vec1[Feature("only1")], "expected", 4)
 	}
-	if v.vec1[Feature("a")] != 1.0 {
-		v.t.Error("Got", v.vec1[Feature("a")], "expected", 1.0)
+	if v.vec1[Feature("a")] != 4 {
+		v.t.Error("Got", v.vec1[Feature("a")], "expected", 4)
 	}
-	if v.vec1[Feature("b")] != 0.5 {
-		v.t.Error("Got", v.vec1[Feature("b")], "expected", 0.5)
+	if v.vec1[Feature("b")] != 2 {
+		v.t.Error("Got", v.vec1[Feature("b")], "expected", 2)
 	}
-	if v.vec1[Feature("c")] != -0.5 {
-		v.t.Error("Got", v.vec1[Feature("c")], "expected", -0.5)
+	if v.vec1[Feature("c")] != -2 {
+		v.t.Error("Got", v.vec1[Feature("c")], "expected", -2)
 	}
-	if v.vec1[Feature("only2")] != 0.0 {
-		v.t.Error("Got", v.vec1[Feature("only2")], "expected", 0.0)
+	if v.vec1[Feature("only2")] != 0 {
+		v.t.Error("Got", v.vec1[Feature("only2")], "expected", 0)
 	}
-	v.vec1.UpdateScalarDivide(2.0)
-	if v.vec1[Feature("only1")] != 0.5 {
-		v.t.Error("Got", v.vec1[Feature("only1")], "expected", 0.5)
+	v.vec1.UpdateScalarDivide(2)
+	if v.vec1[Feature("only1")] != 2 {
+		v.t.Error("Got", v.vec1[Feature("only1")], "expected", 2)
 	}
-	if v.vec1[Feature("a")] != 0.5 {
-		v.t.Error("Got", v.vec1[Feature("a")], "expected", 0.5)
+	if v.vec1[Feature("a")] != 2 {
+		v.t.Error("Got", v.vec1[Feature("a")], "expected", 2)
 	}
-	if v.vec1[Feature("b")] != 0.25 {
-		v.t.Error("Got", v.vec1[Feature("b")], "expected", 0.25)
+	if v.vec1[Feature("b")] != 1 {
+		v.t.Error("Got", v.vec1[Feature("b")], "expected", 1)
 	}
-	if v.vec1[Feature("c")] != -0.25 {
-		v.t.Error("Got", v.vec1[Feature("c")], "expected", -0.25)
+	if v.vec1[Feature("c")] != -1 {
+		v.t.Error("Got", v.vec1[Feature("c")], "expected", -1)
 	}
-	if v.vec1[Feature("only2")] != 0.0 {
-		v.t.Error("Got", v.vec1[Feature("only2")], "expected", 0.0)
+	if v.vec1[Feature("only2")] != 0 {
+		v.t.Error("Got", v.vec1[Feature("only2")], "expected", 0)
 	}
 }
 
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	// "io"
 	"log"
+	"math/rand"
+	"sync"
 
 // "os"
 )
@@ -24,6 +26,22 @@ type LinearPerceptron struct {
 	TrainI, TrainJ int
 	TempLines      int
 
+	// number of updates (generations) done before TrainI, TrainJ
+	// when resuming training
+	TrainGenerations int
+
+	// shuffle the gold instances every iteration, with a generator seeded
+	// once per training run
+	Shuffle     bool
+	ShuffleSeed int64
+	shuffleRand *rand.Rand
+
+	// iterative parameter mixing (McDonald et al. 2010): given more than
+	// one shard decoder, every iteration trains copies of the model on
+	// shards of the gold instances concurrently, then mixes (averages) them
+	ShardDecoders     []EarlyUpdateInstanceDecoder
+	ShardGoldDecoders []InstanceDecoder
+
 	FailedInstances int
 
 	Continue StopCondition
@@ -42,9 +60,18 @@ var PercepAllOut bool = false
 func (m *LinearPerceptron) Init(newModel Model) {
 	m.Model = newModel
 	m.TrainI, m.TrainJ = 0, -1
+	m.TrainGenerations = 0
 	m.Updater.Init(m.Model, m.Iterations)
 }
 
+// Resume sets the position (iteration, last trained instance) and number
+// of generations from which training continues, after Init with a model
+// restored from a checkpoint
+func (m *LinearPerceptron) Resume(i, j, generations int) {
+	m.TrainI, m.TrainJ = i, j
+	m.TrainGenerations = generations
+}
+
 func DefaultStopCondition(iteration, iterations, generations int, model Model) bool {
 	return iteration < iterations
 }
@@ -53,9 +80,195 @@ func (m *LinearPerceptron) Train(goldInstances []DecodedInstance) {
 	if m.Continue == nil {
 		m.Continue = DefaultStopCondition
 	}
+	if len(m.ShardDecoders) > 1 {
+		m.trainMixed(goldInstances, m.Iterations)
+		return
+	}
 	m.train(goldInstances, m.Decoder, m.Iterations)
 }
 
+// A GenerationModel counts its updates (generations) for averaging, which
+// the shards of iterative parameter mixing do without an UpdateStrategy.
+// A shard copy has the weights of the model with its averaging restarted,
+// so that adding the trained copy adds only its own generations
+type GenerationModel interface {
+	Model
+	IncrementGeneration()
+	ShardCopy() Model
+}
+
+func (m *LinearPerceptron) trainMixed(goldInstances []DecodedInstance, iterations int) {
+	if m.Model == nil {
+		panic("Model not initialized")
+	}
+	mixedModel, ok := m.Model.(GenerationModel)
+	if !ok {
+		panic("Iterative parameter mixing requires a model counting its generations")
+	}
+	if len(m.ShardGoldDecoders) != len(m.ShardDecoders) {
+		panic("Got different number of shard decoders and gold decoders")
+	}
+	var (
+		shards      = len(m.ShardDecoders)
+		generations = m.TrainGenerations
+		prevPrefix  = log.Prefix()
+	)
+	if m.Shuffle {
+		m.initShuffle(len(goldInstances))
+	}
+	for i := m.TrainI; m.Continue(i, iterations, generations, m.Model); i++ {
+		log.SetPrefix("IT #" + fmt.Sprintf("%v ", i) + prevPrefix)
+		instances := goldInstances
+		if m.Shuffle {
+			instances = m.shuffled(goldInstances)
+		}
+		// the first shard trains the model itself, the others copies of it
+		models := make([]Model, shards)
+		models[0] = m.Model
+		for s := 1; s < shards; s++ {
+			models[s] = mixedModel.ShardCopy()
+		}
+		var (
+			wg                    sync.WaitGroup
+			shardGens, shardFails = make([]int, shards), make([]int, shards)
+		)
+		for s := range models {
+			wg.Add(1)
+			go func(s int) {
+				defer wg.Done()
+				shardGens[s], shardFails[s] = m.trainShard(instances, s, i, models[s].(GenerationModel))
+			}(s)
+		}
+		wg.Wait()
+		for s, local := range models {
+			if s > 0 {
+				m.Model.AddModel(local)
+			}
+			generations += shardGens[s]
+			m.FailedInstances += shardFails[s]
+		}
+		// mix (average) the weights; the averaging totals and generations
+		// are summed over the shards, and averaged once by the updater
+		m.Model.ScalarDivide(int64(shards))
+		if m.Log {
+			log.Println("Mixed", shards, "shards at generation", generations)
+		}
+		m.TrainI, m.TrainJ, m.TrainGenerations = i+1, -1, generations
+	}
+	log.SetPrefix(prevPrefix)
+	m.Model = m.Updater.Finalize(m.Model)
+}
+
+// trainShard trains a model on every shards'th gold instance starting at
+// shard, returning the number of generations and failed instances
+func (m *LinearPerceptron) trainShard(goldInstances []DecodedInstance, shard, iteration int, model GenerationModel) (generations, failed int) {
+	shards := len(m.ShardDecoders)
+	decoder, goldDecoder := m.ShardDecoders[shard], m.ShardGoldDecoders[shard]
+	if iterationDecoder, ok := decoder.(IterationDecoder); ok {
+		iterationDecoder.SetIteration(iteration)
+	}
+	for j := shard; j < len(goldInstances); j += shards {
+		if !m.trainInstance(goldInstances[j], iteration, j, decoder, goldDecoder, model, "") {
+			failed++
+			continue
+		}
+		generations += 1
+		model.IncrementGeneration()
+	}
+	return
+}
+
+// trainInstance decodes the j'th gold instance of iteration i, updating
+// the model if the decoded instance differs from the gold. Returns false
+// if the instance was skipped. Given a log prefix, the gold decoding is
+// logged with the instance number
+func (m *LinearPerceptron) trainInstance(goldInstance DecodedInstance, i, j int, decoder EarlyUpdateInstanceDecoder, goldDecoder InstanceDecoder, model Model, logPrefix string) bool {
+	if len(logPrefix) > 0 {
+		log.SetPrefix(logPrefix + fmt.Sprintf("sent %v ", j))
+	}
+	goldDecoded, _ := goldDecoder.DecodeGold(goldInstance, model)
+	if len(logPrefix) > 0 {
+		log.SetPrefix(logPrefix)
+	}
+	if goldDecoded == nil && i == 0 {
+		if m.Log {
+			log.Println("At instance", j, "skipped (decode)")
+
+		}
+		return false
+	}
+	decodedInstance, decodedFeatures, goldFeatures, earlyUpdatedAt, goldSize, score := decoder.DecodeEarlyUpdate(goldDecoded, model)
+	if decodedInstance == nil {
+		if m.Log {
+			log.Println("At instance", j, "skipped (parse)")
+		}
+		return false
+	}
+	if !goldDecoded.Equal(decodedInstance) {
+		if m.Log {
+			// if PercepAllOut {
+			// score = m.Model.Score(decodedFeatures)
+			// }
+			if earlyUpdatedAt >= 0 {
+				if PercepAllOut {
+					log.Printf("Error at %d of %d ; score %v\n", earlyUpdatedAt, goldSize, score)
+				} else {
+					log.Println("At instance", j, "failed", earlyUpdatedAt, "of", goldSize)
+				}
+			} else {
+				if PercepAllOut {
+					log.Printf("Error at %d of %d ; score %v\n", goldSize-1, goldSize, score)
+				} else {
+					log.Println("At instance", j, "failed", goldSize, "of", goldSize)
+				}
+			}
+			// log.Println("Decoded did not equal gold, updating")
+			// log.Println("Decoded:")
+			// log.Println(decodedInstance.Decoded())
+			// log.Println("Gold:")
+			// log.Println(goldDecoded.Decoded())
+			// if goldFeatures != nil {
+			// 	log.Println("Add Gold:", goldFeatures, "features")
+			// } else {
+			// 	panic("Decode failed but got nil gold model")
+			// }
+			// if decodedFeatures != nil {
+			// 	log.Println("Sub Pred:", decodedFeatures, "features")
+			// } else {
+			// 	panic("Decode failed but got nil decode model")
+			// }
+		}
+		if PercepAllOut {
+			log.Println("Score 1 to")
+		}
+		model.AddSubtract(goldFeatures, decodedFeatures, 1.0)
+		if PercepAllOut {
+			log.Println("Score -1 to")
+		}
+		model.AddSubtract(decodedFeatures, decodedFeatures, -1.0)
+		if PercepAllOut {
+			log.Println("ITERATION COMPLETE")
+		}
+
+		// if m.Log {
+		// 	log.Println("After Model Update:")
+		// 	log.Println("\n", m.Model)
+		// }
+		// log.Println()
+
+		// log.Println("Model after:")
+		// for k, v := range *m.Model {
+		// 	log.Println(k, v)
+		// }
+		// log.Println()
+	} else {
+		if m.Log && !PercepAllOut {
+			log.Println("At instance", j, "success")
+		}
+	}
+	return true
+}
+
 func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyUpdateInstanceDecoder, iterations int) {
 	var (
 		generations int
@@ -68,6 +281,10 @@ func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyU
 	prevFlags := log.Flags()
 	// prevGC := debug.SetGCPercent(-1)
 	// var score int64
+	generations = m.TrainGenerations
+	if m.Shuffle {
+		m.initShuffle(len(goldInstances))
+	}
 	for i := m.TrainI; m.Continue(i, iterations, generations, m.Model); i++ {
 		logPrefix = "IT #" + fmt.Sprintf("%v ", i) + prevPrefix
 		log.SetPrefix(logPrefix)
@@ -76,96 +293,29 @@ func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyU
 			log.SetPrefix("")
 			log.SetFlags(0)
 		}
-		for j, goldInstance := range goldInstances[m.TrainJ+1:] {
+		instances := goldInstances
+		if m.Shuffle {
+			instances = m.shuffled(goldInstances)
+		}
+		if iterationDecoder, ok := decoder.(IterationDecoder); ok {
+			iterationDecoder.SetIteration(i)
+		}
+		startJ := m.TrainJ + 1
+		for j, goldInstance := range instances[startJ:] {
+			j += startJ
 			// if m.Log {
 			// 	if j%100 == 0 {
 			// 		runtime.GC()
 			// 	}
 			// }
 			// log.Println("At goldinstance", j)
-			log.SetPrefix(logPrefix + fmt.Sprintf("sent %v ", j))
-			goldDecoded, _ := m.GoldDecoder.DecodeGold(goldInstance, m.Model)
-			log.SetPrefix(logPrefix)
-			if goldDecoded == nil && i == 0 {
-				if m.Log {
-					log.Println("At instance", j, "skipped (decode)")
-
-				}
-				m.FailedInstances++
-				continue
-			}
-			decodedInstance, decodedFeatures, goldFeatures, earlyUpdatedAt, goldSize, score := decoder.DecodeEarlyUpdate(goldDecoded, m.Model)
-			if decodedInstance == nil {
-				if m.Log {
-					log.Println("At instance", j, "skipped (parse)")
-				}
+			if !m.trainInstance(goldInstance, i, j, decoder, m.GoldDecoder, m.Model, logPrefix) {
 				m.FailedInstances++
 				continue
 			}
-			if !goldDecoded.Equal(decodedInstance) {
-				if m.Log {
-					// if PercepAllOut {
-					// score = m.Model.Score(decodedFeatures)
-					// }
-					if earlyUpdatedAt >= 0 {
-						if PercepAllOut {
-							log.Printf("Error at %d of %d ; score %v\n", earlyUpdatedAt, goldSize, score)
-						} else {
-							log.Println("At instance", j, "failed", earlyUpdatedAt, "of", goldSize)
-						}
-					} else {
-						if PercepAllOut {
-							log.Printf("Error at %d of %d ; score %v\n", goldSize-1, goldSize, score)
-						} else {
-							log.Println("At instance", j, "failed", goldSize, "of", goldSize)
-						}
-					}
-					// log.Println("Decoded did not equal gold, updating")
-					// log.Println("Decoded:")
-					// log.Println(decodedInstance.Decoded())
-					// log.Println("Gold:")
-					// log.Println(goldDecoded.Decoded())
-					// if goldFeatures != nil {
-					// 	log.Println("Add Gold:", goldFeatures, "features")
-					// } else {
-					// 	panic("Decode failed but got nil gold model")
-					// }
-					// if decodedFeatures != nil {
-					// 	log.Println("Sub Pred:", decodedFeatures, "features")
-					// } else {
-					// 	panic("Decode failed but got nil decode model")
-					// }
-				}
-				if PercepAllOut {
-					log.Println("Score 1 to")
-				}
-				m.Model.AddSubtract(goldFeatures, decodedFeatures, 1.0)
-				if PercepAllOut {
-					log.Println("Score -1 to")
-				}
-				m.Model.AddSubtract(decodedFeatures, decodedFeatures, -1.0)
-				if PercepAllOut {
-					log.Println("ITERATION COMPLETE")
-				}
-
-				// if m.Log {
-				// 	log.Println("After Model Update:")
-				// 	log.Println("\n", m.Model)
-				// }
-				// log.Println()
-
-				// log.Println("Model after:")
-				// for k, v := range *m.Model {
-				// 	log.Println(k, v)
-				// }
-				// log.Println()
-			} else {
-				if m.Log && !PercepAllOut {
-					log.Println("At instance", j, "success")
-				}
-			}
 			generations += 1
 			m.Updater.Update(m.Model)
+			m.TrainI, m.TrainJ, m.TrainGenerations = i, j, generations
 			// if m.TempLines > 0 && j > 0 && j%m.TempLines == 0 {
 			// 	// m.TrainJ = j
 			// 	// m.TrainI = i
@@ -202,6 +352,7 @@ func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyU
 		// }
 
 		// log.Println("Ending iteration", i)
+		m.TrainI, m.TrainJ = i+1, -1
 	}
 	log.SetPrefix(prevPrefix)
 	log.SetFlags(prevFlags)
@@ -209,6 +360,25 @@ func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyU
 	// debug.SetGCPercent(prevGC)
 }
 
+// initShuffle seeds the generator shuffling the gold instances, replaying
+// the shuffles of the iterations before TrainI so that resumed training
+// sees the same orders as uninterrupted training
+func (m *LinearPerceptron) initShuffle(instances int) {
+	m.shuffleRand = rand.New(rand.NewSource(m.ShuffleSeed))
+	for i := 0; i < m.TrainI; i++ {
+		m.shuffleRand.Perm(instances)
+	}
+}
+
+// shuffled returns the gold instances in the order of the next iteration
+func (m *LinearPerceptron) shuffled(goldInstances []DecodedInstance) []DecodedInstance {
+	retval := make([]DecodedInstance, len(goldInstances))
+	for j, k := range m.shuffleRand.Perm(len(goldInstances)) {
+		retval[j] = goldInstances[k]
+	}
+	return retval
+}
+
 // func (m *LinearPerceptron) Read(reader io.Reader) {
 // 	dec := gob.NewDecoder(reader)
 // 	model := make(Model)
@@ -1,34 +1,167 @@
 package perceptron
 
-import "testing"
+import (
+	"yap/alg/featurevector"
+	"yap/util"
+
+	"reflect"
+	"testing"
+)
+
+// sparseModel is a Model of feature weights, scoring lists of features
+type sparseModel struct {
+	featurevector.Sparse
+}
+
+var _ Model = &sparseModel{}
+
+func (m *sparseModel) Score(features interface{}) int64 {
+	return m.Sparse.DotProductFeatures(features.([]featurevector.Feature))
+}
+
+func (m *sparseModel) apply(features interface{}, amount int64) {
+	for _, feature := range features.([]featurevector.Feature) {
+		m.Sparse[feature] += amount
+	}
+}
+
+func (m *sparseModel) Add(features interface{}) Model {
+	m.apply(features, 1)
+	return m
+}
+
+func (m *sparseModel) Subtract(features interface{}) Model {
+	m.apply(features, -1)
+	return m
+}
+
+func (m *sparseModel) AddSubtract(goldFeatures, decodedFeatures interface{}, amount int64) {
+	m.apply(goldFeatures, amount)
+}
+
+func (m *sparseModel) ScalarDivide(value int64) {
+	m.Sparse.UpdateScalarDivide(value)
+}
+
+func (m *sparseModel) Copy() Model {
+	return &sparseModel{m.Sparse.Copy()}
+}
+
+func (m *sparseModel) AddModel(other Model) {
+	m.Sparse.UpdateAdd(other.(*sparseModel).Sparse)
+}
+
+func (m *sparseModel) New() Model {
+	return &sparseModel{make(featurevector.Sparse)}
+}
 
 func TestPerceptron(t *testing.T) {
 
 }
 
 func TestTrivialStrategy(t *testing.T) {
-	v := make(featurevector.Sparse)
+	v := &sparseModel{make(featurevector.Sparse)}
 	w := new(TrivialStrategy)
-	w.Init(&v, 10)
-	w.Update(&v)
-	if &v != w.Finalize(&v) {
+	w.Init(v, 10)
+	w.Update(v)
+	if v != w.Finalize(v) {
 		t.Error("Should return trivial value")
 	}
 }
 
 func TestAveragedStrategy(t *testing.T) {
-	v := make(featurevector.Sparse)
-	v[Feature("a")] = 4.0 // (1/8)
-	v[Feature("b")] = 1.0
+	v := &sparseModel{make(featurevector.Sparse)}
+	v.Sparse["a"] = 4
+	v.Sparse["b"] = 1
 	w := new(AveragedStrategy)
-	w.Init(&v, 4)
-	w.Update(&v)
-	w.Update(&v)
-	avg := *(w.Finalize(&v))
-	if avg[Feature("a")] != 1.0 {
-		t.Error("Got averaged value", avg[Feature("a")], "expected", 1.0)
-	}
-	if avg[Feature("b")] != 0.25 {
-		t.Error("Got averaged value", avg[Feature("a")], "expected", 0.25)
+	w.Init(v, 4)
+	w.Update(v)
+	v.Sparse["a"] = 8
+	v.Sparse["b"] = 3
+	w.Update(v)
+	avg := w.Finalize(v).(*sparseModel).Sparse
+	if avg["a"] != 6 {
+		t.Error("Got averaged value", avg["a"], "expected", 6)
+	}
+	if avg["b"] != 2 {
+		t.Error("Got averaged value", avg["b"], "expected", 2)
+	}
+}
+
+type intInstance int
+
+func (i intInstance) Equal(other util.Equaler) bool {
+	otherInt, ok := other.(intInstance)
+	return ok && i == otherInt
+}
+
+// orderDecoder decodes every instance correctly, recording the order of
+// the instances of each iteration (of the given number of instances)
+type orderDecoder struct {
+	instances int
+	orders    [][]int
+}
+
+func (d *orderDecoder) DecodeEarlyUpdate(goldInstance DecodedInstance, m Model) (DecodedInstance, interface{}, interface{}, int, int, float64) {
+	if last := len(d.orders) - 1; last < 0 || len(d.orders[last]) == d.instances {
+		d.orders = append(d.orders, make([]int, 0, d.instances))
+	}
+	last := len(d.orders) - 1
+	d.orders[last] = append(d.orders[last], int(goldInstance.Instance().(intInstance)))
+	return goldInstance, nil, nil, -1, 1, 0
+}
+
+func (d *orderDecoder) Decode(instance Instance, m Model) (DecodedInstance, interface{}) {
+	return &Decoded{instance, instance}, nil
+}
+
+func (d *orderDecoder) DecodeGold(goldInstance DecodedInstance, m Model) (DecodedInstance, interface{}) {
+	return goldInstance, nil
+}
+
+func shuffledOrders(instances []DecodedInstance, iterations, resumeAt int) [][]int {
+	decoder := &orderDecoder{instances: len(instances)}
+	trainer := &LinearPerceptron{
+		Decoder:     decoder,
+		GoldDecoder: decoder,
+		Updater:     new(TrivialStrategy),
+		Iterations:  iterations,
+		Shuffle:     true,
+		ShuffleSeed: 3,
+	}
+	trainer.Init(&sparseModel{make(featurevector.Sparse)})
+	trainer.Resume(resumeAt, -1, 0)
+	trainer.Train(instances)
+	return decoder.orders
+}
+
+func TestShuffle(t *testing.T) {
+	const iterations = 4
+	instances := make([]DecodedInstance, 20)
+	for i := range instances {
+		instances[i] = &Decoded{intInstance(i), intInstance(i)}
+	}
+	orders := shuffledOrders(instances, iterations, 0)
+	if len(orders) != iterations {
+		t.Fatalf("Expected %d iterations, got %d", iterations, len(orders))
+	}
+	for i, order := range orders {
+		seen := make(map[int]bool, len(order))
+		for _, instance := range order {
+			seen[instance] = true
+		}
+		if len(order) != len(instances) || len(seen) != len(instances) {
+			t.Errorf("Iteration %d order %v is not a permutation of the instances", i, order)
+		}
+		if i > 0 && reflect.DeepEqual(order, orders[i-1]) {
+			t.Errorf("Iterations %d and %d have the same order %v", i-1, i, order)
+		}
+	}
+	if again := shuffledOrders(instances, iterations, 0); !reflect.DeepEqual(again, orders) {
+		t.Errorf("Got different orders for the same seed:\n%v\n%v", orders, again)
+	}
+	// resumed training replays the shuffles of the skipped iterations
+	if resumed := shuffledOrders(instances, iterations, 2); !reflect.DeepEqual(resumed, orders[2:]) {
+		t.Errorf("Got resumed orders\n%v\nexpected\n%v", resumed, orders[2:])
 	}
 }
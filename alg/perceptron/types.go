@@ -83,6 +83,12 @@ type EarlyUpdateInstanceDecoder interface {
 	DecodeEarlyUpdate(i DecodedInstance, m Model) (decoded DecodedInstance, decodedFeatures, goldFeatures interface{}, earlyUpdatedAt, goldSize int, decodeScore float64)
 }
 
+// An IterationDecoder is told the training iteration before decoding its
+// instances
+type IterationDecoder interface {
+	SetIteration(int)
+}
+
 type SupervisedTrainer interface {
 	Train(instances []DecodedInstance)
 }
@@ -4,6 +4,7 @@ import (
 	"container/heap"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -57,6 +58,35 @@ type Beam struct {
 	candidateScorePool    *sync.Pool
 	IntegrationGeneration int
 	ScoredStoreDense      bool
+
+	// violation-fixing strategy of training (default early update)
+	Violation string
+
+	// number of best candidates Parse returns in its result parameters, if
+	// more than one
+	KBest int
+	// Parse returns the candidates of the final agenda in its result
+	// parameters
+	ReturnAgenda bool
+}
+
+// Violation-fixing strategies for beam training
+const (
+	EARLY_UPDATE  = "early"
+	MAX_VIOLATION = "max-violation"
+	LATEST_UPDATE = "latest"
+	FULL_UPDATE   = "full"
+)
+
+var VIOLATION_STRATEGIES = []string{EARLY_UPDATE, MAX_VIOLATION, LATEST_UPDATE, FULL_UPDATE}
+
+func ValidViolationStrategy(strategy string) bool {
+	for _, valid := range VIOLATION_STRATEGIES {
+		if strategy == valid {
+			return true
+		}
+	}
+	return false
 }
 
 var _ Interface = &Beam{}
@@ -92,6 +122,7 @@ func (b *Beam) StartItem(p Problem) []Candidate {
 		b.EstimatedTransitions = b.Size
 	}
 
+	p, _ = SplitConstraints(p)
 	c := b.Base.Copy()
 	c.Clear()
 	c.Init(p)
@@ -209,6 +240,9 @@ func (b *Beam) Expand(c Candidate, p Problem, candidateNum int) chan Candidate {
 		// scores.Init()
 		scores.Clear()
 		transType, transitions = b.TransFunc.GetTransitions(currentConf)
+		if _, constraints := SplitConstraints(p); constraints != nil {
+			transitions = constraints.Allowed(currentConf, transType, transitions)
+		}
 		if AllOut {
 			// log.Println("\tSetting transitions to", transitions)
 		}
@@ -369,6 +403,35 @@ func (b *Beam) Top(a Agenda) Candidate {
 	return bestCandidate
 }
 
+// TopK returns the best K distinct candidates of an agenda, best first, all
+// terminal when the search reached its goal. The agenda is a heap, so all
+// of it is sorted and candidates equal to better ones are skipped before
+// cutting to K
+func (b *Beam) TopK(a Agenda, K int) []Candidate {
+	sorted := append([]*ScoredConfiguration(nil), a.(*BaseAgenda).Confs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Score() > sorted[j].Score()
+	})
+	candidates := make([]Candidate, 0, K)
+	for _, candidate := range sorted {
+		if len(candidates) == K {
+			break
+		}
+		candidate.Expand(b.TransFunc)
+		duplicate := false
+		for _, taken := range candidates {
+			if taken.Equal(candidate) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			candidates = append(candidates, candidate.Copy())
+		}
+	}
+	return candidates
+}
+
 func (b *Beam) SetEarlyUpdate(i int) {
 	b.EarlyUpdateAt = i
 }
@@ -426,11 +489,32 @@ func (b *Beam) Parse(problem Problem) (transition.Configuration, interface{}) {
 	prefix := log.Prefix()
 	// log.SetPrefix("Parsing ")
 	// log.Println("Starting parse")
-	beamScored := Search(b, problem, b.Size).(*ScoredConfiguration)
+	var (
+		beamScored    *ScoredConfiguration
+		kBest, agenda []*ScoredConfiguration
+	)
+	if b.KBest > 1 || b.ReturnAgenda {
+		// distinct candidates may still format as the same parse, so k-best
+		// output takes all of them, cutting to KBest after its own dedupe
+		top := SearchKBest(b, problem, b.Size, b.Size)
+		candidates := make([]*ScoredConfiguration, len(top))
+		for i, candidate := range top {
+			candidates[i] = candidate.(*ScoredConfiguration)
+		}
+		if b.KBest > 1 {
+			kBest = candidates
+		}
+		if b.ReturnAgenda {
+			agenda = candidates
+		}
+		beamScored = candidates[0]
+	} else {
+		beamScored = Search(b, problem, b.Size).(*ScoredConfiguration)
+	}
 	// build result parameters
 	var resultParams *ParseResultParameters
-	if b.ReturnModelValue || b.ReturnSequence {
-		resultParams = new(ParseResultParameters)
+	if b.ReturnModelValue || b.ReturnSequence || kBest != nil || agenda != nil {
+		resultParams = &ParseResultParameters{KBest: kBest, Agenda: agenda}
 		if b.ReturnModelValue {
 			resultParams.ModelValue = beamScored.Features
 		}
@@ -470,7 +554,12 @@ func (b *Beam) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perc
 	b.ReturnModelValue = true
 
 	// log.Println("Begin search..")
-	beamResult, goldResult := SearchEarlyUpdate(b, sent, b.Size, goldSequence)
+	var beamResult, goldResult Candidate
+	if b.Violation == "" || b.Violation == EARLY_UPDATE {
+		beamResult, goldResult = SearchEarlyUpdate(b, sent, b.Size, goldSequence)
+	} else {
+		beamResult, goldResult = b.chooseViolation(SearchViolations(b, sent, b.Size, goldSequence), goldSequence)
+	}
 	// log.Println("Search ended")
 
 	beamScored := beamResult.(*ScoredConfiguration)
@@ -559,6 +648,69 @@ func (b *Beam) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perc
 	return &perceptron.Decoded{goldInstance.Instance(), beamScored.C}, parsedFeatures, goldFeatures, b.EarlyUpdateAt, len(goldSequence) - 1, beamScore
 }
 
+// goldScores are the cumulative model scores of a gold sequence by index,
+// pairing each transition with the features of the configuration it was
+// taken from (as the model is updated)
+func (b *Beam) goldScores(goldSequence ScoredConfigurations) []float64 {
+	scorer := b.Model.(*TransitionModel.AvgMatrixSparse)
+	scores := make([]float64, len(goldSequence))
+	for i := 1; i < len(goldSequence); i++ {
+		f := goldSequence[i].Features
+		scores[i] = scores[i-1] + float64(scorer.TransitionScore(f.Transition, f.Previous.Features))
+	}
+	return scores
+}
+
+// violation is the score by which the best beam candidate of a step
+// outscores the gold, and whether the step is a violation: the best
+// candidate is not the gold and scores at least as high. The best
+// candidate's cumulative score is kept by the search
+func (b *Beam) violation(step *ViolationStep, goldScores []float64) (float64, bool) {
+	best, gold := step.Best.(*ScoredConfiguration), step.Gold.(*ScoredConfiguration)
+	if best.Equal(gold) {
+		return 0, false
+	}
+	violation := best.InternalScores.Total() - goldScores[step.GoldIndex]
+	return violation, violation >= 0
+}
+
+// chooseViolation chooses the step of the training search to update at,
+// according to the beam's violation-fixing strategy
+func (b *Beam) chooseViolation(steps []*ViolationStep, goldSequence ScoredConfigurations) (Candidate, Candidate) {
+	if len(steps) == 0 {
+		panic("Got no training search steps")
+	}
+	chosen := steps[len(steps)-1]
+	switch b.Violation {
+	case MAX_VIOLATION:
+		var (
+			maxViolation float64
+			found        bool
+		)
+		goldScores := b.goldScores(goldSequence)
+		for _, step := range steps {
+			if violation, violated := b.violation(step, goldScores); violated && (!found || violation > maxViolation) {
+				chosen, maxViolation, found = step, violation, true
+			}
+		}
+	case LATEST_UPDATE:
+		goldScores := b.goldScores(goldSequence)
+		for i := len(steps) - 1; i >= 0; i-- {
+			if _, violated := b.violation(steps[i], goldScores); violated {
+				chosen = steps[i]
+				break
+			}
+		}
+	case FULL_UPDATE:
+	default:
+		panic("Unknown violation-fixing strategy " + b.Violation)
+	}
+	if !chosen.Goal {
+		b.SetEarlyUpdate(util.Min(chosen.GoldIndex, chosen.Best.Len()-1))
+	}
+	return chosen.Best, chosen.Gold
+}
+
 func (b *Beam) Aligned() bool {
 	return b.Align
 }
@@ -690,17 +842,13 @@ func (scs ScoredConfigurations) Get(i int) Candidate {
 	return scs[i]
 }
 
+// Equal compares the last configuration of the sequence (a util.Equaler is
+// never a Candidate, their Equal methods differ)
 func (scs ScoredConfigurations) Equal(otherEq util.Equaler) bool {
-	switch other := otherEq.(type) {
-	case Candidate:
-		return scs[0].Equal(other)
-	default:
-		// log.Println("Equating", scs[len(scs)-1].C, "and", otherEq)
-		// log.Println(scs[len(scs)-1].C.GetSequence())
-		// log.Println(otherEq.GetSequence())
-		return otherEq.Equal(scs[len(scs)-1].C)
-		panic("Cannot compare to other")
-	}
+	// log.Println("Equating", scs[len(scs)-1].C, "and", otherEq)
+	// log.Println(scs[len(scs)-1].C.GetSequence())
+	// log.Println(otherEq.GetSequence())
+	return otherEq.Equal(scs[len(scs)-1].C)
 }
 
 func (s *ScoredConfiguration) AddScore(newScore int64, assignment uint16) {
@@ -999,6 +1147,8 @@ func CompareConf(confA, confB *ScoredConfiguration, reverse bool) bool {
 type ParseResultParameters struct {
 	ModelValue interface{}
 	Sequence   transition.ConfigurationSequence
+	KBest      []*ScoredConfiguration // distinct candidates of the final agenda, best first, when the beam's KBest is set
+	Agenda     []*ScoredConfiguration // distinct candidates of the final agenda, best first, when the beam's ReturnAgenda is set
 }
 
 func (a *BaseAgenda) Copy(i, j int) {
@@ -3,6 +3,7 @@ package search
 import (
 	"fmt"
 	"log"
+	"math/rand"
 	"sort"
 	"yap/alg/featurevector"
 	"yap/alg/perceptron"
@@ -25,17 +26,33 @@ type Deterministic struct {
 	Base               transition.Configuration
 	NoRecover          bool
 	TransEnum          *util.EnumSet
-	DefaultTransType  byte
+	DefaultTransType   byte
+
+	// train with the transition system's dynamic oracle (Goldberg & Nivre
+	// 2012) instead of early updates against the static gold sequence:
+	// follow the model's predictions, updating towards the best scoring
+	// zero cost transition wherever a prediction is not one. From iteration
+	// ExploreAfter on, a wrong prediction is followed with probability
+	// Explore, otherwise the best zero cost transition is
+	DynamicOracle bool
+	Explore       float64
+	ExploreAfter  int
+	ExploreSeed   int64
+	iteration     int
+	explore       *rand.Rand
 }
 
 var _ perceptron.InstanceDecoder = &Deterministic{}
+var _ perceptron.EarlyUpdateInstanceDecoder = &Deterministic{}
+var _ perceptron.IterationDecoder = &Deterministic{}
 
 // Parser functions
 func (d *Deterministic) Parse(problem Problem) (transition.Configuration, interface{}) {
 	if d.TransFunc == nil {
 		panic("Can't parse without a transition system")
 	}
-	transitionClassifier := &TransitionClassifier{Model: d.Model, TransFunc: d.TransFunc, FeatExtractor: d.FeatExtractor}
+	problem, constraints := SplitConstraints(problem)
+	transitionClassifier := &TransitionClassifier{Model: d.Model, TransFunc: d.constrained(constraints), FeatExtractor: d.FeatExtractor}
 	transitionClassifier.Init()
 	transitionClassifier.ShowConsiderations = d.ShowConsiderations
 
@@ -117,10 +134,17 @@ func (d *Deterministic) ParseOracle(gold perceptron.DecodedInstance) (configurat
 	return
 }
 
-func (d *Deterministic) ParseOracleEarlyUpdate(sent nlp.Sentence, gold transition.ConfigurationSequence, constraints interface{}, model dependency.ParameterModel) (transition.Configuration, transition.Configuration, interface{}, interface{}, int) {
-	if constraints != nil {
-		panic("Got non-nil constraints; deterministic dependency parsing does not consider constraints")
+// constrained returns the transition system restricted by constraints, if any
+func (d *Deterministic) constrained(constraints transition.Constraints) transition.TransitionSystem {
+	if constraints == nil {
+		return d.TransFunc
 	}
+	return &transition.ConstrainedSystem{TransitionSystem: d.TransFunc, Constraints: constraints}
+}
+
+// ParseOracleEarlyUpdate parses until the first prediction off the gold
+// sequence, predicting only transitions the constraints allow, if any
+func (d *Deterministic) ParseOracleEarlyUpdate(sent nlp.Sentence, gold transition.ConfigurationSequence, constraints dependency.ConstraintModel, model dependency.ParameterModel) (transition.Configuration, transition.Configuration, interface{}, interface{}, int) {
 	if d.TransFunc == nil {
 		panic("Can't parse without a transition system")
 	}
@@ -130,7 +154,7 @@ func (d *Deterministic) ParseOracleEarlyUpdate(sent nlp.Sentence, gold transitio
 	c.Clear()
 	c.Init(sent)
 
-	classifier := TransitionClassifier{Model: model.(dependency.TransitionParameterModel), FeatExtractor: d.FeatExtractor, TransFunc: d.TransFunc}
+	classifier := TransitionClassifier{Model: model.(dependency.TransitionParameterModel), FeatExtractor: d.FeatExtractor, TransFunc: d.constrained(constraints)}
 	classifier.ShowConsiderations = d.ShowConsiderations
 
 	classifier.Init()
@@ -190,39 +214,7 @@ func (d *Deterministic) DecodeGold(goldInstance perceptron.DecodedInstance, m pe
 	// }
 	// 	_, goldParams := deterministic.ParseOracle(graph, nil, tempModel)
 	if goldParams != nil {
-		seq := goldParams.(*ParseResultParameters).Sequence
-
-		goldSequence := make(ScoredConfigurations, len(seq))
-		var (
-			lastFeatures *transition.FeaturesList
-			curFeats     []featurevector.Feature
-		)
-		for i := len(seq) - 1; i >= 0; i-- {
-			val := seq[i]
-			// log.Println("Gold seq val", i, val)
-			// log.Println("Pre extract")
-			nextTransition := make([]int, 0, 1)
-			nextTransitionType := d.DefaultTransType // default to MD
-			if i > 0 {
-				// if i < len(seq)-1 {
-				// log.Println("Configuration for transition is:", seq[i-1])
-				// log.Println("Configuration Type for transition is:", seq[i-1].GetLastTransition().Type())
-				// log.Println("Configuration is:", val)
-				nextTransition = append(nextTransition, int(seq[i-1].GetLastTransition().Value()))
-				nextTransitionType = seq[i-1].GetLastTransition().Type()
-			}
-			// nextTransitionType = seq[i].State()
-			// nextTransition = append(nextTransition, int(val.GetLastTransition()))
-			// d.FeatExtractor.SetLog(true)
-			// log.Println("Features")
-			curFeats = d.FeatExtractor.Features(val, false, nextTransitionType, nextTransition)
-			// d.FeatExtractor.SetLog(false)
-			// log.Println("Features")
-			// log.Println(curFeats)
-			// log.Println("Post extract")
-			lastFeatures = &transition.FeaturesList{curFeats, val.GetLastTransition(), lastFeatures}
-			goldSequence[len(seq)-i-1] = &ScoredConfiguration{val, val.GetLastTransition(), NewScoreState(), lastFeatures, 0, 0, true, false}
-		}
+		goldSequence := GoldSequence(goldParams.(*ParseResultParameters).Sequence, d.FeatExtractor, d.DefaultTransType)
 
 		// log.Println("Gold seq:\n", seq)
 		decoded := &perceptron.Decoded{goldInstance.Instance(), goldSequence}
@@ -232,7 +224,34 @@ func (d *Deterministic) DecodeGold(goldInstance perceptron.DecodedInstance, m pe
 	}
 }
 
-func (d *Deterministic) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}, interface{}, int, int, int64) {
+// GoldSequence scores the configurations of a sequence (as returned by
+// GetSequence) as a gold sequence for training, with the features of each
+// configuration for its next transition
+func GoldSequence(seq transition.ConfigurationSequence, extractor perceptron.FeatureExtractor, defaultTransType byte) ScoredConfigurations {
+	goldSequence := make(ScoredConfigurations, len(seq))
+	var (
+		lastFeatures *transition.FeaturesList
+		curFeats     []featurevector.Feature
+	)
+	for i := len(seq) - 1; i >= 0; i-- {
+		val := seq[i]
+		nextTransition := make([]int, 0, 1)
+		nextTransitionType := defaultTransType
+		if i > 0 {
+			nextTransition = append(nextTransition, int(seq[i-1].GetLastTransition().Value()))
+			nextTransitionType = seq[i-1].GetLastTransition().Type()
+		}
+		curFeats = extractor.Features(val, false, nextTransitionType, nextTransition)
+		lastFeatures = &transition.FeaturesList{curFeats, val.GetLastTransition(), lastFeatures}
+		goldSequence[len(seq)-i-1] = &ScoredConfiguration{val, val.GetLastTransition(), NewScoreState(), lastFeatures, 0, 0, true, false}
+	}
+	return goldSequence
+}
+
+func (d *Deterministic) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}, interface{}, int, int, float64) {
+	if d.DynamicOracle {
+		return d.decodeDynamic(goldInstance, m)
+	}
 	sent := goldInstance.Instance().(nlp.Sentence)
 
 	// abstract casting >:-[
@@ -253,6 +272,115 @@ func (d *Deterministic) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstanc
 	return &perceptron.Decoded{goldInstance.Instance(), parsedConf}, parsedWeights, goldWeights, earlyUpdatedAt, len(rawGoldSequence), 0
 }
 
+// SetIteration seeds the exploration of dynamic oracle training with the
+// i'th seed drawn from ExploreSeed, so that it does not depend on where
+// training was resumed, and the explorations of consecutive iterations are
+// not correlated
+func (d *Deterministic) SetIteration(i int) {
+	d.iteration = i
+	seeds := rand.New(rand.NewSource(d.ExploreSeed))
+	seed := seeds.Int63()
+	for j := 0; j < i; j++ {
+		seed = seeds.Int63()
+	}
+	d.explore = rand.New(rand.NewSource(seed))
+}
+
+// featuresChain links the features of a sequence of configurations with
+// the transitions taken from them, as the model's updates expect: each
+// link's transition goes with the features of the link preceding it
+func featuresChain(features [][]featurevector.Feature, transitions []transition.Transition) *transition.FeaturesList {
+	chain := &transition.FeaturesList{features[0], transition.ConstTransition(0), nil}
+	for i, t := range transitions {
+		var next []featurevector.Feature
+		if i+1 < len(features) {
+			next = features[i+1]
+		}
+		chain = &transition.FeaturesList{next, t, chain}
+	}
+	return chain
+}
+
+func (d *Deterministic) decodeDynamic(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}, interface{}, int, int, float64) {
+	if d.TransFunc == nil {
+		panic("Can't parse without a transition system")
+	}
+	oracle, ok := d.TransFunc.Oracle().(transition.DynamicOracle)
+	if !ok {
+		panic(fmt.Sprintf("Transition system %s has no dynamic oracle", d.TransFunc.Name()))
+	}
+	if d.explore == nil {
+		d.SetIteration(d.iteration)
+	}
+	if goldInstance == nil {
+		// the static oracle failed on the instance
+		return nil, nil, nil, -1, 0, 0
+	}
+	goldSequence := goldInstance.Decoded().(ScoredConfigurations)
+	goldConf := goldSequence[len(goldSequence)-1].C
+	oracle.SetGold(goldConf)
+	model := m.(TransitionModel.Interface)
+
+	c := d.Base.Copy()
+	c.Clear()
+	c.Init(goldInstance.Instance())
+	var (
+		features                         [][]featurevector.Feature
+		predTransitions, goldTransitions []transition.Transition
+		failedConf                       transition.Configuration
+		failedAt                         int = -1
+	)
+	for i := 0; !c.Terminal(); i++ {
+		tType, transitions := d.TransFunc.GetTransitions(c)
+		if len(transitions) == 0 {
+			break
+		}
+		feats := d.FeatExtractor.Features(c, false, tType, nil)
+		costs := make([]int, len(transitions))
+		minCost := -1
+		for j, t := range transitions {
+			costs[j] = oracle.Cost(c, &transition.TypedTransition{tType, t})
+			if minCost < 0 || costs[j] < minCost {
+				minCost = costs[j]
+			}
+		}
+		var (
+			pred, best           int = -1, -1
+			predScore, bestScore int64
+		)
+		for j, t := range transitions {
+			score := model.TransitionScore(transition.ConstTransition(t), feats)
+			if pred < 0 || score > predScore {
+				pred, predScore = j, score
+			}
+			if costs[j] == minCost && (best < 0 || score > bestScore) {
+				best, bestScore = j, score
+			}
+		}
+		predTrans := &transition.TypedTransition{tType, transitions[pred]}
+		next := predTrans
+		if costs[pred] > minCost {
+			bestTrans := &transition.TypedTransition{tType, transitions[best]}
+			features = append(features, feats)
+			predTransitions = append(predTransitions, predTrans)
+			goldTransitions = append(goldTransitions, bestTrans)
+			if failedAt < 0 {
+				failedAt = i
+				failedConf = d.TransFunc.Transition(c, predTrans)
+			}
+			if d.iteration < d.ExploreAfter || d.explore.Float64() >= d.Explore {
+				next = bestTrans
+			}
+		}
+		c = d.TransFunc.Transition(c, next)
+	}
+	if failedAt < 0 {
+		return &perceptron.Decoded{goldInstance.Instance(), goldConf}, nil, nil, -1, len(goldSequence), 0
+	}
+	// the configuration of the first wrong prediction never equals the gold
+	return &perceptron.Decoded{goldInstance.Instance(), failedConf}, featuresChain(features, predTransitions), featuresChain(features, goldTransitions), failedAt, len(goldSequence), 0
+}
+
 type TransitionClassifier struct {
 	Model              dependency.TransitionParameterModel
 	TransFunc          transition.TransitionSystem
@@ -23,6 +23,22 @@ type Agenda interface {
 
 type Problem interface{}
 
+// A ConstrainedProblem is searched with only the transitions its
+// constraints allow
+type ConstrainedProblem struct {
+	Problem
+	Constraints transition.Constraints
+}
+
+// SplitConstraints returns a problem without its constraints, and its
+// constraints, if it is constrained
+func SplitConstraints(p Problem) (Problem, transition.Constraints) {
+	if constrained, ok := p.(*ConstrainedProblem); ok {
+		return constrained.Problem, constrained.Constraints
+	}
+	return p, nil
+}
+
 type Candidate interface {
 	Copy() Candidate
 	Equal(Candidate) bool
@@ -56,22 +72,55 @@ type Interface interface {
 	Aligned() bool
 }
 
+// A KBest search returns the best K terminal candidates of its final agenda,
+// best first
+type KBest interface {
+	TopK(a Agenda, K int) []Candidate
+}
+
 type IdleFunc func(c Candidate, candidateNum int) Candidate
 
 type Idle interface {
 	Idle(c Candidate, candidateNum int) Candidate
 }
 
+// A ViolationStep is the best beam candidate and the gold candidate at one
+// step of training search, from which violation-fixing strategies choose
+// the step to update at. The last step of a search reaching the goal
+// holds the best terminal candidate
+type ViolationStep struct {
+	Best, Gold Candidate
+	GoldInBeam bool
+	GoldIndex  int
+	Goal       bool
+}
+
 func Search(b Interface, problem Problem, B int) Candidate {
-	candidate, _ := search(b, problem, B, 1, false, nil)
+	candidate, _ := search(b, problem, B, 1, false, nil, nil, nil)
 	return candidate
 }
 
+// SearchKBest searches like Search, returning the best K terminal
+// candidates, best first
+func SearchKBest(b Interface, problem Problem, B, K int) []Candidate {
+	var top []Candidate
+	search(b, problem, B, K, false, nil, nil, &top)
+	return top
+}
+
 func SearchEarlyUpdate(b Interface, problem Problem, B int, goldSequence Candidates) (Candidate, Candidate) {
-	return search(b, problem, B, 1, true, goldSequence)
+	return search(b, problem, B, 1, true, goldSequence, nil, nil)
+}
+
+// SearchViolations searches like SearchEarlyUpdate, but continues after the
+// gold falls off the beam, returning every step until the gold sequence ends
+func SearchViolations(b Interface, problem Problem, B int, goldSequence Candidates) []*ViolationStep {
+	var steps []*ViolationStep
+	search(b, problem, B, 1, true, goldSequence, &steps, nil)
+	return steps
 }
 
-func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSequence Candidates) (Candidate, Candidate) {
+func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSequence Candidates, steps *[]*ViolationStep, top *[]Candidate) (Candidate, Candidate) {
 	var (
 		goldValue Candidate
 		best      Candidate
@@ -251,7 +300,13 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 
 		// early update
 		if earlyUpdate {
-			if !goldExists || goldIndex+1 >= (goldSequence.Len()+idleGoldTransitions) {
+			if steps != nil {
+				if bestBeamCandidate == nil {
+					panic("Best Beam Candidate is nil")
+				}
+				*steps = append(*steps, &ViolationStep{Best: bestBeamCandidate.Copy(), Gold: goldValue, GoldInBeam: goldExists, GoldIndex: goldIndex})
+			}
+			if (!goldExists && steps == nil) || goldIndex+1 >= (goldSequence.Len()+idleGoldTransitions) {
 				if AllOut {
 					log.Println("EARLY UPDATE")
 				}
@@ -268,7 +323,10 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 						log.Println("\tMin Alignment:", minAgendaAlignment)
 						log.Println("\tNext Gold Alignment:", goldSequence.Get(goldIndex).(Aligned).Alignment())
 					}
-					if goldSequence.Get(goldIndex).(Aligned).Alignment() == minAgendaAlignment {
+					// a gold that fell off the beam (searching for violations)
+					// may fall behind the agenda, and advances until it catches up
+					goldAlignment := goldSequence.Get(goldIndex).(Aligned).Alignment()
+					if goldAlignment == minAgendaAlignment || (!goldExists && goldAlignment < minAgendaAlignment) {
 						goldIndex++
 						nextValue := goldSequence.Get(goldIndex)
 						nextValue.(*ScoredConfiguration).C.SetPrevious(goldValue.(*ScoredConfiguration).C)
@@ -308,6 +366,9 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 					log.Println("Returning:", goldValue.(*ScoredConfiguration).C.GetSequence())
 				}
 			}
+			if earlyUpdate && steps != nil {
+				*steps = append(*steps, &ViolationStep{Best: best.Copy(), Gold: goldValue, GoldInBeam: best.Equal(goldValue), GoldIndex: goldIndex, Goal: true})
+			}
 
 			// return best
 			break
@@ -322,6 +383,13 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 	}
 	if !earlyUpdate {
 		best = b.Best(agenda)
+		if top != nil {
+			kBest, ok := b.(KBest)
+			if !ok {
+				panic("Can't return the K best candidates when search does not support it")
+			}
+			*top = kBest.TopK(agenda, topK)
+		}
 	}
 	best = best.Copy()
 	agenda = b.Clear(agenda)
@@ -0,0 +1,311 @@
+package search
+
+import (
+	"fmt"
+	"yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+	TransitionModel "yap/alg/transition/model"
+	"yap/util"
+
+	"testing"
+)
+
+// a toy aligned problem: each token of the input is analyzed as one or
+// two morphemes (MORPH) before it is popped (POP); a configuration is
+// aligned with the number of tokens it popped
+const (
+	MORPH = iota
+	POP
+)
+
+type morphConfig struct {
+	tokens, popped, morphs int
+	last                   transition.Transition
+	previous               *morphConfig
+}
+
+var _ transition.Configuration = &morphConfig{}
+
+func (c *morphConfig) Init(p interface{}) {
+	if instance, isInstance := p.(morphInstance); isInstance {
+		p = int(instance)
+	}
+	c.tokens = p.(int)
+	c.last = transition.ConstTransition(0)
+}
+
+func (c *morphConfig) Terminal() bool {
+	return c.popped == c.tokens
+}
+
+func (c *morphConfig) Copy() transition.Configuration {
+	newConf := *c
+	return &newConf
+}
+
+func (c *morphConfig) CopyTo(other transition.Configuration) {
+	*other.(*morphConfig) = *c
+}
+
+func (c *morphConfig) Clear() {
+	*c = morphConfig{}
+}
+
+func (c *morphConfig) Len() int {
+	if c.previous == nil {
+		return 1
+	}
+	return c.previous.Len() + 1
+}
+
+func (c *morphConfig) Previous() transition.Configuration {
+	if c.previous == nil {
+		return nil
+	}
+	return c.previous
+}
+
+func (c *morphConfig) SetPrevious(previous transition.Configuration) {
+	if previous == nil {
+		c.previous = nil
+	} else {
+		c.previous = previous.(*morphConfig)
+	}
+}
+
+func (c *morphConfig) GetSequence() transition.ConfigurationSequence {
+	var seq transition.ConfigurationSequence
+	for cur := c; cur != nil; cur = cur.previous {
+		seq = append(seq, cur)
+	}
+	return seq
+}
+
+func (c *morphConfig) SetLastTransition(t transition.Transition) {
+	c.last = t
+}
+
+func (c *morphConfig) GetLastTransition() transition.Transition {
+	return c.last
+}
+
+func (c *morphConfig) String() string {
+	if c.previous == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v%d", c.previous, c.last.Value())
+}
+
+func (c *morphConfig) Equal(otherEq util.Equaler) bool {
+	other, ok := otherEq.(*morphConfig)
+	return ok && c.String() == other.String()
+}
+
+func (c *morphConfig) Address(location []byte, offset int) (int, bool, bool) {
+	return 0, false, false
+}
+
+func (c *morphConfig) GenerateAddresses(nodeID int, location []byte) []int {
+	return nil
+}
+
+func (c *morphConfig) Attribute(source byte, nodeID int, attribute []byte, transitions []int) (interface{}, bool, bool) {
+	return nil, false, false
+}
+
+func (c *morphConfig) Assignment() uint16 {
+	return 0
+}
+
+func (c *morphConfig) State() byte {
+	return 'M'
+}
+
+func (c *morphConfig) Alignment() int {
+	return c.popped
+}
+
+type morphSystem struct{}
+
+var _ transition.TransitionSystem = &morphSystem{}
+
+func (s *morphSystem) Transition(from transition.Configuration, t transition.Transition) transition.Configuration {
+	c := from.Copy().(*morphConfig)
+	c.previous, c.last = from.(*morphConfig), t
+	if t.Value() == MORPH {
+		c.morphs++
+	} else {
+		c.popped, c.morphs = c.popped+1, 0
+	}
+	return c
+}
+
+func (s *morphSystem) TransitionTypes() []string {
+	return []string{"M"}
+}
+
+func (s *morphSystem) GetTransitions(from transition.Configuration) (byte, []int) {
+	c := from.(*morphConfig)
+	var transitions []int
+	if c.Terminal() {
+		return 'M', transitions
+	}
+	if c.morphs < 2 {
+		transitions = append(transitions, MORPH)
+	}
+	if c.morphs > 0 {
+		transitions = append(transitions, POP)
+	}
+	return 'M', transitions
+}
+
+func (s *morphSystem) YieldTransitions(from transition.Configuration) (byte, chan int) {
+	transType, transitions := s.GetTransitions(from)
+	yield := make(chan int, len(transitions))
+	for _, t := range transitions {
+		yield <- t
+	}
+	close(yield)
+	return transType, yield
+}
+
+func (s *morphSystem) Oracle() transition.Oracle {
+	return nil
+}
+
+func (s *morphSystem) AddDefaultOracle() {
+}
+
+func (s *morphSystem) Name() string {
+	return "Toy Morphemes"
+}
+
+// morphExtractor extracts a single constant feature, so that the score of
+// a transition is its weight
+type morphExtractor struct{}
+
+func (e *morphExtractor) Features(instance perceptron.Instance, flag bool, transType byte, transitions []int) []featurevector.Feature {
+	return []featurevector.Feature{"f"}
+}
+
+func (e *morphExtractor) EstimatedNumberOfFeatures() int {
+	return 1
+}
+
+func (e *morphExtractor) SetLog(bool) {
+}
+
+// morphBeam is an aligned beam of size 1 over the toy problem, preferring
+// to pop every token after its first morpheme
+func morphBeam() *Beam {
+	model := TransitionModel.NewAvgMatrixSparse(1, nil, false)
+	for t, weight := range []int64{MORPH: 1, POP: 10} {
+		features := &transition.FeaturesList{
+			Transition: &transition.TypedTransition{T: 'M', V: t},
+			Previous:   &transition.FeaturesList{Features: []featurevector.Feature{"f"}},
+		}
+		model.AddSubtract(features, features, weight)
+	}
+	return &Beam{
+		Base:             &morphConfig{},
+		TransFunc:        &morphSystem{},
+		FeatExtractor:    &morphExtractor{},
+		Model:            model,
+		Size:             1,
+		Align:            true,
+		ReturnModelValue: true,
+	}
+}
+
+// morphGold is the gold sequence analyzing every token as two morphemes
+func morphGold(b *Beam, tokens int) ScoredConfigurations {
+	var c transition.Configuration = b.Base.Copy()
+	c.Init(tokens)
+	for i := 0; i < tokens; i++ {
+		for _, t := range []int{MORPH, MORPH, POP} {
+			c = b.TransFunc.Transition(c, &transition.TypedTransition{T: 'M', V: t})
+		}
+	}
+	return GoldSequence(c.GetSequence(), b.FeatExtractor, 'M')
+}
+
+func TestSearchViolationsAligned(t *testing.T) {
+	defer func(allOut bool) { AllOut = allOut }(AllOut)
+	AllOut = false
+	const tokens = 2
+	b := morphBeam()
+	gold := morphGold(b, tokens)
+	steps := SearchViolations(b, tokens, b.Size, gold)
+
+	// the gold falls off the beam at the third step, when the beam pops
+	// the first token after one morpheme (MORPH POP) but the gold
+	// analyzes another (MORPH MORPH)
+	for i, step := range steps {
+		if inBeam := i < 2; step.GoldInBeam != inBeam {
+			t.Errorf("Step %d gold in beam %v, expected %v", i, step.GoldInBeam, inBeam)
+		}
+	}
+	// the gold, behind the beam, keeps advancing until it catches up
+	for i := 1; i < len(steps); i++ {
+		if steps[i].GoldIndex <= steps[i-1].GoldIndex {
+			t.Errorf("Gold stalled at index %d in steps %d and %d", steps[i].GoldIndex, i-1, i)
+		}
+	}
+	// the beam reaches the goal popping both tokens (MORPH POP MORPH POP)
+	if last := steps[len(steps)-1]; len(steps) != 5 || !last.Goal || last.GoldIndex != 4 {
+		t.Errorf("Expected 5 steps, the last at the goal with gold index 4, got %d steps ending at %+v", len(steps), last)
+	}
+
+	// violations by cumulative scores are those of rescoring the sequences
+	scorer := b.Model.(*TransitionModel.AvgMatrixSparse)
+	rescore := func(features *transition.FeaturesList) float64 {
+		var score int64
+		for f := features; f != nil && f.Previous != nil; f = f.Previous {
+			score += scorer.TransitionScore(f.Transition, f.Previous.Features)
+		}
+		return float64(score)
+	}
+	goldScores := b.goldScores(gold)
+	for i, step := range steps {
+		best, stepGold := step.Best.(*ScoredConfiguration), step.Gold.(*ScoredConfiguration)
+		violation, violated := b.violation(step, goldScores)
+		expected := rescore(&transition.FeaturesList{Transition: best.C.GetLastTransition(), Previous: best.Features}) - rescore(stepGold.Features)
+		if best.Equal(stepGold) {
+			expected = 0
+		}
+		if violation != expected || violated != (!best.Equal(stepGold) && expected >= 0) {
+			t.Errorf("Step %d violation %v (%v), expected %v", i, violation, violated, expected)
+		}
+	}
+}
+
+func TestBeamTopK(t *testing.T) {
+	b := morphBeam()
+	var root transition.Configuration = b.Base.Copy()
+	root.Init(2)
+	morph := b.TransFunc.Transition(root, &transition.TypedTransition{T: 'M', V: MORPH})
+	candidate := func(t int, score int64) *ScoredConfiguration {
+		return &ScoredConfiguration{
+			C:              morph,
+			Transition:     &transition.TypedTransition{T: 'M', V: t},
+			InternalScores: ScoreState{{Total: score}},
+		}
+	}
+	// a heap ordered agenda, with the second best candidate twice
+	agenda := &BaseAgenda{Confs: []*ScoredConfiguration{candidate(POP, 5), candidate(POP, 5), candidate(MORPH, 7)}}
+	for _, K := range []int{2, 3} {
+		top := b.TopK(agenda, K)
+		if len(top) != 2 {
+			t.Fatalf("Got %d of the top %d candidates, expected the 2 distinct candidates", len(top), K)
+		}
+		for i, expected := range []string{"00", "01"} {
+			if scored := top[i].(*ScoredConfiguration); scored.C.String() != expected || !scored.Expanded {
+				t.Errorf("Got top %d candidate %v, expected %v", i, scored.C, expected)
+			}
+		}
+	}
+	if top := b.TopK(agenda, 1); len(top) != 1 || top[0].Score() != 7 {
+		t.Errorf("Got top candidates %v, expected the best", top)
+	}
+}
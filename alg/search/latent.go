@@ -0,0 +1,46 @@
+package search
+
+import (
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+	TransitionModel "yap/alg/transition/model"
+	"yap/util"
+)
+
+// PartialGold is the decoded value of a partially annotated training
+// instance: the constraints of its annotation
+type PartialGold struct {
+	Constraints transition.Constraints
+}
+
+func (p *PartialGold) Equal(otherEq util.Equaler) bool {
+	other, ok := otherEq.(*PartialGold)
+	return ok && p == other
+}
+
+// LatentGold decodes the gold sequences of partially annotated training
+// instances, whose decoded value is a PartialGold. The unannotated
+// decisions of such an instance are latent: its gold sequence is the best
+// sequence under the current model of those its constraints allow, found by
+// constrained beam search. Other instances are decoded by the oracle of Gold
+type LatentGold struct {
+	Beam *Beam
+	Gold *Deterministic
+}
+
+var _ perceptron.InstanceDecoder = &LatentGold{}
+
+func (l *LatentGold) Decode(instance perceptron.Instance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
+	return l.Gold.Decode(instance, m)
+}
+
+func (l *LatentGold) DecodeGold(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
+	partial, isPartial := goldInstance.Decoded().(*PartialGold)
+	if !isPartial {
+		return l.Gold.DecodeGold(goldInstance, m)
+	}
+	l.Beam.Model = m.(TransitionModel.Interface)
+	best := Search(l.Beam, &ConstrainedProblem{Problem: goldInstance.Instance(), Constraints: partial.Constraints}, l.Beam.Size).(*ScoredConfiguration)
+	goldSequence := GoldSequence(best.C.GetSequence(), l.Gold.FeatExtractor, l.Gold.DefaultTransType)
+	return &perceptron.Decoded{goldInstance.Instance(), goldSequence}, nil
+}
@@ -0,0 +1,78 @@
+package search
+
+import (
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+	"yap/util"
+
+	"testing"
+)
+
+// a morphInstance is a training instance of the toy problem, of its number
+// of tokens
+type morphInstance int
+
+func (i morphInstance) Equal(other util.Equaler) bool {
+	return other == i
+}
+
+// morphAnnotation constrains the number of morphemes of the annotated
+// tokens, by index; other tokens are unannotated
+type morphAnnotation map[int]int
+
+func (a morphAnnotation) Allowed(conf transition.Configuration, transType byte, transitions []int) []int {
+	c := conf.(*morphConfig)
+	morphs, annotated := a[c.popped]
+	if !annotated {
+		return transitions
+	}
+	allowed := make([]int, 0, 1)
+	for _, t := range transitions {
+		if (t == POP) == (c.morphs == morphs) {
+			allowed = append(allowed, t)
+		}
+	}
+	return allowed
+}
+
+func TestLatentGoldDecodeGold(t *testing.T) {
+	defer func(allOut bool) { AllOut = allOut }(AllOut)
+	AllOut = false
+	b := morphBeam()
+	latent := &LatentGold{
+		Beam: b,
+		Gold: &Deterministic{FeatExtractor: &morphExtractor{}, DefaultTransType: 'M'},
+	}
+	tests := []struct {
+		name       string
+		annotation morphAnnotation
+		expected   string
+	}{
+		// the model prefers a single morpheme per token
+		{"unannotated", morphAnnotation{}, "0101"},
+		{"first annotated", morphAnnotation{0: 2}, "00101"},
+		{"second annotated", morphAnnotation{1: 2}, "01001"},
+		{"fully annotated", morphAnnotation{0: 2, 1: 1}, "00101"},
+	}
+	for _, test := range tests {
+		goldInstance := &perceptron.Decoded{morphInstance(2), &PartialGold{Constraints: test.annotation}}
+		decoded, _ := latent.DecodeGold(goldInstance, b.Model)
+		if decoded.Instance() != goldInstance.Instance() {
+			t.Errorf("%s: decoded instance %v, expected %v", test.name, decoded.Instance(), goldInstance.Instance())
+		}
+		gold := decoded.Decoded().(ScoredConfigurations)
+		if len(gold) != len(test.expected)+1 {
+			t.Fatalf("%s: got a gold sequence of %d configurations, expected %d", test.name, len(gold), len(test.expected)+1)
+		}
+		// the sequence starts at the initial configuration, and every
+		// configuration has the features of its transition
+		for i, scored := range gold {
+			if conf := scored.C.String(); conf != test.expected[:i] {
+				t.Errorf("%s: got configuration %q at %d of the gold sequence, expected %q", test.name, conf, i, test.expected[:i])
+			}
+			if scored.Features == nil {
+				t.Errorf("%s: configuration %d of the gold sequence has no features", test.name, i)
+			}
+		}
+	}
+}
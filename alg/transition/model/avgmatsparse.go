@@ -46,6 +46,8 @@ type AvgMatrixSparseSerialized struct {
 	Generation int
 	Features   []string
 	Mat        []interface{}
+	Updates    []map[interface{}]int                  // per feature update counts, nil if not tracked
+	History    []map[interface{}]map[int]HistoryState // averaging state, for resuming training
 }
 
 var _ perceptron.Model = &AvgMatrixSparse{}
@@ -187,9 +189,11 @@ func (t *AvgMatrixSparse) apply(features interface{}, amount int64) perceptron.M
 	return t
 }
 
+// ScalarDivide divides the weights, keeping their averaging totals, so
+// that models summed with AddModel are mixed (averaged)
 func (t *AvgMatrixSparse) ScalarDivide(val int64) {
 	for _, avgsparse := range t.Mat {
-		avgsparse.UpdateScalarDivide(val)
+		avgsparse.ScalarDivideValues(val, t.Generation)
 	}
 }
 
@@ -204,8 +208,30 @@ func (t *AvgMatrixSparse) IncrementGeneration() {
 }
 
 func (t *AvgMatrixSparse) Copy() perceptron.Model {
-	panic("Cannot copy an avg matrix sparse representation")
-	// return nil
+	retval := &AvgMatrixSparse{
+		Mat:        make([]*AvgSparse, len(t.Mat)),
+		Features:   t.Features,
+		Generation: t.Generation,
+		Formatters: t.Formatters,
+		Log:        t.Log,
+		Extractor:  t.Extractor,
+	}
+	for i, val := range t.Mat {
+		retval.Mat[i] = val.Copy()
+	}
+	return retval
+}
+
+// ShardCopy returns a copy whose averaging restarts at generation 0, so
+// that adding it to the model it was copied from with AddModel adds only
+// the generations and averaging totals of its own training
+func (t *AvgMatrixSparse) ShardCopy() perceptron.Model {
+	retval := t.Copy().(*AvgMatrixSparse)
+	retval.Generation = 0
+	for _, val := range retval.Mat {
+		val.RestartHistory()
+	}
+	return retval
 }
 
 func (t *AvgMatrixSparse) New() perceptron.Model {
@@ -216,8 +242,21 @@ func (t *AvgMatrixSparse) Make(dense bool) perceptron.Model {
 	return NewAvgMatrixSparse(t.Features, nil, dense)
 }
 
+// AddModel adds the weights and averaging totals of another
+// AvgMatrixSparse, summing the generations
 func (t *AvgMatrixSparse) AddModel(m perceptron.Model) {
-	panic("Cannot add two avg matrix sparse types")
+	other, ok := m.(*AvgMatrixSparse)
+	if !ok {
+		panic(fmt.Sprintf("Cannot add model of type %T to an avg matrix sparse", m))
+	}
+	if len(other.Mat) != len(t.Mat) {
+		panic("Cannot add avg matrix sparse models with different features")
+	}
+	generation := t.Generation + other.Generation
+	for i, val := range t.Mat {
+		val.AddHistory(other.Mat[i], t.Generation, other.Generation, generation)
+	}
+	t.Generation = generation
 }
 
 func (t *AvgMatrixSparse) TransitionScore(transition transition.Transition, features []Feature) int64 {
@@ -303,6 +342,90 @@ func (t *AvgMatrixSparse) Deserialize(data *AvgMatrixSparseSerialized) {
 	}
 }
 
+// SerializeHistory adds the averaging state of the model to its
+// serialization, making it a checkpoint training can be resumed from
+func (t *AvgMatrixSparse) SerializeHistory(data *AvgMatrixSparseSerialized) {
+	data.History = make([]map[interface{}]map[int]HistoryState, len(t.Mat))
+	for i, val := range t.Mat {
+		data.History[i] = val.SerializeHistory()
+	}
+}
+
+// DeserializeHistory restores the averaging state, generation and update
+// counts of a checkpoint into a (new) model in training. Returns false if
+// the serialized model is not a checkpoint
+func (t *AvgMatrixSparse) DeserializeHistory(data *AvgMatrixSparseSerialized) bool {
+	if data.History == nil {
+		return false
+	}
+	if len(data.History) != len(t.Mat) {
+		panic(fmt.Sprintf("Checkpoint has %d feature templates, model has %d", len(data.History), len(t.Mat)))
+	}
+	t.Generation = data.Generation
+	for i, history := range data.History {
+		t.Mat[i].DeserializeHistory(history)
+	}
+	t.DeserializeUpdates(data)
+	return true
+}
+
+// CountUpdates starts counting the updates per feature, which are needed
+// only for pruning rarely updated features
+func (t *AvgMatrixSparse) CountUpdates() {
+	for _, val := range t.Mat {
+		val.CountUpdates()
+	}
+}
+
+// SerializeUpdates adds the per feature update counts, if counted, to
+// the serialization of a model in training
+func (t *AvgMatrixSparse) SerializeUpdates(data *AvgMatrixSparseSerialized) {
+	for i, val := range t.Mat {
+		if updates := val.SerializeUpdates(); updates != nil {
+			if data.Updates == nil {
+				data.Updates = make([]map[interface{}]int, len(t.Mat))
+			}
+			data.Updates[i] = updates
+		}
+	}
+}
+
+// DeserializeUpdates restores the per feature update counts, which are not
+// needed for parsing and so are not restored by Deserialize. Returns false
+// if the serialized model has no update counts
+func (t *AvgMatrixSparse) DeserializeUpdates(data *AvgMatrixSparseSerialized) bool {
+	if data.Updates == nil {
+		return false
+	}
+	for i, updates := range data.Updates {
+		if i < len(t.Mat) && updates != nil {
+			t.Mat[i].DeserializeUpdates(updates)
+		}
+	}
+	return true
+}
+
+// Prune removes feature values with a weight magnitude below minWeight and
+// features updated fewer than minUpdates times
+func (t *AvgMatrixSparse) Prune(minWeight int64, minUpdates int) (removedValues, removedFeatures int) {
+	for _, val := range t.Mat {
+		values, features := val.Prune(minWeight, minUpdates)
+		removedValues += values
+		removedFeatures += features
+	}
+	return
+}
+
+// Size returns the number of features and feature values in the model
+func (t *AvgMatrixSparse) Size() (features, values int) {
+	for _, val := range t.Mat {
+		matFeatures, matValues := val.Size()
+		features += matFeatures
+		values += matValues
+	}
+	return
+}
+
 // func (t *AvgMatrixSparse) Write(writer io.Writer) {
 // 	// marshalled, _ := json.Marshal(t.Serialize(), "", " ")
 // 	// writer.Write(marshalled)
@@ -343,14 +466,14 @@ type AveragedModelStrategy struct {
 }
 
 func (u *AveragedModelStrategy) Init(m perceptron.Model, iterations int) {
-	// explicitly reset u.N = 0.0 in case of reuse of vector
-	// even though 0.0 is zero value
-	u.N = 0
 	u.P = iterations
 	avgModel, ok := m.(*AvgMatrixSparse)
 	if !ok {
 		panic("AveragedModelStrategy requires AvgMatrixSparse model")
 	}
+	// explicitly reset u.N in case of reuse of vector, to the model's
+	// generation which is non-zero when resuming training
+	u.N = avgModel.Generation
 	u.accumModel = avgModel
 }
 
@@ -359,8 +482,9 @@ func (u *AveragedModelStrategy) Update(m perceptron.Model) {
 	u.N += 1
 }
 
+// Finalize averages the model at the generation it counts, which includes
+// the generations of shards mixed into it without Update
 func (u *AveragedModelStrategy) Finalize(m perceptron.Model) perceptron.Model {
-	u.accumModel.Generation = u.N
 	u.accumModel.Integrate()
 	return u.accumModel
 }
@@ -0,0 +1,261 @@
+package model
+
+import (
+	"yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+	"yap/util"
+
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// a toy multiclass problem: instances are pairs of feature values, labeled
+// with one of three classes
+type toyInstance [2]int
+
+func (i toyInstance) Equal(other util.Equaler) bool {
+	otherInstance, ok := other.(toyInstance)
+	return ok && i == otherInstance
+}
+
+type toyLabel int
+
+func (l toyLabel) Equal(other util.Equaler) bool {
+	otherLabel, ok := other.(toyLabel)
+	return ok && l == otherLabel
+}
+
+const toyLabels = 3
+
+func toyFeatures(instance toyInstance, label int) *transition.FeaturesList {
+	return &transition.FeaturesList{
+		Transition: transition.ConstTransition(label),
+		Previous:   &transition.FeaturesList{Features: []featurevector.Feature{instance[0], instance[1]}},
+	}
+}
+
+type toyDecoder struct{}
+
+func (d *toyDecoder) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}, interface{}, int, int, float64) {
+	instance := goldInstance.Instance().(toyInstance)
+	best, bestScore := 0, int64(0)
+	for label := 0; label < toyLabels; label++ {
+		if score := m.Score(toyFeatures(instance, label)); label == 0 || score > bestScore {
+			best, bestScore = label, score
+		}
+	}
+	gold := int(goldInstance.Decoded().(toyLabel))
+	decoded := &perceptron.Decoded{InstanceVal: instance, DecodedVal: toyLabel(best)}
+	return decoded, toyFeatures(instance, best), toyFeatures(instance, gold), -1, 1, float64(bestScore)
+}
+
+func (d *toyDecoder) Decode(instance perceptron.Instance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
+	panic("not implemented")
+}
+
+func (d *toyDecoder) DecodeGold(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
+	return goldInstance, nil
+}
+
+func toyInstances() []perceptron.DecodedInstance {
+	var instances []perceptron.DecodedInstance
+	for i := 0; i < 12; i++ {
+		instance := toyInstance{i % 4, i % 3}
+		label := toyLabel((i%4 + i%3) % toyLabels)
+		instances = append(instances, &perceptron.Decoded{InstanceVal: instance, DecodedVal: label})
+	}
+	return instances
+}
+
+func toyTrainer(model *AvgMatrixSparse, iterations int) *perceptron.LinearPerceptron {
+	trainer := &perceptron.LinearPerceptron{
+		Decoder:     &toyDecoder{},
+		GoldDecoder: &toyDecoder{},
+		Updater:     new(AveragedModelStrategy),
+		Shuffle:     true,
+		ShuffleSeed: 7,
+	}
+	trainer.Iterations = iterations
+	trainer.Init(model)
+	return trainer
+}
+
+// training resumed from a checkpoint after k iterations must result in
+// the same model as uninterrupted training
+func TestAvgMatrixSparseResume(t *testing.T) {
+	const iterations, k = 5, 2
+	instances := toyInstances()
+
+	trainer := toyTrainer(NewAvgMatrixSparse(2, nil, false), iterations)
+	trainer.Train(instances)
+	expected := trainer.Model.(*AvgMatrixSparse).Serialize(-1)
+
+	// train k iterations, checkpointing as the app does and stopping
+	var checkpoint bytes.Buffer
+	interrupted := toyTrainer(NewAvgMatrixSparse(2, nil, false), iterations)
+	interrupted.Continue = func(curIteration, iterations, generations int, m perceptron.Model) bool {
+		if curIteration < k {
+			return true
+		}
+		avgModel := m.(*AvgMatrixSparse)
+		serialized := avgModel.Serialize(generations)
+		avgModel.SerializeHistory(serialized)
+		if err := gob.NewEncoder(&checkpoint).Encode(serialized); err != nil {
+			t.Fatal("Failed writing checkpoint:", err)
+		}
+		return false
+	}
+	interrupted.Train(instances)
+
+	restored := &AvgMatrixSparseSerialized{}
+	if err := gob.NewDecoder(&checkpoint).Decode(restored); err != nil {
+		t.Fatal("Failed reading checkpoint:", err)
+	}
+	model := NewAvgMatrixSparse(2, nil, false)
+	if !model.DeserializeHistory(restored) {
+		t.Fatal("Checkpoint has no averaging state")
+	}
+	resumed := toyTrainer(model, iterations)
+	resumed.Resume(k, -1, model.Generation)
+	resumed.Train(instances)
+	result := resumed.Model.(*AvgMatrixSparse).Serialize(-1)
+
+	if result.Generation != expected.Generation {
+		t.Errorf("Resumed training ended at generation %d, expected %d", result.Generation, expected.Generation)
+	}
+	if !reflect.DeepEqual(result.Mat, expected.Mat) {
+		t.Errorf("Resumed training weights differ:\n%v\nexpected\n%v", result.Mat, expected.Mat)
+	}
+}
+
+// the history value of the single feature value of a one template model
+func singleValue(t *testing.T, m *AvgMatrixSparse, feature interface{}, trans int) *featurevector.HistoryValue {
+	store, exists := m.Mat[0].Vals[fmt.Sprintf("%v", feature)]
+	if !exists || store.GetValue(trans) == nil {
+		t.Fatalf("Feature %v of transition %d not found", feature, trans)
+	}
+	return store.GetValue(trans)
+}
+
+// set a feature value of a one template model, followed by generations
+// generations
+func setSingleValue(m *AvgMatrixSparse, amount int64, generations int) {
+	features := &transition.FeaturesList{
+		Transition: transition.ConstTransition(0),
+		Previous:   &transition.FeaturesList{Features: []featurevector.Feature{"f"}},
+	}
+	m.AddSubtract(features, features, amount)
+	for i := 0; i < generations; i++ {
+		m.IncrementGeneration()
+	}
+}
+
+func TestAvgMatrixSparseMixWeights(t *testing.T) {
+	// weight 4 for 4 generations, dividing the weight keeps its total
+	m := NewAvgMatrixSparse(1, nil, false)
+	setSingleValue(m, 4, 4)
+	m.ScalarDivide(2)
+	value := singleValue(t, m, "f", 0)
+	if m.Generation != 4 || value.Value != 2 || value.IntegratedValue(m.Generation) != 16 {
+		t.Errorf("Got generation %d value %d total %d, expected 4 2 16", m.Generation, value.Value, value.IntegratedValue(m.Generation))
+	}
+
+	// mixing: weight 4 for 4 generations, and a shard of it trained to
+	// weight 2 for 2 generations
+	m = NewAvgMatrixSparse(1, nil, false)
+	setSingleValue(m, 4, 4)
+	shard := m.ShardCopy().(*AvgMatrixSparse)
+	if shard.Generation != 0 || singleValue(t, shard, "f", 0).IntegratedValue(0) != 0 {
+		t.Errorf("Shard copy starts at generation %d total %d, expected 0 0", shard.Generation, singleValue(t, shard, "f", 0).IntegratedValue(0))
+	}
+	setSingleValue(shard, -2, 2)
+	m.AddModel(shard)
+	m.ScalarDivide(2)
+	value = singleValue(t, m, "f", 0)
+	if m.Generation != 6 || value.Value != 3 || value.IntegratedValue(m.Generation) != 16+4 {
+		t.Errorf("Got mixed generation %d value %d total %d, expected 6 3 20", m.Generation, value.Value, value.IntegratedValue(m.Generation))
+	}
+}
+
+// iterative parameter mixing of shards trained on identical instances
+// must result in the model trained on the instances without shards, its
+// averaging totals and generations summed over the shards
+func TestAvgMatrixSparseMixing(t *testing.T) {
+	const iterations, shards = 3, 2
+	instances := toyInstances()
+
+	trainer := toyTrainer(NewAvgMatrixSparse(2, nil, false), iterations)
+	trainer.Shuffle = false
+	trainer.Train(instances)
+	expected := trainer.Model.(*AvgMatrixSparse).Serialize(-1)
+
+	// every shard trains on every shards'th instance, a copy of each
+	// instance for each shard trains all shards on the same instances
+	copied := make([]perceptron.DecodedInstance, 0, shards*len(instances))
+	for _, instance := range instances {
+		for s := 0; s < shards; s++ {
+			copied = append(copied, instance)
+		}
+	}
+	mixed := toyTrainer(NewAvgMatrixSparse(2, nil, false), iterations)
+	mixed.Shuffle = false
+	for s := 0; s < shards; s++ {
+		mixed.ShardDecoders = append(mixed.ShardDecoders, &toyDecoder{})
+		mixed.ShardGoldDecoders = append(mixed.ShardGoldDecoders, &toyDecoder{})
+	}
+	mixed.Train(copied)
+	result := mixed.Model.(*AvgMatrixSparse).Serialize(-1)
+
+	if result.Generation != shards*expected.Generation {
+		t.Errorf("Mixed training ended at generation %d, expected %d", result.Generation, shards*expected.Generation)
+	}
+	for _, template := range expected.Mat {
+		for _, values := range template.(map[interface{}]map[int]int64) {
+			for trans := range values {
+				values[trans] *= shards
+			}
+		}
+	}
+	if !reflect.DeepEqual(result.Mat, expected.Mat) {
+		t.Errorf("Mixed training weights differ:\n%v\nexpected\n%v", result.Mat, expected.Mat)
+	}
+}
+
+// the averaged model mixed from two shards trained on different instances
+func TestAvgMatrixSparseMixedAverage(t *testing.T) {
+	// the first shard trains on a, a; the second on b, b
+	a, b := toyInstance{0, 0}, toyInstance{1, 1}
+	var instances []perceptron.DecodedInstance
+	for i := 0; i < 2; i++ {
+		instances = append(instances,
+			&perceptron.Decoded{InstanceVal: a, DecodedVal: toyLabel(1)},
+			&perceptron.Decoded{InstanceVal: b, DecodedVal: toyLabel(2)})
+	}
+	mixed := toyTrainer(NewAvgMatrixSparse(2, nil, false), 1)
+	mixed.Shuffle = false
+	for s := 0; s < 2; s++ {
+		mixed.ShardDecoders = append(mixed.ShardDecoders, &toyDecoder{})
+		mixed.ShardGoldDecoders = append(mixed.ShardGoldDecoders, &toyDecoder{})
+	}
+	mixed.Train(instances)
+	result := mixed.Model.(*AvgMatrixSparse).Serialize(-1)
+
+	// each shard decodes label 0 for its first instance and updates the
+	// weights of its features by 1 and -1, which hold for both of the
+	// shard's generations, then decodes its second instance correctly
+	if result.Generation != 4 {
+		t.Errorf("Mixed training ended at generation %d, expected 4", result.Generation)
+	}
+	averaged := map[interface{}]map[int]int64{
+		"0": {0: -2, 1: 2},
+		"1": {0: -2, 2: 2},
+	}
+	expected := []interface{}{averaged, averaged}
+	if !reflect.DeepEqual(result.Mat, expected) {
+		t.Errorf("Mixed average weights\n%v\nexpected\n%v", result.Mat, expected)
+	}
+}
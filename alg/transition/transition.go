@@ -126,6 +126,52 @@ type Oracle interface {
 	Name() string
 }
 
+// A DynamicOracle can tell the cost of any transition from any
+// configuration, not only those on the gold path (Goldberg & Nivre 2012)
+type DynamicOracle interface {
+	Oracle
+	// Cost is the number of gold arcs no longer reachable after the transition
+	Cost(Configuration, Transition) int
+}
+
+// A DynamicTransitionSystem can replace its default oracle with a dynamic one
+type DynamicTransitionSystem interface {
+	TransitionSystem
+	AddDynamicOracle()
+}
+
+// Constraints restrict the transitions from a configuration, e.g. to those
+// consistent with a partial annotation of the parsed instance
+type Constraints interface {
+	// Allowed returns the allowed transitions of the given transitions of
+	// a configuration, all of the same type
+	Allowed(conf Configuration, transType byte, transitions []int) []int
+}
+
+// ConstrainedSystem is a transition system of only the transitions its
+// constraints allow
+type ConstrainedSystem struct {
+	TransitionSystem
+	Constraints Constraints
+}
+
+var _ TransitionSystem = &ConstrainedSystem{}
+
+func (s *ConstrainedSystem) GetTransitions(conf Configuration) (byte, []int) {
+	transType, transitions := s.TransitionSystem.GetTransitions(conf)
+	return transType, s.Constraints.Allowed(conf, transType, transitions)
+}
+
+func (s *ConstrainedSystem) YieldTransitions(conf Configuration) (byte, chan int) {
+	transType, transitions := s.GetTransitions(conf)
+	yielded := make(chan int, len(transitions))
+	for _, t := range transitions {
+		yielded <- t
+	}
+	close(yielded)
+	return transType, yielded
+}
+
 func (seq ConfigurationSequence) String() string {
 	var buf bytes.Buffer
 	w := new(tabwriter.Writer)
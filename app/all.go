@@ -26,12 +26,19 @@ var AppCommands []*commander.Command = []*commander.Command{
 	// MorphCmd(),
 	// DepEvalCmd(),
 	DepCmd(),
+	MSTCmd(),
 	MdCmd(),
 	JointCmd(),
 	MALearnCmd(),
 	MACmd(),
 	HebMACmd(),
+	LexStatsCmd(),
 	FuseCmd(),
+	APICmd(),
+	PipelineCmd(),
+	SelfTrainCmd(),
+	EvalCmd(),
+	ModelCmd(),
 	// ValidateMAGoldCmd(),
 	// GenLemmasCmd(),
 	// GenUnAmbLemmasCmd(),
@@ -46,13 +53,25 @@ func AllCommands() *commander.Command {
 		Flag:        *flag.NewFlagSet("app", flag.ExitOnError),
 	}
 	for _, app := range cmd.Subcommands {
-		app.Run = NewAppWrapCommand(app.Run)
-		app.Flag.IntVar(&CPUs, NUM_CPUS_FLAG, 0, "Max CPUS to use (runtime.GOMAXPROCS); 0 = all")
-		app.Flag.StringVar(&CPUProfile, "cpuprofile", "", "write cpu profile to file")
+		wrapAppCommand(app)
 	}
 	return cmd
 }
 
+// commands grouping subcommands (without a Run of their own) have only
+// their subcommands wrapped
+func wrapAppCommand(app *commander.Command) {
+	for _, sub := range app.Subcommands {
+		wrapAppCommand(sub)
+	}
+	if app.Run == nil {
+		return
+	}
+	app.Run = NewAppWrapCommand(app.Run)
+	app.Flag.IntVar(&CPUs, NUM_CPUS_FLAG, 0, "Max CPUS to use (runtime.GOMAXPROCS); 0 = all")
+	app.Flag.StringVar(&CPUProfile, "cpuprofile", "", "write cpu profile to file")
+}
+
 func InitCommand(cmd *commander.Command, args []string) {
 	maxCPUs := runtime.NumCPU()
 	if CPUs > maxCPUs {
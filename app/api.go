@@ -0,0 +1,242 @@
+package app
+
+import (
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+	nlp "yap/nlp/types"
+
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	apiAddr        string
+	apiMaxBodySize int64
+)
+
+// An APIRequest holds either tokenized text or a lattice in the lattice
+// file format. Text is one whitespace tokenized sentence per line
+type APIRequest struct {
+	Text    string   `json:"text,omitempty"`
+	Tokens  []string `json:"tokens,omitempty"`
+	Lattice string   `json:"lattice,omitempty"`
+}
+
+type APISentence struct {
+	MALattice []lattice.JSONLattice `json:"ma_lattice,omitempty"`
+	OOV       []bool                `json:"oov,omitempty"`
+	MDLattice []lattice.JSONLattice `json:"md_lattice,omitempty"`
+	DepTree   []conll.JSONRow       `json:"dep_tree,omitempty"`
+}
+
+type APIResponse struct {
+	Sentences []*APISentence `json:"sentences,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+type apiStage func(p *PipelineParser, req *APIRequest) ([]*APISentence, error)
+
+func (r *APIRequest) Sentences() ([][]string, error) {
+	if len(r.Tokens) > 0 {
+		return [][]string{r.Tokens}, nil
+	}
+	var sents [][]string
+	for _, line := range strings.Split(r.Text, "\n") {
+		if tokens := strings.Fields(line); len(tokens) > 0 {
+			sents = append(sents, tokens)
+		}
+	}
+	if len(sents) == 0 {
+		return nil, errors.New("Request has no text or tokens")
+	}
+	return sents, nil
+}
+
+func (r *APIRequest) Lattices() ([]lattice.Lattice, error) {
+	lats, err := ReadLattices(r.Lattice)
+	if err != nil {
+		return nil, err
+	}
+	if len(lats) == 0 {
+		return nil, errors.New("Request has no lattice")
+	}
+	return lats, nil
+}
+
+func oovFlags(oov nlp.BasicSentence) []bool {
+	flags := make([]bool, len(oov))
+	for i, token := range oov {
+		flags[i] = token == "1"
+	}
+	return flags
+}
+
+func APIAnalyze(p *PipelineParser, req *APIRequest) ([]*APISentence, error) {
+	sents, err := req.Sentences()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*APISentence, len(sents))
+	for i, tokens := range sents {
+		lat, oov := p.Analyze(tokens)
+		result[i] = &APISentence{
+			MALattice: lattice.Lattice2JSON(lat),
+			OOV:       oovFlags(oov),
+		}
+	}
+	return result, nil
+}
+
+func APIDisambiguate(p *PipelineParser, req *APIRequest) ([]*APISentence, error) {
+	lats, err := req.Lattices()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*APISentence, len(lats))
+	for i, lat := range lats {
+		_, disLat := p.Disambiguate(lat)
+		result[i] = &APISentence{MDLattice: lattice.Lattice2JSON(disLat)}
+	}
+	return result, nil
+}
+
+func APIParse(p *PipelineParser, req *APIRequest) ([]*APISentence, error) {
+	lats, err := req.Lattices()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*APISentence, len(lats))
+	for i, lat := range lats {
+		_, sent := p.DepParse(lat)
+		result[i] = &APISentence{DepTree: sent.JSON()}
+	}
+	return result, nil
+}
+
+func APIPipeline(p *PipelineParser, req *APIRequest) ([]*APISentence, error) {
+	sents, err := req.Sentences()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*APISentence, len(sents))
+	for i, tokens := range sents {
+		ambLat, oov := p.Analyze(tokens)
+		// the MD may modify the lattice, so convert it to json first
+		sentResult := &APISentence{
+			MALattice: lattice.Lattice2JSON(ambLat),
+			OOV:       oovFlags(oov),
+		}
+		_, disLat := p.Disambiguate(ambLat)
+		sentResult.MDLattice = lattice.Lattice2JSON(disLat)
+		_, sent := p.DepParse(disLat)
+		sentResult.DepTree = sent.JSON()
+		result[i] = sentResult
+	}
+	return result, nil
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, response *APIResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Println("Failed writing response", err)
+	}
+}
+
+func APIHandler(p *PipelineParser, stage apiStage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeAPIResponse(w, http.StatusMethodNotAllowed, &APIResponse{Error: "Only POST is supported"})
+			return
+		}
+		req := &APIRequest{}
+		body := http.MaxBytesReader(w, r.Body, apiMaxBodySize)
+		if err := json.NewDecoder(body).Decode(req); err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			writeAPIResponse(w, status, &APIResponse{Error: fmt.Sprintf("Failed decoding request - %v", err)})
+			return
+		}
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Println("Recovered error", rec, "for", r.URL.Path)
+				writeAPIResponse(w, http.StatusInternalServerError, &APIResponse{Error: fmt.Sprint(rec)})
+			}
+		}()
+		sents, err := stage(p, req)
+		if err != nil {
+			writeAPIResponse(w, http.StatusBadRequest, &APIResponse{Error: err.Error()})
+			return
+		}
+		writeAPIResponse(w, http.StatusOK, &APIResponse{Sentences: sents})
+	}
+}
+
+func APIServer(cmd *commander.Command, args []string) error {
+	LocatePipelineFiles(cmd, []string{"addr"})
+	PipelineConfigOut()
+	log.Printf("Listen Address:\t%s", apiAddr)
+	log.Printf("Max Body Size:\t%d", apiMaxBodySize)
+	log.Println()
+	parser := LoadPipelineParser()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/analyze", APIHandler(parser, APIAnalyze))
+	mux.HandleFunc("/disambiguate", APIHandler(parser, APIDisambiguate))
+	mux.HandleFunc("/parse", APIHandler(parser, APIParse))
+	mux.HandleFunc("/pipeline", APIHandler(parser, APIPipeline))
+	log.Println("Serving on", apiAddr)
+	return http.ListenAndServe(apiAddr, mux)
+}
+
+func APICmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       APIServer,
+		UsageLine: "api <file options> [arguments]",
+		Short:     "serve morphological analysis, disambiguation and dependency parsing over HTTP/JSON",
+		Long: `
+serve morphological analysis, disambiguation and dependency parsing over HTTP/JSON
+
+	$ ./yap api [-addr <host:port>] [options]
+
+Models are loaded once at startup. All endpoints accept a JSON POST body:
+
+	/analyze       {"text": "..."} or {"tokens": [...]}  -> ma_lattice, oov
+	/disambiguate  {"lattice": "<ambiguous lattice>"}    -> md_lattice
+	/parse         {"lattice": "<disambiguated lattice>"} -> dep_tree
+	/pipeline      {"text": "..."} or {"tokens": [...]}  -> ma_lattice, oov, md_lattice, dep_tree
+
+Text is one whitespace tokenized sentence per line, lattices are in the lattice file format.
+Request bodies larger than -maxbody bytes are rejected.
+`,
+		Flag: *flag.NewFlagSet("api", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&apiAddr, "addr", "localhost:8000", "Listen address")
+	cmd.Flag.Int64Var(&apiMaxBodySize, "maxbody", 10<<20, "Max size of a request body in bytes")
+	cmd.Flag.StringVar(&prefixFile, "prefix", "bgupreflex_withdef.utf8.hr", "Prefix file for morphological analyzer")
+	cmd.Flag.StringVar(&lexiconFile, "lexicon", "bgulex.utf8.hr", "Lexicon file for morphological analyzer")
+	cmd.Flag.BoolVar(&nnpnofeats, "addnnpnofeats", false, "Add NNP in lex but without features")
+	cmd.Flag.StringVar(&mdModelName, "mdmn", "hebmd.b32", "MD Modelfile")
+	cmd.Flag.StringVar(&mdFeaturesFile, "mdf", "standalone.md.yaml", "MD Features Configuration File")
+	cmd.Flag.IntVar(&mdBeamSize, "mdb", 32, "MD Beam Size")
+	cmd.Flag.StringVar(&paramFuncName, "p", "Funcs_Main_POS_Both_Prop", "Param Func types: ["+nlp.AllParamFuncNames+"]")
+	cmd.Flag.BoolVar(&UsePOP, "pop", true, "Add POP operation to MD")
+	cmd.Flag.BoolVar(&pipelineNoLemma, "nolemma", true, "Ignore lemmas")
+	cmd.Flag.StringVar(&depModelName, "depmn", "dep.b64", "Dependency Modelfile")
+	cmd.Flag.StringVar(&depFeaturesFile, "depf", "zhangnivre2011.yaml", "Dependency Features Configuration File")
+	cmd.Flag.StringVar(&depLabelsFile, "l", "hebtb.labels.conf", "Dependency Labels Configuration File")
+	cmd.Flag.IntVar(&DepBeamSize, "depb", 64, "Dependency Beam Size")
+	cmd.Flag.StringVar(&arcSystemStr, "a", "eager", "Optional - Arc System [standard, eager, swap, hybrid, covington]")
+	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
+	return cmd
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"yap/alg/perceptron"
+	transitionmodel "yap/alg/transition/model"
+	"yap/util"
+
+	"log"
+)
+
+var (
+	resumeFile         string
+	checkpointTraining bool
+)
+
+// A Checkpoint is a temporary model written at the start of each training
+// iteration (model.temp.iN) when training with -checkpoint, holding the
+// complete training state
+type Checkpoint struct {
+	File   string
+	Header *ModelHeader
+	Data   *Serialization
+}
+
+// checkpoint training resumes from, set by LoadCheckpoint
+var resumeCheckpoint *Checkpoint
+
+// LoadCheckpoint reads the checkpoint given with -resume (if any), verifies
+// it was written by training with the expected configuration and training
+// files, and restores the enumerations. Must be called right after the
+// enumerations are set up, before they are referenced or added to
+func LoadCheckpoint(expected *ModelHeader) {
+	if len(resumeFile) == 0 {
+		return
+	}
+	log.Println("Loading checkpoint", resumeFile)
+	header, data, err := ReadModelFile(resumeFile)
+	if err != nil {
+		log.Fatalln("Failed reading checkpoint from", resumeFile, err)
+	}
+	if header == nil || data.WeightModel.History == nil {
+		log.Fatalln("File", resumeFile, "is not a training checkpoint, checkpoints are written when training with -checkpoint")
+	}
+	if err := header.Verify(expected); err != nil {
+		log.Fatalln("Checkpoint", resumeFile, "does not match the configuration:", err)
+	}
+	if header.Shuffle != expected.Shuffle || header.ShuffleSeed != expected.ShuffleSeed {
+		log.Fatalln("Checkpoint", resumeFile, "was written with a different training instance order (-shuffle, -seed)")
+	}
+	if header.UpdateStrategy != expected.UpdateStrategy {
+		log.Fatalln("Checkpoint", resumeFile, "was written training with update strategy", header.UpdateStrategy, "not", expected.UpdateStrategy)
+	}
+	if header.DynamicOracle != expected.DynamicOracle || header.Explore != expected.Explore || header.ExploreAfter != expected.ExploreAfter {
+		log.Fatalln("Checkpoint", resumeFile, "was written training with a different oracle (-dyn, -explore, -exploreit)")
+	}
+	if header.PseudoProjective != expected.PseudoProjective {
+		log.Fatalln("Checkpoint", resumeFile, "was written training on pseudo-projective trees", header.PseudoProjective, "not", expected.PseudoProjective)
+	}
+	if header.TrainShards != expected.TrainShards {
+		log.Fatalln("Checkpoint", resumeFile, "was written training", header.TrainShards, "shards, not", expected.TrainShards)
+	}
+	for file, md5 := range header.TrainingFiles {
+		if cur, err := util.MD5File(file); err == nil && cur != md5 {
+			log.Fatalln("Training file", file, "changed since checkpoint", resumeFile, "was written")
+		}
+	}
+	for _, enum := range []struct {
+		cur   **util.EnumSet
+		saved *util.EnumSet
+	}{
+		{&EWord, data.EWord}, {&EPOS, data.EPOS}, {&EWPOS, data.EWPOS},
+		{&EMHost, data.EMHost}, {&EMSuffix, data.EMSuffix}, {&EMorphProp, data.EMorphProp},
+		{&ETrans, data.ETrans}, {&ETokens, data.ETokens},
+	} {
+		if enum.saved != nil {
+			*enum.cur = enum.saved
+		}
+	}
+	resumeCheckpoint = &Checkpoint{resumeFile, header, data}
+}
+
+// Restore sets the weights, averaging state and update counts of a new
+// model in training to those of the checkpoint
+func (c *Checkpoint) Restore(model *transitionmodel.AvgMatrixSparse) {
+	if !model.DeserializeHistory(c.Data.WeightModel) {
+		log.Fatalln("File", c.File, "is not a training checkpoint")
+	}
+	log.Println("Resuming training from", c.File, "at iteration", c.Header.TrainIterations, "generation", model.Generation)
+}
+
+// Resume sets the position training continues from
+func (c *Checkpoint) Resume(trainer *perceptron.LinearPerceptron, model *transitionmodel.AvgMatrixSparse) {
+	trainer.Resume(c.Header.TrainIterations, -1, model.Generation)
+}
+
+// A StopState is the state of a training stop condition, saved in
+// checkpoints so that resumed training stops as the original would have
+type StopState struct {
+	Continue            bool // the decision of the stop condition
+	EqualIterations     int
+	ContinuousDecreases int
+	PrevResult          float64
+	BestResult          float64
+	BestIteration       int
+	BestModelFile       string
+}
+
+// resumedStopState returns the stop condition state of the checkpoint
+// training resumes from, or a new state
+func resumedStopState() *StopState {
+	if resumeCheckpoint != nil && resumeCheckpoint.Header.Stop != nil {
+		state := *resumeCheckpoint.Header.Stop
+		return &state
+	}
+	return &StopState{}
+}
+
+// StopCondition wraps the stop condition of resumed training. The stop
+// condition was already checked at the checkpoint's iteration (writing the
+// checkpoint), its decision is reused rather than evaluated again
+func (c *Checkpoint) StopCondition(converge perceptron.StopCondition) perceptron.StopCondition {
+	if converge == nil {
+		converge = perceptron.DefaultStopCondition
+	}
+	checked := false
+	return func(curIteration, iterations, generations int, m perceptron.Model) bool {
+		if !checked && curIteration == c.Header.TrainIterations {
+			checked = true
+			if c.Header.Stop != nil && !c.Header.Stop.Continue {
+				log.Println("Training had stopped at checkpoint", c.File)
+				return false
+			}
+			return true
+		}
+		return converge(curIteration, iterations, generations, m)
+	}
+}
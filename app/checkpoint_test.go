@@ -0,0 +1,47 @@
+package app
+
+import (
+	"yap/alg/perceptron"
+
+	"testing"
+)
+
+func TestCheckpointStopCondition(t *testing.T) {
+	var checked []int
+	converge := func(curIteration, iterations, generations int, m perceptron.Model) bool {
+		checked = append(checked, curIteration)
+		return curIteration < iterations
+	}
+
+	checkpoint := &Checkpoint{Header: &ModelHeader{TrainIterations: 2, Stop: &StopState{Continue: true}}}
+	resumed := checkpoint.StopCondition(converge)
+	for i := 2; resumed(i, 4, 0, nil); i++ {
+	}
+	if len(checked) != 2 || checked[0] != 3 || checked[1] != 4 {
+		t.Errorf("Expected the stop condition checked at iterations 3 and 4 only, got %v", checked)
+	}
+
+	checked = nil
+	checkpoint.Header.Stop.Continue = false
+	if checkpoint.StopCondition(converge)(2, 4, 0, nil) || len(checked) > 0 {
+		t.Errorf("Expected training stopped at the checkpoint to remain stopped, checked %v", checked)
+	}
+}
+
+func TestResumedStopState(t *testing.T) {
+	defer func() { resumeCheckpoint = nil }()
+	resumeCheckpoint = nil
+	if state := resumedStopState(); *state != (StopState{}) {
+		t.Errorf("Expected a new stop state without a checkpoint, got %+v", state)
+	}
+	saved := &StopState{Continue: true, EqualIterations: 1, PrevResult: 0.5, BestIteration: 2, BestModelFile: "model.temp.i2"}
+	resumeCheckpoint = &Checkpoint{Header: &ModelHeader{TrainIterations: 3, Stop: saved}}
+	state := resumedStopState()
+	if *state != *saved {
+		t.Errorf("Got resumed stop state %+v, expected %+v", state, saved)
+	}
+	state.EqualIterations++
+	if saved.EqualIterations != 1 {
+		t.Error("Resumed stop state must not share the checkpoint's state")
+	}
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/alg/transition"
+	"yap/nlp/format/conllu"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	nlp "yap/nlp/types"
+
+	"fmt"
+	"log"
+	"math"
+)
+
+var (
+	Confidence     bool
+	ConfidenceTemp float64
+)
+
+func VerifyConfidence() {
+	if Confidence && Stream {
+		log.Fatalln("Confidence scores can not be streamed")
+	}
+	if ConfidenceTemp < 0 {
+		log.Fatalln("Confidence temperature must not be negative, got", ConfidenceTemp)
+	}
+}
+
+// ConfidenceConfigOut logs the confidence configuration, if any
+func ConfidenceConfigOut() {
+	if !Confidence {
+		return
+	}
+	if ConfidenceTemp > 0 {
+		log.Printf("Confidence:\t\ttemperature %v", ConfidenceTemp)
+	} else {
+		log.Printf("Confidence:\t\ttemperature of score deviation")
+	}
+}
+
+// AgendaWeights normalizes the scores of the candidates of a final agenda
+// with a softmax, scaled by the temperature, or if it is 0, by the standard
+// deviation of the scores
+func AgendaWeights(agenda []*search.ScoredConfiguration, temperature float64) []float64 {
+	weights := make([]float64, len(agenda))
+	if len(agenda) == 0 {
+		return weights
+	}
+	var (
+		max       = agenda[0].Score()
+		mean, sum float64
+	)
+	for _, candidate := range agenda {
+		score := candidate.Score()
+		max = math.Max(max, score)
+		mean += score / float64(len(agenda))
+	}
+	if temperature == 0 {
+		for _, candidate := range agenda {
+			temperature += math.Pow(candidate.Score()-mean, 2) / float64(len(agenda))
+		}
+		if temperature = math.Sqrt(temperature); temperature == 0 {
+			temperature = 1
+		}
+	}
+	for i, candidate := range agenda {
+		weights[i] = math.Exp((candidate.Score() - max) / temperature)
+		sum += weights[i]
+	}
+	for i := range weights {
+		weights[i] /= sum
+	}
+	return weights
+}
+
+// ArcConfidence sets the MISC column of each row of the best parse of a
+// sentence, the first, to the confidence of its head (HeadConf) and of its
+// labeled arc (ArcConf): the weight of the parses agreeing on them. Rows of
+// different parses are the same node if they have the same id, form and
+// token
+func ArcConfidence(parses []conllu.Sentence, weights []float64) conllu.Sentence {
+	best := parses[0]
+	for id, row := range best.Deps {
+		var headConf, arcConf float64
+		for i, parse := range parses {
+			other, exists := parse.Deps[id]
+			if !exists || other.Form != row.Form || other.TokenID != row.TokenID || other.Head != row.Head {
+				continue
+			}
+			headConf += weights[i]
+			if other.DepRel == row.DepRel {
+				arcConf += weights[i]
+			}
+		}
+		row.Misc = fmt.Sprintf("HeadConf=%.4f|ArcConf=%.4f", headConf, arcConf)
+		best.Deps[id] = row
+	}
+	return best
+}
+
+// SpelloutConfidence returns the confidence of the spellout of each mapping
+// of the best path of a sentence, the first: the weight of the paths
+// agreeing on it
+func SpelloutConfidence(paths []*disambig.MDConfig, weights []float64) []float64 {
+	best := paths[0].Mappings
+	confidence := make([]float64, len(best))
+	for i, mapping := range best {
+		for j, path := range paths {
+			if i < len(path.Mappings) && path.Mappings[i].Spellout.Equal(mapping.Spellout) {
+				confidence[i] += weights[j]
+			}
+		}
+	}
+	return confidence
+}
+
+// ArcConfidenceCorpus returns the best parse of each sentence in CoNLL-U with
+// its arc confidences, given the parse result parameters with the final
+// agendas
+func ArcConfidenceCorpus(params []interface{}, toConllU func(transition.Configuration) conllu.Sentence) []interface{} {
+	sents := make([]interface{}, len(params))
+	for i, param := range params {
+		agenda := param.(*search.ParseResultParameters).Agenda
+		parses := make([]conllu.Sentence, len(agenda))
+		for j, candidate := range agenda {
+			parses[j] = toConllU(candidate.C)
+		}
+		sents[i] = ArcConfidence(parses, AgendaWeights(agenda, ConfidenceTemp))
+	}
+	return sents
+}
+
+// SpelloutConfidenceCorpus returns the spellout confidences of the best path
+// of each sentence, given the parse result parameters with the final agendas
+func SpelloutConfidenceCorpus(params []interface{}, toMDConfig func(transition.Configuration) *disambig.MDConfig) [][]float64 {
+	confidences := make([][]float64, len(params))
+	for i, param := range params {
+		agenda := param.(*search.ParseResultParameters).Agenda
+		paths := make([]*disambig.MDConfig, len(agenda))
+		for j, candidate := range agenda {
+			paths[j] = toMDConfig(candidate.C)
+		}
+		confidences[i] = SpelloutConfidence(paths, AgendaWeights(agenda, ConfidenceTemp))
+	}
+	return confidences
+}
+
+func MDConfOf(conf transition.Configuration) *disambig.MDConfig {
+	return conf.(*disambig.MDConfig)
+}
+
+func JointMDConfOf(conf transition.Configuration) *disambig.MDConfig {
+	return &conf.(*joint.JointConfig).MDConfig
+}
+
+func JointConllU(conf transition.Configuration) conllu.Sentence {
+	return conllu.MorphGraph2ConllU(conf.(nlp.MorphDependencyGraph))
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/nlp/format/conllu"
+	"yap/nlp/format/mapping"
+	"yap/nlp/parser/disambig"
+	nlp "yap/nlp/types"
+
+	"bytes"
+	"math"
+	"testing"
+)
+
+func scoredAgenda(scores ...int64) []*search.ScoredConfiguration {
+	agenda := make([]*search.ScoredConfiguration, len(scores))
+	for i, score := range scores {
+		agenda[i] = &search.ScoredConfiguration{InternalScores: search.ScoreState{{Total: score}}}
+	}
+	return agenda
+}
+
+func closeTo(values, expected []float64) bool {
+	if len(values) != len(expected) {
+		return false
+	}
+	for i := range values {
+		if math.Abs(values[i]-expected[i]) > 1e-4 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAgendaWeights(t *testing.T) {
+	tests := []struct {
+		name        string
+		scores      []int64
+		temperature float64
+		expected    []float64
+	}{
+		{"empty", nil, 1, []float64{}},
+		{"softmax", []int64{2, 1, 0}, 1, []float64{0.6652, 0.2447, 0.0900}},
+		{"temperature", []int64{2, 0}, 2, []float64{0.7311, 0.2689}},
+		// the standard deviation of 2, 0 is 1
+		{"zero temperature", []int64{2, 0}, 0, []float64{0.8808, 0.1192}},
+		// no deviation, the temperature is 1
+		{"all equal", []int64{5, 5, 5}, 0, []float64{1. / 3, 1. / 3, 1. / 3}},
+		{"all equal temperature", []int64{5, 5}, 2, []float64{0.5, 0.5}},
+	}
+	for _, test := range tests {
+		if weights := AgendaWeights(scoredAgenda(test.scores...), test.temperature); !closeTo(weights, test.expected) {
+			t.Errorf("%s: got weights %v, expected %v", test.name, weights, test.expected)
+		}
+	}
+}
+
+func depRows(rows ...conllu.Row) conllu.Sentence {
+	sent := conllu.Sentence{Deps: make(map[int]conllu.Row)}
+	for _, row := range rows {
+		sent.Deps[row.ID] = row
+	}
+	return sent
+}
+
+func TestArcConfidence(t *testing.T) {
+	parses := []conllu.Sentence{
+		depRows(conllu.Row{ID: 1, Form: "a", TokenID: 1, Head: 2, DepRel: "subj"}, conllu.Row{ID: 2, Form: "b", TokenID: 2, Head: 0, DepRel: "root"}),
+		// same head of a, different label
+		depRows(conllu.Row{ID: 1, Form: "a", TokenID: 1, Head: 2, DepRel: "obj"}, conllu.Row{ID: 2, Form: "b", TokenID: 2, Head: 0, DepRel: "root"}),
+		depRows(conllu.Row{ID: 1, Form: "a", TokenID: 1, Head: 0, DepRel: "root"}, conllu.Row{ID: 2, Form: "b", TokenID: 2, Head: 1, DepRel: "subj"}),
+		// a different node with the id of a
+		depRows(conllu.Row{ID: 1, Form: "c", TokenID: 1, Head: 2, DepRel: "subj"}, conllu.Row{ID: 2, Form: "b", TokenID: 2, Head: 0, DepRel: "root"}),
+	}
+	best := ArcConfidence(parses, []float64{0.4, 0.3, 0.2, 0.1})
+	expected := map[int]string{
+		1: "HeadConf=0.7000|ArcConf=0.4000",
+		2: "HeadConf=0.8000|ArcConf=0.8000",
+	}
+	for id, misc := range expected {
+		if best.Deps[id].Misc != misc {
+			t.Errorf("Got confidence %q of row %d, expected %q", best.Deps[id].Misc, id, misc)
+		}
+	}
+
+	// a single parse is certain
+	single := ArcConfidence(parses[2:3], AgendaWeights(scoredAgenda(3), 0))
+	if misc := single.Deps[1].Misc; misc != "HeadConf=1.0000|ArcConf=1.0000" {
+		t.Errorf("Got confidence %q of a single parse, expected %q", misc, "HeadConf=1.0000|ArcConf=1.0000")
+	}
+}
+
+func spellout(morphs ...[2]string) nlp.Spellout {
+	result := make(nlp.Spellout, len(morphs))
+	for i, morph := range morphs {
+		result[i] = &nlp.EMorpheme{Morpheme: nlp.Morpheme{Form: morph[0], CPOS: morph[1], POS: morph[1]}}
+	}
+	return result
+}
+
+func TestSpelloutConfidence(t *testing.T) {
+	var (
+		segmented = &nlp.Mapping{Token: "bbit", Spellout: spellout([2]string{"b", "PREP"}, [2]string{"bit", "NN"})}
+		whole     = &nlp.Mapping{Token: "bbit", Spellout: spellout([2]string{"bbit", "NN"})}
+		adjective = &nlp.Mapping{Token: "gdol", Spellout: spellout([2]string{"gdol", "JJ"})}
+		paths     = []*disambig.MDConfig{
+			{Mappings: []*nlp.Mapping{segmented, adjective}},
+			{Mappings: []*nlp.Mapping{whole, adjective}},
+			// a path shorter than the best
+			{Mappings: []*nlp.Mapping{segmented}},
+		}
+	)
+	confidence := SpelloutConfidence(paths, []float64{0.5, 0.3, 0.2})
+	if !closeTo(confidence, []float64{0.7, 0.8}) {
+		t.Errorf("Got spellout confidence %v, expected [0.7 0.8]", confidence)
+	}
+
+	var out bytes.Buffer
+	mapping.WriteConfidence(&out, []interface{}{paths[0]}, [][]float64{confidence})
+	expected := "0\t1\tb\t_\tPREP\tPREP\t_\t1\t0.7000\n" +
+		"1\t2\tbit\t_\tNN\tNN\t_\t1\t0.7000\n" +
+		"2\t3\tgdol\t_\tJJ\tJJ\t_\t2\t0.8000\n" +
+		"\n"
+	if out.String() != expected {
+		t.Errorf("Got mapping with confidence\n%s\nexpected\n%s", out.String(), expected)
+	}
+}
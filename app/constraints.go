@@ -0,0 +1,184 @@
+package app
+
+import (
+	"yap/alg/perceptron"
+	"yap/alg/search"
+	"yap/alg/transition"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+	. "yap/nlp/parser/dependency/transition"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"log"
+)
+
+var (
+	Constrained bool
+	Partial     bool
+)
+
+func VerifyConstrained() {
+	if Constrained && Stream {
+		log.Fatalln("Constrained parsing can not be streamed")
+	}
+	if Constrained && len(pseudoProjective) > 0 {
+		log.Fatalln("Constrained parsing does not support pseudo-projective labels")
+	}
+}
+
+func VerifyPartial() {
+	if Partial && DynamicOracle {
+		log.Fatalln("Training from partial annotations requires beam training, not the dynamic oracle")
+	}
+	if Partial && len(pseudoProjective) > 0 {
+		log.Fatalln("Training from partial annotations does not support pseudo-projective labels")
+	}
+}
+
+// PartialConfigOut logs what is latent in partially annotated training
+// instances, if training from them
+func PartialConfigOut(latent string) {
+	if Partial {
+		log.Printf("Partial annotation:\t%s", latent)
+	}
+}
+
+// ConstrainedConfigOut logs what the input constrains, if constrained
+func ConstrainedConfigOut(constraints string) {
+	if Constrained {
+		log.Printf("Constrained:\t\t%s", constraints)
+	}
+}
+
+// ConstrainCorpus pairs each instance with its constraints
+func ConstrainCorpus(instances []interface{}, constraints []transition.Constraints) []interface{} {
+	if len(instances) != len(constraints) {
+		log.Fatalln("Got constraints of", len(constraints), "sentences for", len(instances), "sentences")
+	}
+	constrained := make([]interface{}, len(instances))
+	for i, instance := range instances {
+		constrained[i] = &search.ConstrainedProblem{Problem: instance, Constraints: constraints[i]}
+	}
+	return constrained
+}
+
+// ArcConstraintsCorpus reads the annotated heads and labels of the words of
+// a CoNLL or CoNLL-U file as constraints of an arc system: an unannotated
+// (_) head or label is not constrained, nor is the label of a word whose
+// head is not
+func ArcConstraintsCorpus(filename string, system PartialOracleSystem, relations *util.EnumSet) []transition.Constraints {
+	constraints, _ := arcConstraintsCorpus(filename, system, relations)
+	return constraints
+}
+
+// arcConstraintsCorpus reads the constraints of the annotation of each
+// sentence of a file, and whether every head and label of it is annotated
+func arcConstraintsCorpus(filename string, system PartialOracleSystem, relations *util.EnumSet) ([]transition.Constraints, []bool) {
+	sents, err := conll.ReadArcsFile(filename, limit)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	constraints, complete := make([]transition.Constraints, len(sents)), make([]bool, len(sents))
+	for i, sent := range sents {
+		heads, rels := make([]int, len(sent)), make([]nlp.DepRel, len(sent))
+		complete[i] = true
+		for j, arc := range sent {
+			heads[j] = UNKNOWN_HEAD
+			if !arc.HasHead || len(arc.DepRel) == 0 {
+				complete[i] = false
+			}
+			if !arc.HasHead {
+				continue
+			}
+			if arc.Head < 0 || arc.Head > len(sent) || arc.Head == j+1 {
+				log.Fatalln("Sentence", i+1, "word", j+1, "has an invalid head", arc.Head)
+			}
+			heads[j] = arc.Head - 1
+			if arc.Head == 0 || len(arc.DepRel) == 0 {
+				continue
+			}
+			if _, exists := relations.IndexOf(nlp.DepRel(arc.DepRel)); !exists {
+				log.Fatalln("Sentence", i+1, "word", j+1, "has an unknown label", arc.DepRel)
+			}
+			rels[j] = nlp.DepRel(arc.DepRel)
+		}
+		constraints[i] = NewArcConstraints(system, heads, rels)
+	}
+	return constraints, complete
+}
+
+// FixedPathsCorpus returns the fixed lattice paths of each sentence as
+// constraints of its disambiguation
+func FixedPathsCorpus(lattices lattice.Lattices, sents []interface{}) []transition.Constraints {
+	constraints := make([]transition.Constraints, len(sents))
+	for i, sent := range sents {
+		constraints[i] = disambig.FixedPaths(lattice.FixedMorphemes(lattices[i], sent.(nlp.LatticeSentence)))
+	}
+	return constraints
+}
+
+// JointFixedPathsCorpus returns the fixed lattice paths of each sentence as
+// constraints of its joint parse
+func JointFixedPathsCorpus(lattices lattice.Lattices, sents []interface{}) []transition.Constraints {
+	constraints := FixedPathsCorpus(lattices, sents)
+	for i, fixed := range constraints {
+		constraints[i] = &joint.MDConstraints{MD: fixed}
+	}
+	return constraints
+}
+
+// PartialArcCorpus makes the training instances of the partially annotated
+// sentences of a CoNLL or CoNLL-U training file latent, replacing their gold
+// graphs by the constraints of their annotated heads and labels. Returns the
+// number of partially annotated sentences
+func PartialArcCorpus(instances []perceptron.DecodedInstance, filename string, system PartialOracleSystem, relations *util.EnumSet) int {
+	constraints, complete := arcConstraintsCorpus(filename, system, relations)
+	if len(instances) != len(constraints) {
+		log.Fatalln("Got annotations of", len(constraints), "sentences for", len(instances), "training sentences")
+	}
+	var partial int
+	for i, instance := range instances {
+		if complete[i] {
+			continue
+		}
+		instances[i] = &perceptron.Decoded{InstanceVal: instance.Instance(), DecodedVal: &search.PartialGold{Constraints: constraints[i]}}
+		partial++
+	}
+	return partial
+}
+
+// PartialMDCorpus makes the training instances of partially disambiguated
+// sentences latent, those with tokens missing from the disambiguated
+// lattices, replacing their gold mappings by the fixed paths of the
+// annotated tokens. Returns the number of partially disambiguated sentences
+func PartialMDCorpus(instances []perceptron.DecodedInstance, configs []interface{}) int {
+	var partial int
+	for i, instance := range instances {
+		conf := configs[i].(*disambig.MDConfig)
+		complete := len(conf.Mappings) >= len(conf.Lattices)
+		for _, mapping := range conf.Mappings {
+			complete = complete && mapping != nil
+		}
+		if complete {
+			continue
+		}
+		instances[i] = &perceptron.Decoded{InstanceVal: instance.Instance(), DecodedVal: &search.PartialGold{Constraints: disambig.MappingPaths(conf.Lattices, conf.Mappings)}}
+		partial++
+	}
+	return partial
+}
+
+// LatentGoldDecoder returns the gold decoder of training, which when
+// training from partial annotations decodes the latent gold of partially
+// annotated instances by constrained search with a copy of the beam
+func LatentGoldDecoder(beam *search.Beam, deterministic *search.Deterministic) perceptron.InstanceDecoder {
+	if !Partial {
+		return deterministic
+	}
+	latentBeam := &search.Beam{}
+	*latentBeam = *beam
+	return &search.LatentGold{Beam: latentBeam, Gold: deterministic}
+}
@@ -29,9 +29,9 @@ var (
 	depLabelsFile   string
 )
 
-func SetupDepEnum(relations []string) {
+func SetupDepEnum(relations []string, arcSystem string) {
 	SetupRelationEnum(relations)
-	SetupTransEnum(relations)
+	SetupTransEnum(relations, arcSystem)
 	EWord, EPOS, EWPOS = util.NewEnumSet(APPROX_WORDS, "EWord"), util.NewEnumSet(APPROX_POS, "EPOS"), util.NewEnumSet(APPROX_WORDS*WORDS_POS_FACTOR, "EWPOS")
 	EMHost, EMSuffix = util.NewEnumSet(APPROX_MHOSTS, "EMHost"), util.NewEnumSet(APPROX_MSUFFIXES, "EMSuffix")
 	EMorphProp = util.NewEnumSet(130, "EMorphProp") // random guess of number of possible values
@@ -50,8 +50,24 @@ func DepConfigOut(outModelFile string, b search.Interface, t transition.Transiti
 	log.Printf("Beam:             \t%s", b.Name())
 	log.Printf("Transition System:\t%s", t.Name())
 	log.Printf("Iterations:\t\t%d", Iterations)
+	if Shuffle {
+		log.Printf("Shuffle Seed:\t\t%d", ShuffleSeed)
+	}
+	if TrainShards > 1 {
+		log.Printf("Train Shards:\t\t%d", TrainShards)
+	}
+	if DynamicOracle {
+		log.Printf("Dynamic Oracle:\t\texplore %v after %d iteration(s)", Explore, ExploreAfter)
+	} else {
+		log.Printf("Update Strategy:\t%s", ViolationStrategy)
+	}
+	if len(pseudoProjective) > 0 {
+		log.Printf("Pseudo-projective:\t%s", pseudoProjective)
+	}
+	PartialConfigOut("unannotated heads and labels of the training file")
 	log.Printf("Beam Size:\t\t%d", DepBeamSize)
 	log.Printf("Beam Concurrent:\t%v", ConcurrentBeam)
+	log.Printf("Parse Workers:\t\t%d", Workers)
 	log.Printf("Model file:\t\t%s", outModelFile)
 	log.Printf("Use Lemmas:\t\t%v", !conll.IGNORE_LEMMA)
 	log.Printf("Word Type:\t\t%v", conll.WORD_TYPE)
@@ -84,28 +100,37 @@ func DepConfigOut(outModelFile string, b search.Interface, t transition.Transiti
 
 	}
 	log.Printf("Out (conll) file:\t\t\t%s", outConll)
+	KBestConfigOut()
+	ConfidenceConfigOut()
+	ConstrainedConfigOut("annotated heads and labels of the input")
 }
 
 func DepTrainAndParse(cmd *commander.Command, args []string) error {
 	// instantiate the arc system for config output only
 	// it will be reinstantiated later on with struct values
-
-	var (
-		arcSystem     transition.TransitionSystem
-		terminalStack int
-	)
-	switch arcSystemStr {
-	case "standard":
-		arcSystem = &ArcStandard{}
-		terminalStack = 1
-	case "eager":
-		arcSystem = &ArcEager{}
-		terminalStack = 0
-	default:
-		panic("Unknown arc system")
-	}
+	arcSystem, terminalStack := NewArcSystem(arcSystemStr)
 
 	arcSystem.AddDefaultOracle()
+	VerifyPseudoProjective()
+	VerifyKBest()
+	VerifyConfidence()
+	VerifyConstrained()
+	if _, partial := arcSystem.(PartialOracleSystem); Constrained && !partial {
+		log.Fatalln("Constrained parsing is only supported for the eager and hybrid arc systems")
+	}
+	if Constrained && len(inputLat) > 0 {
+		log.Fatalln("Constrained parsing reads the annotations of conll or conllu input (-in)")
+	}
+	VerifyPartial()
+	if _, partial := arcSystem.(PartialOracleSystem); Partial && !partial {
+		log.Fatalln("Training from partial annotations is only supported for the eager and hybrid arc systems")
+	}
+	if (KBest > 1 || Confidence) && DynamicOracle {
+		log.Fatalln("K best parses and confidence scores require a beam, models trained with the dynamic oracle are greedy")
+	}
+	if _, dynamic := arcSystem.(transition.DynamicTransitionSystem); DynamicOracle && !dynamic {
+		log.Fatalln("Dynamic oracle training is only supported for the eager and hybrid arc systems")
+	}
 
 	transitionSystem := transition.TransitionSystem(arcSystem)
 	REQUIRED_FLAGS := []string{"oc"}
@@ -162,38 +187,24 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		// start processing - setup enumerations
 		log.Println("Setup enumerations")
 	}
-	SetupDepEnum(relations.Values)
+	modelHeader := NewModelHeader("dep", arcSystemStr, DepBeamSize, featuresFile, relations.Values, "")
+	SetupDepEnum(relations.Values, arcSystemStr)
+	LoadCheckpoint(modelHeader)
 
 	// after calling SetupDepEnum, enums are instantiated and set according to the relations
 	// therefore we re-instantiate the arc system with the right parameters
-	switch arcSystemStr {
-	case "standard":
-		arcSystem = &ArcStandard{
-			SHIFT:       SH.Value(),
-			LEFT:        LA.Value(),
-			RIGHT:       RA.Value(),
-			Transitions: ETrans,
-			Relations:   ERel,
-		}
-	case "eager":
-		arcSystem = &ArcEager{
-			ArcStandard: ArcStandard{
-				SHIFT:       SH.Value(),
-				LEFT:        LA.Value(),
-				RIGHT:       RA.Value(),
-				Relations:   ERel,
-				Transitions: ETrans,
-			},
-			REDUCE:  RE.Value(),
-			POPROOT: PR.Value(),
-		}
-	default:
-		panic("Unknown arc system")
-	}
+	// (again if the enums are extended with pseudo-projective labels)
+	setupArcSystem := func() {
+		arcSystem, terminalStack = NewArcSystem(arcSystemStr)
 
-	arcSystem.AddDefaultOracle()
+		arcSystem.AddDefaultOracle()
+		if DynamicOracle {
+			arcSystem.(transition.DynamicTransitionSystem).AddDynamicOracle()
+		}
 
-	transitionSystem = transition.TransitionSystem(arcSystem)
+		transitionSystem = transition.TransitionSystem(arcSystem)
+	}
+	setupArcSystem()
 
 	if allOut && !parseOut {
 		log.Println()
@@ -290,6 +301,10 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 			}
 			goldGraphs = conll.Conll2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
 		}
+		if len(pseudoProjective) > 0 {
+			modelHeader.LiftedLabels = ProjectivizeCorpus(goldGraphs, pseudoProjective, arcSystemStr)
+			setupArcSystem()
+		}
 		if allOut {
 			log.Println()
 
@@ -297,8 +312,15 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		}
 		// goldGraphs = goldGraphs[:NUM_SENTS]
 		goldSequences := TrainingSequences(goldGraphs, GetAsTaggedSentence, GetAsLabeledDepGraph)
+		var numPartial int
+		if Partial {
+			numPartial = PartialArcCorpus(goldSequences, tConll, transitionSystem.(PartialOracleSystem), ERel)
+		}
 		if allOut {
 			log.Println("Generated", len(goldSequences), "training sequences")
+			if Partial {
+				log.Println(numPartial, "of them partially annotated")
+			}
 			log.Println()
 			log.Println("Training", Iterations, "iteration(s)")
 		}
@@ -326,6 +348,10 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 			Base:               conf,
 			NoRecover:          false,
 			DefaultTransType:   'A', // use Arc as default transition type
+			DynamicOracle:      DynamicOracle,
+			Explore:            Explore,
+			ExploreAfter:       ExploreAfter,
+			ExploreSeed:        ShuffleSeed,
 		}
 
 		beam := &search.Beam{
@@ -403,7 +429,13 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 			}
 			evaluator = MakeDepEvalStopCondition(sents, goldSents, testSents, decodeTestBeam, perceptron.InstanceDecoder(deterministic), DepBeamSize)
 		}
-		_ = Train(goldSequences, Iterations, modelFile, model, perceptron.EarlyUpdateInstanceDecoder(beam), perceptron.InstanceDecoder(deterministic), evaluator)
+		modelHeader.AddTrainingFiles(tConll)
+		trainingHeader = modelHeader
+		decoder := perceptron.EarlyUpdateInstanceDecoder(beam)
+		if DynamicOracle {
+			decoder = deterministic
+		}
+		trainer := Train(goldSequences, Iterations, modelFile, model, decoder, LatentGoldDecoder(beam, deterministic), evaluator)
 		if allOut {
 			log.Println("Done Training")
 			log.Println()
@@ -413,7 +445,9 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 			model.Serialize(-1),
 			EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
 		}
-		WriteModel(outModelFile, serialization)
+		// the stop condition may end training before or after Iterations
+		modelHeader.TrainIterations = trainer.TrainI
+		WriteModel(outModelFile, modelHeader, serialization)
 		if allOut {
 			log.Println("Done writing model")
 		}
@@ -421,7 +455,16 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		if allOut && !parseOut {
 			log.Println("Found model file", outModelFile, " ... loading model")
 		}
-		serialization := ReadModel(outModelFile)
+		header, serialization := LoadModelAndHeader(outModelFile, modelHeader)
+		if header != nil && header.DynamicOracle {
+			DynamicOracle = true
+		}
+		if header != nil && len(header.PseudoProjective) > 0 {
+			pseudoProjective = header.PseudoProjective
+			VerifyConstrained()
+			ExtendRelationEnum(header.LiftedLabels, arcSystemStr)
+			setupArcSystem()
+		}
 		model.Deserialize(serialization.WeightModel)
 		EWord, EPOS, EWPOS, EMHost, EMSuffix = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix
 		if allOut && !parseOut {
@@ -516,6 +559,9 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		for i, instance := range asGraphs {
 			sents[i] = GetAsTaggedSentence(instance)
 		}
+		if Constrained {
+			sents = ConstrainCorpus(sents, ArcConstraintsCorpus(input, transitionSystem.(PartialOracleSystem), ERel))
+		}
 	}
 
 	conf := &SimpleConfiguration{
@@ -540,15 +586,32 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		ShortTempAgenda:      true,
 		EstimatedTransitions: EstimatedBeamTransitions(),
 		ScoredStoreDense:     true,
+		KBest:                KBest,
+		ReturnAgenda:         Confidence,
+	}
+	// models trained with the dynamic oracle are greedy parsers
+	var parser Parser = beam
+	if DynamicOracle {
+		parser = &search.Deterministic{
+			Model:            model,
+			TransFunc:        transitionSystem,
+			FeatExtractor:    extractor,
+			Base:             conf,
+			DefaultTransType: 'A',
+		}
 	}
 	if Stream {
 		parsedStream := make(chan interface{}, 2)
 		if allOut {
 			log.Println("Starting parser")
 		}
-		go ParseStream(sentsStream, parsedStream, beam)
+		go ParseStream(sentsStream, parsedStream, parser)
 		log.Println("Streaming conversion to conll")
-		graphAsConllStream := conll.Graph2ConllStream(parsedStream, EMHost, EMSuffix)
+		var graphStream chan interface{} = parsedStream
+		if len(pseudoProjective) > 0 {
+			graphStream = DeprojectivizeStream(parsedStream, pseudoProjective)
+		}
+		graphAsConllStream := conll.Graph2ConllStream(graphStream, EMHost, EMSuffix)
 		if allOut {
 			log.Println("Creating writer stream to", outConll)
 		}
@@ -564,11 +627,26 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 			log.Print("Parsing")
 		}
 
-		parsedGraphs := Parse(sents, beam)
+		parsedGraphs, params := ParseWithParams(sents, parser)
+		if KBest > 1 {
+			WriteKBestOutput(params, DepKBestFormat)
+		}
+		if len(pseudoProjective) > 0 {
+			parsedGraphs = DeprojectivizeCorpus(parsedGraphs, pseudoProjective)
+		}
 		if !parseOut {
 			log.Println("Converting to conll")
 		}
-		if useConllU {
+		if Confidence {
+			graphAsConll := ArcConfidenceCorpus(params, DepConllU)
+			if useConllU {
+				graphAsConll = conllu.MergeGraphAndMorphCorpus(graphAsConll, asMorphGraphs)
+			}
+			conllu.WriteFile(outConll, graphAsConll)
+			if !parseOut {
+				log.Println("Wrote", len(parsedGraphs), "in conllu format with confidence scores to", outConll)
+			}
+		} else if useConllU {
 			graphAsConll := conllu.Graph2ConllUCorpus(parsedGraphs, EMHost, EMSuffix)
 			morphGraphs := conllu.MergeGraphAndMorphCorpus(graphAsConll, asMorphGraphs)
 			conllu.WriteFile(outConll, morphGraphs)
@@ -590,14 +668,35 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		log.SetPrefix("")
 		log.SetFlags(0)
 		log.Print("Parsing started")
-		parsedGraphs := Parse(sents, beam)
-		graphAsConll := conll.Graph2ConllCorpus(parsedGraphs, EMHost, EMSuffix)
-		conll.WriteFile(outConll, graphAsConll)
-		log.Println("Wrote", len(parsedGraphs), "in conll format to", outConll)
+		parsedGraphs, params := ParseWithParams(sents, parser)
+		if KBest > 1 {
+			WriteKBestOutput(params, DepKBestFormat)
+		}
+		if len(pseudoProjective) > 0 {
+			parsedGraphs = DeprojectivizeCorpus(parsedGraphs, pseudoProjective)
+		}
+		if Confidence {
+			conllu.WriteFile(outConll, ArcConfidenceCorpus(params, DepConllU))
+			log.Println("Wrote", len(parsedGraphs), "in conllu format with confidence scores to", outConll)
+		} else {
+			graphAsConll := conll.Graph2ConllCorpus(parsedGraphs, EMHost, EMSuffix)
+			conll.WriteFile(outConll, graphAsConll)
+			log.Println("Wrote", len(parsedGraphs), "in conll format to", outConll)
+		}
 	}
 	return nil
 }
 
+// DepConllU converts a parsed configuration to CoNLL-U, deprojectivized by
+// the pseudo-projective scheme, if any
+func DepConllU(conf transition.Configuration) conllu.Sentence {
+	graph := conf.(nlp.LabeledDependencyGraph)
+	if len(pseudoProjective) > 0 {
+		graph = Deprojectivize(graph, pseudoProjective, ERel)
+	}
+	return conllu.Graph2ConllU(graph, EMHost, EMSuffix)
+}
+
 func DepCmd() *commander.Command {
 	cmd := &commander.Command{
 		Run:       DepTrainAndParse,
@@ -612,11 +711,23 @@ runs dependency training/parsing
 		Flag: *flag.NewFlagSet("dep", flag.ExitOnError),
 	}
 	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
+	cmd.Flag.IntVar(&Workers, "workers", 1, "Number of sentences to parse concurrently (each with its own beam)")
+	cmd.Flag.BoolVar(&Shuffle, "shuffle", false, "Shuffle the training instances every iteration")
+	cmd.Flag.Int64Var(&ShuffleSeed, "seed", 1, "Random seed for shuffling the training instances (and exploration of the dynamic oracle)")
+	cmd.Flag.IntVar(&TrainShards, "shards", 1, "Number of training shards trained concurrently, mixing their weights every iteration (iterative parameter mixing)")
+	cmd.Flag.BoolVar(&CountUpdates, "countupdates", false, "Count the updates of each feature in the per-iteration models, for pruning rarely updated features (yap model prune -minupdates)")
+	cmd.Flag.StringVar(&ViolationStrategy, "update", search.EARLY_UPDATE, "Violation-fixing update strategy of beam training [early, max-violation, latest, full]")
+	cmd.Flag.StringVar(&resumeFile, "resume", "", "Optional - Resume training from a checkpoint (model.temp.iN file written with -checkpoint)")
+	cmd.Flag.BoolVar(&checkpointTraining, "checkpoint", false, "Write a training checkpoint (model.temp.iN) every iteration, which training can be resumed from")
+	cmd.Flag.BoolVar(&DynamicOracle, "dyn", false, "Train a greedy parser with the dynamic oracle, exploring wrong predictions (eager and hybrid arc systems only)")
+	cmd.Flag.Float64Var(&Explore, "explore", 0.9, "Probability of following a wrong prediction in dynamic oracle training")
+	cmd.Flag.IntVar(&ExploreAfter, "exploreit", 1, "Number of dynamic oracle training iterations before exploring wrong predictions")
 	cmd.Flag.IntVar(&Iterations, "it", 1, "Number of Perceptron Iterations")
 	cmd.Flag.IntVar(&DepBeamSize, "b", 64, "Dependency Beam Size")
 	cmd.Flag.StringVar(&modelFile, "m", "model", "Prefix for model file ({m}.b{b}.i{it}.model)")
 	cmd.Flag.StringVar(&depModelName, "mn", "dep.b64", "Modelfile")
-	cmd.Flag.StringVar(&arcSystemStr, "a", "eager", "Optional - Arc System [standard, eager]")
+	cmd.Flag.StringVar(&arcSystemStr, "a", "eager", "Optional - Arc System [standard, eager, swap, hybrid, covington]")
+	cmd.Flag.StringVar(&pseudoProjective, "pproj", "", "Optional - Pseudo-projective transformation of non-projective training trees [head, path]")
 
 	cmd.Flag.StringVar(&tConll, "tc", "", "Training Conll File")
 	cmd.Flag.StringVar(&input, "in", "", "Dev Tagged Sentences File")
@@ -624,6 +735,13 @@ runs dependency training/parsing
 	cmd.Flag.StringVar(&inputGold, "ing", "", "Optional - Dev Gold Parsed Sentences (for convergence)")
 	cmd.Flag.StringVar(&test, "test", "", "Test Conll File")
 	cmd.Flag.StringVar(&outConll, "oc", "", "Output Conll File")
+	cmd.Flag.IntVar(&KBest, "kbest", 1, "Optional - Number of best distinct parses per sentence to output (-okb, -okbjson)")
+	cmd.Flag.StringVar(&outKBest, "okb", "", "Optional - Output K Best Conll File (with # parse_rank and # score comments)")
+	cmd.Flag.StringVar(&outKBestJSON, "okbjson", "", "Optional - Output K Best JSON File")
+	cmd.Flag.BoolVar(&Confidence, "conf", false, "Optional - Output arc confidence scores of the final beam in the MISC column (output in CoNLL-U)")
+	cmd.Flag.Float64Var(&ConfidenceTemp, "conftemp", 0, "Optional - Softmax temperature of confidence scores (0 for the standard deviation of the beam's scores)")
+	cmd.Flag.BoolVar(&Constrained, "constrained", false, "Optional - Constrain parsing to the input's annotated heads and labels (HEAD/DEPREL other than _); eager and hybrid only")
+	cmd.Flag.BoolVar(&Partial, "partial", false, "Optional - Train from partially annotated sentences (HEAD/DEPREL of _), whose unannotated arcs are latent; eager and hybrid only")
 	cmd.Flag.StringVar(&depFeaturesFile, "f", "zhangnivre2011.yaml", "Features Configuration File")
 	cmd.Flag.StringVar(&depLabelsFile, "l", "hebtb.labels.conf", "Dependency Labels Configuration File")
 	cmd.Flag.BoolVar(&conll.IGNORE_LEMMA, "nolemma", false, "Ignore lemmas")
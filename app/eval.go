@@ -0,0 +1,674 @@
+package app
+
+import (
+	"yap/eval"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/conllu"
+	"yap/nlp/format/lattice"
+	nlp "yap/nlp/types"
+
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	evalPredMapFile, evalGoldMapFile string
+	evalMetrics                      string
+	evalJSONFile                     string
+	evalNoPunct                      bool
+)
+
+// punctuation excluded from attachment scores with -nopunct, as in
+// scripts/eval.py
+var evalPunct = regexp.MustCompile("^[,?!:;]$|^-LRB-$|^-RRB-$|^[.]+$|^[`]+$|^[']+$|^（$|^）$|^、$|^。$|^！$|^？$|^…$|^，$|^；$|^／$|^：$|^“$|^”$|^「$|^」$|^『$|^』$|^《$|^》$|^一一$")
+
+// An EvalWord is a syntactic word (morpheme) of an evaluated sentence: its
+// lattice edge, token and morphological analysis, and its head and label if
+// read from a dependency file
+type EvalWord struct {
+	nlp.EMorpheme
+	Head   int
+	DepRel string
+}
+
+type EvalSentence []*EvalWord
+
+// Spellouts returns the spellouts of the sentence's tokens by token id
+func (s EvalSentence) Spellouts() map[int]nlp.Spellout {
+	spellouts := make(map[int]nlp.Spellout)
+	for _, word := range s {
+		spellouts[word.TokenID] = append(spellouts[word.TokenID], &word.EMorpheme)
+	}
+	return spellouts
+}
+
+func newEvalWord(id, from, to, token int, form, lemma, cpos, pos, feats string, head int, deprel string) *EvalWord {
+	return &EvalWord{
+		EMorpheme: nlp.EMorpheme{Morpheme: nlp.Morpheme{
+			BasicDirectedEdge: [3]int{id, from, to},
+			Form:              form,
+			Lemma:             lemma,
+			CPOS:              cpos,
+			POS:               pos,
+			TokenID:           token,
+			FeatureStr:        feats,
+		}},
+		Head:   head,
+		DepRel: deprel,
+	}
+}
+
+// ReadEvalConll reads the words of a CoNLL file, each its own token
+func ReadEvalConll(filename string) []EvalSentence {
+	sents, err := conll.ReadFile(filename, 0)
+	if err != nil {
+		log.Fatalln("Failed reading CoNLL file", filename, err)
+	}
+	corpus := make([]EvalSentence, len(sents))
+	for i, sent := range sents {
+		corpus[i] = make(EvalSentence, len(sent))
+		for j := range corpus[i] {
+			row := sent[j+1]
+			corpus[i][j] = newEvalWord(j, j, j+1, j, row.Form, row.Lemma, row.CPosTag, row.PosTag, row.FeatStr, row.Head, row.DepRel)
+		}
+	}
+	return corpus
+}
+
+// ReadEvalConllU reads the syntactic words of a CoNLL-U file, with the
+// tokens of its multi-word token rows
+func ReadEvalConllU(filename string) []EvalSentence {
+	sents, _, err := conllu.ReadFile(filename, 0)
+	if err != nil {
+		log.Fatalln("Failed reading CoNLL-U file", filename, err)
+	}
+	corpus := make([]EvalSentence, len(sents))
+	for i, sent := range sents {
+		corpus[i] = make(EvalSentence, len(sent.Deps))
+		for j := range corpus[i] {
+			row := sent.Deps[j+1]
+			corpus[i][j] = newEvalWord(j, j, j+1, row.TokenID, row.Form, row.Lemma, row.UPosTag, row.XPosTag, row.FeatStr, row.Head, row.DepRel)
+		}
+	}
+	return corpus
+}
+
+// ReadEvalMapping reads the morphemes of a mapping (disambiguated lattice)
+// file, without heads
+func ReadEvalMapping(filename string) []EvalSentence {
+	lats, err := lattice.ReadFile(filename, 0)
+	if err != nil {
+		log.Fatalln("Failed reading mapping file", filename, err)
+	}
+	corpus := make([]EvalSentence, len(lats))
+	for i, lat := range lats {
+		starts := make([]int, 0, len(lat))
+		for start := range lat {
+			starts = append(starts, start)
+		}
+		sort.Ints(starts)
+		for _, start := range starts {
+			for _, edge := range lat[start] {
+				corpus[i] = append(corpus[i], newEvalWord(len(corpus[i]), edge.Start, edge.End, edge.Token, edge.Word, edge.Lemma, edge.CPosTag, edge.PosTag, edge.FeatStr, 0, ""))
+			}
+		}
+	}
+	return corpus
+}
+
+// DepResult holds the labeled and unlabeled attachment and label accuracy
+// results of words; each result's TP are the correct words and FP the
+// incorrect ones. Words of differently segmented tokens are not scored, and
+// counted as unaligned
+type DepResult struct {
+	LAS, UAS, LA                 *eval.Result
+	UnalignedGold, UnalignedPred int
+}
+
+func NewDepResult() *DepResult {
+	return &DepResult{LAS: &eval.Result{}, UAS: &eval.Result{}, LA: &eval.Result{}}
+}
+
+func (r *DepResult) add(head, label bool) {
+	for _, res := range []struct {
+		result  *eval.Result
+		correct bool
+	}{{r.LAS, head && label}, {r.UAS, head}, {r.LA, label}} {
+		if res.correct {
+			res.result.TP++
+		} else {
+			res.result.FP++
+		}
+	}
+}
+
+// AlignWords aligns the words of a predicted sentence to those of the gold
+// sentence by token: the words of a token segmented as in gold (the same
+// forms, in lattice order) are aligned to its gold words in order, the
+// words of differently segmented tokens are not aligned. It returns the
+// index of the predicted word aligned to each gold word, or -1
+func AlignWords(pred, gold EvalSentence) []int {
+	predTokens := make(map[int][]int, len(pred))
+	for i, word := range pred {
+		predTokens[word.TokenID] = append(predTokens[word.TokenID], i)
+	}
+	goldTokens := make(map[int][]int, len(gold))
+	for i, word := range gold {
+		goldTokens[word.TokenID] = append(goldTokens[word.TokenID], i)
+	}
+	alignment := make([]int, len(gold))
+	for i := range alignment {
+		alignment[i] = -1
+	}
+	for token, goldWords := range goldTokens {
+		predWords := predTokens[token]
+		if len(predWords) != len(goldWords) {
+			continue
+		}
+		aligned := true
+		for j, goldWord := range goldWords {
+			if pred[predWords[j]].Form != gold[goldWord].Form {
+				aligned = false
+				break
+			}
+		}
+		if aligned {
+			for j, goldWord := range goldWords {
+				alignment[goldWord] = predWords[j]
+			}
+		}
+	}
+	return alignment
+}
+
+// DepEvalSentence evaluates the heads and labels of a predicted sentence's
+// words against those of the gold sentence, aligned by AlignWords. A head
+// is correct if it is the root in both, or its predicted word is aligned to
+// the gold head. Only aligned words are scored; the unaligned words of
+// either sentence (e.g. by a predicted segmentation) are counted in the
+// result. Counts are also added by gold label and POS to the given
+// breakdowns, if not nil
+func DepEvalSentence(pred, gold EvalSentence, byLabel, byPOS map[string]*DepResult) *DepResult {
+	result := NewDepResult()
+	alignment := AlignWords(pred, gold)
+	result.UnalignedPred = len(pred)
+	for i, goldWord := range gold {
+		if alignment[i] >= 0 {
+			result.UnalignedPred--
+		}
+		if evalNoPunct && evalPunct.MatchString(goldWord.Form) {
+			continue
+		}
+		if alignment[i] < 0 {
+			result.UnalignedGold++
+			continue
+		}
+		predWord := pred[alignment[i]]
+		head := predWord.Head == 0 && goldWord.Head == 0 ||
+			predWord.Head > 0 && goldWord.Head > 0 && alignment[goldWord.Head-1] == predWord.Head-1
+		label := predWord.DepRel == goldWord.DepRel
+		result.add(head, label)
+		for _, breakdown := range []struct {
+			scores map[string]*DepResult
+			key    string
+		}{{byLabel, goldWord.DepRel}, {byPOS, goldWord.CPOS}} {
+			if breakdown.scores == nil {
+				continue
+			}
+			if _, exists := breakdown.scores[breakdown.key]; !exists {
+				breakdown.scores[breakdown.key] = NewDepResult()
+			}
+			breakdown.scores[breakdown.key].add(head, label)
+		}
+	}
+	return result
+}
+
+// MorphEvalSentence compares the morphemes of a predicted sentence to those
+// of the gold sentence, projected by an MDParams param func. Token aligned,
+// the spellouts of each token are compared as by Spellout.Compare; lattice
+// aligned, the morphemes of the sentence are compared along with their
+// lattice edges. As in Spellout.Compare, TN counts the gold morphemes
+// missing from the prediction
+func MorphEvalSentence(pred, gold EvalSentence, metric string, latticeAligned bool) *eval.Result {
+	result := &eval.Result{}
+	if !latticeAligned {
+		predSpellouts, goldSpellouts := pred.Spellouts(), gold.Spellouts()
+		for token, goldSpellout := range goldSpellouts {
+			TP, TN, FP, FN := predSpellouts[token].Compare(goldSpellout, metric)
+			result.TP, result.TN, result.FP, result.FN = result.TP+TP, result.TN+TN, result.FP+FP, result.FN+FN
+		}
+		for token, predSpellout := range predSpellouts {
+			if _, exists := goldSpellouts[token]; !exists {
+				_, _, FP, _ := predSpellout.Compare(nil, metric)
+				result.FP += FP
+			}
+		}
+		return result
+	}
+	paramFunc, exists := nlp.MDParams[metric]
+	if !exists {
+		log.Fatalln("Unknown param func", metric)
+	}
+	goldMorphs := make(map[string]int, len(gold))
+	for _, word := range gold {
+		goldMorphs[fmt.Sprintf("%d-%d %s", word.From(), word.To(), paramFunc(&word.EMorpheme))]++
+	}
+	for _, word := range pred {
+		key := fmt.Sprintf("%d-%d %s", word.From(), word.To(), paramFunc(&word.EMorpheme))
+		if goldMorphs[key] > 0 {
+			goldMorphs[key]--
+			result.TP++
+		} else {
+			result.FP++
+		}
+	}
+	for _, missing := range goldMorphs {
+		result.TN += missing
+	}
+	return result
+}
+
+// MorphPOSEvalSentence adds to a breakdown by POS the morphemes of a
+// predicted sentence whose form and POS match a morpheme of the same gold
+// token (TP), those that do not (FP, by predicted POS) and the gold
+// morphemes missing from the prediction (TN, by gold POS)
+func MorphPOSEvalSentence(pred, gold EvalSentence, byPOS map[string]*eval.Result) {
+	posResult := func(pos string) *eval.Result {
+		if _, exists := byPOS[pos]; !exists {
+			byPOS[pos] = &eval.Result{}
+		}
+		return byPOS[pos]
+	}
+	type tokenMorph struct {
+		token      int
+		form, cpos string
+	}
+	goldMorphs := make(map[tokenMorph]int, len(gold))
+	for _, word := range gold {
+		goldMorphs[tokenMorph{word.TokenID, word.Form, word.CPOS}]++
+	}
+	for _, word := range pred {
+		key := tokenMorph{word.TokenID, word.Form, word.CPOS}
+		if goldMorphs[key] > 0 {
+			goldMorphs[key]--
+			posResult(word.CPOS).TP++
+		} else {
+			posResult(word.CPOS).FP++
+		}
+	}
+	for morph, missing := range goldMorphs {
+		posResult(morph.cpos).TN += missing
+	}
+}
+
+// AttachmentScores are the attachment scores of a set of words
+type AttachmentScores struct {
+	Words    int     `json:"words"`
+	LAS      float64 `json:"las"`
+	UAS      float64 `json:"uas"`
+	LabelAcc float64 `json:"label_acc"`
+}
+
+func NewAttachmentScores(r *DepResult) *AttachmentScores {
+	return &AttachmentScores{r.LAS.All(), r.LAS.Precision(), r.UAS.Precision(), r.LA.Precision()}
+}
+
+type DepEvalReport struct {
+	AttachmentScores
+	ExactMatch    float64                      `json:"exact_match"`
+	Mismatched    int                          `json:"mismatched_sentences"`
+	UnalignedGold int                          `json:"unaligned_gold_words"`
+	UnalignedPred int                          `json:"unaligned_pred_words"`
+	ByLabel       map[string]*AttachmentScores `json:"by_label"`
+	ByPOS         map[string]*AttachmentScores `json:"by_pos"`
+}
+
+// MorphScores are the scores of morphemes projected by a param func
+type MorphScores struct {
+	Gold       int     `json:"gold"`
+	Pred       int     `json:"pred"`
+	Correct    int     `json:"correct"`
+	Precision  float64 `json:"precision"`
+	Recall     float64 `json:"recall"`
+	F1         float64 `json:"f1"`
+	ExactMatch float64 `json:"exact_match,omitempty"`
+}
+
+func NewMorphScores(r *eval.Result) *MorphScores {
+	scores := &MorphScores{Gold: r.ConditionPositives(), Pred: r.TestPositives(), Correct: r.TP}
+	if r.TP > 0 {
+		scores.Precision, scores.Recall, scores.F1 = r.Precision(), r.Recall(), r.F1()
+	}
+	return scores
+}
+
+type MorphEvalReport struct {
+	Metrics []string                `json:"metrics"`
+	Token   map[string]*MorphScores `json:"token"`
+	Lattice map[string]*MorphScores `json:"lattice"`
+	ByPOS   map[string]*MorphScores `json:"by_pos"`
+}
+
+type EvalReport struct {
+	Sentences int              `json:"sentences"`
+	Dep       *DepEvalReport   `json:"dependency,omitempty"`
+	Morph     *MorphEvalReport `json:"morphology,omitempty"`
+}
+
+// DepEvalCorpus evaluates the attachment scores of a predicted corpus
+// against its gold corpus, returning the totals of its sentences' results
+// along with the report
+func DepEvalCorpus(pred, gold []EvalSentence) (*DepEvalReport, *eval.Total) {
+	byLabel, byPOS := make(map[string]*DepResult), make(map[string]*DepResult)
+	total, utotal, ltotal := &eval.Total{Results: make([]*eval.Result, 0, len(gold))}, &eval.Total{}, &eval.Total{}
+	report := &DepEvalReport{ByLabel: make(map[string]*AttachmentScores), ByPOS: make(map[string]*AttachmentScores)}
+	for i, goldSent := range gold {
+		result := DepEvalSentence(pred[i], goldSent, byLabel, byPOS)
+		total.Add(result.LAS)
+		if result.UnalignedGold > 0 || result.UnalignedPred > 0 {
+			report.Mismatched++
+			report.UnalignedGold += result.UnalignedGold
+			report.UnalignedPred += result.UnalignedPred
+			// a differently segmented sentence is not an exact match
+			if result.LAS.Incorrect() == 0 {
+				total.Exact--
+			}
+		}
+		utotal.Add(result.UAS)
+		ltotal.Add(result.LA)
+	}
+	report.AttachmentScores = *NewAttachmentScores(&DepResult{LAS: &total.Result, UAS: &utotal.Result, LA: &ltotal.Result})
+	report.ExactMatch = total.ExactMatch()
+	for label, result := range byLabel {
+		report.ByLabel[label] = NewAttachmentScores(result)
+	}
+	for pos, result := range byPOS {
+		report.ByPOS[pos] = NewAttachmentScores(result)
+	}
+	return report, total
+}
+
+// MorphEvalCorpus evaluates the morphemes of a predicted corpus against its
+// gold corpus by each metric, token and lattice aligned
+func MorphEvalCorpus(pred, gold []EvalSentence, metrics []string) *MorphEvalReport {
+	report := &MorphEvalReport{
+		Metrics: metrics,
+		Token:   make(map[string]*MorphScores, len(metrics)),
+		Lattice: make(map[string]*MorphScores, len(metrics)),
+		ByPOS:   make(map[string]*MorphScores),
+	}
+	for _, metric := range metrics {
+		for _, aligned := range []struct {
+			scores  map[string]*MorphScores
+			lattice bool
+		}{{report.Token, false}, {report.Lattice, true}} {
+			total := &eval.Total{}
+			var exact int
+			for i, goldSent := range gold {
+				result := MorphEvalSentence(pred[i], goldSent, metric, aligned.lattice)
+				if result.FP == 0 && result.TN == 0 {
+					exact++
+				}
+				total.Add(result)
+			}
+			aligned.scores[metric] = NewMorphScores(&total.Result)
+			aligned.scores[metric].ExactMatch = float64(exact) / float64(len(gold))
+		}
+	}
+	byPOS := make(map[string]*eval.Result)
+	for i, goldSent := range gold {
+		MorphPOSEvalSentence(pred[i], goldSent, byPOS)
+	}
+	for pos, result := range byPOS {
+		report.ByPOS[pos] = NewMorphScores(result)
+	}
+	return report
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch typed := m.(type) {
+	case map[string]*AttachmentScores:
+		for k := range typed {
+			keys = append(keys, k)
+		}
+	case map[string]*MorphScores:
+		for k := range typed {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r *EvalReport) Log() {
+	log.Println("Sentences:", r.Sentences)
+	if dep := r.Dep; dep != nil {
+		log.Println()
+		log.Printf("Words:\t%d", dep.Words)
+		log.Printf("LAS:\t%.4f", dep.LAS)
+		log.Printf("UAS:\t%.4f", dep.UAS)
+		log.Printf("LA:\t%.4f", dep.LabelAcc)
+		log.Printf("EM:\t%.4f", dep.ExactMatch)
+		if dep.Mismatched > 0 {
+			log.Println(dep.Mismatched, "sentences have tokens segmented differently from gold, their", dep.UnalignedGold, "gold and", dep.UnalignedPred, "predicted words are not scored")
+		}
+		for _, breakdown := range []struct {
+			name   string
+			scores map[string]*AttachmentScores
+		}{{"Label", dep.ByLabel}, {"POS", dep.ByPOS}} {
+			log.Println()
+			log.Printf("%-12s\t%s\t%s\t%s\t%s", breakdown.name, "Words", "LAS", "UAS", "LA")
+			for _, key := range sortedKeys(breakdown.scores) {
+				s := breakdown.scores[key]
+				log.Printf("%-12s\t%d\t%.4f\t%.4f\t%.4f", key, s.Words, s.LAS, s.UAS, s.LabelAcc)
+			}
+		}
+	}
+	if morph := r.Morph; morph != nil {
+		for _, aligned := range []struct {
+			name   string
+			scores map[string]*MorphScores
+		}{{"Token aligned", morph.Token}, {"Lattice aligned", morph.Lattice}} {
+			log.Println()
+			log.Printf("%-16s\t%s\t%s\t%s\t%s\t%s\t%s\t%s", aligned.name, "Gold", "Pred", "Correct", "P", "R", "F1", "EM")
+			for _, metric := range morph.Metrics {
+				s := aligned.scores[metric]
+				log.Printf("%-16s\t%d\t%d\t%d\t%.4f\t%.4f\t%.4f\t%.4f", metric, s.Gold, s.Pred, s.Correct, s.Precision, s.Recall, s.F1, s.ExactMatch)
+			}
+		}
+		log.Println()
+		log.Printf("%-16s\t%s\t%s\t%s\t%s\t%s\t%s", "POS (Form_POS)", "Gold", "Pred", "Correct", "P", "R", "F1")
+		for _, pos := range sortedKeys(morph.ByPOS) {
+			s := morph.ByPOS[pos]
+			log.Printf("%-16s\t%d\t%d\t%d\t%.4f\t%.4f\t%.4f", pos, s.Gold, s.Pred, s.Correct, s.Precision, s.Recall, s.F1)
+		}
+	}
+}
+
+// setMappingTokens sets the tokens and lattice edges of the words of a
+// CoNLL corpus to those of the morphemes of its mapping corpus, for the
+// sentences of the same forms in both
+func setMappingTokens(corpus, mappings []EvalSentence) {
+	for i, sent := range corpus {
+		if len(sent) != len(mappings[i]) {
+			continue
+		}
+		same := true
+		for j, word := range sent {
+			if word.Form != mappings[i][j].Form {
+				same = false
+				break
+			}
+		}
+		if !same {
+			continue
+		}
+		for j, word := range sent {
+			word.TokenID, word.BasicDirectedEdge = mappings[i][j].TokenID, mappings[i][j].BasicDirectedEdge
+		}
+	}
+}
+
+// readEvalPair reads a predicted and a gold file with a reader, verifying
+// they have the same number of sentences
+func readEvalPair(predFile, goldFile string, reader func(string) []EvalSentence) ([]EvalSentence, []EvalSentence) {
+	pred, gold := reader(predFile), reader(goldFile)
+	if len(pred) != len(gold) {
+		log.Fatalln("Predicted file", predFile, "has", len(pred), "sentences, gold file", goldFile, "has", len(gold))
+	}
+	return pred, gold
+}
+
+// WriteEvalJSON writes a report as indented JSON
+func WriteEvalJSON(filename string, report interface{}) {
+	file, err := os.Create(filename)
+	if err != nil {
+		log.Fatalln("Failed creating JSON output file", filename, err)
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalln("Failed writing JSON output", err)
+	}
+	log.Println("Wrote report in json to", filename)
+}
+
+func EvalConfigOut() {
+	log.Println("Configuration")
+	log.Printf("Metrics:\t\t%s", evalMetrics)
+	log.Printf("No punctuation:\t%v", evalNoPunct)
+	log.Println()
+	log.Println("Data")
+	if len(input) > 0 {
+		log.Printf("Predicted:\t\t%s", input)
+		log.Printf("Gold:\t\t\t%s", inputGold)
+	}
+	if len(evalPredMapFile) > 0 {
+		log.Printf("Predicted mapping:\t%s", evalPredMapFile)
+		log.Printf("Gold mapping:\t\t%s", evalGoldMapFile)
+	}
+	if len(evalJSONFile) > 0 {
+		log.Printf("Out (json):\t\t%s", evalJSONFile)
+	}
+	log.Println()
+}
+
+func Eval(cmd *commander.Command, args []string) error {
+	if len(input) == 0 && len(evalPredMapFile) == 0 {
+		log.Println("Evaluation requires predicted and gold CoNLL (-p, -g) or mapping (-pm, -gm) files")
+		VerifyFlags(cmd, []string{"p", "g"})
+	}
+	if len(input) > 0 {
+		VerifyFlags(cmd, []string{"g"})
+	}
+	if len(evalPredMapFile) > 0 {
+		VerifyFlags(cmd, []string{"gm"})
+	}
+	for _, file := range []string{input, inputGold, evalPredMapFile, evalGoldMapFile} {
+		if len(file) > 0 && !VerifyExists(file) {
+			os.Exit(1)
+		}
+	}
+	metrics := strings.Split(evalMetrics, ",")
+	for _, metric := range metrics {
+		if _, exists := nlp.MDParams[metric]; !exists {
+			log.Fatalln("Unknown param func", metric, "options are", nlp.AllParamFuncNames)
+		}
+	}
+	EvalConfigOut()
+	if useConllU {
+		nlp.InitOpenParamFamily("UD")
+	} else {
+		nlp.InitOpenParamFamily("HEBTB")
+	}
+
+	report := &EvalReport{}
+	var morphPred, morphGold []EvalSentence
+	if len(evalPredMapFile) > 0 {
+		morphPred, morphGold = readEvalPair(evalPredMapFile, evalGoldMapFile, ReadEvalMapping)
+		report.Sentences = len(morphGold)
+	}
+	if len(input) > 0 {
+		reader := ReadEvalConll
+		if useConllU {
+			reader = ReadEvalConllU
+		}
+		pred, gold := readEvalPair(input, inputGold, reader)
+		if morphGold != nil {
+			if len(morphGold) != len(gold) {
+				log.Fatalln("Mapping files have", len(morphGold), "sentences, CoNLL files have", len(gold))
+			}
+			setMappingTokens(pred, morphPred)
+			setMappingTokens(gold, morphGold)
+		} else {
+			morphPred, morphGold = pred, gold
+		}
+		report.Sentences = len(gold)
+		report.Dep, _ = DepEvalCorpus(pred, gold)
+	}
+	report.Morph = MorphEvalCorpus(morphPred, morphGold, metrics)
+	report.Log()
+
+	if len(evalJSONFile) > 0 {
+		WriteEvalJSON(evalJSONFile, report)
+	}
+	return nil
+}
+
+func EvalCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       Eval,
+		UsageLine: "eval <file options> [arguments]",
+		Short:     "evaluates parser and morphological disambiguation outputs against gold files",
+		Long: `
+evaluates predicted CoNLL/CoNLL-U and mapping files against gold files
+
+	$ ./yap eval -p <conll> -g <conll> [-pm <mapping> -gm <mapping>] [options]
+
+Reports LAS, UAS, label accuracy and exact match of CoNLL(-U) files, with
+breakdowns by gold label and POS, and precision, recall and F1 of
+morphemes by the param funcs of -metrics, aligned by token (as in training
+convergence) and by lattice edge. Morphemes are read from the mapping
+files if given, otherwise from the CoNLL(-U) files.
+
+Attachment scores are of the words of tokens segmented as in gold, by the
+multi-word tokens of CoNLL-U files or the tokens of the mapping files if
+given; the words of differently segmented tokens are reported as
+unaligned.
+
+	$ ./yap eval compare -p1 <conll> -p2 <conll> -g <conll> [options]
+
+tests the significance of the difference between two systems' outputs.
+
+	$ ./yap eval errors -p <conll> -g <conll> [options]
+
+analyzes the errors of a dependency parser's output.
+
+	$ ./yap eval md -pm <mapping> -gm <mapping> [options]
+
+analyzes the errors of morphological disambiguation output.
+`,
+		Flag:        *flag.NewFlagSet("eval", flag.ExitOnError),
+		Subcommands: []*commander.Command{EvalCompareCmd(), EvalErrorsCmd(), EvalMDErrorsCmd()},
+	}
+	cmd.Flag.StringVar(&input, "p", "", "Predicted CoNLL File")
+	cmd.Flag.StringVar(&inputGold, "g", "", "Gold CoNLL File")
+	cmd.Flag.BoolVar(&useConllU, "conllu", false, "Read CoNLL-U files (-p, -g)")
+	cmd.Flag.StringVar(&evalPredMapFile, "pm", "", "Predicted Mapping File")
+	cmd.Flag.StringVar(&evalGoldMapFile, "gm", "", "Gold Mapping (Disambiguated Lattice) File")
+	cmd.Flag.StringVar(&evalMetrics, "metrics", "Form,Form_POS,Form_POS_Prop", "Comma separated param funcs to evaluate morphemes by (segmentation, POS, features): ["+nlp.AllParamFuncNames+"]")
+	cmd.Flag.BoolVar(&evalNoPunct, "nopunct", false, "Exclude punctuation from attachment scores")
+	cmd.Flag.StringVar(&evalJSONFile, "json", "", "Optional - Output JSON File")
+	return cmd
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+// depSentence is a sentence of words of the given heads and labels, each
+// its own token
+func depSentence(heads []int, labels []string) EvalSentence {
+	sent := make(EvalSentence, len(heads))
+	for i := range heads {
+		sent[i] = newEvalWord(i, i, i+1, i, "w", "w", "NN", "NN", "_", heads[i], labels[i])
+	}
+	return sent
+}
+
+func TestDepEvalSentence(t *testing.T) {
+	defer func(noPunct bool) { evalNoPunct = noPunct }(evalNoPunct)
+	evalNoPunct = false
+	gold := depSentence([]int{2, 0, 2, 3}, []string{"subj", "root", "obj", "det"})
+	// a wrong head of a right label and a wrong label of a right head
+	pred := depSentence([]int{2, 0, 1, 3}, []string{"subj", "root", "obj", "amod"})
+	byLabel := make(map[string]*DepResult)
+	result := DepEvalSentence(pred, gold, byLabel, nil)
+	for _, score := range []struct {
+		name              string
+		correct, expected int
+	}{{"LAS", result.LAS.TP, 2}, {"UAS", result.UAS.TP, 3}, {"LA", result.LA.TP, 3}} {
+		if score.correct != score.expected {
+			t.Errorf("Got %s %d correct of 4, expected %d", score.name, score.correct, score.expected)
+		}
+	}
+	if result.LAS.All() != 4 {
+		t.Errorf("Got %d evaluated words, expected 4", result.LAS.All())
+	}
+	if obj := byLabel["obj"]; obj == nil || obj.LAS.TP != 0 || obj.LA.TP != 1 || byLabel["subj"].LAS.TP != 1 {
+		t.Errorf("Got wrong breakdown by label %v", byLabel)
+	}
+
+	// the words of a missing token are unaligned
+	result = DepEvalSentence(gold[:3], gold, nil, nil)
+	if result.UAS.TP != 3 || result.UAS.FP != 0 || result.UnalignedGold != 1 || result.UnalignedPred != 0 {
+		t.Errorf("Got %d correct, %d incorrect, %d and %d unaligned of a truncated sentence, expected 3 0 1 0",
+			result.UAS.TP, result.UAS.FP, result.UnalignedGold, result.UnalignedPred)
+	}
+}
+
+func TestDepEvalSentenceSegmentation(t *testing.T) {
+	defer func(noPunct bool) { evalNoPunct = noPunct }(evalNoPunct)
+	evalNoPunct = false
+	// b+bit gdol mAod: "b" and "gdol" attach to "bit", "mAod" to "gdol"
+	gold := EvalSentence{
+		newEvalWord(0, 0, 1, 0, "b", "b", "IN", "IN", "_", 2, "prepmod"),
+		newEvalWord(1, 1, 2, 0, "bit", "bit", "NN", "NN", "_", 0, "root"),
+		newEvalWord(2, 2, 3, 1, "gdol", "gdol", "JJ", "JJ", "_", 2, "amod"),
+		newEvalWord(3, 3, 4, 2, "mAod", "mAod", "RB", "RB", "_", 3, "advmod"),
+	}
+	// bbit unsegmented, gdol attached to it
+	pred := EvalSentence{
+		newEvalWord(0, 0, 1, 0, "bbit", "bbit", "NN", "NN", "_", 0, "root"),
+		newEvalWord(1, 1, 2, 1, "gdol", "gdol", "JJ", "JJ", "_", 1, "amod"),
+		newEvalWord(2, 2, 3, 2, "mAod", "mAod", "RB", "RB", "_", 2, "advmod"),
+	}
+	if alignment := AlignWords(pred, gold); !reflect.DeepEqual(alignment, []int{-1, -1, 1, 2}) {
+		t.Errorf("Got alignment %v, expected [-1 -1 1 2]", alignment)
+	}
+	// gdol's predicted head is not aligned to its gold head, mAod's is
+	result := DepEvalSentence(pred, gold, nil, nil)
+	if result.LAS.All() != 2 || result.LAS.TP != 1 || result.LA.TP != 2 || result.UnalignedGold != 2 || result.UnalignedPred != 1 {
+		t.Errorf("Got %d scored words of LAS %d LA %d, %d and %d unaligned, expected 2 of 1 2, 2 and 1 unaligned",
+			result.LAS.All(), result.LAS.TP, result.LA.TP, result.UnalignedGold, result.UnalignedPred)
+	}
+
+	report, _ := DepEvalCorpus([]EvalSentence{pred, gold}, []EvalSentence{gold, gold})
+	if report.Words != 6 || report.ExactMatch != 0.5 || report.Mismatched != 1 || report.UnalignedGold != 2 || report.UnalignedPred != 1 {
+		t.Errorf("Got %d words of exact match %v, %d mismatched sentences of %d and %d unaligned words, expected 6 of 0.5, 1 of 2 and 1",
+			report.Words, report.ExactMatch, report.Mismatched, report.UnalignedGold, report.UnalignedPred)
+	}
+}
+
+func TestDepEvalCorpus(t *testing.T) {
+	defer func(noPunct bool) { evalNoPunct = noPunct }(evalNoPunct)
+	evalNoPunct = false
+	gold := []EvalSentence{
+		depSentence([]int{2, 0}, []string{"subj", "root"}),
+		depSentence([]int{2, 0}, []string{"subj", "root"}),
+	}
+	pred := []EvalSentence{
+		depSentence([]int{2, 0}, []string{"subj", "root"}),
+		depSentence([]int{0, 0}, []string{"subj", "root"}),
+	}
+	report, total := DepEvalCorpus(pred, gold)
+	if report.Words != 4 || report.LAS != 0.75 || report.UAS != 0.75 || report.LabelAcc != 1 {
+		t.Errorf("Got %d words of LAS %v UAS %v label accuracy %v, expected 4 of 0.75 0.75 1", report.Words, report.LAS, report.UAS, report.LabelAcc)
+	}
+	if report.ExactMatch != 0.5 || report.Mismatched != 0 || len(total.Results) != 2 {
+		t.Errorf("Got exact match %v of %d results (%d mismatched), expected 0.5 of 2", report.ExactMatch, len(total.Results), report.Mismatched)
+	}
+}
+
+func TestMorphEvalSentence(t *testing.T) {
+	// two tokens, the first of a prefix: b+bit gdol
+	gold := EvalSentence{
+		newEvalWord(0, 0, 1, 0, "b", "b", "IN", "IN", "_", 0, ""),
+		newEvalWord(1, 1, 2, 0, "bit", "bit", "NN", "NN", "_", 0, ""),
+		newEvalWord(2, 2, 3, 1, "gdol", "gdol", "JJ", "JJ", "_", 0, ""),
+	}
+	// the right spellouts, the second token on a different lattice edge
+	shifted := EvalSentence{gold[0], gold[1], newEvalWord(2, 3, 4, 1, "gdol", "gdol", "JJ", "JJ", "_", 0, "")}
+	// the first token unsegmented
+	unsegmented := EvalSentence{newEvalWord(0, 0, 2, 0, "bbit", "bbit", "NN", "NN", "_", 0, ""), gold[2]}
+
+	for _, test := range []struct {
+		name           string
+		pred           EvalSentence
+		latticeAligned bool
+		TP, FP, TN     int
+	}{
+		{"same", gold, true, 3, 0, 0},
+		{"shifted token aligned", shifted, false, 3, 0, 0},
+		{"shifted lattice aligned", shifted, true, 2, 1, 1},
+		{"unsegmented token aligned", unsegmented, false, 1, 1, 2},
+		{"unsegmented lattice aligned", unsegmented, true, 1, 1, 2},
+	} {
+		result := MorphEvalSentence(test.pred, gold, "Form_POS", test.latticeAligned)
+		if result.TP != test.TP || result.FP != test.FP || result.TN != test.TN {
+			t.Errorf("%s: got TP %d FP %d TN %d, expected %d %d %d", test.name, result.TP, result.FP, result.TN, test.TP, test.FP, test.TN)
+		}
+	}
+
+	report := MorphEvalCorpus([]EvalSentence{unsegmented}, []EvalSentence{gold}, []string{"Form_POS"})
+	if scores := report.Token["Form_POS"]; scores.Gold != 3 || scores.Pred != 2 || scores.Correct != 1 || scores.ExactMatch != 0 {
+		t.Errorf("Got token aligned scores %+v, expected 1 correct of 2 predicted and 3 gold", scores)
+	}
+	if in, nn := report.ByPOS["IN"], report.ByPOS["NN"]; in == nil || in.Gold != 1 || in.Correct != 0 || nn == nil || nn.Pred != 1 {
+		t.Errorf("Got breakdown by POS IN %+v NN %+v", in, nn)
+	}
+}
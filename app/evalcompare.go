@@ -0,0 +1,188 @@
+package app
+
+import (
+	"yap/eval"
+	nlp "yap/nlp/types"
+
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+const COMPARE_CONFIDENCE = 0.95
+
+var (
+	comparePredFiles    [2]string
+	comparePredMapFiles [2]string
+	compareSamples      int
+	compareSeed         int64
+)
+
+// A ComparisonReport holds the significance of the differences between the
+// scores of two systems by score name
+type ComparisonReport struct {
+	Sentences  int                           `json:"sentences"`
+	Samples    int                           `json:"samples"`
+	Confidence float64                       `json:"confidence"`
+	Scores     []string                      `json:"scores"`
+	Comparison map[string]*eval.Significance `json:"comparison"`
+}
+
+func (r *ComparisonReport) add(name string, a, b []*eval.Result, score eval.Score, rng *rand.Rand) {
+	r.Scores = append(r.Scores, name)
+	r.Comparison[name] = eval.Compare(a, b, score, r.Samples, r.Confidence, rng)
+}
+
+func (r *ComparisonReport) Log() {
+	log.Println("Sentences:", r.Sentences)
+	log.Println("Samples:", r.Samples)
+	log.Println()
+	log.Printf("%-22s\t%s\t%s\t%s\t%-17s\t%-17s\t%-17s\t%s\t%s", "Score", "A", "B", "A-B", "A CI", "B CI", "A-B CI", "p (boot)", "p (rand)")
+	for _, name := range r.Scores {
+		s := r.Comparison[name]
+		log.Printf("%-22s\t%.4f\t%.4f\t%+.4f\t[%.4f, %.4f]\t[%.4f, %.4f]\t[%+.4f, %+.4f]\t%.4f\t%.4f",
+			name, s.A, s.B, s.Diff, s.ACI.Low, s.ACI.High, s.BCI.Low, s.BCI.High, s.DiffCI.Low, s.DiffCI.High, s.BootstrapP, s.RandP)
+	}
+}
+
+// depSentenceResults returns the LAS and UAS results of each sentence
+func depSentenceResults(pred, gold []EvalSentence) ([]*eval.Result, []*eval.Result) {
+	las, uas := make([]*eval.Result, len(gold)), make([]*eval.Result, len(gold))
+	for i, goldSent := range gold {
+		result := DepEvalSentence(pred[i], goldSent, nil, nil)
+		las[i], uas[i] = result.LAS, result.UAS
+	}
+	return las, uas
+}
+
+// morphSentenceResults returns the token aligned results of each sentence
+func morphSentenceResults(pred, gold []EvalSentence, metric string) []*eval.Result {
+	results := make([]*eval.Result, len(gold))
+	for i, goldSent := range gold {
+		results[i] = MorphEvalSentence(pred[i], goldSent, metric, false)
+	}
+	return results
+}
+
+func EvalCompare(cmd *commander.Command, args []string) error {
+	if len(inputGold) == 0 && len(evalGoldMapFile) == 0 {
+		log.Println("Comparison requires two predicted and a gold CoNLL (-p1, -p2, -g) or mapping (-pm1, -pm2, -gm) files")
+		VerifyFlags(cmd, []string{"p1", "p2", "g"})
+	}
+	if len(inputGold) > 0 {
+		VerifyFlags(cmd, []string{"p1", "p2"})
+	}
+	if len(evalGoldMapFile) > 0 {
+		VerifyFlags(cmd, []string{"pm1", "pm2"})
+	}
+	for _, file := range []string{comparePredFiles[0], comparePredFiles[1], inputGold, comparePredMapFiles[0], comparePredMapFiles[1], evalGoldMapFile} {
+		if len(file) > 0 && !VerifyExists(file) {
+			os.Exit(1)
+		}
+	}
+	if compareSamples < 1 {
+		log.Fatalln("Comparison requires at least one sample, got", compareSamples)
+	}
+	metrics := strings.Split(evalMetrics, ",")
+	for _, metric := range metrics {
+		if _, exists := nlp.MDParams[metric]; !exists {
+			log.Fatalln("Unknown param func", metric, "options are", nlp.AllParamFuncNames)
+		}
+	}
+	log.Println("Configuration")
+	log.Printf("Metrics:\t\t%s", evalMetrics)
+	log.Printf("No punctuation:\t%v", evalNoPunct)
+	log.Printf("Samples:\t\t%d", compareSamples)
+	log.Printf("Seed:\t\t\t%d", compareSeed)
+	log.Println()
+	log.Println("Data")
+	if len(inputGold) > 0 {
+		log.Printf("Predicted (A):\t\t%s", comparePredFiles[0])
+		log.Printf("Predicted (B):\t\t%s", comparePredFiles[1])
+		log.Printf("Gold:\t\t\t%s", inputGold)
+	}
+	if len(evalGoldMapFile) > 0 {
+		log.Printf("Predicted mapping (A):\t%s", comparePredMapFiles[0])
+		log.Printf("Predicted mapping (B):\t%s", comparePredMapFiles[1])
+		log.Printf("Gold mapping:\t\t%s", evalGoldMapFile)
+	}
+	log.Println()
+	if useConllU {
+		nlp.InitOpenParamFamily("UD")
+	} else {
+		nlp.InitOpenParamFamily("HEBTB")
+	}
+
+	rng := rand.New(rand.NewSource(compareSeed))
+	report := &ComparisonReport{Samples: compareSamples, Confidence: COMPARE_CONFIDENCE, Comparison: make(map[string]*eval.Significance)}
+	var morphPred [2][]EvalSentence
+	var morphGold []EvalSentence
+	if len(inputGold) > 0 {
+		reader := ReadEvalConll
+		if useConllU {
+			reader = ReadEvalConllU
+		}
+		predA, gold := readEvalPair(comparePredFiles[0], inputGold, reader)
+		predB, _ := readEvalPair(comparePredFiles[1], inputGold, reader)
+		report.Sentences = len(gold)
+		lasA, uasA := depSentenceResults(predA, gold)
+		lasB, uasB := depSentenceResults(predB, gold)
+		report.add("LAS", lasA, lasB, eval.PrecisionScore, rng)
+		report.add("UAS", uasA, uasB, eval.PrecisionScore, rng)
+		morphPred, morphGold = [2][]EvalSentence{predA, predB}, gold
+	}
+	if len(evalGoldMapFile) > 0 {
+		var gold []EvalSentence
+		morphPred[0], gold = readEvalPair(comparePredMapFiles[0], evalGoldMapFile, ReadEvalMapping)
+		morphPred[1], _ = readEvalPair(comparePredMapFiles[1], evalGoldMapFile, ReadEvalMapping)
+		if report.Sentences > 0 && report.Sentences != len(gold) {
+			log.Fatalln("Mapping files have", len(gold), "sentences, CoNLL files have", report.Sentences)
+		}
+		report.Sentences, morphGold = len(gold), gold
+	}
+	for _, metric := range metrics {
+		report.add("F1 "+metric, morphSentenceResults(morphPred[0], morphGold, metric), morphSentenceResults(morphPred[1], morphGold, metric), eval.F1Score, rng)
+	}
+	report.Log()
+
+	if len(evalJSONFile) > 0 {
+		WriteEvalJSON(evalJSONFile, report)
+	}
+	return nil
+}
+
+func EvalCompareCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       EvalCompare,
+		UsageLine: "compare <file options> [arguments]",
+		Short:     "tests the significance of the difference between two systems' outputs",
+		Long: `
+compares two systems' predicted files (A and B) against a gold file
+
+	$ ./yap eval compare -p1 <conll> -p2 <conll> -g <conll> [-pm1 <mapping> -pm2 <mapping> -gm <mapping>] [options]
+
+Reports the LAS, UAS and (token aligned) morphological F1 of each system and
+their difference, with 95% confidence intervals by paired bootstrap
+resampling of sentences, and p-values of the difference by paired bootstrap
+and approximate randomization (both two sided).
+`,
+		Flag: *flag.NewFlagSet("compare", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&comparePredFiles[0], "p1", "", "Predicted CoNLL File of system A")
+	cmd.Flag.StringVar(&comparePredFiles[1], "p2", "", "Predicted CoNLL File of system B")
+	cmd.Flag.StringVar(&inputGold, "g", "", "Gold CoNLL File")
+	cmd.Flag.BoolVar(&useConllU, "conllu", false, "Read CoNLL-U files (-p1, -p2, -g)")
+	cmd.Flag.StringVar(&comparePredMapFiles[0], "pm1", "", "Predicted Mapping File of system A")
+	cmd.Flag.StringVar(&comparePredMapFiles[1], "pm2", "", "Predicted Mapping File of system B")
+	cmd.Flag.StringVar(&evalGoldMapFile, "gm", "", "Gold Mapping (Disambiguated Lattice) File")
+	cmd.Flag.StringVar(&evalMetrics, "metrics", "Form,Form_POS,Form_POS_Prop", "Comma separated param funcs to compare morphological F1 by: ["+nlp.AllParamFuncNames+"]")
+	cmd.Flag.BoolVar(&evalNoPunct, "nopunct", false, "Exclude punctuation from attachment scores")
+	cmd.Flag.IntVar(&compareSamples, "samples", 10000, "Number of bootstrap samples and randomization shuffles")
+	cmd.Flag.Int64Var(&compareSeed, "seed", 1, "Random seed for resampling")
+	cmd.Flag.StringVar(&evalJSONFile, "json", "", "Optional - Output JSON File")
+	return cmd
+}
@@ -0,0 +1,414 @@
+package app
+
+import (
+	"yap/eval"
+	dep "yap/nlp/parser/dependency/transition"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+const (
+	ERR_HEAD       = "head"
+	ERR_LABEL      = "label"
+	ERR_HEAD_LABEL = "head+label"
+)
+
+var (
+	// attachment distance and sentence length buckets, by their upper
+	// bounds
+	errDistanceBuckets = []int{1, 2, 6}
+	errLengthBuckets   = []int{10, 20, 30, 40}
+)
+
+// A DepError is an incorrectly attached word of a parsed sentence
+type DepError struct {
+	Sentence int    `json:"sentence"`
+	Word     int    `json:"word"`
+	Form     string `json:"form"`
+	GoldHead int    `json:"gold_head"`
+	PredHead int    `json:"pred_head"`
+	GoldRel  string `json:"gold_rel"`
+	PredRel  string `json:"pred_rel"`
+	Type     string `json:"type"`
+}
+
+var _ eval.Error = &DepError{}
+
+func (e *DepError) String() string {
+	return fmt.Sprintf("%d:%d %s %s:%d-%s -> %d-%s", e.Sentence, e.Word, e.Form, e.Type, e.GoldHead, e.GoldRel, e.PredHead, e.PredRel)
+}
+
+func (e *DepError) Class() string {
+	return e.Type
+}
+
+// ErrorCounts are the numbers of words of a category and of their errors
+type ErrorCounts struct {
+	Words       int `json:"words"`
+	Errors      int `json:"errors"`
+	HeadErrors  int `json:"head_errors"`
+	LabelErrors int `json:"label_errors"`
+}
+
+func (c *ErrorCounts) add(err *DepError) {
+	c.Words++
+	if err == nil {
+		return
+	}
+	c.Errors++
+	if err.Type != ERR_LABEL {
+		c.HeadErrors++
+	}
+	if err.Type != ERR_HEAD {
+		c.LabelErrors++
+	}
+}
+
+type ErrorBreakdown map[string]*ErrorCounts
+
+func (b ErrorBreakdown) add(key string, err *DepError) {
+	if _, exists := b[key]; !exists {
+		b[key] = &ErrorCounts{}
+	}
+	b[key].add(err)
+}
+
+type RootErrors struct {
+	GoldRoots  int `json:"gold_roots"`
+	Correct    int `json:"correct"`
+	Missed     int `json:"missed"`
+	FalseRoots int `json:"false_roots"`
+}
+
+// ProjectivityErrors count non-projective (crossing) arcs of the gold and
+// predicted parses, and the errors on gold non-projective arcs
+type ProjectivityErrors struct {
+	GoldArcs   int `json:"gold_nonprojective_arcs"`
+	GoldErrors int `json:"gold_nonprojective_errors"`
+	PredArcs   int `json:"pred_nonprojective_arcs"`
+	PredWrong  int `json:"pred_nonprojective_wrong"`
+}
+
+type DepErrorReport struct {
+	Sentences      int                       `json:"sentences"`
+	Mismatched     int                       `json:"mismatched_sentences"`
+	Words          int                       `json:"words"`
+	Errors         int                       `json:"errors"`
+	ByType         map[string]int            `json:"by_type"`
+	ByRelation     ErrorBreakdown            `json:"by_relation"`
+	ByDistance     ErrorBreakdown            `json:"by_distance"`
+	ByHeadPOS      ErrorBreakdown            `json:"by_head_pos"`
+	ByDependentPOS ErrorBreakdown            `json:"by_dependent_pos"`
+	ByLength       ErrorBreakdown            `json:"by_length"`
+	Root           RootErrors                `json:"root"`
+	Projectivity   ProjectivityErrors        `json:"projectivity"`
+	Confusion      map[string]map[string]int `json:"confusion"`
+	List           []*DepError               `json:"errors_list"`
+}
+
+func bucket(value int, bounds []int) string {
+	low := 1
+	for _, bound := range bounds {
+		if value <= bound {
+			if low == bound {
+				return fmt.Sprintf("%d", bound)
+			}
+			return fmt.Sprintf("%d-%d", low, bound)
+		}
+		low = bound + 1
+	}
+	return fmt.Sprintf("%d+", low)
+}
+
+// evalArcSet returns the arcs of a sentence's words, whose ids and heads
+// are 1-based (0 is the root)
+func evalArcSet(sent EvalSentence) *dep.ArcSetSimple {
+	arcs := dep.NewArcSetSimple(len(sent))
+	for i, word := range sent {
+		arcs.Add(&dep.BasicDepArc{Head: word.Head, Modifier: i + 1, RawRelation: nlp.DepRel(word.DepRel)})
+	}
+	return arcs
+}
+
+// nonProjective returns the modifiers of the arcs of a sentence crossing
+// another arc
+func nonProjective(sent EvalSentence) map[int]bool {
+	crossing := make(map[int]bool)
+	span := func(i int) (int, int) {
+		if head := sent[i].Head; head < i+1 {
+			return head, i + 1
+		}
+		return i + 1, sent[i].Head
+	}
+	for i := range sent {
+		l1, r1 := span(i)
+		for j := i + 1; j < len(sent); j++ {
+			l2, r2 := span(j)
+			if (l1 < l2 && l2 < r1 && r1 < r2) || (l2 < l1 && l1 < r2 && r2 < r1) {
+				crossing[i+1], crossing[j+1] = true, true
+			}
+		}
+	}
+	return crossing
+}
+
+// DepErrorsSentence classifies the errors of a predicted sentence, the
+// arcs of its gold sentence missing from it, into the report. Returns the
+// sentence's result, whose TP are its correct words and FP its errors
+func DepErrorsSentence(report *DepErrorReport, sentID int, pred, gold EvalSentence) *eval.Result {
+	result := &eval.Result{}
+	if len(pred) != len(gold) {
+		report.Mismatched++
+		return result
+	}
+	goldOnly, _ := evalArcSet(gold).Diff(evalArcSet(pred))
+	errors := make(map[int]*DepError, goldOnly.Size())
+	for _, arc := range goldOnly.(*dep.ArcSetSimple).Arcs {
+		word := arc.GetModifier()
+		predWord := pred[word-1]
+		err := &DepError{
+			Sentence: sentID,
+			Word:     word,
+			Form:     gold[word-1].Form,
+			GoldHead: arc.GetHead(),
+			PredHead: predWord.Head,
+			GoldRel:  string(arc.GetRelation()),
+			PredRel:  predWord.DepRel,
+			Type:     ERR_HEAD_LABEL,
+		}
+		if err.GoldHead == err.PredHead {
+			err.Type = ERR_LABEL
+		} else if err.GoldRel == err.PredRel {
+			err.Type = ERR_HEAD
+		}
+		errors[word] = err
+	}
+	goldCrossing, predCrossing := nonProjective(gold), nonProjective(pred)
+	for i, goldWord := range gold {
+		word := i + 1
+		predWord := pred[i]
+		if evalNoPunct && evalPunct.MatchString(goldWord.Form) {
+			continue
+		}
+		err := errors[word]
+		report.Words++
+		if err != nil {
+			report.Errors++
+			result.FP++
+			result.Errors = append(result.Errors, err)
+			report.List = append(report.List, err)
+		} else {
+			result.TP++
+		}
+		report.ByRelation.add(goldWord.DepRel, err)
+		distance := "root"
+		if goldWord.Head > 0 {
+			distance = bucket(util.AbsInt(goldWord.Head-word), errDistanceBuckets)
+		}
+		report.ByDistance.add(distance, err)
+		headPOS := nlp.ROOT_TOKEN
+		if goldWord.Head > 0 && goldWord.Head <= len(gold) {
+			headPOS = gold[goldWord.Head-1].CPOS
+		}
+		report.ByHeadPOS.add(headPOS, err)
+		report.ByDependentPOS.add(goldWord.CPOS, err)
+		report.ByLength.add(bucket(len(gold), errLengthBuckets), err)
+		if goldWord.Head == 0 {
+			report.Root.GoldRoots++
+			if predWord.Head == 0 {
+				report.Root.Correct++
+			} else {
+				report.Root.Missed++
+			}
+		} else if predWord.Head == 0 {
+			report.Root.FalseRoots++
+		}
+		if goldCrossing[word] {
+			report.Projectivity.GoldArcs++
+			if err != nil {
+				report.Projectivity.GoldErrors++
+			}
+		}
+		if predCrossing[word] {
+			report.Projectivity.PredArcs++
+			if err != nil && err.Type != ERR_LABEL {
+				report.Projectivity.PredWrong++
+			}
+		}
+		if _, exists := report.Confusion[goldWord.DepRel]; !exists {
+			report.Confusion[goldWord.DepRel] = make(map[string]int)
+		}
+		report.Confusion[goldWord.DepRel][predWord.DepRel]++
+	}
+	return result
+}
+
+// DepErrorsCorpus analyzes the errors of a predicted corpus
+func DepErrorsCorpus(pred, gold []EvalSentence) *DepErrorReport {
+	report := &DepErrorReport{
+		Sentences:      len(gold),
+		ByRelation:     make(ErrorBreakdown),
+		ByDistance:     make(ErrorBreakdown),
+		ByHeadPOS:      make(ErrorBreakdown),
+		ByDependentPOS: make(ErrorBreakdown),
+		ByLength:       make(ErrorBreakdown),
+		Confusion:      make(map[string]map[string]int),
+	}
+	total := &eval.Total{Results: make([]*eval.Result, 0, len(gold))}
+	for i, goldSent := range gold {
+		total.Add(DepErrorsSentence(report, i+1, pred[i], goldSent))
+	}
+	report.ByType = total.Errors().ByType()
+	return report
+}
+
+func (b ErrorBreakdown) log(name string, keys []string) {
+	log.Println()
+	log.Printf("%-14s\t%s\t%s\t%s\t%s\t%s", name, "Words", "Errors", "Head", "Label", "Acc")
+	for _, key := range keys {
+		c := b[key]
+		log.Printf("%-14s\t%d\t%d\t%d\t%d\t%.4f", key, c.Words, c.Errors, c.HeadErrors, c.LabelErrors, 1-float64(c.Errors)/float64(c.Words))
+	}
+}
+
+func (b ErrorBreakdown) keys() []string {
+	keys := make([]string, 0, len(b))
+	for key := range b {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// bucketKeys returns keys of buckets in the order of their bounds
+func bucketKeys(b ErrorBreakdown, bounds []int, first ...string) []string {
+	keys := make([]string, 0, len(bounds)+len(first)+1)
+	for _, key := range first {
+		keys = append(keys, key)
+	}
+	for _, bound := range bounds {
+		keys = append(keys, bucket(bound, bounds))
+	}
+	keys = append(keys, bucket(bounds[len(bounds)-1]+1, bounds))
+	present := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, exists := b[key]; exists {
+			present = append(present, key)
+		}
+	}
+	return present
+}
+
+func (r *DepErrorReport) Log() {
+	log.Println("Sentences:", r.Sentences)
+	if r.Mismatched > 0 {
+		log.Println(r.Mismatched, "sentences differ in their number of words from gold and are not analyzed")
+	}
+	log.Println("Words:", r.Words)
+	log.Println("Errors:", r.Errors)
+	for _, errType := range []string{ERR_HEAD, ERR_LABEL, ERR_HEAD_LABEL} {
+		log.Printf("\t%-12s\t%d", errType, r.ByType[errType])
+	}
+	r.ByRelation.log("Gold relation", r.ByRelation.keys())
+	r.ByDistance.log("Distance", bucketKeys(r.ByDistance, errDistanceBuckets, "root"))
+	r.ByHeadPOS.log("Head POS", r.ByHeadPOS.keys())
+	r.ByDependentPOS.log("Dependent POS", r.ByDependentPOS.keys())
+	r.ByLength.log("Length", bucketKeys(r.ByLength, errLengthBuckets))
+	log.Println()
+	log.Printf("Root:\t%d gold roots, %d correct, %d missed, %d false roots", r.Root.GoldRoots, r.Root.Correct, r.Root.Missed, r.Root.FalseRoots)
+	log.Printf("Non-projective:\t%d gold arcs (%d errors), %d predicted arcs (%d wrongly attached)", r.Projectivity.GoldArcs, r.Projectivity.GoldErrors, r.Projectivity.PredArcs, r.Projectivity.PredWrong)
+
+	labels := make(map[string]bool)
+	for gold, preds := range r.Confusion {
+		labels[gold] = true
+		for pred := range preds {
+			labels[pred] = true
+		}
+	}
+	sorted := make([]string, 0, len(labels))
+	for label := range labels {
+		sorted = append(sorted, label)
+	}
+	sort.Strings(sorted)
+	log.Println()
+	log.Println("Relation confusion (rows gold, columns predicted)")
+	header := fmt.Sprintf("%-10s", "")
+	for _, pred := range sorted {
+		header += fmt.Sprintf("\t%.7s", pred)
+	}
+	log.Println(header)
+	for _, gold := range sorted {
+		if _, exists := r.Confusion[gold]; !exists {
+			continue
+		}
+		row := fmt.Sprintf("%-10s", gold)
+		for _, pred := range sorted {
+			row += fmt.Sprintf("\t%d", r.Confusion[gold][pred])
+		}
+		log.Println(row)
+	}
+}
+
+func EvalErrors(cmd *commander.Command, args []string) error {
+	VerifyFlags(cmd, []string{"p", "g"})
+	for _, file := range []string{input, inputGold} {
+		if !VerifyExists(file) {
+			os.Exit(1)
+		}
+	}
+	log.Println("Configuration")
+	log.Printf("No punctuation:\t%v", evalNoPunct)
+	log.Println()
+	log.Println("Data")
+	log.Printf("Predicted:\t\t%s", input)
+	log.Printf("Gold:\t\t\t%s", inputGold)
+	if len(evalJSONFile) > 0 {
+		log.Printf("Out (json):\t\t%s", evalJSONFile)
+	}
+	log.Println()
+
+	reader := ReadEvalConll
+	if useConllU {
+		reader = ReadEvalConllU
+	}
+	pred, gold := readEvalPair(input, inputGold, reader)
+	report := DepErrorsCorpus(pred, gold)
+	report.Log()
+	if len(evalJSONFile) > 0 {
+		WriteEvalJSON(evalJSONFile, report)
+	}
+	return nil
+}
+
+func EvalErrorsCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       EvalErrors,
+		UsageLine: "errors <file options> [arguments]",
+		Short:     "analyzes the errors of a dependency parser's output",
+		Long: `
+classifies the errors of a predicted CoNLL/CoNLL-U file against a gold file
+
+	$ ./yap eval errors -p <conll> -g <conll> [-json <file>] [options]
+
+Errors are classified as wrong head, wrong label or both, and counted by
+gold relation, attachment distance, POS of the (gold) head and dependent
+and sentence length, with root and non-projective (crossing) arc errors
+and a confusion matrix of relations. The JSON output lists every error.
+`,
+		Flag: *flag.NewFlagSet("errors", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&input, "p", "", "Predicted CoNLL File")
+	cmd.Flag.StringVar(&inputGold, "g", "", "Gold CoNLL File")
+	cmd.Flag.BoolVar(&useConllU, "conllu", false, "Read CoNLL-U files (-p, -g)")
+	cmd.Flag.BoolVar(&evalNoPunct, "nopunct", false, "Exclude punctuation")
+	cmd.Flag.StringVar(&evalJSONFile, "json", "", "Optional - Output JSON File")
+	return cmd
+}
@@ -0,0 +1,382 @@
+package app
+
+import (
+	nlp "yap/nlp/types"
+
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+// the value of a feature missing from a morpheme
+const NO_FEATURE = "_"
+
+var (
+	mdErrorsParamFunc string
+	mdErrorsTop       int
+)
+
+// Mappings returns the mappings of the sentence's tokens, in token order;
+// the token of a mapping is the concatenation of its morphemes' forms
+func (s EvalSentence) Mappings() nlp.Mappings {
+	spellouts := s.Spellouts()
+	tokens := make([]int, 0, len(spellouts))
+	for token := range spellouts {
+		tokens = append(tokens, token)
+	}
+	sort.Ints(tokens)
+	mappings := make(nlp.Mappings, len(tokens))
+	for i, token := range tokens {
+		forms := make([]string, len(spellouts[token]))
+		for j, morph := range spellouts[token] {
+			forms[j] = morph.Form
+		}
+		mappings[i] = &nlp.Mapping{Token: nlp.Token(strings.Join(forms, "")), Spellout: spellouts[token]}
+	}
+	return mappings
+}
+
+// A Confusion counts gold values (rows) by their predicted values
+type Confusion map[string]map[string]int
+
+func (c Confusion) add(gold, pred string) {
+	if _, exists := c[gold]; !exists {
+		c[gold] = make(map[string]int)
+	}
+	c[gold][pred]++
+}
+
+// ConfusionPair is a count of a gold value predicted as another value
+type ConfusionPair struct {
+	Gold  string `json:"gold"`
+	Pred  string `json:"pred"`
+	Count int    `json:"count"`
+}
+
+// Top returns the n most frequent confusions of differing values
+func (c Confusion) Top(n int) []*ConfusionPair {
+	var pairs []*ConfusionPair
+	for gold, preds := range c {
+		for pred, count := range preds {
+			if gold != pred {
+				pairs = append(pairs, &ConfusionPair{gold, pred, count})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Count != pairs[j].Count {
+			return pairs[i].Count > pairs[j].Count
+		}
+		return pairs[i].Gold+"\t"+pairs[i].Pred < pairs[j].Gold+"\t"+pairs[j].Pred
+	})
+	if len(pairs) > n {
+		pairs = pairs[:n]
+	}
+	return pairs
+}
+
+func (c Confusion) logMatrix(name string) {
+	values := make(map[string]bool)
+	for gold, preds := range c {
+		values[gold] = true
+		for pred := range preds {
+			values[pred] = true
+		}
+	}
+	sorted := make([]string, 0, len(values))
+	for value := range values {
+		sorted = append(sorted, value)
+	}
+	sort.Strings(sorted)
+	log.Println()
+	log.Println(name, "confusion (rows gold, columns predicted)")
+	header := fmt.Sprintf("%-10s", "")
+	for _, pred := range sorted {
+		header += fmt.Sprintf("\t%.7s", pred)
+	}
+	log.Println(header)
+	for _, gold := range sorted {
+		if _, exists := c[gold]; !exists {
+			continue
+		}
+		row := fmt.Sprintf("%-10s", gold)
+		for _, pred := range sorted {
+			row += fmt.Sprintf("\t%d", c[gold][pred])
+		}
+		log.Println(row)
+	}
+}
+
+func logConfusionPairs(name string, pairs []*ConfusionPair) {
+	log.Println()
+	log.Printf("Top %s confusions", name)
+	log.Printf("%-7s\t%-20s\t%s", "Count", "Gold", "Predicted")
+	for _, pair := range pairs {
+		log.Printf("%-7d\t%-20s\t%s", pair.Count, pair.Gold, pair.Pred)
+	}
+}
+
+// SegmentationSignature is the segmentation of a spellout: the forms of its
+// prefixes and suffixes around its host (longest) morpheme, marked *, e.g.
+// ה+* for a host with a definite article
+func SegmentationSignature(s nlp.Spellout) string {
+	if len(s) == 0 {
+		return ""
+	}
+	host := 0
+	for i, morph := range s {
+		if len(morph.Form) > len(s[host].Form) {
+			host = i
+		}
+	}
+	parts := make([]string, len(s))
+	for i, morph := range s {
+		parts[i] = morph.Form
+	}
+	parts[host] = "*"
+	return strings.Join(parts, "+")
+}
+
+// morphFeatures returns the feature values of a morpheme by name
+func morphFeatures(m *nlp.EMorpheme) map[string]string {
+	features := make(map[string]string)
+	if m.FeatureStr == "" || m.FeatureStr == NO_FEATURE {
+		return features
+	}
+	for _, feature := range strings.Split(m.FeatureStr, "|") {
+		if nameValue := strings.SplitN(feature, "=", 2); len(nameValue) == 2 {
+			features[nameValue[0]] = nameValue[1]
+		} else {
+			features[feature] = feature
+		}
+	}
+	return features
+}
+
+// A TokenErrors counts the mis-disambiguations of a token by gold and
+// predicted spellouts
+type TokenErrors struct {
+	Token       string           `json:"token"`
+	Occurrences int              `json:"occurrences"`
+	Errors      int              `json:"errors"`
+	Spellouts   []*ConfusionPair `json:"spellouts"`
+
+	spellouts Confusion
+}
+
+type MDErrorReport struct {
+	ParamFunc    string               `json:"param_func"`
+	Sentences    int                  `json:"sentences"`
+	Mismatched   int                  `json:"mismatched_sentences"`
+	Tokens       int                  `json:"tokens"`
+	Errors       int                  `json:"errors"`
+	SegErrors    int                  `json:"segmentation_errors"`
+	POS          Confusion            `json:"pos"`
+	Segmentation Confusion            `json:"segmentation"`
+	Features     map[string]Confusion `json:"features"`
+	Lemmas       Confusion            `json:"-"`
+	TopLemmas    []*ConfusionPair     `json:"lemmas"`
+	TopTokens    []*TokenErrors       `json:"tokens_list"`
+
+	tokens map[string]*TokenErrors
+}
+
+// MDErrorsSentence adds the confusions of the predicted mappings of a
+// sentence's tokens to the report. A token is mis-disambiguated if its
+// spellouts differ under the report's param func; the POS, features and
+// lemmas of morphemes are compared for tokens segmented like gold
+func MDErrorsSentence(report *MDErrorReport, pred, gold nlp.Mappings) {
+	if len(pred) != len(gold) {
+		report.Mismatched++
+		return
+	}
+	for i, goldMapping := range gold {
+		goldSpellout, predSpellout := goldMapping.Spellout, pred[i].Spellout
+		report.Tokens++
+		goldSeg, predSeg := SegmentationSignature(goldSpellout), SegmentationSignature(predSpellout)
+		report.Segmentation.add(goldSeg, predSeg)
+		token := string(goldMapping.Token)
+		if _, exists := report.tokens[token]; !exists {
+			report.tokens[token] = &TokenErrors{Token: token, spellouts: make(Confusion)}
+		}
+		tokenErrors := report.tokens[token]
+		tokenErrors.Occurrences++
+		if !predSpellout.EqualCompare(goldSpellout, report.ParamFunc) || len(predSpellout) != len(goldSpellout) {
+			report.Errors++
+			tokenErrors.Errors++
+			paramFunc := nlp.MDParams[report.ParamFunc]
+			tokenErrors.spellouts.add(nlp.ProjectSpellout(goldSpellout, paramFunc), nlp.ProjectSpellout(predSpellout, paramFunc))
+		}
+		if nlp.ProjectSpellout(goldSpellout, nlp.Form) != nlp.ProjectSpellout(predSpellout, nlp.Form) {
+			report.SegErrors++
+			continue
+		}
+		for j, goldMorph := range goldSpellout {
+			predMorph := predSpellout[j]
+			report.POS.add(goldMorph.CPOS, predMorph.CPOS)
+			report.Lemmas.add(goldMorph.Lemma, predMorph.Lemma)
+			goldFeatures, predFeatures := morphFeatures(goldMorph), morphFeatures(predMorph)
+			names := make(map[string]bool)
+			for name := range goldFeatures {
+				names[name] = true
+			}
+			for name := range predFeatures {
+				names[name] = true
+			}
+			for name := range names {
+				goldValue, predValue := goldFeatures[name], predFeatures[name]
+				if goldValue == "" {
+					goldValue = NO_FEATURE
+				}
+				if predValue == "" {
+					predValue = NO_FEATURE
+				}
+				if _, exists := report.Features[name]; !exists {
+					report.Features[name] = make(Confusion)
+				}
+				report.Features[name].add(goldValue, predValue)
+			}
+		}
+	}
+}
+
+// MDErrorsCorpus analyzes the mis-disambiguations of a predicted corpus of
+// mappings, listing the top most frequently mis-disambiguated tokens and
+// lemma confusions
+func MDErrorsCorpus(pred, gold []nlp.Mappings, paramFunc string, top int) *MDErrorReport {
+	report := &MDErrorReport{
+		ParamFunc:    paramFunc,
+		Sentences:    len(gold),
+		POS:          make(Confusion),
+		Segmentation: make(Confusion),
+		Features:     make(map[string]Confusion),
+		Lemmas:       make(Confusion),
+		tokens:       make(map[string]*TokenErrors),
+	}
+	for i, goldMappings := range gold {
+		MDErrorsSentence(report, pred[i], goldMappings)
+	}
+	report.TopLemmas = report.Lemmas.Top(top)
+	for _, tokenErrors := range report.tokens {
+		if tokenErrors.Errors > 0 {
+			tokenErrors.Spellouts = tokenErrors.spellouts.Top(top)
+			report.TopTokens = append(report.TopTokens, tokenErrors)
+		}
+	}
+	sort.Slice(report.TopTokens, func(i, j int) bool {
+		if report.TopTokens[i].Errors != report.TopTokens[j].Errors {
+			return report.TopTokens[i].Errors > report.TopTokens[j].Errors
+		}
+		return report.TopTokens[i].Token < report.TopTokens[j].Token
+	})
+	if len(report.TopTokens) > top {
+		report.TopTokens = report.TopTokens[:top]
+	}
+	return report
+}
+
+func (r *MDErrorReport) Log() {
+	log.Println("Sentences:", r.Sentences)
+	if r.Mismatched > 0 {
+		log.Println(r.Mismatched, "sentences differ in their number of tokens from gold and are not analyzed")
+	}
+	log.Println("Tokens:", r.Tokens)
+	log.Printf("Mis-disambiguated (%s):\t%d", r.ParamFunc, r.Errors)
+	log.Printf("Mis-segmented:\t\t%d", r.SegErrors)
+	r.POS.logMatrix("POS")
+	logConfusionPairs("segmentation", r.Segmentation.Top(mdErrorsTop))
+	names := make([]string, 0, len(r.Features))
+	for name := range r.Features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.Features[name].logMatrix("Feature " + name)
+	}
+	logConfusionPairs("lemma", r.TopLemmas)
+	log.Println()
+	log.Println("Most frequently mis-disambiguated tokens")
+	log.Printf("%-12s\t%s\t%s\t%-30s\t%s", "Token", "Errors", "Occur.", "Gold", "Predicted")
+	for _, tokenErrors := range r.TopTokens {
+		for i, spellouts := range tokenErrors.Spellouts {
+			if i == 0 {
+				log.Printf("%-12s\t%d\t%d\t%-30s\t%s (%d)", tokenErrors.Token, tokenErrors.Errors, tokenErrors.Occurrences, spellouts.Gold, spellouts.Pred, spellouts.Count)
+			} else {
+				log.Printf("%-12s\t\t\t%-30s\t%s (%d)", "", spellouts.Gold, spellouts.Pred, spellouts.Count)
+			}
+		}
+	}
+}
+
+func EvalMDErrors(cmd *commander.Command, args []string) error {
+	VerifyFlags(cmd, []string{"pm", "gm"})
+	for _, file := range []string{evalPredMapFile, evalGoldMapFile} {
+		if !VerifyExists(file) {
+			os.Exit(1)
+		}
+	}
+	if _, exists := nlp.MDParams[mdErrorsParamFunc]; !exists {
+		log.Fatalln("Unknown param func", mdErrorsParamFunc, "options are", nlp.AllParamFuncNames)
+	}
+	log.Println("Configuration")
+	log.Printf("Param Func:\t\t%s", mdErrorsParamFunc)
+	log.Printf("Top:\t\t\t%d", mdErrorsTop)
+	log.Println()
+	log.Println("Data")
+	log.Printf("Predicted mapping:\t%s", evalPredMapFile)
+	log.Printf("Gold mapping:\t\t%s", evalGoldMapFile)
+	if len(evalJSONFile) > 0 {
+		log.Printf("Out (json):\t\t%s", evalJSONFile)
+	}
+	log.Println()
+	if useConllU {
+		nlp.InitOpenParamFamily("UD")
+	} else {
+		nlp.InitOpenParamFamily("HEBTB")
+	}
+
+	predSents, goldSents := readEvalPair(evalPredMapFile, evalGoldMapFile, ReadEvalMapping)
+	pred, gold := make([]nlp.Mappings, len(predSents)), make([]nlp.Mappings, len(goldSents))
+	for i := range goldSents {
+		pred[i], gold[i] = predSents[i].Mappings(), goldSents[i].Mappings()
+	}
+	report := MDErrorsCorpus(pred, gold, mdErrorsParamFunc, mdErrorsTop)
+	report.Log()
+	if len(evalJSONFile) > 0 {
+		WriteEvalJSON(evalJSONFile, report)
+	}
+	return nil
+}
+
+func EvalMDErrorsCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       EvalMDErrors,
+		UsageLine: "md <file options> [arguments]",
+		Short:     "analyzes the errors of morphological disambiguation output",
+		Long: `
+reports where predicted mappings differ from gold mappings
+
+	$ ./yap eval md -pm <mapping> -gm <mapping> [-p <param func>] [-json <file>] [options]
+
+Reports the number of tokens whose spellouts differ under the param func
+(as in training), confusions of segmentations (prefixes and suffixes
+around the host morpheme, e.g. ה+* for a definite article), and, in tokens
+segmented like gold, confusion matrices of POS tags and feature values and
+the top lemma confusions, with the most frequently mis-disambiguated
+tokens and their gold and predicted spellouts.
+`,
+		Flag: *flag.NewFlagSet("md", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&evalPredMapFile, "pm", "", "Predicted Mapping File")
+	cmd.Flag.StringVar(&evalGoldMapFile, "gm", "", "Gold Mapping (Disambiguated Lattice) File")
+	cmd.Flag.StringVar(&mdErrorsParamFunc, "p", "Funcs_Main_POS_Both_Prop", "Param Func to compare spellouts by: ["+nlp.AllParamFuncNames+"]")
+	cmd.Flag.BoolVar(&useConllU, "ud", false, "Use the UD open class POS family (for Funcs_* param funcs)")
+	cmd.Flag.IntVar(&mdErrorsTop, "top", 20, "Number of most frequent confusions and tokens to list")
+	cmd.Flag.StringVar(&evalJSONFile, "json", "", "Optional - Output JSON File")
+	return cmd
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	nlp "yap/nlp/types"
+
+	"reflect"
+	"testing"
+)
+
+// mdWord is a morpheme of a token, on its own lattice edge
+func mdWord(id, token int, form, pos, feats string) *EvalWord {
+	return newEvalWord(id, id, id+1, token, form, form, pos, pos, feats, 0, "")
+}
+
+func TestMDErrorsCorpus(t *testing.T) {
+	// h+bit gdol, twice
+	gold := EvalSentence{
+		mdWord(0, 0, "h", "DEF", "_"),
+		mdWord(1, 0, "bit", "NN", "gen=M|num=S"),
+		mdWord(2, 1, "gdol", "JJ", "gen=M|num=S"),
+	}
+	// hbit unsegmented, and gdol of a wrong POS
+	unsegmented := EvalSentence{
+		mdWord(0, 0, "hbit", "NN", "gen=M|num=S"),
+		mdWord(1, 1, "gdol", "NN", "gen=M|num=S"),
+	}
+	// a wrong feature value, which Form_POS does not consider
+	feature := EvalSentence{
+		mdWord(0, 0, "h", "DEF", "_"),
+		mdWord(1, 0, "bit", "NN", "gen=F|num=S"),
+		mdWord(2, 1, "gdol", "JJ", "gen=M|num=S"),
+	}
+	pred := []nlp.Mappings{unsegmented.Mappings(), feature.Mappings()}
+	report := MDErrorsCorpus(pred, []nlp.Mappings{gold.Mappings(), gold.Mappings()}, "Form_POS", 5)
+
+	if report.Tokens != 4 || report.Errors != 2 || report.SegErrors != 1 || report.Mismatched != 0 {
+		t.Errorf("Got %d tokens, %d errors, %d segmentation errors, %d mismatched, expected 4 2 1 0", report.Tokens, report.Errors, report.SegErrors, report.Mismatched)
+	}
+	if expected := (Confusion{"h+*": {"*": 1, "h+*": 1}, "*": {"*": 2}}); !reflect.DeepEqual(report.Segmentation, expected) {
+		t.Errorf("Got segmentation confusion %v, expected %v", report.Segmentation, expected)
+	}
+	// the morphemes of the unsegmented token are not compared
+	if expected := (Confusion{"DEF": {"DEF": 1}, "NN": {"NN": 1}, "JJ": {"NN": 1, "JJ": 1}}); !reflect.DeepEqual(report.POS, expected) {
+		t.Errorf("Got POS confusion %v, expected %v", report.POS, expected)
+	}
+	if gen := report.Features["gen"]; gen["M"]["F"] != 1 || gen["M"]["M"] != 2 || report.Features["num"]["S"]["S"] != 3 {
+		t.Errorf("Got feature confusions %v", report.Features)
+	}
+	if top := report.POS.Top(5); len(top) != 1 || *top[0] != (ConfusionPair{"JJ", "NN", 1}) {
+		t.Errorf("Got top POS confusions %v, expected JJ as NN", top)
+	}
+
+	if len(report.TopTokens) != 2 {
+		t.Fatalf("Got %d mis-disambiguated tokens, expected 2", len(report.TopTokens))
+	}
+	for i, expected := range []struct {
+		token               string
+		occurrences, errors int
+	}{{"gdol", 2, 1}, {"hbit", 2, 1}} {
+		if got := report.TopTokens[i]; got.Token != expected.token || got.Occurrences != expected.occurrences || got.Errors != expected.errors || len(got.Spellouts) != 1 {
+			t.Errorf("Got mis-disambiguated token %d %+v, expected %+v", i, got, expected)
+		}
+	}
+
+	// sentences of a different number of tokens are not analyzed
+	report = MDErrorsCorpus([]nlp.Mappings{unsegmented[:1].Mappings()}, []nlp.Mappings{gold.Mappings()}, "Form_POS", 5)
+	if report.Mismatched != 1 || report.Tokens != 0 {
+		t.Errorf("Got %d mismatched sentences of %d tokens, expected 1 of 0", report.Mismatched, report.Tokens)
+	}
+}
+
+func TestSegmentationSignature(t *testing.T) {
+	for _, test := range []struct {
+		forms     []string
+		signature string
+	}{
+		{[]string{"bit"}, "*"},
+		{[]string{"h", "bit"}, "h+*"},
+		{[]string{"w", "h", "bit", "w"}, "w+h+*+w"},
+		{nil, ""},
+	} {
+		var spellout nlp.Spellout
+		for _, form := range test.forms {
+			spellout = append(spellout, &nlp.EMorpheme{Morpheme: nlp.Morpheme{Form: form}})
+		}
+		if signature := SegmentationSignature(spellout); signature != test.signature {
+			t.Errorf("Got signature %q of %v, expected %q", signature, test.forms, test.signature)
+		}
+	}
+}
@@ -42,7 +42,7 @@ func Fuse(cmd *commander.Command, args []string) error {
 	VerifyFlags(cmd, REQUIRED_FLAGS)
 
 	FuseConfigOut()
-	SetupEnum([]string{})
+	SetupEnum([]string{}, "")
 
 	var (
 		lAmb  chan lattice.Lattice
@@ -32,9 +32,9 @@ var (
 	hebMACompat                   bool
 )
 
-func SetupEnum(relations []string) {
+func SetupEnum(relations []string, arcSystem string) {
 	SetupRelationEnum(relations)
-	SetupMorphTransEnum(relations)
+	SetupMorphTransEnum(relations, arcSystem)
 	EWord, EPOS, EWPOS = util.NewEnumSet(APPROX_WORDS, "EWord"), util.NewEnumSet(APPROX_POS, "EPOS"), util.NewEnumSet(APPROX_WORDS*5, "EWPOS")
 	EMHost, EMSuffix = util.NewEnumSet(APPROX_MHOSTS, "EMHost"), util.NewEnumSet(APPROX_MSUFFIXES, "EMSuffix")
 	EMorphProp = util.NewEnumSet(130, "EMorphProp") // random guess of number of possible values
@@ -47,7 +47,7 @@ func SetupEnum(relations []string) {
 
 func CombineJointCorpus(graphs, goldLats, ambLats []interface{}) ([]interface{}, int) {
 	if len(graphs) != len(goldLats) || len(graphs) != len(ambLats) {
-		panic(fmt.Sprintf("Got mismatched training slice inputs (graphs, gold lattices, ambiguous lattices):", len(graphs), len(goldLats), len(ambLats)))
+		panic(fmt.Sprintf("Got mismatched training slice inputs (graphs, gold lattices, ambiguous lattices): %v %v %v", len(graphs), len(goldLats), len(ambLats)))
 	}
 	morphGraphs := make([]interface{}, len(graphs))
 	var (
@@ -69,7 +69,7 @@ func CombineJointCorpus(graphs, goldLats, ambLats []interface{}) ([]interface{},
 
 func CombineToGoldMorphs(goldLats, ambLats []interface{}) ([]interface{}, int) {
 	if len(goldLats) != len(ambLats) {
-		panic(fmt.Sprintf("Got mismatched training slice inputs (gold lattices, ambiguous lattices):", len(goldLats), len(ambLats)))
+		panic(fmt.Sprintf("Got mismatched training slice inputs (gold lattices, ambiguous lattices): %v %v", len(goldLats), len(ambLats)))
 	}
 	morphGraphs := make([]interface{}, len(goldLats))
 	var (
@@ -96,8 +96,16 @@ func JointConfigOut(outModelFile string, b search.Interface, t transition.Transi
 	log.Printf("Transition System:\t%s", t.Name())
 	log.Printf("Transition Oracle:\t%s", t.Oracle().Name())
 	log.Printf("Iterations:\t\t%d", Iterations)
+	if Shuffle {
+		log.Printf("Shuffle Seed:\t\t%d", ShuffleSeed)
+	}
+	if TrainShards > 1 {
+		log.Printf("Train Shards:\t\t%d", TrainShards)
+	}
+	log.Printf("Update Strategy:\t%s", ViolationStrategy)
 	log.Printf("Beam Size:\t\t%d", BeamSize)
 	log.Printf("Beam Concurrent:\t%v", ConcurrentBeam)
+	log.Printf("Parse Workers:\t\t%d", Workers)
 	log.Printf("Parameter Func:\t%v", paramFuncName)
 	log.Printf("Use Lemmas:\t\t%v", !lattice.IGNORE_LEMMA)
 	log.Printf("Use POP:\t\t%v", UsePOP)
@@ -143,9 +151,27 @@ func JointConfigOut(outModelFile string, b search.Interface, t transition.Transi
 	log.Printf("Out (segmt.) file:\t\t\t%s", outSeg)
 	log.Printf("Out (mapping.) file:\t\t\t%s", outMap)
 	log.Printf("Out Train (segmt.) file:\t\t%s", tSeg)
+	KBestConfigOut()
+	ConfidenceConfigOut()
+	ConstrainedConfigOut("fixed paths of the input lattices")
 }
 
 func JointTrainAndParse(cmd *commander.Command, args []string) error {
+	modelExists := VerifyExists(modelFile)
+	REQUIRED_FLAGS := []string{"in", "oc", "om", "os", "f", "l", "jointstr", "oraclestr"}
+	VerifyFlags(cmd, REQUIRED_FLAGS)
+	if !modelExists {
+		REQUIRED_FLAGS = []string{"it", "tc", "td", "tl", "in", "oc", "om", "os", "ots", "f", "l", "jointstr", "oraclestr"}
+		VerifyFlags(cmd, REQUIRED_FLAGS)
+	}
+	_, err := JointRun(modelExists)
+	return err
+}
+
+// JointRun trains a joint model from the training files if the model does
+// not exist, returning the file of the trained model, otherwise parses the
+// input with it
+func JointRun(modelExists bool) (string, error) {
 	// *** SETUP ***
 	paramFunc, exists := nlp.MDParams[paramFuncName]
 	if !exists {
@@ -163,18 +189,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		terminalStack int
 	)
 
-	switch arcSystemStr {
-	case "standard":
-		arcSystem = &ArcStandard{}
-		terminalStack = 1
-	case "eager":
-		arcSystem = &ArcEager{
-			ArcStandard: ArcStandard{},
-		}
-		terminalStack = 0
-	default:
-		panic("Unknown arc system")
-	}
+	arcSystem, terminalStack = NewArcSystem(arcSystemStr)
 
 	arcSystem.AddDefaultOracle()
 
@@ -188,13 +203,11 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	transitionSystem := transition.TransitionSystem(jointTrans)
 
 	outModelFile := modelFile
-	modelExists := VerifyExists(outModelFile)
-	REQUIRED_FLAGS := []string{"in", "oc", "om", "os", "f", "l", "jointstr", "oraclestr"}
-	VerifyFlags(cmd, REQUIRED_FLAGS)
-
-	if !modelExists {
-		REQUIRED_FLAGS = []string{"it", "tc", "td", "tl", "in", "oc", "om", "os", "ots", "f", "l", "jointstr", "oraclestr"}
-		VerifyFlags(cmd, REQUIRED_FLAGS)
+	VerifyKBest()
+	VerifyConfidence()
+	VerifyConstrained()
+	if Constrained && useConllU {
+		log.Fatalln("Fixed lattice paths are only read from lattice format input")
 	}
 
 	// RegisterTypes()
@@ -217,35 +230,14 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		// start processing - setup enumerations
 		log.Println("Setup enumerations")
 	}
-	SetupEnum(relations.Values)
+	modelHeader := NewModelHeader("joint", arcSystemStr, BeamSize, featuresFile, relations.Values, paramFuncName)
+	SetupEnum(relations.Values, arcSystemStr)
+	LoadCheckpoint(modelHeader)
 
 	// after calling SetupEnum, enums are instantiated and set according to the relations
 	// therefore we re-instantiate the arc system with the right parameters
 	// DON'T REMOVE!!
-	switch arcSystemStr {
-	case "standard":
-		arcSystem = &ArcStandard{
-			SHIFT:       SH.Value(),
-			LEFT:        LA.Value(),
-			RIGHT:       RA.Value(),
-			Transitions: ETrans,
-			Relations:   ERel,
-		}
-	case "eager":
-		arcSystem = &ArcEager{
-			ArcStandard: ArcStandard{
-				SHIFT:       SH.Value(),
-				LEFT:        LA.Value(),
-				RIGHT:       RA.Value(),
-				Relations:   ERel,
-				Transitions: ETrans,
-			},
-			REDUCE:  RE.Value(),
-			POPROOT: PR.Value(),
-		}
-	default:
-		panic("Unknown arc system")
-	}
+	arcSystem, terminalStack = NewArcSystem(arcSystemStr)
 	arcSystem.AddDefaultOracle()
 	jointTrans.ArcSys = arcSystem
 	jointTrans.Transitions = ETrans
@@ -312,7 +304,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 			s, _, e := conllu.ReadFile(tConll, limit)
 			if e != nil {
 				log.Println(e)
-				return e
+				return "", e
 			}
 			if allOut {
 				log.Println("Conll:\tRead", len(s), "sentences")
@@ -323,7 +315,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 			s, e := conll.ReadFile(tConll, limit)
 			if e != nil {
 				log.Println(e)
-				return e
+				return "", e
 			}
 			if allOut {
 				log.Println("Conll:\tRead", len(s), "sentences")
@@ -340,7 +332,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 			lDis, lDisE := lattice.ReadFile(tLatDis, limit)
 			if lDisE != nil {
 				log.Println(lDisE)
-				return lDisE
+				return "", lDisE
 			}
 			if allOut {
 				log.Println("Dis. Lat.:\tRead", len(lDis), "disambiguated lattices")
@@ -368,7 +360,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		}
 		if lAmbE != nil {
 			log.Println(lAmbE)
-			return lAmbE
+			return "", lAmbE
 		}
 		if allOut {
 			log.Println("Amb. Lat:\tRead", len(lAmb), "ambiguous lattices")
@@ -460,7 +452,10 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 			DefaultTransType:   'M',
 		}
 
-		var evaluator perceptron.StopCondition
+		var (
+			evaluator perceptron.StopCondition
+			stopState *StopState
+		)
 		if len(inputGold) > 0 && !noconverge {
 			var (
 				convCombined []interface{}
@@ -481,7 +476,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 				s, _, e := conllu.ReadFile(inputGold, limitdev)
 				if e != nil {
 					log.Println(e)
-					return e
+					return "", e
 				}
 				if allOut {
 					log.Println("Convergence Dev Gold Dis. Lat.:\tRead", len(s), "disambiguated lattices")
@@ -497,7 +492,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 				lConvDis, lConvDisE := lattice.ReadFile(inputGold, limitdev)
 				if lConvDisE != nil {
 					log.Println(lConvDisE)
-					return lConvDisE
+					return "", lConvDisE
 				}
 				if allOut {
 					log.Println("Convergence Dev Gold Dis. Lat.:\tRead", len(lConvDis), "disambiguated lattices")
@@ -523,7 +518,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 			// lConvAmb = lConvAmb[:NUM_SENTS]
 			if lConvAmbE != nil {
 				log.Println(lConvAmbE)
-				return lConvAmbE
+				return "", lConvAmbE
 			}
 			// lAmb = lAmb[:NUM_SENTS]
 			if allOut {
@@ -551,7 +546,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 					lConvDis, lConvDisE := lattice.ReadFile(testGold, limitdev)
 					if lConvDisE != nil {
 						log.Println(lConvDisE)
-						return lConvDisE
+						return "", lConvDisE
 					}
 					if allOut {
 						log.Println("Convergence Test Gold Dis. Lat.:\tRead", len(lConvDis), "disambiguated lattices")
@@ -568,7 +563,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 				// lConvAmb = lConvAmb[:NUM_SENTS]
 				if lConvAmbE != nil {
 					log.Println(lConvAmbE)
-					return lConvAmbE
+					return "", lConvAmbE
 				}
 				// lAmb = lAmb[:NUM_SENTS]
 				if allOut {
@@ -590,60 +585,46 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 				// convCombined = convCombined[:100]
 			}
 			// TODO: replace nil param with test sentences
-			evaluator = MakeJointEvalStopCondition(convAmbLat, convCombined, testAmbLat, testCombined, decodeTestBeam, perceptron.InstanceDecoder(deterministic), BeamSize)
+			stopState = resumedStopState()
+			evaluator = MakeJointEvalStopCondition(convAmbLat, convCombined, testAmbLat, testCombined, decodeTestBeam, perceptron.InstanceDecoder(deterministic), BeamSize, stopState)
 		}
-		_ = Train(goldSequences, Iterations, modelFile, model, perceptron.EarlyUpdateInstanceDecoder(beam), perceptron.InstanceDecoder(deterministic), evaluator)
+		modelHeader.AddTrainingFiles(tConll, tLatDis, tLatAmb)
+		trainingHeader = modelHeader
+		trainer := Train(goldSequences, Iterations, modelFile, model, perceptron.EarlyUpdateInstanceDecoder(beam), perceptron.InstanceDecoder(deterministic), evaluator)
 		search.AllOut = false
 		if allOut {
 			log.Println("Done Training")
 			// util.LogMemory()
-			// log.Println()
-			// serialization := &Serialization{
-			// 	model.Serialize(-1),
-			// 	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
-			// }
-			// log.Println("Writing final model to", outModelFile)
-			// WriteModel(outModelFile, serialization)
-			// if allOut {
-			// 	log.Println("Done writing model")
-			// }
-		}
-		return nil
+		}
+		if stopState != nil && len(stopState.BestModelFile) > 0 {
+			// the convergence test keeps the model of its best iteration
+			return stopState.BestModelFile, nil
+		}
+		serialization := &Serialization{
+			model.Serialize(-1),
+			EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
+		}
+		if allOut {
+			log.Println("Writing final model to", outModelFile)
+		}
+		modelHeader.TrainIterations = trainer.TrainI
+		WriteModel(outModelFile, modelHeader, serialization)
+		if allOut {
+			log.Println("Done writing model")
+		}
+		return outModelFile, nil
 	} else {
 		if allOut && !parseOut {
 			log.Println("Found model file", outModelFile, " ... loading model")
 		}
-		serialization := ReadModel(outModelFile)
+		serialization := LoadModel(outModelFile, modelHeader)
 		model.Deserialize(serialization.WeightModel)
 		model.Formatters = formatters
 		EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix, serialization.EMorphProp, serialization.ETrans, serialization.ETokens
 		if allOut && !parseOut {
 			log.Println("Loaded model")
 		}
-		switch arcSystemStr {
-		case "standard":
-			arcSystem = &ArcStandard{
-				SHIFT:       SH.Value(),
-				LEFT:        LA.Value(),
-				RIGHT:       RA.Value(),
-				Transitions: ETrans,
-				Relations:   ERel,
-			}
-		case "eager":
-			arcSystem = &ArcEager{
-				ArcStandard: ArcStandard{
-					SHIFT:       SH.Value(),
-					LEFT:        LA.Value(),
-					RIGHT:       RA.Value(),
-					Relations:   ERel,
-					Transitions: ETrans,
-				},
-				REDUCE:  RE.Value(),
-				POPROOT: PR.Value(),
-			}
-		default:
-			panic("Unknown arc system")
-		}
+		arcSystem, terminalStack = NewArcSystem(arcSystemStr)
 		arcSystem.AddDefaultOracle()
 		jointTrans.ArcSys = arcSystem
 		jointTrans.Transitions = ETrans
@@ -678,7 +659,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	}
 	if lAmbE != nil {
 		log.Println(lAmbE)
-		return lAmbE
+		return "", lAmbE
 	}
 	// lAmb = lAmb[:NUM_SENTS]
 	if allOut {
@@ -700,7 +681,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		}
 		if lDisE != nil {
 			log.Println(lDisE)
-			return lDisE
+			return "", lDisE
 		}
 		if allOut {
 			log.Println("Dev Gold Dis. Lat.:\tRead", len(lDis), "disambiguated lattices")
@@ -752,7 +733,15 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	}
 	beam.Model = model
 	beam.ShortTempAgenda = true
-	parsedGraphs := Parse(predAmbLat, beam)
+	beam.KBest = KBest
+	beam.ReturnAgenda = Confidence
+	if Constrained {
+		predAmbLat = ConstrainCorpus(predAmbLat, JointFixedPathsCorpus(lAmb, predAmbLat))
+	}
+	parsedGraphs, params := ParseWithParams(predAmbLat, beam)
+	if KBest > 1 {
+		WriteKBestOutput(params, JointKBestFormat)
+	}
 
 	if allOut {
 		log.Println("Converting", len(parsedGraphs), "to conll")
@@ -761,7 +750,10 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		log.Println("Writing to output file")
 	}
 	var graphAsConll []interface{}
-	if useConllU {
+	if Confidence {
+		graphAsConll = ArcConfidenceCorpus(params, JointConllU)
+		conllu.WriteFile(outConll, graphAsConll)
+	} else if useConllU {
 		graphAsConll = conllu.MorphGraph2ConllCorpus(parsedGraphs)
 		conllu.WriteFile(outConll, graphAsConll)
 	} else {
@@ -779,13 +771,17 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 
 		log.Println("Writing to mapping file")
 	}
-	mapping.WriteFile(outMap, GetInstances(parsedGraphs, GetJointMDConfig))
+	if Confidence {
+		mapping.WriteConfidenceFile(outMap, GetInstances(parsedGraphs, GetJointMDConfig), SpelloutConfidenceCorpus(params, JointMDConfOf))
+	} else {
+		mapping.WriteFile(outMap, GetInstances(parsedGraphs, GetJointMDConfig))
+	}
 	if allOut {
 		log.Println("Wrote", len(parsedGraphs), "in mapping format to", outMap)
 
 		log.Println("Writing to gold segmentation file")
 	}
-	return nil
+	return "", nil
 }
 
 func JointCmd() *commander.Command {
@@ -802,10 +798,18 @@ runs morpho-syntactic training and parsing
 		Flag: *flag.NewFlagSet("joint", flag.ExitOnError),
 	}
 	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
+	cmd.Flag.IntVar(&Workers, "workers", 1, "Number of sentences to parse concurrently (each with its own beam)")
+	cmd.Flag.BoolVar(&Shuffle, "shuffle", false, "Shuffle the training instances every iteration")
+	cmd.Flag.Int64Var(&ShuffleSeed, "seed", 1, "Random seed for shuffling the training instances")
+	cmd.Flag.IntVar(&TrainShards, "shards", 1, "Number of training shards trained concurrently, mixing their weights every iteration (iterative parameter mixing)")
+	cmd.Flag.BoolVar(&CountUpdates, "countupdates", false, "Count the updates of each feature in the per-iteration models, for pruning rarely updated features (yap model prune -minupdates)")
+	cmd.Flag.StringVar(&ViolationStrategy, "update", search.EARLY_UPDATE, "Violation-fixing update strategy of beam training [early, max-violation, latest, full]")
+	cmd.Flag.StringVar(&resumeFile, "resume", "", "Optional - Resume training from a checkpoint (model.temp.iN file written with -checkpoint)")
+	cmd.Flag.BoolVar(&checkpointTraining, "checkpoint", false, "Write a training checkpoint (model.temp.iN) every iteration, which training can be resumed from")
 	cmd.Flag.IntVar(&Iterations, "it", 1, "Number of Perceptron Iterations")
 	cmd.Flag.IntVar(&BeamSize, "b", 64, "Beam Size")
 	cmd.Flag.StringVar(&modelFile, "m", "model", "Prefix for model file ({m}.b{b}.i{it}.model)")
-	cmd.Flag.StringVar(&arcSystemStr, "a", "eager", "Optional - Arc System [standard, eager]")
+	cmd.Flag.StringVar(&arcSystemStr, "a", "eager", "Optional - Arc System [standard, eager, swap, hybrid, covington]")
 
 	cmd.Flag.StringVar(&tConll, "tc", "", "Training Conll File")
 	cmd.Flag.StringVar(&tLatDis, "td", "", "Training Disambiguated Lattices File")
@@ -817,6 +821,12 @@ runs morpho-syntactic training and parsing
 	cmd.Flag.StringVar(&outConll, "oc", "", "Output Conll File")
 	cmd.Flag.StringVar(&outSeg, "os", "", "Output Segmentation File")
 	cmd.Flag.StringVar(&outMap, "om", "", "Output Mapping File")
+	cmd.Flag.IntVar(&KBest, "kbest", 1, "Optional - Number of best distinct parses per sentence to output (-okb, -okbjson)")
+	cmd.Flag.StringVar(&outKBest, "okb", "", "Optional - Output K Best Conll File (with # parse_rank and # score comments)")
+	cmd.Flag.StringVar(&outKBestJSON, "okbjson", "", "Optional - Output K Best JSON File")
+	cmd.Flag.BoolVar(&Confidence, "conf", false, "Optional - Output confidence scores of the final beam, of arcs in the MISC column (output in CoNLL-U) and of spellouts as a last mapping column")
+	cmd.Flag.Float64Var(&ConfidenceTemp, "conftemp", 0, "Optional - Softmax temperature of confidence scores (0 for the standard deviation of the beam's scores)")
+	cmd.Flag.BoolVar(&Constrained, "constrained", false, "Optional - Constrain parsing to the paths through the input lattice's fixed edges (a ninth column of \"fixed\")")
 	cmd.Flag.StringVar(&tSeg, "ots", "", "Output Training Segmentation File")
 	cmd.Flag.StringVar(&featuresFile, "f", "", "Features Configuration File")
 	cmd.Flag.StringVar(&labelsFile, "l", "", "Dependency Labels Configuration File")
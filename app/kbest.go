@@ -0,0 +1,193 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/alg/transition"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+	"yap/nlp/format/mapping"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	nlp "yap/nlp/types"
+
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	KBest        int
+	outKBest     string
+	outKBestJSON string
+)
+
+// A KBestParse is one of the best parses of a sentence, its rank starting
+// at 1 for the best
+type KBestParse struct {
+	Rank  int      `json:"rank"`
+	Score float64  `json:"score"`
+	Lines []string `json:"-"`
+	*APISentence
+}
+
+type KBestSentence struct {
+	ID     int           `json:"sent_id"`
+	Parses []*KBestParse `json:"parses"`
+}
+
+// A KBestFormat returns a parsed configuration in the text format of the
+// command's output, one line per row, and in JSON
+type KBestFormat func(conf transition.Configuration) ([]string, *APISentence)
+
+func VerifyKBest() {
+	if KBest < 1 {
+		log.Fatalln("K best must be at least 1, got", KBest)
+	}
+	if KBest > 1 && len(outKBest) == 0 && len(outKBestJSON) == 0 {
+		log.Fatalln("K best parses require an output file (-okb or -okbjson)")
+	}
+	if KBest > 1 && Stream {
+		log.Fatalln("K best parses can not be streamed")
+	}
+}
+
+// KBestConfigOut logs the k-best configuration, if any
+func KBestConfigOut() {
+	if KBest < 2 {
+		return
+	}
+	log.Printf("K Best:\t\t\t%d", KBest)
+	if len(outKBest) > 0 {
+		log.Printf("K Best Out file:\t%s", outKBest)
+	}
+	if len(outKBestJSON) > 0 {
+		log.Printf("K Best JSON Out file:\t%s", outKBestJSON)
+	}
+}
+
+// KBestCorpus returns the k distinct best parses of each sentence from the
+// result parameters of a k-best beam; parses of different transition
+// sequences formatted the same are the same parse, of the best score
+func KBestCorpus(params []interface{}, format KBestFormat, k int) []*KBestSentence {
+	corpus := make([]*KBestSentence, len(params))
+	for i, param := range params {
+		sent := &KBestSentence{ID: i + 1}
+		seen := make(map[string]bool)
+		for _, scored := range param.(*search.ParseResultParameters).KBest {
+			if len(sent.Parses) == k {
+				break
+			}
+			lines, asJSON := format(scored.C)
+			key := strings.Join(lines, "\n")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			sent.Parses = append(sent.Parses, &KBestParse{
+				Rank:        len(sent.Parses) + 1,
+				Score:       scored.Score(),
+				Lines:       lines,
+				APISentence: asJSON,
+			})
+		}
+		corpus[i] = sent
+	}
+	return corpus
+}
+
+// WriteKBest writes the parses of every sentence in text format, each
+// preceded by comment lines of its sentence, rank and score
+func WriteKBest(filename string, corpus []*KBestSentence) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	for _, sent := range corpus {
+		for _, parse := range sent.Parses {
+			fmt.Fprintf(file, "# sent_id = %d\n", sent.ID)
+			fmt.Fprintf(file, "# parse_rank = %d\n", parse.Rank)
+			fmt.Fprintf(file, "# score = %v\n", parse.Score)
+			for _, line := range parse.Lines {
+				fmt.Fprintln(file, line)
+			}
+			fmt.Fprintln(file)
+		}
+	}
+	return nil
+}
+
+func WriteKBestJSON(filename string, corpus []*KBestSentence) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewEncoder(file).Encode(corpus)
+}
+
+// WriteKBestOutput writes the k-best parses of the result parameters to the
+// configured outputs
+func WriteKBestOutput(params []interface{}, format KBestFormat) {
+	corpus := KBestCorpus(params, format, KBest)
+	if len(outKBest) > 0 {
+		if err := WriteKBest(outKBest, corpus); err != nil {
+			log.Fatalln(err)
+		}
+		if allOut {
+			log.Println("Wrote up to", KBest, "best parses of", len(corpus), "sentences to", outKBest)
+		}
+	}
+	if len(outKBestJSON) > 0 {
+		if err := WriteKBestJSON(outKBestJSON, corpus); err != nil {
+			log.Fatalln(err)
+		}
+		if allOut {
+			log.Println("Wrote up to", KBest, "best parses of", len(corpus), "sentences in json to", outKBestJSON)
+		}
+	}
+}
+
+func conllLines(sent conll.Sentence) []string {
+	lines := make([]string, len(sent))
+	for i := range lines {
+		lines[i] = sent[i+1].String()
+	}
+	return lines
+}
+
+func mappingLines(mdConf *disambig.MDConfig) []string {
+	var buf bytes.Buffer
+	mapping.Write(&buf, []interface{}{mdConf})
+	return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+}
+
+// DepKBestFormat formats dependency parses in conll, deprojectivized
+// by the pseudo-projective scheme, if any
+func DepKBestFormat(conf transition.Configuration) ([]string, *APISentence) {
+	graph := conf.(nlp.LabeledDependencyGraph)
+	if len(pseudoProjective) > 0 {
+		graph = DeprojectivizeCorpus([]interface{}{graph}, pseudoProjective)[0].(nlp.LabeledDependencyGraph)
+	}
+	sent := conll.Graph2Conll(graph, EMHost, EMSuffix)
+	return conllLines(sent), &APISentence{DepTree: sent.JSON()}
+}
+
+// MDKBestFormat formats disambiguated paths in the mapping format
+func MDKBestFormat(conf transition.Configuration) ([]string, *APISentence) {
+	mdConf := conf.(*disambig.MDConfig)
+	return mappingLines(mdConf), &APISentence{MDLattice: lattice.Lattice2JSON(MDLattice(mdConf))}
+}
+
+// JointKBestFormat formats joint parses in conll
+func JointKBestFormat(conf transition.Configuration) ([]string, *APISentence) {
+	jointConf := conf.(*joint.JointConfig)
+	sent := conll.MorphGraph2Conll(jointConf)
+	return conllLines(sent), &APISentence{
+		MDLattice: lattice.Lattice2JSON(MDLattice(&jointConf.MDConfig)),
+		DepTree:   sent.JSON(),
+	}
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/alg/transition"
+	dep "yap/nlp/parser/dependency/transition"
+
+	"reflect"
+	"testing"
+)
+
+func TestKBestCorpus(t *testing.T) {
+	// the candidates of a sentence, best first, two of which format as the
+	// same parse
+	var (
+		candidates []*search.ScoredConfiguration
+		formatted  = make(map[transition.Configuration][]string)
+	)
+	for i, parse := range []string{"a", "b", "a", "c", "d"} {
+		conf := &dep.SimpleConfiguration{}
+		formatted[conf] = []string{parse}
+		candidates = append(candidates, &search.ScoredConfiguration{
+			C:              conf,
+			InternalScores: search.ScoreState{{Total: int64(10 - i)}},
+		})
+	}
+	format := func(conf transition.Configuration) ([]string, *APISentence) {
+		return formatted[conf], nil
+	}
+	params := []interface{}{&search.ParseResultParameters{KBest: candidates}}
+
+	// the duplicate is skipped before cutting to k
+	corpus := KBestCorpus(params, format, 3)
+	var parses []string
+	var scores []float64
+	for i, parse := range corpus[0].Parses {
+		if parse.Rank != i+1 {
+			t.Errorf("Got rank %d of parse %d", parse.Rank, i)
+		}
+		parses = append(parses, parse.Lines...)
+		scores = append(scores, parse.Score)
+	}
+	if !reflect.DeepEqual(parses, []string{"a", "b", "c"}) || !reflect.DeepEqual(scores, []float64{10, 9, 7}) {
+		t.Errorf("Got parses %v scored %v, expected [a b c] scored [10 9 7]", parses, scores)
+	}
+	if corpus := KBestCorpus(params, format, 10); len(corpus[0].Parses) != 4 {
+		t.Errorf("Got %d parses, expected the 4 distinct parses", len(corpus[0].Parses))
+	}
+}
@@ -0,0 +1,448 @@
+package app
+
+import (
+	"yap/nlp/format/conllu"
+	"yap/nlp/format/lattice"
+	"yap/nlp/format/raw"
+	"yap/nlp/parser/ma"
+	nlp "yap/nlp/types"
+
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	lexStatsGoldFile  string
+	lexStatsParamFunc string
+	lexStatsTop       int
+	lexStatsSuggest   int
+)
+
+// An OOVToken is an unknown token with its number of occurences and the
+// analyses the analyzer suggests for it
+type OOVToken struct {
+	Token    string   `json:"token"`
+	Count    int      `json:"count"`
+	Analyses []string `json:"analyses"`
+}
+
+// A PathBucket counts the sentences whose lattices have at most MaxPaths
+// paths (and more than the previous bucket's)
+type PathBucket struct {
+	MaxPaths  float64 `json:"max_paths"`
+	Sentences int     `json:"sentences"`
+}
+
+// GoldCoverage counts the gold spellouts missing from analyzed lattices, as
+// added when combining gold and ambiguous lattices for training
+type GoldCoverage struct {
+	Sentences        int     `json:"sentences"`
+	Tokens           int     `json:"tokens"`
+	SpelloutsAdded   int     `json:"spellouts_added"`
+	CoveredSentences int     `json:"covered_sentences"`
+	FailedSentences  int     `json:"failed_sentences"`
+	TokenCoverage    float64 `json:"token_coverage"`
+	SentenceCoverage float64 `json:"sentence_coverage"`
+}
+
+type LexStatsReport struct {
+	Sentences         int           `json:"sentences"`
+	Tokens            int           `json:"tokens"`
+	Types             int           `json:"types"`
+	OOVTokens         int           `json:"oov_tokens"`
+	OOVTypes          int           `json:"oov_types"`
+	OOVTokenRate      float64       `json:"oov_token_rate"`
+	OOVTypeRate       float64       `json:"oov_type_rate"`
+	AvgSpellouts      float64       `json:"avg_spellouts"`
+	MaxSpellouts      int           `json:"max_spellouts"`
+	MaxSpelloutsToken string        `json:"max_spellouts_token"`
+	AvgPaths          float64       `json:"avg_paths"`
+	AvgLog10Paths     float64       `json:"avg_log10_paths"`
+	MaxPaths          float64       `json:"max_paths"`
+	MaxPathsSentence  int           `json:"max_paths_sentence"`
+	PathHistogram     []*PathBucket `json:"path_histogram"`
+	Gold              *GoldCoverage `json:"gold_coverage,omitempty"`
+	TopOOV            []*OOVToken   `json:"top_oov"`
+
+	spellouts      int
+	sumPaths       float64
+	sumLog10Paths  float64
+	suggestions    map[string][]string
+	paramFunc      nlp.MDParam
+	maxSuggestions int
+}
+
+func NewLexStatsReport(paramFunc nlp.MDParam, maxSuggestions int) *LexStatsReport {
+	return &LexStatsReport{
+		suggestions:    make(map[string][]string),
+		paramFunc:      paramFunc,
+		maxSuggestions: maxSuggestions,
+	}
+}
+
+// pathBucket returns the index of the histogram bucket of a number of paths;
+// buckets grow by powers of 10
+func pathBucket(paths float64) int {
+	if paths <= 1 {
+		return 0
+	}
+	return int(math.Ceil(math.Log10(paths)))
+}
+
+// AddSentence adds the spellouts and paths of an analyzed sentence, and the
+// analyses of its unknown tokens (by the OOV vector of the analyzer)
+func (r *LexStatsReport) AddSentence(lat nlp.LatticeSentence, oovVector nlp.BasicSentence) {
+	r.Sentences++
+	paths := 1.0
+	for i := range lat {
+		lat[i].GenSpellouts()
+		numSpellouts := len(lat[i].Spellouts)
+		r.spellouts += numSpellouts
+		if numSpellouts > r.MaxSpellouts {
+			r.MaxSpellouts, r.MaxSpelloutsToken = numSpellouts, string(lat[i].Token)
+		}
+		if numSpellouts > 0 {
+			paths *= float64(numSpellouts)
+		}
+		token := string(lat[i].Token)
+		if i < len(oovVector) && oovVector[i] == nlp.Token("1") {
+			if _, exists := r.suggestions[token]; !exists {
+				r.suggestions[token] = r.analyses(lat[i].Spellouts)
+			}
+		}
+	}
+	r.sumPaths += paths
+	r.sumLog10Paths += math.Log10(paths)
+	if paths > r.MaxPaths {
+		r.MaxPaths, r.MaxPathsSentence = paths, r.Sentences
+	}
+	bucket := pathBucket(paths)
+	for len(r.PathHistogram) <= bucket {
+		r.PathHistogram = append(r.PathHistogram, &PathBucket{MaxPaths: math.Pow10(len(r.PathHistogram))})
+	}
+	r.PathHistogram[bucket].Sentences++
+}
+
+// analyses returns the distinct projections of the spellouts of a token
+func (r *LexStatsReport) analyses(spellouts nlp.Spellouts) []string {
+	seen := make(map[string]bool, len(spellouts))
+	analyses := make([]string, 0, r.maxSuggestions)
+	for _, spellout := range spellouts {
+		if len(analyses) == r.maxSuggestions {
+			break
+		}
+		analysis := nlp.ProjectSpellout(spellout, r.paramFunc)
+		if !seen[analysis] {
+			seen[analysis] = true
+			analyses = append(analyses, analysis)
+		}
+	}
+	return analyses
+}
+
+// AddGold combines the gold lattice of a sentence with its analysis and
+// counts the gold spellouts missing from it
+func (r *LexStatsReport) AddGold(goldLat, ambLat nlp.LatticeSentence) {
+	if r.Gold == nil {
+		r.Gold = &GoldCoverage{}
+	}
+	r.Gold.Sentences++
+	r.Gold.Tokens += len(goldLat)
+	if len(goldLat) != len(ambLat) {
+		log.Println("Sentence", r.Gold.Sentences, "has", len(goldLat), "gold tokens, analyzed", len(ambLat))
+		r.Gold.FailedSentences++
+		return
+	}
+	m, spelloutsAdded := CombineToGoldMorph(goldLat, ambLat)
+	if m == nil {
+		r.Gold.FailedSentences++
+		return
+	}
+	r.Gold.SpelloutsAdded += spelloutsAdded
+	if spelloutsAdded == 0 {
+		r.Gold.CoveredSentences++
+	}
+}
+
+// Finish computes the rates and averages of the report, and the top
+// unknown tokens by the analyzer's stats
+func (r *LexStatsReport) Finish(stats *ma.AnalyzeStats, top int) {
+	r.Tokens, r.Types = stats.TotalTokens, len(stats.UniqTokens)
+	r.OOVTokens, r.OOVTypes = stats.OOVTokens, len(stats.UniqOOVTokens)
+	if r.Tokens > 0 {
+		r.OOVTokenRate = float64(r.OOVTokens) / float64(r.Tokens)
+		r.AvgSpellouts = float64(r.spellouts) / float64(r.Tokens)
+	}
+	if r.Types > 0 {
+		r.OOVTypeRate = float64(r.OOVTypes) / float64(r.Types)
+	}
+	if r.Sentences > 0 {
+		r.AvgPaths = r.sumPaths / float64(r.Sentences)
+		r.AvgLog10Paths = r.sumLog10Paths / float64(r.Sentences)
+	}
+	if r.Gold != nil {
+		if r.Gold.Tokens > 0 {
+			r.Gold.TokenCoverage = 1 - float64(r.Gold.SpelloutsAdded)/float64(r.Gold.Tokens)
+		}
+		if r.Gold.Sentences > 0 {
+			r.Gold.SentenceCoverage = float64(r.Gold.CoveredSentences) / float64(r.Gold.Sentences)
+		}
+	}
+	r.TopOOV = make([]*OOVToken, 0, len(stats.UniqOOVTokens))
+	for token, count := range stats.UniqOOVTokens {
+		r.TopOOV = append(r.TopOOV, &OOVToken{token, count, r.suggestions[token]})
+	}
+	sort.Slice(r.TopOOV, func(i, j int) bool {
+		if r.TopOOV[i].Count != r.TopOOV[j].Count {
+			return r.TopOOV[i].Count > r.TopOOV[j].Count
+		}
+		return r.TopOOV[i].Token < r.TopOOV[j].Token
+	})
+	if top < len(r.TopOOV) {
+		r.TopOOV = r.TopOOV[:top]
+	}
+}
+
+func (r *LexStatsReport) Log() {
+	log.Println("Sentences:", r.Sentences)
+	log.Println()
+	log.Printf("%-14s\t%s\t%s\t%s", "", "Total", "OOV", "OOV Rate")
+	log.Printf("%-14s\t%d\t%d\t%.4f", "Tokens", r.Tokens, r.OOVTokens, r.OOVTokenRate)
+	log.Printf("%-14s\t%d\t%d\t%.4f", "Types", r.Types, r.OOVTypes, r.OOVTypeRate)
+	log.Println()
+	log.Printf("Spellouts per token:\tavg %.2f\tmax %d (%s)", r.AvgSpellouts, r.MaxSpellouts, r.MaxSpelloutsToken)
+	log.Printf("Paths per sentence:\tavg %.4g\tavg log10 %.2f\tmax %.4g (sentence %d)", r.AvgPaths, r.AvgLog10Paths, r.MaxPaths, r.MaxPathsSentence)
+	log.Println()
+	log.Printf("%-12s\t%s", "Paths <=", "Sentences")
+	for _, bucket := range r.PathHistogram {
+		if bucket.Sentences > 0 {
+			log.Printf("%-12.4g\t%d", bucket.MaxPaths, bucket.Sentences)
+		}
+	}
+	if r.Gold != nil {
+		log.Println()
+		log.Println("Gold coverage")
+		log.Printf("Tokens:\t\t%d\tmissing %d\tcoverage %.4f", r.Gold.Tokens, r.Gold.SpelloutsAdded, r.Gold.TokenCoverage)
+		log.Printf("Sentences:\t\t%d\tcovered %d\tcoverage %.4f", r.Gold.Sentences, r.Gold.CoveredSentences, r.Gold.SentenceCoverage)
+		if r.Gold.FailedSentences > 0 {
+			log.Printf("Failed:\t\t%d", r.Gold.FailedSentences)
+		}
+	}
+	if len(r.TopOOV) > 0 {
+		log.Println()
+		log.Println("Top OOV tokens")
+		for _, oov := range r.TopOOV {
+			log.Printf("%-20s\t%d\t%s", oov.Token, oov.Count, strings.Join(oov.Analyses, " | "))
+		}
+	}
+}
+
+// readBackLattice returns an analyzed lattice as the parsers read it from
+// the analyzer's output file
+func readBackLattice(lat nlp.LatticeSentence) nlp.LatticeSentence {
+	var buf bytes.Buffer
+	lattice.Write(&buf, []lattice.Lattice{lattice.Sentence2Lattice(lat, nil)})
+	lats, err := lattice.Read(&buf, 0)
+	if err != nil || len(lats) != 1 {
+		panic(fmt.Sprintf("Failed reading back analyzed lattice - %v", err))
+	}
+	return lattice.Lattice2Sentence(lats[0], EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+}
+
+// lexStatsInput reads the tokens of the input sentences, from the raw or
+// CoNLL-U input, or else from the gold lattices
+func lexStatsInput(goldLats []interface{}) []nlp.BasicSentence {
+	if useConllU {
+		conllSents, _, err := conllu.ReadFile(conlluFile, limit)
+		if err != nil {
+			panic(fmt.Sprintf("Failed reading CoNLL-U file - %v", err))
+		}
+		sents := make([]nlp.BasicSentence, len(conllSents))
+		for i, sent := range conllSents {
+			sents[i] = make([]nlp.Token, len(sent.Tokens))
+			for j, token := range sent.Tokens {
+				sents[i][j] = nlp.Token(token)
+			}
+		}
+		return sents
+	}
+	if len(inRawFile) > 0 {
+		sents, err := raw.ReadFile(inRawFile, limit)
+		if err != nil {
+			panic(fmt.Sprintf("Failed reading raw file - %v", err))
+		}
+		return sents
+	}
+	sents := make([]nlp.BasicSentence, len(goldLats))
+	for i, goldLat := range goldLats {
+		lat := goldLat.(nlp.LatticeSentence)
+		sents[i] = make([]nlp.Token, len(lat))
+		for j := range lat {
+			lat[j].GenToken()
+			sents[i][j] = lat[j].Token
+		}
+	}
+	return sents
+}
+
+func LexStatsConfigOut() {
+	log.Println("Configuration")
+	if len(dictFile) > 0 {
+		log.Printf("MA Dict:\t\t%s", dictFile)
+	} else {
+		log.Printf("Heb Prefix:\t\t%s", prefixFile)
+		log.Printf("Heb Lexicon:\t\t%s", lexiconFile)
+	}
+	log.Printf("Param Func:\t\t%s", lexStatsParamFunc)
+	log.Printf("Top:\t\t\t%d", lexStatsTop)
+	log.Printf("Limit:\t\t%v", limit)
+	log.Println()
+	log.Println("Data")
+	if useConllU {
+		log.Printf("CoNLL-U Input:\t%s", conlluFile)
+	} else if len(inRawFile) > 0 {
+		log.Printf("Raw Input:\t\t%s", inRawFile)
+	}
+	if len(lexStatsGoldFile) > 0 {
+		log.Printf("Gold Dis. Lat.:\t%s", lexStatsGoldFile)
+	}
+	if len(evalJSONFile) > 0 {
+		log.Printf("Out (json):\t\t%s", evalJSONFile)
+	}
+	log.Println()
+}
+
+func LexStats(cmd *commander.Command, args []string) error {
+	useConllU = len(conlluFile) > 0
+	var REQUIRED_FLAGS []string
+	if !useConllU && len(inRawFile) == 0 && len(lexStatsGoldFile) == 0 {
+		log.Println("Lexicon stats require an input raw (-raw), CoNLL-U (-conllu) or gold lattice (-gold) file")
+		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "raw")
+	}
+	if len(dictFile) == 0 {
+		var found bool
+		if prefixFile, found = locateFile(prefixFile, DEFAULT_DATA_DIRS); !found {
+			REQUIRED_FLAGS = append(REQUIRED_FLAGS, "prefix")
+		}
+		if lexiconFile, found = locateFile(lexiconFile, DEFAULT_DATA_DIRS); !found {
+			REQUIRED_FLAGS = append(REQUIRED_FLAGS, "lexicon")
+		}
+	}
+	VerifyFlags(cmd, REQUIRED_FLAGS)
+	for _, file := range []string{inRawFile, conlluFile, lexStatsGoldFile} {
+		if len(file) > 0 && !VerifyExists(file) {
+			os.Exit(1)
+		}
+	}
+	paramFunc, exists := nlp.MDParams[lexStatsParamFunc]
+	if !exists {
+		log.Fatalln("Unknown param func", lexStatsParamFunc, "options are", nlp.AllParamFuncNames)
+	}
+	LexStatsConfigOut()
+	if useConllU {
+		nlp.InitOpenParamFamily("UD")
+	} else {
+		nlp.InitOpenParamFamily("HEBTB")
+	}
+
+	stats := new(ma.AnalyzeStats)
+	stats.Init()
+	var analyzer ma.MorphologicalAnalyzer
+	if len(dictFile) > 0 {
+		log.Println("Reading Morphological Analyzer Dictionary")
+		maData := new(ma.MADict)
+		if err := maData.ReadFile(dictFile); err != nil {
+			panic(fmt.Sprintf("Failed reading MA dict file - %v", err))
+		}
+		maData.ComputeOOVMSRs(maxOOVMSRPerPOS)
+		maData.Init()
+		maData.Stats = stats
+		analyzer = maData
+	} else {
+		maData := LoadHebMA(prefixFile, lexiconFile)
+		maData.Stats = stats
+		analyzer = maData
+	}
+	log.Println()
+
+	var goldLats []interface{}
+	if len(lexStatsGoldFile) > 0 {
+		SetupMDEnum()
+		lDis, lDisE := lattice.ReadFile(lexStatsGoldFile, limit)
+		if lDisE != nil {
+			log.Println(lDisE)
+			return lDisE
+		}
+		goldLats = lattice.Lattice2SentenceCorpus(lDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+	}
+	sents := lexStatsInput(goldLats)
+	if goldLats != nil && len(goldLats) != len(sents) {
+		log.Fatalln("Gold file has", len(goldLats), "sentences, input has", len(sents))
+	}
+
+	log.Println("Running Morphological Analysis of", len(sents), "sentences")
+	report := NewLexStatsReport(paramFunc, lexStatsSuggest)
+	prefix := log.Prefix()
+	for i, sent := range sents {
+		log.SetPrefix(fmt.Sprintf("%v graph# %v ", prefix, i))
+		lat, oovVector := analyzer.Analyze(sent.Tokens())
+		report.AddSentence(lat, oovVector.(nlp.BasicSentence))
+		if goldLats != nil {
+			report.AddGold(goldLats[i].(nlp.LatticeSentence), readBackLattice(lat))
+		}
+	}
+	log.SetPrefix(prefix)
+	report.Finish(stats, lexStatsTop)
+	log.Println()
+	report.Log()
+	if len(evalJSONFile) > 0 {
+		WriteEvalJSON(evalJSONFile, report)
+	}
+	return nil
+}
+
+func LexStatsCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       LexStats,
+		UsageLine: "lexstats <file options> [arguments]",
+		Short:     "reports how well a morphological analyzer covers a corpus",
+		Long: `
+reports how well a morphological analyzer covers a corpus
+
+	$ ./yap lexstats -raw <raw file> [-gold <dis. lattice file>] [-prefix <prefix file> -lexicon <lexicon file> | -dict <ma dict file>] [options]
+
+Analyzes the input (raw, CoNLL-U, or the tokens of the gold lattices) with
+the Hebrew lexicon (or a data-driven MA dictionary), and reports the OOV
+rate by token and by type, the average and max number of spellouts per
+token, the number of lattice paths per sentence, and the most frequent
+OOV tokens with the analyses suggested for them.
+
+Given gold disambiguated lattices, also reports the share of gold
+spellouts found in the analyzed lattices, i.e. those not added when
+combining the two for training.
+`,
+		Flag: *flag.NewFlagSet("lexstats", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&prefixFile, "prefix", "bgupreflex_withdef.utf8.hr", "Prefix file for morphological analyzer")
+	cmd.Flag.StringVar(&lexiconFile, "lexicon", "bgulex.utf8.hr", "Lexicon file for morphological analyzer")
+	cmd.Flag.BoolVar(&nnpnofeats, "addnnpnofeats", false, "Add NNP in lex but without features")
+	cmd.Flag.StringVar(&dictFile, "dict", "", "Optional - MA Dictionary file, used instead of the Hebrew lexicon")
+	cmd.Flag.IntVar(&maxOOVMSRPerPOS, "maxmsrperpos", 10, "For OOV tokens, max MSRs per POS to add (with -dict)")
+	cmd.Flag.StringVar(&inRawFile, "raw", "", "Input raw (tokenized) file")
+	cmd.Flag.StringVar(&conlluFile, "conllu", "", "CoNLL-U-format input file")
+	cmd.Flag.StringVar(&lexStatsGoldFile, "gold", "", "Optional - Gold Disambiguated Lattice File, for gold coverage")
+	cmd.Flag.StringVar(&lexStatsParamFunc, "p", "Form_POS_Prop", "Param Func to show suggested analyses by: ["+nlp.AllParamFuncNames+"]")
+	cmd.Flag.IntVar(&lexStatsTop, "top", 20, "Number of most frequent OOV tokens to list")
+	cmd.Flag.IntVar(&lexStatsSuggest, "suggest", 5, "Max number of suggested analyses per OOV token")
+	cmd.Flag.IntVar(&limit, "limit", 0, "Limit input set")
+	cmd.Flag.StringVar(&evalJSONFile, "json", "", "Optional - Output JSON File")
+	return cmd
+}
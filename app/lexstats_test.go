@@ -0,0 +1,88 @@
+package app
+
+import (
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/ma"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"math"
+	"strings"
+	"testing"
+)
+
+// testLattices reads lattice sentences from their lattice file rows
+func testLattices(t *testing.T, rows string) []nlp.LatticeSentence {
+	lats, err := lattice.Read(strings.NewReader(rows), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	eWord, ePOS, eWPOS := util.NewEnumSet(10, "EWord"), util.NewEnumSet(10, "EPOS"), util.NewEnumSet(10, "EWPOS")
+	eMorphFeat, eMHost, eMSuffix := util.NewEnumSet(10, "EMorphFeat"), util.NewEnumSet(10, "EMHost"), util.NewEnumSet(10, "EMSuffix")
+	sents := make([]nlp.LatticeSentence, len(lats))
+	for i, lat := range lats {
+		sents[i] = lattice.Lattice2Sentence(lat, eWord, ePOS, eWPOS, eMorphFeat, eMHost, eMSuffix)
+	}
+	return sents
+}
+
+func TestLexStatsReport(t *testing.T) {
+	// HBIT GDWL of 2 spellouts each, and an unknown token XYZ
+	amb := testLattices(t, "0	1	H	_	DEF	DEF	_	1\n0	2	HBIT	_	NN	NN	gen=M|num=S	1\n1	2	BIT	_	NN	NN	gen=M|num=S	1\n2	3	GDWL	_	JJ	JJ	gen=M|num=S	2\n2	3	GDWL	_	NN	NN	gen=M|num=S	2\n\n"+
+		"0	1	XYZ	_	NNP	NNP	_	1\n0	1	XYZ	_	NN	NN	_	1\n\n")
+	// analyzed lattices are of the input tokens
+	amb[0][0].Token, amb[0][1].Token, amb[1][0].Token = "HBIT", "GDWL", "XYZ"
+	oovVectors := []nlp.BasicSentence{{"0", "0"}, {"1"}}
+	stats := &ma.AnalyzeStats{TotalTokens: 3, OOVTokens: 1}
+	stats.Init()
+	for _, token := range []string{"HBIT", "GDWL", "XYZ"} {
+		stats.AddToken(token)
+	}
+	stats.AddOOVToken("XYZ")
+
+	report := NewLexStatsReport(nlp.MDParams["Form_POS"], 5)
+	for i, lat := range amb {
+		report.AddSentence(lat, oovVectors[i])
+	}
+	// the first sentence's gold analysis is in its lattice, the second's is not
+	gold := testLattices(t, "0	1	H	_	DEF	DEF	_	1\n1	2	BIT	_	NN	NN	gen=M|num=S	1\n2	3	GDWL	_	JJ	JJ	gen=M|num=S	2\n\n"+
+		"0	1	XYZ	_	VB	VB	_	1\n\n")
+	for i, lat := range gold {
+		report.AddGold(lat, amb[i])
+	}
+	report.Finish(stats, 5)
+
+	if report.Sentences != 2 || report.Tokens != 3 || report.Types != 3 || report.OOVTokens != 1 || report.OOVTypes != 1 {
+		t.Errorf("Got %d sentences, %d tokens, %d types, %d OOV tokens, %d OOV types, expected 2 3 3 1 1",
+			report.Sentences, report.Tokens, report.Types, report.OOVTokens, report.OOVTypes)
+	}
+	if report.AvgSpellouts != 2 || report.MaxSpellouts != 2 || report.MaxSpelloutsToken != "HBIT" {
+		t.Errorf("Got avg %v max %d (%s) spellouts per token, expected avg 2 max 2 (HBIT)", report.AvgSpellouts, report.MaxSpellouts, report.MaxSpelloutsToken)
+	}
+	if report.AvgPaths != 3 || report.MaxPaths != 4 || report.MaxPathsSentence != 1 {
+		t.Errorf("Got avg %v max %v (sentence %d) paths per sentence, expected avg 3 max 4 (sentence 1)", report.AvgPaths, report.MaxPaths, report.MaxPathsSentence)
+	}
+	if len(report.PathHistogram) != 2 || report.PathHistogram[0].Sentences != 0 || *report.PathHistogram[1] != (PathBucket{10, 2}) {
+		t.Errorf("Got path histogram %v, expected 2 sentences of at most 10 paths", report.PathHistogram)
+	}
+	if report.Gold == nil {
+		t.Fatal("Got no gold coverage")
+	}
+	if expected := (GoldCoverage{2, 3, 1, 1, 0, report.Gold.TokenCoverage, 0.5}); *report.Gold != expected || math.Abs(expected.TokenCoverage-2.0/3) > 1e-9 {
+		t.Errorf("Got gold coverage %+v, expected %+v", report.Gold, expected)
+	}
+	if len(report.TopOOV) != 1 || report.TopOOV[0].Token != "XYZ" || report.TopOOV[0].Count != 1 || len(report.TopOOV[0].Analyses) != 2 {
+		t.Errorf("Got %d top OOV tokens, expected XYZ of 2 analyses", len(report.TopOOV))
+	}
+}
+
+func TestPathBucket(t *testing.T) {
+	for _, test := range []struct {
+		paths  float64
+		bucket int
+	}{{0, 0}, {1, 0}, {2, 1}, {10, 1}, {11, 2}, {1000, 3}} {
+		if bucket := pathBucket(test.paths); bucket != test.bucket {
+			t.Errorf("Got bucket %d for %v paths, expected %d", bucket, test.paths, test.bucket)
+		}
+	}
+}
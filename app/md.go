@@ -179,8 +179,17 @@ func MDConfigOut(outModelFile string, b search.Interface, t transition.Transitio
 	log.Printf("Beam:\t\t%s", b.Name())
 	log.Printf("Transition System:\t%s", t.Name())
 	log.Printf("Iterations:\t\t%d", Iterations)
+	if Shuffle {
+		log.Printf("Shuffle Seed:\t\t%d", ShuffleSeed)
+	}
+	if TrainShards > 1 {
+		log.Printf("Train Shards:\t\t%d", TrainShards)
+	}
+	log.Printf("Update Strategy:\t%s", ViolationStrategy)
+	PartialConfigOut("tokens missing from the disambiguated training lattices")
 	log.Printf("Beam Size:\t\t%d", BeamSize)
 	log.Printf("Beam Concurrent:\t%v", ConcurrentBeam)
+	log.Printf("Parse Workers:\t\t%d", Workers)
 	log.Printf("Parameter Func:\t%v", paramFuncName)
 	log.Printf("Use POP:\t\t%v", UsePOP)
 	log.Printf("Infuse Gold Dev:\t%v", combineGold)
@@ -218,6 +227,9 @@ func MDConfigOut(outModelFile string, b search.Interface, t transition.Transitio
 		}
 	}
 	log.Printf("Out (disamb.) file:\t\t\t%s", outMap)
+	KBestConfigOut()
+	ConfidenceConfigOut()
+	ConstrainedConfigOut("fixed paths of the input lattices")
 }
 
 func MDTrainAndParse(cmd *commander.Command, args []string) error {
@@ -255,6 +267,16 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "f")
 	}
 	VerifyFlags(cmd, REQUIRED_FLAGS)
+	VerifyKBest()
+	VerifyConfidence()
+	VerifyConstrained()
+	if Constrained && useConllU {
+		log.Fatalln("Fixed lattice paths are only read from lattice format input")
+	}
+	VerifyPartial()
+	if Partial && UseWB {
+		log.Fatalln("Training from partial disambiguation is not supported for word based MD")
+	}
 
 	var (
 		outModelFile string = fmt.Sprintf("%s.b%d", modelFile, BeamSize)
@@ -292,7 +314,13 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		// start processing - setup enumerations
 		log.Println("Setup enumerations")
 	}
+	mdTransName := "md"
+	if UseWB {
+		mdTransName = "md-wb"
+	}
+	modelHeader := NewModelHeader("md", mdTransName, BeamSize, featuresFile, nil, paramFuncName)
 	SetupMDEnum()
+	LoadCheckpoint(modelHeader)
 	if UseWB {
 		mdTrans.(*disambig.MDWBTrans).POP = POP
 		mdTrans.(*disambig.MDWBTrans).Transitions = ETrans
@@ -413,8 +441,15 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		}
 		// combined = combined[:NUM_SENTS]
 		goldSequences := TrainingSequences(combined, GetMDConfigAsLattices, GetMDConfigAsMappings)
+		var numPartial int
+		if Partial {
+			numPartial = PartialMDCorpus(goldSequences, combined)
+		}
 		if allOut {
 			log.Println("Generated", len(goldSequences), "training sequences")
+			if Partial {
+				log.Println(numPartial, "of them partially disambiguated")
+			}
 			log.Println()
 			// util.LogMemory()
 			log.Println("Training", Iterations, "iteration(s)")
@@ -611,7 +646,9 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 				evaluator = MakeMorphEvalStopCondition(convAmbLat, convCombined, testAmbLat, testCombined, decodeTestBeam, perceptron.InstanceDecoder(deterministic), BeamSize)
 			}
 		}
-		_ = Train(goldSequences, Iterations, modelFile, model, perceptron.EarlyUpdateInstanceDecoder(beam), perceptron.InstanceDecoder(deterministic), evaluator)
+		modelHeader.AddTrainingFiles(tLatDis, tLatAmb)
+		trainingHeader = modelHeader
+		trainer := Train(goldSequences, Iterations, modelFile, model, perceptron.EarlyUpdateInstanceDecoder(beam), LatentGoldDecoder(beam, deterministic), evaluator)
 
 		if allOut {
 			log.Println("Done Training")
@@ -622,7 +659,9 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 				model.Serialize(-1),
 				EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
 			}
-			WriteModel(outModelFile, serialization)
+			// the stop condition may end training before or after Iterations
+			modelHeader.TrainIterations = trainer.TrainI
+			WriteModel(outModelFile, modelHeader, serialization)
 			log.Println("Done")
 			// log.Print("Parsing test")
 		}
@@ -631,7 +670,7 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 	if allOut {
 		log.Println("Found model file", outModelFile, " ... loading model")
 	}
-	serialization := ReadModel(outModelFile)
+	serialization := LoadModel(outModelFile, modelHeader)
 	model.Deserialize(serialization.WeightModel)
 	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix, serialization.EMorphProp, serialization.ETrans, serialization.ETokens
 
@@ -783,7 +822,15 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 	beam.ShortTempAgenda = true
 	beam.Model = model
 
-	mappings := Parse(predAmbLat, beam)
+	beam.KBest = KBest
+	beam.ReturnAgenda = Confidence
+	if Constrained {
+		predAmbLat = ConstrainCorpus(predAmbLat, FixedPathsCorpus(lAmb, predAmbLat))
+	}
+	mappings, params := ParseWithParams(predAmbLat, beam)
+	if KBest > 1 {
+		WriteKBestOutput(params, MDKBestFormat)
+	}
 
 	/*	if allOut {
 			log.Println("Converting", len(parsedGraphs), "to conll")
@@ -809,7 +856,11 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 	if allOut {
 		log.Println("Writing to mapping file")
 	}
-	mapping.WriteFile(outMap, mappings)
+	if Confidence {
+		mapping.WriteConfidenceFile(outMap, mappings, SpelloutConfidenceCorpus(params, MDConfOf))
+	} else {
+		mapping.WriteFile(outMap, mappings)
+	}
 
 	if allOut {
 		log.Println("Wrote", len(mappings), "in mapping format to", outMap)
@@ -831,6 +882,14 @@ runs standalone morphological disambiguation training and parsing
 		Flag: *flag.NewFlagSet("md", flag.ExitOnError),
 	}
 	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
+	cmd.Flag.IntVar(&Workers, "workers", 1, "Number of sentences to parse concurrently (each with its own beam)")
+	cmd.Flag.BoolVar(&Shuffle, "shuffle", false, "Shuffle the training instances every iteration")
+	cmd.Flag.Int64Var(&ShuffleSeed, "seed", 1, "Random seed for shuffling the training instances")
+	cmd.Flag.IntVar(&TrainShards, "shards", 1, "Number of training shards trained concurrently, mixing their weights every iteration (iterative parameter mixing)")
+	cmd.Flag.BoolVar(&CountUpdates, "countupdates", false, "Count the updates of each feature in the per-iteration models, for pruning rarely updated features (yap model prune -minupdates)")
+	cmd.Flag.StringVar(&ViolationStrategy, "update", search.EARLY_UPDATE, "Violation-fixing update strategy of beam training [early, max-violation, latest, full]")
+	cmd.Flag.StringVar(&resumeFile, "resume", "", "Optional - Resume training from a checkpoint (model.temp.iN file written with -checkpoint)")
+	cmd.Flag.BoolVar(&checkpointTraining, "checkpoint", false, "Write a training checkpoint (model.temp.iN) every iteration, which training can be resumed from")
 	cmd.Flag.IntVar(&Iterations, "it", 1, "Minimum Number of Perceptron Iterations")
 	cmd.Flag.IntVar(&mdBeamSize, "b", 32, "Beam Size")
 	cmd.Flag.StringVar(&modelFile, "m", "model", "Prefix for model file ({m}.b{b}.model)")
@@ -843,6 +902,13 @@ runs standalone morphological disambiguation training and parsing
 	cmd.Flag.StringVar(&test, "test", "", "Test Ambiguous Lattices File")
 	cmd.Flag.StringVar(&testGold, "testgold", "", "Optional - Gold Test Lattices File (for infusion into test ambiguous)")
 	cmd.Flag.StringVar(&outMap, "om", "", "Output Mapping File")
+	cmd.Flag.IntVar(&KBest, "kbest", 1, "Optional - Number of best distinct paths per sentence to output (-okb, -okbjson)")
+	cmd.Flag.StringVar(&outKBest, "okb", "", "Optional - Output K Best Mapping File (with # parse_rank and # score comments)")
+	cmd.Flag.StringVar(&outKBestJSON, "okbjson", "", "Optional - Output K Best JSON File")
+	cmd.Flag.BoolVar(&Confidence, "conf", false, "Optional - Output spellout confidence scores of the final beam as a last mapping column")
+	cmd.Flag.Float64Var(&ConfidenceTemp, "conftemp", 0, "Optional - Softmax temperature of confidence scores (0 for the standard deviation of the beam's scores)")
+	cmd.Flag.BoolVar(&Constrained, "constrained", false, "Optional - Constrain disambiguation to the paths through the input lattice's fixed edges (a ninth column of \"fixed\")")
+	cmd.Flag.BoolVar(&Partial, "partial", false, "Optional - Train from partially disambiguated sentences, whose tokens missing from the disambiguated lattices are latent")
 	cmd.Flag.StringVar(&mdFeaturesFile, "f", "standalone.md.yaml", "Features Configuration File")
 	cmd.Flag.StringVar(&paramFuncName, "p", "Funcs_Main_POS_Both_Prop", "Param Func types: ["+nlp.AllParamFuncNames+"]")
 	cmd.Flag.BoolVar(&AlignBeam, "align", false, "Use Beam Alignment")
@@ -0,0 +1,240 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/util"
+
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+const (
+	// MODEL_MAGIC prefixes model files with a header; legacy model files
+	// are gob streams of a Serialization, which never start with a 0 byte
+	MODEL_MAGIC          = "\x00yap model\n"
+	MODEL_FORMAT_VERSION = 1
+)
+
+// A ModelHeader follows MODEL_MAGIC in a model file, preceding the
+// Serialization, and records how the model was trained
+type ModelHeader struct {
+	FormatVersion    int
+	YapVersion       string
+	Parser           string // dep, mst, md or joint
+	TransitionSystem string
+	BeamSize         int
+	FeaturesFile     string
+	Features         string // contents of the features file
+	Labels           []string
+	ParamFunc        string
+	TrainingFiles    map[string]string // file name -> md5
+	TrainIterations  int
+	Shuffle          bool
+	ShuffleSeed      int64
+	TrainShards      int
+	UpdateStrategy   string
+	DynamicOracle    bool // greedy parser trained with the dynamic oracle
+	Explore          float64
+	ExploreAfter     int
+	PseudoProjective string
+	LiftedLabels     []string   // encoded labels of lifted arcs, added to the relations
+	Stop             *StopState // stop condition state of a checkpoint, nil otherwise
+}
+
+// header of the model being trained, written along with the temporary
+// per-iteration models
+var trainingHeader *ModelHeader
+
+func NewModelHeader(parser, transitionSystem string, beamSize int, featuresFile string, labels []string, paramFunc string) *ModelHeader {
+	header := &ModelHeader{
+		FormatVersion:    MODEL_FORMAT_VERSION,
+		YapVersion:       VERSION,
+		Parser:           parser,
+		TransitionSystem: transitionSystem,
+		BeamSize:         beamSize,
+		FeaturesFile:     featuresFile,
+		Labels:           labels,
+		ParamFunc:        paramFunc,
+		TrainingFiles:    make(map[string]string),
+	}
+	if Shuffle {
+		header.Shuffle, header.ShuffleSeed = true, ShuffleSeed
+	}
+	if TrainShards > 1 {
+		header.TrainShards = TrainShards
+	}
+	if ViolationStrategy != search.EARLY_UPDATE {
+		header.UpdateStrategy = ViolationStrategy
+	}
+	if DynamicOracle {
+		header.DynamicOracle, header.Explore, header.ExploreAfter = true, Explore, ExploreAfter
+	}
+	header.PseudoProjective = pseudoProjective
+	if len(featuresFile) > 0 {
+		features, err := ioutil.ReadFile(featuresFile)
+		if err != nil {
+			log.Fatalln("Failed reading feature configuration file:", featuresFile, err)
+		}
+		header.Features = string(features)
+	}
+	return header
+}
+
+// AddTrainingFiles records the md5 hashes of the training files
+func (h *ModelHeader) AddTrainingFiles(files ...string) {
+	for _, file := range files {
+		if len(file) == 0 {
+			continue
+		}
+		md5, err := util.MD5File(file)
+		if err != nil {
+			log.Println("Failed computing md5 of training file", file, err)
+			continue
+		}
+		h.TrainingFiles[file] = md5
+	}
+}
+
+// Verify checks a model's header against the header expected by the
+// current configuration
+func (h *ModelHeader) Verify(expected *ModelHeader) error {
+	if h.FormatVersion > MODEL_FORMAT_VERSION {
+		return fmt.Errorf("model format version %d is newer than supported version %d (model written by yap %s)", h.FormatVersion, MODEL_FORMAT_VERSION, h.YapVersion)
+	}
+	if h.Parser != expected.Parser {
+		return fmt.Errorf("model was trained for %s, not %s", h.Parser, expected.Parser)
+	}
+	if h.TransitionSystem != expected.TransitionSystem {
+		return fmt.Errorf("model was trained with transition system %s, not %s", h.TransitionSystem, expected.TransitionSystem)
+	}
+	if strings.TrimSpace(h.Features) != strings.TrimSpace(expected.Features) {
+		return fmt.Errorf("model was trained with features %s, which differ from %s", h.FeaturesFile, expected.FeaturesFile)
+	}
+	if expected.Labels != nil && strings.Join(h.Labels, "\n") != strings.Join(expected.Labels, "\n") {
+		return errors.New("model was trained with a different dependency label set")
+	}
+	if h.ParamFunc != expected.ParamFunc {
+		return fmt.Errorf("model was trained with param func %s, not %s", h.ParamFunc, expected.ParamFunc)
+	}
+	return nil
+}
+
+// WriteModel writes a model file, in the legacy format if header is nil
+func WriteModel(file string, header *ModelHeader, data *Serialization) {
+	fObj, err := os.Create(file)
+	if err != nil {
+		log.Fatalln("Failed creating model file", file, err)
+		return
+	}
+	defer fObj.Close()
+	writer := gob.NewEncoder(fObj)
+	if header != nil {
+		if _, err = fObj.WriteString(MODEL_MAGIC); err != nil {
+			log.Fatalln("Failed writing model header to", file, err)
+		}
+		header.FormatVersion, header.YapVersion = MODEL_FORMAT_VERSION, VERSION
+		err = writer.Encode(header)
+		if err != nil {
+			log.Fatalln("Failed writing model header to", file, err)
+		}
+	}
+	err = writer.Encode(data)
+	if err != nil {
+		log.Fatalln("Failed writing model model to", file, err)
+		panic("Failed to write model")
+	}
+}
+
+// ReadModelHeader reads only the header of a model file, returning a nil
+// header for files without MODEL_MAGIC (legacy model files)
+func ReadModelHeader(file string) (*ModelHeader, error) {
+	fObj, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fObj.Close()
+	reader := bufio.NewReader(fObj)
+	return decodeModelHeader(reader, gob.NewDecoder(reader))
+}
+
+// decodeModelHeader decodes the header following MODEL_MAGIC at the start
+// of a model file, returning a nil header without consuming the reader if
+// the file does not start with MODEL_MAGIC
+func decodeModelHeader(reader *bufio.Reader, decoder *gob.Decoder) (*ModelHeader, error) {
+	prefix, err := reader.Peek(len(MODEL_MAGIC))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if string(prefix) != MODEL_MAGIC {
+		return nil, nil
+	}
+	if _, err := reader.Discard(len(MODEL_MAGIC)); err != nil {
+		return nil, err
+	}
+	header := &ModelHeader{}
+	if err := decoder.Decode(header); err != nil {
+		return nil, fmt.Errorf("failed decoding model header - %v", err)
+	}
+	return header, nil
+}
+
+// ReadModelFile reads a model file, returning a nil header for legacy
+// model files, which hold only a Serialization
+func ReadModelFile(file string) (*ModelHeader, *Serialization, error) {
+	fObj, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer fObj.Close()
+	data := &Serialization{}
+	reader := bufio.NewReader(fObj)
+	decoder := gob.NewDecoder(reader)
+	header, err := decodeModelHeader(reader, decoder)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := decoder.Decode(data); err != nil {
+		return nil, nil, err
+	}
+	return header, data, nil
+}
+
+func ReadModel(file string) *Serialization {
+	_, data, err := ReadModelFile(file)
+	if err != nil {
+		log.Fatalln("Failed reading model from", file, err)
+	}
+	return data
+}
+
+// LoadModel reads a model file and verifies it was trained with the
+// configuration of the expected header. Legacy model files are loaded
+// without verification
+func LoadModel(file string, expected *ModelHeader) *Serialization {
+	_, data := LoadModelAndHeader(file, expected)
+	return data
+}
+
+// LoadModelAndHeader is LoadModel also returning the model's header, nil
+// for legacy model files
+func LoadModelAndHeader(file string, expected *ModelHeader) (*ModelHeader, *Serialization) {
+	header, data, err := ReadModelFile(file)
+	if err != nil {
+		log.Fatalln("Failed reading model from", file, err)
+	}
+	if header == nil {
+		log.Println("Model file", file, "is in the legacy format, skipping configuration verification")
+		return nil, data
+	}
+	if err := header.Verify(expected); err != nil {
+		log.Fatalln("Model file", file, "does not match the configuration:", err)
+	}
+	return header, data
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"yap/util"
+
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testModelData() *Serialization {
+	trans := util.NewEnumSet(2, "ETrans")
+	trans.Add("SH")
+	trans.Add("RE")
+	return &Serialization{ETrans: trans}
+}
+
+func testModelDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "yapmodel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestModelFileWithHeader(t *testing.T) {
+	dir := testModelDir(t)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "model")
+	header := &ModelHeader{Parser: "dep", TransitionSystem: "standard", BeamSize: 64, ParamFunc: "Funky_Stuff"}
+	WriteModel(file, header, testModelData())
+
+	readHeader, err := ReadModelHeader(file)
+	if err != nil {
+		t.Fatal("Failed reading header:", err)
+	}
+	if readHeader == nil || readHeader.FormatVersion != MODEL_FORMAT_VERSION || readHeader.Parser != "dep" || readHeader.BeamSize != 64 {
+		t.Fatalf("Got wrong header %+v", readHeader)
+	}
+	readHeader, data, err := ReadModelFile(file)
+	if err != nil {
+		t.Fatal("Failed reading model:", err)
+	}
+	if readHeader == nil || readHeader.TransitionSystem != "standard" || readHeader.ParamFunc != "Funky_Stuff" {
+		t.Errorf("Got wrong header %+v", readHeader)
+	}
+	if data.ETrans == nil || data.ETrans.Len() != 2 {
+		t.Errorf("Got wrong model data %+v", data)
+	}
+}
+
+func TestModelFileLegacy(t *testing.T) {
+	dir := testModelDir(t)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "model")
+	WriteModel(file, nil, testModelData())
+
+	header, err := ReadModelHeader(file)
+	if err != nil || header != nil {
+		t.Fatalf("Expected nil header and error for legacy model, got %v %v", header, err)
+	}
+	header, data, err := ReadModelFile(file)
+	if err != nil {
+		t.Fatal("Failed reading legacy model:", err)
+	}
+	if header != nil {
+		t.Errorf("Expected nil header for legacy model, got %+v", header)
+	}
+	if data.ETrans == nil || data.ETrans.Len() != 2 {
+		t.Errorf("Got wrong legacy model data %+v", data)
+	}
+}
+
+func TestModelFileCorrupt(t *testing.T) {
+	dir := testModelDir(t)
+	defer os.RemoveAll(dir)
+
+	garbage := filepath.Join(dir, "garbage")
+	if err := ioutil.WriteFile(garbage, []byte("not a gob stream"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReadModelFile(garbage); err == nil {
+		t.Error("Expected error reading a corrupt model")
+	}
+
+	// a corrupt header is an error, not a legacy model
+	corrupt := filepath.Join(dir, "header")
+	if err := ioutil.WriteFile(corrupt, []byte(MODEL_MAGIC+"not a gob stream"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadModelHeader(corrupt); err == nil {
+		t.Error("Expected error reading a corrupt header")
+	}
+	if _, _, err := ReadModelFile(corrupt); err == nil {
+		t.Error("Expected error reading a model of a corrupt header")
+	}
+
+	// a gob of the header not preceded by MODEL_MAGIC
+	unprefixed := filepath.Join(dir, "unprefixed")
+	fObj, err := os.Create(unprefixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gob.NewEncoder(fObj).Encode(&ModelHeader{Parser: "dep"})
+	fObj.Close()
+	if header, err := ReadModelHeader(unprefixed); header != nil || err != nil {
+		t.Errorf("Expected no header for a file without the magic prefix, got %+v %v", header, err)
+	}
+}
@@ -0,0 +1,589 @@
+package app
+
+import (
+	"yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/search"
+	"yap/alg/transition"
+	transitionmodel "yap/alg/transition/model"
+	"yap/nlp/format/conll"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	inspectModelFile    string
+	inspectFeaturesFile string
+	inspectGroup        string
+	inspectTransition   string
+	inspectTopN         int
+	inspectSentence     int
+)
+
+// An InspectedModel is a model loaded for inspection rather than parsing,
+// with the feature templates needed to render its feature values
+type InspectedModel struct {
+	Header    *ModelHeader
+	Model     *transitionmodel.AvgMatrixSparse
+	Names     []string
+	Templates []transition.FeatureTemplate
+	ETrans    *util.EnumSet
+}
+
+// locates the model file in the default model directories
+func locateModelFile(modelFile string) string {
+	location, found := locateFile(modelFile, DEFAULT_MODEL_DIRS)
+	if !found {
+		log.Fatalln("Model file", modelFile, "not found")
+	}
+	return location
+}
+
+func LoadInspectedModel(modelFile, featuresFile, group string) *InspectedModel {
+	header, serialization, err := ReadModelFile(locateModelFile(modelFile))
+	if err != nil {
+		log.Fatalln("Failed reading model from", modelFile, err)
+	}
+	var features []byte
+	if len(featuresFile) > 0 {
+		features, err = ioutil.ReadFile(featuresFile)
+		if err != nil {
+			log.Fatalln("Failed reading feature configuration file:", featuresFile, err)
+		}
+	} else if header != nil && len(header.Features) > 0 {
+		features = []byte(header.Features)
+	} else {
+		log.Fatalln("Model file", modelFile, "does not contain its features, set -f to the features configuration file")
+	}
+	model := &transitionmodel.AvgMatrixSparse{}
+	model.Deserialize(serialization.WeightModel)
+	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix, serialization.EMorphProp, serialization.ETrans, serialization.ETokens
+	if header != nil && header.Labels != nil {
+		SetupRelationEnum(header.Labels)
+		ERel.Frozen = false
+		for _, label := range header.LiftedLabels {
+			ERel.Add(nlp.DepRel(label))
+		}
+		ERel.Frozen = true
+	}
+
+	featureSetup := transition.LoadFeatureConf(features)
+	transTypes := make([]byte, 0, len(featureSetup.FeatureGroups))
+	for _, featureGroup := range featureSetup.FeatureGroups {
+		transType := transition.ConstTransition(0).Type()
+		if len(featureGroup.Transition) > 0 {
+			transType = featureGroup.Transition[0]
+		}
+		if strings.IndexByte(string(transTypes), transType) < 0 {
+			transTypes = append(transTypes, transType)
+		}
+	}
+	extractor := SetupExtractor(featureSetup, transTypes)
+	if len(group) == 0 {
+		group = string(transTypes[:1])
+	}
+	templateGroup, exists := extractor.TransTypeGroups[group[0]]
+	if !exists {
+		log.Fatalln("Unknown feature group", group, "- features are defined for groups", string(transTypes))
+	}
+
+	inspected := &InspectedModel{
+		Header:    header,
+		Model:     model,
+		Names:     make([]string, len(model.Mat)),
+		Templates: templateGroup.FeatureTemplates,
+		ETrans:    ETrans,
+	}
+	for i := range inspected.Names {
+		switch {
+		case i < len(inspected.Templates):
+			inspected.Names[i] = inspected.Templates[i].String()
+		case i < len(serialization.WeightModel.Features) && len(serialization.WeightModel.Features[i]) > 0:
+			inspected.Names[i] = serialization.WeightModel.Features[i]
+		default:
+			inspected.Names[i] = fmt.Sprintf("feature%d", i)
+		}
+	}
+	return inspected
+}
+
+func (m *InspectedModel) TransitionName(t int) string {
+	if m.ETrans != nil && t < m.ETrans.Len() {
+		return fmt.Sprintf("%v", m.ETrans.ValueOf(t))
+	}
+	return strconv.Itoa(t)
+}
+
+// FormatValue renders a stored feature value using the enumerations of the
+// model. Features are stored by the "%v" representation of the values
+// their templates extract, which is decoded into the types of the
+// template's attributes. Values not of the template's types (e.g. when
+// rendering with the templates of another feature group) are printed as is
+func (m *InspectedModel) FormatValue(i int, key interface{}) string {
+	stored := fmt.Sprintf("%v", key)
+	if i >= len(m.Templates) {
+		return stored
+	}
+	value, ok := decodeFeatureValue(m.Templates[i], stored)
+	if !ok {
+		return stored
+	}
+	return m.Templates[i].FormatWithGenerator(value, false)
+}
+
+// a storedValue is the "%v" representation of a value, split to the atoms
+// and elements of its arrays
+type storedValue struct {
+	atom     string
+	elements []*storedValue
+}
+
+func (v *storedValue) isArray() bool {
+	return v.elements != nil
+}
+
+// splitStoredValue splits the "%v" representation of a value to its
+// arrays; representations with unbalanced brackets or trailing text are
+// not split
+func splitStoredValue(s string) (*storedValue, bool) {
+	value, rest := splitStoredValuePrefix(s)
+	return value, len(rest) == 0
+}
+
+func splitStoredValuePrefix(s string) (*storedValue, string) {
+	if strings.HasPrefix(s, "[") {
+		value := &storedValue{elements: []*storedValue{}}
+		s = s[1:]
+		for len(s) > 0 && s[0] != ']' {
+			var element *storedValue
+			element, s = splitStoredValuePrefix(s)
+			value.elements = append(value.elements, element)
+			s = strings.TrimPrefix(s, " ")
+		}
+		if len(s) == 0 {
+			return value, "unbalanced"
+		}
+		return value, s[1:]
+	}
+	end := strings.IndexAny(s, " ]")
+	if end < 0 {
+		end = len(s)
+	}
+	return &storedValue{atom: s[:end]}, s[end:]
+}
+
+// decodeFeatureValue decodes a stored feature value into the types the
+// template extracts: a single attribute's value, or an array of the values
+// of each attribute of its elements
+func decodeFeatureValue(template transition.FeatureTemplate, stored string) (interface{}, bool) {
+	var attribs []string
+	for _, element := range template.Elements {
+		for _, attrib := range element.Attributes {
+			attribs = append(attribs, string(attrib))
+		}
+	}
+	if len(template.CachedElementIDs) == 1 {
+		if len(attribs) > 0 && attribs[0] == "t" {
+			return stored, true
+		}
+		value, ok := splitStoredValue(stored)
+		if !ok || value.isArray() || len(attribs) == 0 {
+			return nil, false
+		}
+		return decodeAttributeValue(template, attribs[0], value, false)
+	}
+	value, ok := splitStoredValue(stored)
+	if !ok || !value.isArray() || len(value.elements) != len(attribs) {
+		return nil, false
+	}
+	values := make([]interface{}, len(attribs))
+	for i, attrib := range attribs {
+		if values[i], ok = decodeAttributeValue(template, attrib, value.elements[i], true); !ok {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// the enumeration a template attribute's values index, nil for attributes
+// of integer or text values
+func attributeEnum(template transition.FeatureTemplate, attrib string) *util.EnumSet {
+	switch attrib {
+	case "w", "m":
+		return template.EWord
+	case "f":
+		return template.EMorphProp
+	case "p", "fp":
+		return template.EPOS
+	case "h":
+		return template.EMHost
+	case "s":
+		return template.EMSuffix
+	case "wp", "mp":
+		return template.EWPOS
+	case "sl", "sr", "sf":
+		return template.ERel
+	}
+	return nil
+}
+
+// decodeAttributeValue decodes the value of an attribute: an enumeration
+// index, an integer, a set of indices (of labels or POS), or text. The
+// values of an element of a multi-attribute feature may be missing (nil)
+// or arrays of values
+func decodeAttributeValue(template transition.FeatureTemplate, attrib string, value *storedValue, ofElement bool) (interface{}, bool) {
+	enum := attributeEnum(template, attrib)
+	decodeIndex := func(atom string) (int, bool) {
+		index, err := strconv.Atoi(atom)
+		return index, err == nil && index >= 0 && (enum == nil || index < enum.Len())
+	}
+	if ofElement && !value.isArray() && value.atom == "<nil>" {
+		switch attrib {
+		case "vl", "vr", "vf", "o":
+			return nil, false
+		}
+		return nil, true
+	}
+	switch attrib {
+	case "t":
+		if value.isArray() {
+			return nil, false
+		}
+		return value.atom, true
+	case "sl", "sr", "sf", "fp":
+		if !value.isArray() {
+			return decodeIndex(value.atom)
+		}
+		set := make([]int, len(value.elements))
+		for i, element := range value.elements {
+			var ok bool
+			if element.isArray() {
+				return nil, false
+			}
+			if set[i], ok = decodeIndex(element.atom); !ok {
+				return nil, false
+			}
+		}
+		return set, true
+	}
+	if value.isArray() {
+		if !ofElement {
+			return nil, false
+		}
+		values := make([]interface{}, len(value.elements))
+		for i, element := range value.elements {
+			var ok bool
+			if values[i], ok = decodeAttributeValue(template, attrib, element, false); !ok {
+				return nil, false
+			}
+		}
+		return values, true
+	}
+	if enum == nil {
+		intVal, err := strconv.Atoi(value.atom)
+		return intVal, err == nil
+	}
+	return decodeIndex(value.atom)
+}
+
+type featureWeight struct {
+	Template int
+	Value    interface{}
+	Weight   int64
+}
+
+type byAbsWeight []featureWeight
+
+func (b byAbsWeight) Len() int      { return len(b) }
+func (b byAbsWeight) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byAbsWeight) Less(i, j int) bool {
+	left, right := b[i].Weight, b[j].Weight
+	if left < 0 {
+		left = -left
+	}
+	if right < 0 {
+		right = -right
+	}
+	return left > right
+}
+
+func keepTop(weights []featureWeight, n int) []featureWeight {
+	sort.Sort(byAbsWeight(weights))
+	if len(weights) > n {
+		return weights[:n]
+	}
+	return weights
+}
+
+func ModelTemplates(cmd *commander.Command, args []string) error {
+	VerifyFlags(cmd, []string{"m"})
+	m := LoadInspectedModel(inspectModelFile, inspectFeaturesFile, inspectGroup)
+	if m.Header != nil {
+		fmt.Printf("# %s model, transition system %s, trained by yap %s (format %d) for %d iterations\n", m.Header.Parser, m.Header.TransitionSystem, m.Header.YapVersion, m.Header.FormatVersion, m.Header.TrainIterations)
+		if m.Header.Shuffle {
+			fmt.Printf("# training instances shuffled every iteration with seed %d\n", m.Header.ShuffleSeed)
+		}
+		if len(m.Header.UpdateStrategy) > 0 {
+			fmt.Printf("# trained with %s updates\n", m.Header.UpdateStrategy)
+		}
+		if m.Header.DynamicOracle {
+			fmt.Printf("# greedy parser trained with the dynamic oracle, exploring with probability %v after %d iteration(s)\n", m.Header.Explore, m.Header.ExploreAfter)
+		}
+		if len(m.Header.PseudoProjective) > 0 {
+			fmt.Printf("# trained on pseudo-projective trees (%s scheme), %d encoded labels\n", m.Header.PseudoProjective, len(m.Header.LiftedLabels))
+		}
+		if m.Header.TrainShards > 1 {
+			fmt.Printf("# trained in %d shards with iterative parameter mixing\n", m.Header.TrainShards)
+		}
+		for file, md5 := range m.Header.TrainingFiles {
+			fmt.Printf("# trained on %s (md5 %s)\n", file, md5)
+		}
+	} else {
+		fmt.Println("# legacy model file without a header")
+	}
+	fmt.Println("# id\tvalues\tnon-zero\ttemplate")
+	var totalValues, totalNonZero int
+	for i, mat := range m.Model.Mat {
+		var values, nonZero int
+		for _, scores := range mat.Vals {
+			values++
+			scores.Each(func(_ int, value *featurevector.HistoryValue) {
+				if value != nil && value.Value != 0 {
+					nonZero++
+				}
+			})
+		}
+		totalValues += values
+		totalNonZero += nonZero
+		fmt.Printf("%d\t%d\t%d\t%s\n", i, values, nonZero, m.Names[i])
+	}
+	fmt.Printf("# total\t%d\t%d\n", totalValues, totalNonZero)
+	return nil
+}
+
+func ModelTop(cmd *commander.Command, args []string) error {
+	VerifyFlags(cmd, []string{"m"})
+	m := LoadInspectedModel(inspectModelFile, inspectFeaturesFile, inspectGroup)
+	onlyTransition := -1
+	if len(inspectTransition) > 0 {
+		var exists bool
+		if onlyTransition, exists = m.ETrans.IndexOf(inspectTransition); !exists {
+			log.Fatalln("Unknown transition", inspectTransition)
+		}
+	}
+	// keep at most a few times the requested number of weights per transition
+	top := make(map[int][]featureWeight)
+	for i, mat := range m.Model.Mat {
+		for key, scores := range mat.Vals {
+			scores.Each(func(t int, value *featurevector.HistoryValue) {
+				if value == nil || value.Value == 0 || (onlyTransition >= 0 && t != onlyTransition) {
+					return
+				}
+				top[t] = append(top[t], featureWeight{i, key, value.Value})
+				if len(top[t]) >= 4*inspectTopN {
+					top[t] = keepTop(top[t], inspectTopN)
+				}
+			})
+		}
+	}
+	transitions := make([]int, 0, len(top))
+	for t := range top {
+		transitions = append(transitions, t)
+	}
+	sort.Ints(transitions)
+	for _, t := range transitions {
+		fmt.Printf("%s\n", m.TransitionName(t))
+		for _, weight := range keepTop(top[t], inspectTopN) {
+			fmt.Printf("\t%d\t%s\t%s\n", weight.Weight, m.Names[weight.Template], m.FormatValue(weight.Template, weight.Value))
+		}
+	}
+	return nil
+}
+
+// sums the weights a feature contributes to a transition, generated
+// features contribute each of their values
+func templateWeight(mat *featurevector.AvgSparse, t int, feat interface{}) int64 {
+	if generated, isGenerated := feat.([]interface{}); isGenerated {
+		var weight int64
+		for _, generatedFeat := range generated {
+			weight += mat.Value(t, generatedFeat)
+		}
+		return weight
+	}
+	return mat.Value(t, feat)
+}
+
+func ModelWeights(cmd *commander.Command, args []string) error {
+	VerifyFlags(cmd, []string{"m", "in"})
+	if inspectSentence < 1 {
+		log.Fatalln("Sentence number (-s) starts at 1")
+	}
+	modelFile := locateModelFile(inspectModelFile)
+	header, err := ReadModelHeader(modelFile)
+	if err != nil {
+		log.Fatalln("Failed reading model from", modelFile, err)
+	}
+	if header != nil {
+		if header.Parser != "dep" {
+			log.Fatalln("Model file", modelFile, "is a", header.Parser, "model, only dependency models are supported")
+		}
+		arcSystemStr = header.TransitionSystem
+	}
+	var found bool
+	if depFeaturesFile, found = locateFile(depFeaturesFile, DEFAULT_CONF_DIRS); !found {
+		VerifyFlags(cmd, []string{"f"})
+	}
+	if depLabelsFile, found = locateFile(depLabelsFile, DEFAULT_CONF_DIRS); !found {
+		VerifyFlags(cmd, []string{"l"})
+	}
+	beam, enums := LoadDepParser(modelFile, depFeaturesFile, depLabelsFile, 1)
+	beam.TransFunc.AddDefaultOracle()
+	model := beam.Model.(*transitionmodel.AvgMatrixSparse)
+	extractor := beam.FeatExtractor.(*transition.GenericExtractor)
+	templates := extractor.TransTypeGroups['A'].FeatureTemplates
+
+	sents, err := conll.ReadFile(input, inspectSentence)
+	if err != nil {
+		log.Fatalln("Failed reading conll file", input, err)
+	}
+	if len(sents) < inspectSentence {
+		log.Fatalln("Conll file", input, "has only", len(sents), "sentences")
+	}
+	graph := conll.Conll2Graph(sents[inspectSentence-1], enums.EWord, enums.EPOS, enums.EWPOS, ERel, enums.EMHost, enums.EMSuffix)
+	if header != nil && len(header.PseudoProjective) > 0 {
+		// the oracle follows the projective encoding the model was trained on
+		ProjectivizeCorpus([]interface{}{graph}, header.PseudoProjective, arcSystemStr)
+	}
+	deterministic := &search.Deterministic{
+		TransFunc:        beam.TransFunc,
+		FeatExtractor:    extractor,
+		ReturnSequence:   true,
+		Base:             beam.Base,
+		NoRecover:        true,
+		DefaultTransType: 'A',
+	}
+	goldConf, _ := deterministic.ParseOracle(&perceptron.Decoded{InstanceVal: GetAsTaggedSentence(graph), DecodedVal: GetAsLabeledDepGraph(graph)})
+	sequence := goldConf.GetSequence()
+	for step := len(sequence) - 2; step >= 0; step-- {
+		conf := sequence[step+1]
+		gold := sequence[step].GetLastTransition().Value()
+		transType, possible := beam.TransFunc.GetTransitions(conf)
+		features := extractor.Features(conf, false, transType, nil)
+		best, bestScore := -1, int64(0)
+		for _, t := range possible {
+			if score := model.TransitionScore(transition.ConstTransition(t), features); best < 0 || score > bestScore {
+				best, bestScore = t, score
+			}
+		}
+		goldScore := model.TransitionScore(transition.ConstTransition(gold), features)
+		if best < 0 {
+			// no transition is possible, the gold transition was forced
+			fmt.Printf("Step %d: gold %v (%d) predicted none\n", len(sequence)-1-step, ETrans.ValueOf(gold), goldScore)
+			continue
+		}
+		fmt.Printf("Step %d: gold %v (%d) predicted %v (%d)\n", len(sequence)-1-step, ETrans.ValueOf(gold), goldScore, ETrans.ValueOf(best), bestScore)
+		for i, feat := range features {
+			if feat == nil || i >= len(templates) {
+				continue
+			}
+			goldWeight, bestWeight := templateWeight(model.Mat[i], gold, feat), templateWeight(model.Mat[i], best, feat)
+			if goldWeight == 0 && bestWeight == 0 {
+				continue
+			}
+			fmt.Printf("\t%d\t%d\t%s\t%s\n", goldWeight, bestWeight, templates[i], templates[i].FormatWithGenerator(feat, templates[i].Elements[0].IsGenerator))
+		}
+	}
+	return nil
+}
+
+func modelSubCmd(run func(*commander.Command, []string) error, name, short, long string) *commander.Command {
+	cmd := &commander.Command{
+		Run:       run,
+		UsageLine: name + " <file options> [arguments]",
+		Short:     short,
+		Long:      long,
+		Flag:      *flag.NewFlagSet(name, flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&inspectModelFile, "m", "", "Model file")
+	return cmd
+}
+
+func ModelCmd() *commander.Command {
+	templates := modelSubCmd(ModelTemplates, "templates", "list feature templates with their number of weights", `
+list feature templates with their number of feature values and non-zero weights
+
+	$ ./yap model templates -m <model file> [-f <features file>] [-g <feature group>]
+`)
+	top := modelSubCmd(ModelTop, "top", "print the top weighted feature values per transition", `
+print the top weighted (by absolute value) feature values per transition
+
+	$ ./yap model top -m <model file> [-n <N>] [-t <transition>] [-f <features file>] [-g <feature group>]
+`)
+	weights := modelSubCmd(ModelWeights, "weights", "print the weights of features along a gold dependency parse", `
+print the weights the features of each configuration contribute to the gold
+and predicted transitions, along the oracle parse of a gold sentence
+(dependency models only)
+
+	$ ./yap model weights -m <model file> -in <conll file> -s <sentence number> [options]
+
+Each feature line is: gold weight, predicted weight, template, value
+`)
+	prune := modelSubCmd(ModelPrune, "prune", "remove low weight and rarely updated features from a model", `
+remove feature values whose (averaged) weight magnitude is below a threshold,
+and features updated fewer than K times during training, writing a smaller
+model. Reports the model size before and after pruning, and when a dev set
+is given, the accuracy before and after pruning (dependency and MD models)
+
+	$ ./yap model prune -m <model file> -o <output model file> [-minweight <W>] [-minupdates <K>] [options]
+
+Dependency models are evaluated on a gold conll dev set (-dev), MD models
+on ambiguous (-dev) and gold (-devgold) lattice files
+
+Update counts are kept only in the per-iteration models of training runs
+with -countupdates, pruning with -minupdates requires such a model. The
+pruned model holds no update counts
+`)
+	for _, cmd := range []*commander.Command{templates, top} {
+		cmd.Flag.StringVar(&inspectFeaturesFile, "f", "", "Optional - Features Configuration File (default: features stored in model)")
+		cmd.Flag.StringVar(&inspectGroup, "g", "", "Optional - Feature group (transition type) to render templates with (default: first group)")
+	}
+	top.Flag.IntVar(&inspectTopN, "n", 20, "Number of feature values per transition")
+	top.Flag.StringVar(&inspectTransition, "t", "", "Optional - Show only this transition")
+	weights.Flag.StringVar(&input, "in", "", "Gold Conll File")
+	weights.Flag.IntVar(&inspectSentence, "s", 1, "Sentence number in the conll file")
+	weights.Flag.StringVar(&depFeaturesFile, "f", "zhangnivre2011.yaml", "Dependency Features Configuration File")
+	weights.Flag.StringVar(&depLabelsFile, "l", "hebtb.labels.conf", "Dependency Labels Configuration File")
+	weights.Flag.StringVar(&arcSystemStr, "a", "eager", "Optional - Arc System [standard, eager, swap, hybrid, covington] (for models without a header)")
+	prune.Flag.StringVar(&pruneOutFile, "o", "", "Output Model File")
+	prune.Flag.Int64Var(&pruneMinWeight, "minweight", 1, "Remove feature values with a weight magnitude below this")
+	prune.Flag.IntVar(&pruneMinUpdates, "minupdates", 0, "Remove features updated fewer times than this")
+	prune.Flag.StringVar(&pruneDevFile, "dev", "", "Optional - Dev set to evaluate with (gold conll for dependency models, ambiguous lattices for MD models)")
+	prune.Flag.StringVar(&pruneDevGoldFile, "devgold", "", "Optional - Gold lattices of the dev set (MD models)")
+	prune.Flag.StringVar(&depFeaturesFile, "f", "zhangnivre2011.yaml", "Dependency Features Configuration File")
+	prune.Flag.StringVar(&depLabelsFile, "l", "hebtb.labels.conf", "Dependency Labels Configuration File")
+	prune.Flag.StringVar(&mdFeaturesFile, "mdf", "standalone.md.yaml", "MD Features Configuration File")
+	prune.Flag.IntVar(&Workers, "workers", 1, "Number of sentences parsed concurrently")
+
+	cmd := &commander.Command{
+		UsageLine: "model <command> [arguments]",
+		Short:     "inspect and prune the feature weights of a trained model",
+		Subcommands: []*commander.Command{
+			templates,
+			top,
+			weights,
+			prune,
+		},
+		Flag: *flag.NewFlagSet("model", flag.ExitOnError),
+	}
+	return cmd
+}
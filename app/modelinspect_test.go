@@ -0,0 +1,70 @@
+package app
+
+import (
+	"yap/alg/transition"
+	"yap/util"
+
+	"testing"
+)
+
+func TestInspectedModelFormatValue(t *testing.T) {
+	pos := util.NewEnumSet(2, "EPOS")
+	pos.Add("NN")
+	pos.Add("VB")
+	single := transition.FeatureTemplate{
+		Elements:         []transition.FeatureTemplateElement{{Attributes: [][]byte{[]byte("p")}}},
+		CachedElementIDs: []int{0},
+		EPOS:             pos,
+	}
+	pair := transition.FeatureTemplate{
+		Elements: []transition.FeatureTemplateElement{
+			{Attributes: [][]byte{[]byte("p")}},
+			{Attributes: [][]byte{[]byte("d")}},
+		},
+		CachedElementIDs: []int{0, 1},
+		EPOS:             pos,
+	}
+	rel := util.NewEnumSet(2, "ERel")
+	rel.Add("subj")
+	rel.Add("obj")
+	labels := transition.FeatureTemplate{
+		Elements: []transition.FeatureTemplateElement{
+			{Attributes: [][]byte{[]byte("sl")}},
+			{Attributes: [][]byte{[]byte("t")}},
+		},
+		CachedElementIDs: []int{0, 1},
+		ERel:             rel,
+	}
+	m := &InspectedModel{Templates: []transition.FeatureTemplate{single, pair, labels}}
+	for _, test := range []struct {
+		template int
+		value    interface{}
+		expected string
+	}{
+		{0, 1, "VB"},
+		{0, 5, "5"},                                  // unknown enum index
+		{0, [2]interface{}{0, 1}, "[0 1]"},           // another group's value
+		{1, [2]interface{}{0, 3}, "[NN] [3]"},        // typed pair
+		{1, [2]interface{}{nil, nil}, "[-NONE-] []"}, // missing elements
+		{1, [2]interface{}{7, 3}, "[7 3]"},           // unknown enum index
+		{1, 3, "3"},                                  // single value for a pair
+		{3, [2]interface{}{0, 3}, "[0 3]"},           // no template
+		{1, [2]interface{}{"NN", 3}, "[NN 3]"},       // untyped element
+		{0, "1", "VB"},                               // stored values
+		{0, "5", "5"},
+		{1, "[0 3]", "[NN] [3]"},
+		{1, "[<nil> 3]", "[-NONE-] [3]"},
+		{1, "[0 3] 4", "[0 3] 4"}, // trailing text
+		{1, "[NN 3]", "[NN 3]"},
+		{1, "[0 3", "[0 3"},                      // unbalanced
+		{1, "[0 [3 4]]", "[NN] [3 4]"},           // an array of an element's values
+		{2, "[[0 1] SH]", "[[ subj obj ]] [SH]"}, // a label set and a transition
+		{2, "[1 SH]", "[[ obj ]] [SH]"},
+		{2, "[[0 2] SH]", "[[0 2] SH]"}, // unknown label
+		{2, "[[] SH]", "[[  ]] [SH]"},
+	} {
+		if formatted := m.FormatValue(test.template, test.value); formatted != test.expected {
+			t.Errorf("FormatValue(%d, %v) = %q, expected %q", test.template, test.value, formatted, test.expected)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"yap/alg/search"
+	transitionmodel "yap/alg/transition/model"
+	"yap/eval"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gonuts/commander"
+)
+
+var (
+	pruneOutFile     string
+	pruneMinWeight   int64
+	pruneMinUpdates  int
+	pruneDevFile     string
+	pruneDevGoldFile string
+)
+
+// loadPruneDepEvaluator loads a dependency model for parsing, returning a
+// function evaluating it on the gold conll dev set (LAS, UAS)
+func loadPruneDepEvaluator(cmd *commander.Command, modelFile string, header *ModelHeader) (*search.Beam, func() (float64, string)) {
+	arcSystemStr = header.TransitionSystem
+	var found bool
+	if depFeaturesFile, found = locateFile(depFeaturesFile, DEFAULT_CONF_DIRS); !found {
+		VerifyFlags(cmd, []string{"f"})
+	}
+	if depLabelsFile, found = locateFile(depLabelsFile, DEFAULT_CONF_DIRS); !found {
+		VerifyFlags(cmd, []string{"l"})
+	}
+	beam, enums := LoadDepParser(modelFile, depFeaturesFile, depLabelsFile, header.BeamSize)
+	sents, err := conll.ReadFile(pruneDevFile, 0)
+	if err != nil {
+		log.Fatalln("Failed reading dev conll file", pruneDevFile, err)
+	}
+	graphs := conll.Conll2GraphCorpus(sents, enums.EWord, enums.EPOS, enums.EWPOS, ERel, enums.EMHost, enums.EMSuffix)
+	instances := make([]interface{}, len(graphs))
+	for i, graph := range graphs {
+		instances[i] = GetAsTaggedSentence(graph)
+	}
+	return beam, func() (float64, string) {
+		total, utotal := &eval.Total{}, &eval.Total{}
+		for i, parsed := range Parse(instances, beam) {
+			result := DepEval(parsed, GetAsLabeledDepGraph(graphs[i]))
+			total.Add(result)
+			utotal.Add(result.Other.(*eval.Result))
+		}
+		return total.Precision(), fmt.Sprintf("LAS %.4f UAS %.4f", total.Precision(), utotal.Precision())
+	}
+}
+
+// loadPruneMDEvaluator loads an MD model for parsing, returning a function
+// evaluating it on the ambiguous and gold lattice dev set (F1)
+func loadPruneMDEvaluator(cmd *commander.Command, modelFile string, header *ModelHeader) (*search.Beam, func() (float64, string)) {
+	VerifyFlags(cmd, []string{"devgold"})
+	paramFuncName = header.ParamFunc
+	var found bool
+	if mdFeaturesFile, found = locateFile(mdFeaturesFile, DEFAULT_CONF_DIRS); !found {
+		VerifyFlags(cmd, []string{"mdf"})
+	}
+	beam, enums := LoadMDParser(modelFile, mdFeaturesFile, header.ParamFunc, header.BeamSize)
+	lDis, lDisE := lattice.ReadFile(pruneDevGoldFile, 0)
+	if lDisE != nil {
+		log.Fatalln("Failed reading dev gold lattice file", pruneDevGoldFile, lDisE)
+	}
+	lAmb, lAmbE := lattice.ReadFile(pruneDevFile, 0)
+	if lAmbE != nil {
+		log.Fatalln("Failed reading dev ambiguous lattice file", pruneDevFile, lAmbE)
+	}
+	disLat := lattice.Lattice2SentenceCorpus(lDis, enums.EWord, enums.EPOS, enums.EWPOS, enums.EMorphProp, enums.EMHost, enums.EMSuffix)
+	ambLat := lattice.Lattice2SentenceCorpus(lAmb, enums.EWord, enums.EPOS, enums.EWPOS, enums.EMorphProp, enums.EMHost, enums.EMSuffix)
+	combined, _, _, _ := CombineLatticesCorpus(disLat, disLat)
+	gold := TrainingSequences(combined, GetMDConfigAsLattices, GetMDConfigAsMappings)
+	if len(gold) != len(ambLat) {
+		log.Fatalln("Dev set has", len(ambLat), "ambiguous and", len(gold), "gold sentences")
+	}
+	return beam, func() (float64, string) {
+		total := &eval.Total{}
+		for i, parsed := range Parse(ambLat, beam) {
+			if gold[i] != nil {
+				total.Add(MorphEval(parsed, gold[i].Decoded(), "Form_POS_Prop"))
+			}
+		}
+		return total.F1(), fmt.Sprintf("F1 %.4f", total.F1())
+	}
+}
+
+func fileSize(file string) int64 {
+	info, err := os.Stat(file)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func ModelPrune(cmd *commander.Command, args []string) error {
+	VerifyFlags(cmd, []string{"m", "o"})
+	modelFile := locateModelFile(inspectModelFile)
+	header, data, err := ReadModelFile(modelFile)
+	if err != nil {
+		log.Fatalln("Failed reading model from", modelFile, err)
+	}
+	var (
+		model    *transitionmodel.AvgMatrixSparse
+		evaluate func() (float64, string)
+		beam     *search.Beam
+	)
+	if len(pruneDevFile) > 0 {
+		if header == nil {
+			log.Fatalln("Model file", modelFile, "is in the legacy format, dev set evaluation requires a model header")
+		}
+		switch header.Parser {
+		case "dep":
+			beam, evaluate = loadPruneDepEvaluator(cmd, modelFile, header)
+		case "md":
+			beam, evaluate = loadPruneMDEvaluator(cmd, modelFile, header)
+		default:
+			log.Fatalln("Dev set evaluation of", header.Parser, "models is not supported")
+		}
+		model = beam.Model.(*transitionmodel.AvgMatrixSparse)
+	} else {
+		model = &transitionmodel.AvgMatrixSparse{}
+		model.Deserialize(data.WeightModel)
+	}
+	if !model.DeserializeUpdates(data.WeightModel) && pruneMinUpdates > 0 {
+		log.Fatalln("Model file", modelFile, "has no feature update counts, prune with -minupdates 0 or prune a per-iteration model trained with -countupdates")
+	}
+
+	featuresBefore, valuesBefore := model.Size()
+	var resultBefore float64
+	if evaluate != nil {
+		log.Println("Evaluating unpruned model on", pruneDevFile)
+		var report string
+		resultBefore, report = evaluate()
+		fmt.Println("Before:", report)
+	}
+	log.Println("Pruning values with weight magnitude below", pruneMinWeight, "and features updated fewer than", pruneMinUpdates, "times")
+	model.Prune(pruneMinWeight, pruneMinUpdates)
+	featuresAfter, valuesAfter := model.Size()
+	if evaluate != nil {
+		log.Println("Evaluating pruned model on", pruneDevFile)
+		resultAfter, report := evaluate()
+		fmt.Println("After: ", report)
+		fmt.Printf("Delta:  %+.4f\n", resultAfter-resultBefore)
+	}
+
+	features := data.WeightModel.Features
+	data.WeightModel = model.Serialize(-1)
+	data.WeightModel.Features = features
+	WriteModel(pruneOutFile, header, data)
+	fmt.Printf("Features: %d -> %d\n", featuresBefore, featuresAfter)
+	fmt.Printf("Values:   %d -> %d\n", valuesBefore, valuesAfter)
+	fmt.Printf("Size:     %d -> %d bytes\n", fileSize(modelFile), fileSize(pruneOutFile))
+	return nil
+}
@@ -0,0 +1,277 @@
+package app
+
+import (
+	"fmt"
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+	transitionmodel "yap/alg/transition/model"
+	"yap/nlp/format/conll"
+	"yap/nlp/parser/dependency/mst"
+	. "yap/nlp/parser/dependency/transition"
+	"yap/util"
+	"yap/util/conf"
+
+	"log"
+	"os"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	mstFeaturesFile string
+	mstOrder        int
+	mstProjective   bool
+)
+
+// MSTDecoderName is the decoder of the configured order and projectivity
+func MSTDecoderName() string {
+	switch {
+	case mstOrder == 2:
+		return "Eisner second order (McDonald & Pereira 2006)"
+	case mstProjective:
+		return "Eisner (Eisner 1996)"
+	default:
+		return "Chu-Liu/Edmonds (McDonald et al. 2005)"
+	}
+}
+
+func MSTConfigOut(outModelFile string) {
+	log.Println("Configuration")
+	log.Printf("Decoder:\t\t%s", MSTDecoderName())
+	log.Printf("Iterations:\t\t%d", Iterations)
+	if Shuffle {
+		log.Printf("Shuffle Seed:\t\t%d", ShuffleSeed)
+	}
+	log.Printf("Parse Workers:\t\t%d", Workers)
+	log.Printf("Model file:\t\t%s", outModelFile)
+	log.Printf("Use Lemmas:\t\t%v", !conll.IGNORE_LEMMA)
+	log.Printf("Word Type:\t\t%v", conll.WORD_TYPE)
+
+	log.Println()
+	log.Printf("Features File:\t%s", featuresFile)
+	if !VerifyExists(featuresFile) {
+		os.Exit(1)
+	}
+	log.Printf("Labels File:\t\t%s", labelsFile)
+	if !VerifyExists(labelsFile) {
+		os.Exit(1)
+	}
+	log.Println()
+	log.Println("Data")
+	log.Printf("Train file (conll):\t\t\t%s", tConll)
+	if len(tConll) > 0 && !VerifyExists(tConll) {
+		return
+	}
+	log.Printf("Input file  (tagged sentences):\t%s", input)
+	if !VerifyExists(input) {
+		os.Exit(1)
+	}
+	log.Printf("Out (conll) file:\t\t\t%s", outConll)
+}
+
+func MSTTrainAndParse(cmd *commander.Command, args []string) error {
+	if mstOrder != 1 && mstOrder != 2 {
+		log.Fatalln("Unknown order", mstOrder, "- expected 1 or 2")
+	}
+	if mstOrder == 2 && !mstProjective {
+		log.Fatalln("Second order decoding is only supported for projective trees (-proj)")
+	}
+	REQUIRED_FLAGS := []string{"in", "oc"}
+
+	featuresLocation, found := util.LocateFile(mstFeaturesFile, DEFAULT_CONF_DIRS)
+	if found {
+		featuresFile = featuresLocation
+	} else {
+		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "f")
+	}
+	labelsLocation, found := util.LocateFile(depLabelsFile, DEFAULT_CONF_DIRS)
+	if found {
+		labelsFile = labelsLocation
+	} else {
+		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "l")
+	}
+	VerifyFlags(cmd, REQUIRED_FLAGS)
+
+	var (
+		outModelFile string                           = fmt.Sprintf("%s.o%d", modelFile, mstOrder)
+		model        *transitionmodel.AvgMatrixSparse = &transitionmodel.AvgMatrixSparse{}
+		modelExists  bool                             = VerifyExists(outModelFile)
+	)
+	if !modelExists {
+		log.Println("No model found, training")
+		VerifyFlags(cmd, []string{"it", "tc"})
+	}
+	if allOut {
+		MSTConfigOut(outModelFile)
+	}
+	relations, err := conf.ReadFile(labelsFile)
+	if err != nil {
+		log.Println("Failed reading dependency labels configuration file:", labelsFile)
+		log.Fatalln(err)
+	}
+	if allOut {
+		log.Println()
+		log.Println("Setup enumerations")
+	}
+	// the model's order is that of its features, its decoder may differ
+	modelHeader := NewModelHeader("mst", fmt.Sprintf("order %d", mstOrder), 0, featuresFile, relations.Values, "")
+	SetupDepEnum(relations.Values, "")
+	LoadCheckpoint(modelHeader)
+
+	if allOut {
+		log.Println()
+		log.Println("Loading features")
+	}
+	featureSetup, err := transition.LoadFeatureConfFile(featuresFile)
+	if err != nil {
+		log.Println("Failed reading feature configuration file:", featuresFile)
+		log.Fatalln(err)
+	}
+	extractor := SetupExtractor(featureSetup, []byte{mst.ARC_FEATURES, mst.SIBLING_FEATURES})
+	group, _ := extractor.TransTypeGroups[mst.ARC_FEATURES]
+	formatters := make([]util.Format, len(group.FeatureTemplates))
+	for i, formatter := range group.FeatureTemplates {
+		formatters[i] = formatter
+	}
+
+	parser := &mst.Parser{
+		FeatExtractor: extractor,
+		Base: &SimpleConfiguration{
+			EWord:         EWord,
+			EPOS:          EPOS,
+			EWPOS:         EWPOS,
+			EMHost:        EMHost,
+			EMSuffix:      EMSuffix,
+			ERel:          ERel,
+			ETrans:        ETrans,
+			TerminalStack: -1,
+			TerminalQueue: -1,
+		},
+		Relations:   ERel,
+		Projective:  mstProjective,
+		SecondOrder: mstOrder == 2,
+	}
+
+	if !modelExists {
+		if allOut {
+			log.Println("Model file", outModelFile, "not found, training")
+			log.Println("Reading training sentences from", tConll)
+		}
+		s, e := conll.ReadFile(tConll, limit)
+		if e != nil {
+			log.Println(e)
+			return e
+		}
+		if allOut {
+			log.Println("Conll:\tRead", len(s), "sentences")
+			log.Println("Conll:\tConverting from conll to internal structure")
+		}
+		goldGraphs := conll.Conll2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+		goldTrees := TrainingSequences(goldGraphs, GetAsTaggedSentence, GetAsLabeledDepGraph)
+		if allOut {
+			log.Println()
+			log.Println("Training", Iterations, "iteration(s)")
+		}
+		model = transitionmodel.NewAvgMatrixSparse(featureSetup.NumFeatures(), formatters, true)
+		modelHeader.AddTrainingFiles(tConll)
+		trainingHeader = modelHeader
+		trainer := Train(goldTrees, Iterations, modelFile, model, perceptron.EarlyUpdateInstanceDecoder(parser), perceptron.InstanceDecoder(parser), nil)
+		if allOut {
+			log.Println("Done Training")
+			log.Println()
+			log.Println("Writing model to", outModelFile)
+		}
+		serialization := &Serialization{
+			model.Serialize(-1),
+			EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
+		}
+		// the stop condition may end training before or after Iterations
+		modelHeader.TrainIterations = trainer.TrainI
+		WriteModel(outModelFile, modelHeader, serialization)
+		if allOut {
+			log.Println("Done writing model")
+		}
+	} else {
+		if allOut {
+			log.Println("Found model file", outModelFile, " ... loading model")
+		}
+		serialization := LoadModel(outModelFile, modelHeader)
+		model.Deserialize(serialization.WeightModel)
+		EWord, EPOS, EWPOS, EMHost, EMSuffix = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix
+		parser.Base.EWord, parser.Base.EPOS, parser.Base.EWPOS, parser.Base.EMHost, parser.Base.EMSuffix = EWord, EPOS, EWPOS, EMHost, EMSuffix
+		if allOut {
+			log.Println("Loaded model")
+		}
+	}
+	parser.Model = model
+	if allOut {
+		log.Println()
+	}
+
+	devi, e2 := conll.ReadFile(input, limit)
+	if e2 != nil {
+		log.Fatalln(e2)
+	}
+	if allOut {
+		log.Println("Read", len(devi), "sentences from", input)
+		log.Println("Converting from conll to internal format")
+	}
+	asGraphs := conll.Conll2GraphCorpus(devi, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+	sents := make([]interface{}, len(asGraphs))
+	for i, instance := range asGraphs {
+		sents[i] = GetAsTaggedSentence(instance)
+	}
+	if allOut {
+		log.Print("Parsing")
+	}
+	parsedGraphs := Parse(sents, parser)
+	if allOut {
+		log.Println("Converting to conll")
+	}
+	graphAsConll := conll.Graph2ConllCorpus(parsedGraphs, EMHost, EMSuffix)
+	conll.WriteFile(outConll, graphAsConll)
+	if allOut {
+		log.Println("Wrote", len(parsedGraphs), "in conll format to", outConll)
+	}
+	return nil
+}
+
+func MSTCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       MSTTrainAndParse,
+		UsageLine: "mst <file options> [arguments]",
+		Short:     "runs graph-based (maximum spanning tree) dependency training/parsing",
+		Long: `
+runs graph-based (maximum spanning tree) dependency training/parsing
+
+	$ ./yap mst -f <features> -l <labels> -tc <conll> -in <input tagged> -oc <out conll> [-order 1|2] [-proj] [options]
+
+First order models are decoded with Chu-Liu/Edmonds (non-projective) or
+Eisner (projective, -proj), second order (sibling) models with Eisner only.
+Arc features are read from the Arc transition feature groups of the features
+file, sibling features from the Sibling groups.
+
+`,
+		Flag: *flag.NewFlagSet("mst", flag.ExitOnError),
+	}
+	cmd.Flag.IntVar(&Workers, "workers", 1, "Number of sentences to parse concurrently")
+	cmd.Flag.BoolVar(&Shuffle, "shuffle", false, "Shuffle the training instances every iteration")
+	cmd.Flag.Int64Var(&ShuffleSeed, "seed", 1, "Random seed for shuffling the training instances")
+	cmd.Flag.StringVar(&resumeFile, "resume", "", "Optional - Resume training from a checkpoint (model.temp.iN file written with -checkpoint)")
+	cmd.Flag.BoolVar(&checkpointTraining, "checkpoint", false, "Write a training checkpoint (model.temp.iN) every iteration, which training can be resumed from")
+	cmd.Flag.IntVar(&Iterations, "it", 1, "Number of Perceptron Iterations")
+	cmd.Flag.IntVar(&mstOrder, "order", 1, "Order of the model [1 (arcs), 2 (arcs and siblings, requires -proj)]")
+	cmd.Flag.BoolVar(&mstProjective, "proj", false, "Decode projective trees (Eisner) instead of non-projective trees (Chu-Liu/Edmonds)")
+	cmd.Flag.StringVar(&modelFile, "m", "model", "Prefix for model file ({m}.o{order})")
+
+	cmd.Flag.StringVar(&tConll, "tc", "", "Training Conll File")
+	cmd.Flag.StringVar(&input, "in", "", "Dev Tagged Sentences File")
+	cmd.Flag.StringVar(&outConll, "oc", "", "Output Conll File")
+	cmd.Flag.StringVar(&mstFeaturesFile, "f", "mst.yaml", "Features Configuration File")
+	cmd.Flag.StringVar(&depLabelsFile, "l", "hebtb.labels.conf", "Dependency Labels Configuration File")
+	cmd.Flag.BoolVar(&conll.IGNORE_LEMMA, "nolemma", false, "Ignore lemmas")
+	cmd.Flag.StringVar(&conll.WORD_TYPE, "wordtype", "form", "Word type [form, lemma, lemma+f (=lemma if present else form)]")
+	cmd.Flag.IntVar(&limit, "limit", 0, "limit training set")
+	return cmd
+}
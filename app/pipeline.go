@@ -0,0 +1,587 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/alg/transition"
+	transitionmodel "yap/alg/transition/model"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/conllu"
+	"yap/nlp/format/lattice"
+	"yap/nlp/format/mapping"
+	"yap/nlp/format/raw"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	"yap/nlp/parser/ma"
+	nlp "yap/nlp/types"
+	"yap/util"
+	"yap/util/conf"
+
+	. "yap/nlp/parser/dependency/transition"
+
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	// kept apart from lattice.IGNORE_LEMMA, whose flag default differs
+	// between the md and joint commands
+	pipelineNoLemma bool
+)
+
+// ParserEnums holds the enumerations a model was trained with, so that
+// several models can be loaded side by side without sharing the globals
+type ParserEnums struct {
+	EWord, EPOS, EWPOS, EMHost, EMSuffix *util.EnumSet
+	EMorphProp                           *util.EnumSet
+	ETrans                               *util.EnumSet
+	ETokens                              *util.EnumSet
+	ERel                                 *util.EnumSet
+}
+
+func CurrentEnums() *ParserEnums {
+	return &ParserEnums{
+		EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens, ERel,
+	}
+}
+
+// A PipelineParser runs morphological analysis, disambiguation and
+// dependency parsing in memory, one sentence at a time.
+// The loaded models are read only; every call parses with its own copy
+// of the beam so a PipelineParser may be used concurrently
+type PipelineParser struct {
+	MA *ma.BGULex
+
+	MDBeam  *search.Beam
+	MDEnums *ParserEnums
+
+	DepBeam  *search.Beam
+	DepEnums *ParserEnums
+}
+
+// LocatePipelineFiles resolves the lexicon, model, feature and label files
+// of the pipeline and verifies the flags of the ones that weren't found
+func LocatePipelineFiles(cmd *commander.Command, required []string) {
+	var found bool
+	prefixFile, found = locateFile(prefixFile, DEFAULT_DATA_DIRS)
+	if !found {
+		required = append(required, "prefix")
+	}
+	lexiconFile, found = locateFile(lexiconFile, DEFAULT_DATA_DIRS)
+	if !found {
+		required = append(required, "lexicon")
+	}
+	mdModelName, found = locateFile(mdModelName, DEFAULT_MODEL_DIRS)
+	if !found {
+		required = append(required, "mdmn")
+	}
+	mdFeaturesFile, found = locateFile(mdFeaturesFile, DEFAULT_CONF_DIRS)
+	if !found {
+		required = append(required, "mdf")
+	}
+	depModelName, found = locateFile(depModelName, DEFAULT_MODEL_DIRS)
+	if !found {
+		required = append(required, "depmn")
+	}
+	depFeaturesFile, found = locateFile(depFeaturesFile, DEFAULT_CONF_DIRS)
+	if !found {
+		required = append(required, "depf")
+	}
+	depLabelsFile, found = locateFile(depLabelsFile, DEFAULT_CONF_DIRS)
+	if !found {
+		required = append(required, "l")
+	}
+	VerifyFlags(cmd, required)
+}
+
+func locateFile(name string, dirs []string) (string, bool) {
+	if location, found := util.LocateFile(name, dirs); found {
+		return location, true
+	}
+	if _, err := os.Stat(name); err == nil {
+		return name, true
+	}
+	return "", false
+}
+
+func PipelineConfigOut() {
+	log.Println("Configuration")
+	log.Printf("Heb Prefix:\t\t%s", prefixFile)
+	log.Printf("Heb Lexicon:\t\t%s", lexiconFile)
+	log.Printf("MD Model:\t\t%s", mdModelName)
+	log.Printf("MD Features:\t\t%s", mdFeaturesFile)
+	log.Printf("MD Beam Size:\t\t%d", mdBeamSize)
+	log.Printf("Parameter Func:\t%v", paramFuncName)
+	log.Printf("Use POP:\t\t%v", UsePOP)
+	log.Printf("Dep Model:\t\t%s", depModelName)
+	log.Printf("Dep Features:\t\t%s", depFeaturesFile)
+	log.Printf("Dep Labels:\t\t%s", depLabelsFile)
+	log.Printf("Dep Beam Size:\t\t%d", DepBeamSize)
+	log.Printf("Arc System:\t\t%s", arcSystemStr)
+	log.Println()
+}
+
+// LoadPipelineParser loads the lexicon and both models from the located files
+func LoadPipelineParser() *PipelineParser {
+	lattice.IGNORE_LEMMA = pipelineNoLemma
+	p := &PipelineParser{}
+	p.MA = LoadHebMA(prefixFile, lexiconFile)
+	p.MDBeam, p.MDEnums = LoadMDParser(mdModelName, mdFeaturesFile, paramFuncName, mdBeamSize)
+	p.DepBeam, p.DepEnums = LoadDepParser(depModelName, depFeaturesFile, depLabelsFile, DepBeamSize)
+	return p
+}
+
+func LoadHebMA(prefixFile, lexiconFile string) *ma.BGULex {
+	maData := new(ma.BGULex)
+	maData.MAType = "spmrl"
+	log.Println("Reading Morphological Analyzer BGU Prefixes")
+	maData.LoadPrefixes(prefixFile)
+	log.Println("Reading Morphological Analyzer BGU Lexicon")
+	maData.LoadLex(lexiconFile, nnpnofeats)
+	return maData
+}
+
+// LoadMDParser loads an MD model trained with the named param func
+func LoadMDParser(modelFile, featuresFile, mdParamFunc string, beamSize int) (*search.Beam, *ParserEnums) {
+	paramFunc, exists := nlp.MDParams[mdParamFunc]
+	if !exists {
+		log.Fatalln("Param Func", mdParamFunc, "does not exist")
+	}
+	disambig.UsePOP = UsePOP
+	disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
+	nlp.InitOpenParamFamily("HEBTB")
+	SetupMDEnum()
+	featureSetup, err := transition.LoadFeatureConfFile(featuresFile)
+	if err != nil {
+		log.Println("Failed reading feature configuration file:", featuresFile)
+		log.Fatalln(err)
+	}
+	log.Println("Loading MD model", modelFile)
+	serialization := LoadModel(modelFile, NewModelHeader("md", "md", beamSize, featuresFile, nil, mdParamFunc))
+	model := &transitionmodel.AvgMatrixSparse{}
+	model.Deserialize(serialization.WeightModel)
+	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix, serialization.EMorphProp, serialization.ETrans, serialization.ETokens
+
+	mdTrans := &disambig.MDTrans{
+		ParamFunc:   paramFunc,
+		UsePOP:      UsePOP,
+		POP:         POP,
+		Transitions: ETrans,
+	}
+	extractor := SetupExtractor(featureSetup, []byte("MPL"))
+	conf := &disambig.MDConfig{
+		ETokens:     ETokens,
+		POP:         POP,
+		Transitions: ETrans,
+		ParamFunc:   paramFunc,
+	}
+	beam := &search.Beam{
+		TransFunc:            mdTrans,
+		FeatExtractor:        extractor,
+		Base:                 conf,
+		Model:                model,
+		Size:                 beamSize,
+		ConcurrentExec:       ConcurrentBeam,
+		ShortTempAgenda:      true,
+		Transitions:          ETrans,
+		EstimatedTransitions: 1000, // chosen by random dice roll
+	}
+	return beam, CurrentEnums()
+}
+
+// LoadDepParser loads a dependency model, of the arc system of the flags;
+// the encoded labels of a pseudo-projective model are added to the
+// relations
+func LoadDepParser(modelFile, featuresFile, labelsFile string, beamSize int) (*search.Beam, *ParserEnums) {
+	relations, err := conf.ReadFile(labelsFile)
+	if err != nil {
+		log.Println("Failed reading dependency labels configuration file:", labelsFile)
+		log.Fatalln(err)
+	}
+	SetupDepEnum(relations.Values, arcSystemStr)
+	featureSetup, err := transition.LoadFeatureConfFile(featuresFile)
+	if err != nil {
+		log.Println("Failed reading feature configuration file:", featuresFile)
+		log.Fatalln(err)
+	}
+	log.Println("Loading dependency model", modelFile)
+	header, serialization := LoadModelAndHeader(modelFile, NewModelHeader("dep", arcSystemStr, beamSize, featuresFile, relations.Values, ""))
+	if header != nil && len(header.LiftedLabels) > 0 {
+		ExtendRelationEnum(header.LiftedLabels, arcSystemStr)
+	}
+	arcSystem, terminalStack := NewArcSystem(arcSystemStr)
+	model := &transitionmodel.AvgMatrixSparse{}
+	model.Deserialize(serialization.WeightModel)
+	EWord, EPOS, EWPOS, EMHost, EMSuffix = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix
+
+	extractor := SetupExtractor(featureSetup, []byte("A"))
+	conf := &SimpleConfiguration{
+		EWord:         EWord,
+		EPOS:          EPOS,
+		EWPOS:         EWPOS,
+		EMHost:        EMHost,
+		EMSuffix:      EMSuffix,
+		ERel:          ERel,
+		ETrans:        ETrans,
+		TerminalStack: terminalStack,
+		TerminalQueue: 0,
+	}
+	beam := &search.Beam{
+		TransFunc:            arcSystem,
+		FeatExtractor:        extractor,
+		Base:                 conf,
+		Model:                model,
+		Size:                 beamSize,
+		ConcurrentExec:       ConcurrentBeam,
+		ShortTempAgenda:      true,
+		EstimatedTransitions: EstimatedBeamTransitions(),
+		ScoredStoreDense:     true,
+	}
+	return beam, CurrentEnums()
+}
+
+// LoadJointParser loads a joint morpho-syntactic model, of the arc system
+// and joint and oracle strategies of the flags
+func LoadJointParser(modelFile, featuresFile, labelsFile string, beamSize int) (*search.Beam, *ParserEnums) {
+	paramFunc, exists := nlp.MDParams[paramFuncName]
+	if !exists {
+		log.Fatalln("Param Func", paramFuncName, "does not exist")
+	}
+	relations, err := conf.ReadFile(labelsFile)
+	if err != nil {
+		log.Println("Failed reading dependency labels configuration file:", labelsFile)
+		log.Fatalln(err)
+	}
+	SetupEnum(relations.Values, arcSystemStr)
+	nlp.InitOpenParamFamily("HEBTB")
+	featureSetup, err := transition.LoadFeatureConfFile(featuresFile)
+	if err != nil {
+		log.Println("Failed reading feature configuration file:", featuresFile)
+		log.Fatalln(err)
+	}
+	log.Println("Loading joint model", modelFile)
+	serialization := LoadModel(modelFile, NewModelHeader("joint", arcSystemStr, beamSize, featuresFile, relations.Values, paramFuncName))
+	model := &transitionmodel.AvgMatrixSparse{}
+	model.Deserialize(serialization.WeightModel)
+	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix, serialization.EMorphProp, serialization.ETrans, serialization.ETokens
+
+	arcSystem, terminalStack := NewArcSystem(arcSystemStr)
+	arcSystem.AddDefaultOracle()
+	disambig.UsePOP = UsePOP
+	disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
+	disambig.LEMMAS = !lattice.IGNORE_LEMMA
+	mdTrans := &disambig.MDTrans{
+		ParamFunc:   paramFunc,
+		UsePOP:      UsePOP,
+		POP:         POP,
+		Transitions: ETrans,
+	}
+	mdTrans.AddDefaultOracle()
+	jointTrans := &joint.JointTrans{
+		MDTrans:       mdTrans,
+		ArcSys:        arcSystem,
+		JointStrategy: JointStrategy,
+		Transitions:   ETrans,
+		MDTransition:  MD,
+	}
+	jointTrans.AddDefaultOracle()
+	jointTrans.Oracle().(*joint.JointOracle).OracleStrategy = OracleStrategy
+
+	extractor := SetupExtractor(featureSetup, []byte("MPLA"))
+	conf := &joint.JointConfig{
+		SimpleConfiguration: SimpleConfiguration{
+			EWord:         EWord,
+			EPOS:          EPOS,
+			EWPOS:         EWPOS,
+			EMHost:        EMHost,
+			EMSuffix:      EMSuffix,
+			ERel:          ERel,
+			ETrans:        ETrans,
+			TerminalStack: terminalStack,
+			TerminalQueue: 0,
+		},
+		MDConfig: disambig.MDConfig{
+			ETokens:     ETokens,
+			POP:         POP,
+			Transitions: ETrans,
+			ParamFunc:   paramFunc,
+		},
+		MDTrans: MD,
+	}
+	beam := &search.Beam{
+		TransFunc:            jointTrans,
+		FeatExtractor:        extractor,
+		Base:                 conf,
+		Model:                model,
+		Size:                 beamSize,
+		ConcurrentExec:       ConcurrentBeam,
+		ShortTempAgenda:      true,
+		Transitions:          ETrans,
+		EstimatedTransitions: 1000, // chosen by random dice roll
+	}
+	return beam, CurrentEnums()
+}
+
+// reparseLattice reads back the textual output of a stage, so the next stage
+// gets exactly what it would have read from a file
+func reparseLattice(buf *bytes.Buffer) lattice.Lattice {
+	lats, err := lattice.Read(buf, 0)
+	if err != nil {
+		panic(fmt.Sprintf("Failed reading lattice - %v", err))
+	}
+	if len(lats) != 1 {
+		panic(fmt.Sprintf("Expected a single lattice, got %d", len(lats)))
+	}
+	return lats[0]
+}
+
+// ReadLattices reads lattices in the lattice file format from a string
+func ReadLattices(text string) ([]lattice.Lattice, error) {
+	text = strings.TrimRight(text, "\n") + "\n\n"
+	lats, err := lattice.Read(strings.NewReader(text), 0)
+	if err != nil {
+		return nil, err
+	}
+	nonEmpty := make([]lattice.Lattice, 0, len(lats))
+	for _, lat := range lats {
+		if len(lat) > 0 {
+			nonEmpty = append(nonEmpty, lat)
+		}
+	}
+	return nonEmpty, nil
+}
+
+func (p *PipelineParser) Analyze(tokens []string) (lattice.Lattice, nlp.BasicSentence) {
+	sent, oov := p.MA.Analyze(tokens)
+	var buf bytes.Buffer
+	lattice.Write(&buf, []lattice.Lattice{lattice.Sentence2Lattice(sent, nil)})
+	return reparseLattice(&buf), oov.(nlp.BasicSentence)
+}
+
+// MDInstance converts an ambiguous lattice to the input of the MD beam.
+// Note that the conversion may modify the lattice
+func (p *PipelineParser) MDInstance(ambLat lattice.Lattice) interface{} {
+	e := p.MDEnums
+	return lattice.Lattice2Sentence(ambLat, e.EWord, e.EPOS, e.EWPOS, e.EMorphProp, e.EMHost, e.EMSuffix)
+}
+
+// DepInstance converts a disambiguated lattice to the input of the dependency beam
+func (p *PipelineParser) DepInstance(disLat lattice.Lattice) interface{} {
+	e := p.DepEnums
+	return lattice.Lattice2Sentence(disLat, e.EWord, e.EPOS, e.EWPOS, e.EMorphProp, e.EMHost, e.EMSuffix).TaggedSentence()
+}
+
+// MDLattice returns the disambiguated path of a parsed MD configuration in lattice form
+func MDLattice(mdConf *disambig.MDConfig) lattice.Lattice {
+	var buf bytes.Buffer
+	mapping.Write(&buf, []interface{}{mdConf})
+	return reparseLattice(&buf)
+}
+
+// Disambiguate returns the chosen path of an ambiguous lattice, both as the
+// parsed configuration and in lattice form
+func (p *PipelineParser) Disambiguate(ambLat lattice.Lattice) (*disambig.MDConfig, lattice.Lattice) {
+	beam := &search.Beam{}
+	*beam = *p.MDBeam
+	parsed, _ := beam.Parse(p.MDInstance(ambLat))
+	mdConf := parsed.(*disambig.MDConfig)
+	return mdConf, MDLattice(mdConf)
+}
+
+func (p *PipelineParser) DepParse(disLat lattice.Lattice) (nlp.LabeledDependencyGraph, conll.Sentence) {
+	beam := &search.Beam{}
+	*beam = *p.DepBeam
+	parsed, _ := beam.Parse(p.DepInstance(disLat))
+	graph := parsed.(nlp.LabeledDependencyGraph)
+	return graph, conll.Graph2Conll(graph, p.DepEnums.EMHost, p.DepEnums.EMSuffix)
+}
+
+// the number of sentences whose tokens and mappings are kept in flight
+// between the stages of the pipeline command
+const PIPELINE_QUEUE_SIZE = 100
+
+type pipelineMorph struct {
+	Tokens []string
+	MD     *disambig.MDConfig
+}
+
+// PipelineConllU converts a parsed graph to CoNLL-U, with multi-word token
+// lines for the tokens the MD split into several morphemes
+func PipelineConllU(graph nlp.LabeledDependencyGraph, tokens []string, mdConf *disambig.MDConfig, e *ParserEnums) conllu.Sentence {
+	sent := conllu.Graph2ConllU(graph, e.EMHost, e.EMSuffix)
+	sent.Mappings = make(nlp.Mappings, len(mdConf.Mappings))
+	curDepNode := 1
+	for i, m := range mdConf.Mappings {
+		mapped := &nlp.Mapping{Spellout: make(nlp.Spellout, 0, len(m.Spellout))}
+		if i < len(tokens) {
+			mapped.Token = nlp.Token(tokens[i])
+		}
+		for _, morph := range m.Spellout {
+			// nil morphemes are not written to the mapping, nor parsed
+			if morph == nil {
+				continue
+			}
+			mapped.Spellout = append(mapped.Spellout, morph)
+			row := sent.Deps[curDepNode]
+			row.TokenID = i + 1
+			sent.Deps[curDepNode] = row
+			curDepNode++
+		}
+		sent.Mappings[i] = mapped
+	}
+	return sent
+}
+
+func PipelineParse(cmd *commander.Command, args []string) error {
+	LocatePipelineFiles(cmd, []string{"raw", "oc"})
+	PipelineConfigOut()
+	log.Println("Data")
+	log.Printf("Raw Input:\t\t%s", inRawFile)
+	if !VerifyExists(inRawFile) {
+		os.Exit(1)
+	}
+	if len(outLat) > 0 {
+		log.Printf("Out (ambig. lattice):\t%s", outLat)
+	}
+	if len(outMap) > 0 {
+		log.Printf("Out (mapping):\t\t%s", outMap)
+	}
+	log.Printf("Out (conll):\t\t%s", outConll)
+	log.Printf("Use CoNLL-U:\t\t%v", useConllU)
+	log.Printf("Parse Workers:\t\t%d", Workers)
+	log.Println()
+
+	p := LoadPipelineParser()
+
+	var latFile, mapFile *os.File
+	if len(outLat) > 0 {
+		file, err := os.Create(outLat)
+		if err != nil {
+			log.Fatalln("Failed creating lattice file", outLat, err)
+		}
+		defer file.Close()
+		latFile = file
+	}
+	if len(outMap) > 0 {
+		file, err := os.Create(outMap)
+		if err != nil {
+			log.Fatalln("Failed creating mapping file", outMap, err)
+		}
+		defer file.Close()
+		mapFile = file
+	}
+
+	if allOut {
+		log.Println("Piping raw file to pipeline", inRawFile)
+	}
+	sents, err := raw.ReadFileAsStream(inRawFile, limit)
+	if err != nil {
+		log.Fatalln("Failed reading raw file", err)
+	}
+
+	// every stage sends its instance before queueing what the later stages
+	// need along with it, and every stage reads in the same order
+	tokensQueue := make(chan []string, PIPELINE_QUEUE_SIZE)
+	morphQueue := make(chan *pipelineMorph, PIPELINE_QUEUE_SIZE)
+
+	mdInput := make(chan interface{}, 2)
+	go func() {
+		for sent := range sents {
+			tokens := sent.Tokens()
+			ambLat, _ := p.Analyze(tokens)
+			if latFile != nil {
+				lattice.Write(latFile, []lattice.Lattice{ambLat})
+			}
+			mdInput <- p.MDInstance(ambLat)
+			tokensQueue <- tokens
+		}
+		close(mdInput)
+	}()
+	mdOutput := make(chan interface{}, 2)
+	go ParseStream(mdInput, mdOutput, p.MDBeam)
+
+	depInput := make(chan interface{}, 2)
+	go func() {
+		for parsed := range mdOutput {
+			mdConf := parsed.(*disambig.MDConfig)
+			if mapFile != nil {
+				mapping.Write(mapFile, []interface{}{mdConf})
+			}
+			depInput <- p.DepInstance(MDLattice(mdConf))
+			morphQueue <- &pipelineMorph{<-tokensQueue, mdConf}
+		}
+		close(depInput)
+	}()
+	depOutput := make(chan interface{}, 2)
+	go ParseStream(depInput, depOutput, p.DepBeam)
+
+	output := make(chan interface{}, 2)
+	go func() {
+		for parsed := range depOutput {
+			morph := <-morphQueue
+			graph := parsed.(nlp.LabeledDependencyGraph)
+			if useConllU {
+				output <- PipelineConllU(graph, morph.Tokens, morph.MD, p.DepEnums)
+			} else {
+				output <- conll.Graph2Conll(graph, p.DepEnums.EMHost, p.DepEnums.EMSuffix)
+			}
+		}
+		close(output)
+	}()
+	if allOut {
+		log.Println("Creating writer stream to", outConll)
+	}
+	if useConllU {
+		err = conllu.WriteStreamToFile(outConll, output)
+	} else {
+		err = conll.WriteStreamToFile(outConll, output)
+	}
+	if err != nil {
+		log.Fatalln("Failed writing output file", outConll, err)
+	}
+	return nil
+}
+
+func PipelineCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       PipelineParse,
+		UsageLine: "pipeline <file options> [arguments]",
+		Short:     "runs morphological analysis, disambiguation and dependency parsing from raw tokens",
+		Long: `
+runs morphological analysis, disambiguation and dependency parsing from raw tokens
+
+	$ ./yap pipeline -raw <raw file> -oc <out conll> [-ol <out ambig. lattice>] [-om <out mapping>] [options]
+
+Sentences are streamed through all stages in memory, no intermediate files are needed.
+`,
+		Flag: *flag.NewFlagSet("pipeline", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&inRawFile, "raw", "", "Input raw (tokenized) file")
+	cmd.Flag.StringVar(&outConll, "oc", "", "Output Conll File")
+	cmd.Flag.StringVar(&outLat, "ol", "", "Optional - Output Ambiguous Lattices File")
+	cmd.Flag.StringVar(&outMap, "om", "", "Optional - Output Mapping File")
+	cmd.Flag.BoolVar(&useConllU, "conllu", false, "Output in CoNLL-U format")
+	cmd.Flag.StringVar(&prefixFile, "prefix", "bgupreflex_withdef.utf8.hr", "Prefix file for morphological analyzer")
+	cmd.Flag.StringVar(&lexiconFile, "lexicon", "bgulex.utf8.hr", "Lexicon file for morphological analyzer")
+	cmd.Flag.BoolVar(&nnpnofeats, "addnnpnofeats", false, "Add NNP in lex but without features")
+	cmd.Flag.StringVar(&mdModelName, "mdmn", "hebmd.b32", "MD Modelfile")
+	cmd.Flag.StringVar(&mdFeaturesFile, "mdf", "standalone.md.yaml", "MD Features Configuration File")
+	cmd.Flag.IntVar(&mdBeamSize, "mdb", 32, "MD Beam Size")
+	cmd.Flag.StringVar(&paramFuncName, "p", "Funcs_Main_POS_Both_Prop", "Param Func types: ["+nlp.AllParamFuncNames+"]")
+	cmd.Flag.BoolVar(&UsePOP, "pop", true, "Add POP operation to MD")
+	cmd.Flag.BoolVar(&pipelineNoLemma, "nolemma", true, "Ignore lemmas")
+	cmd.Flag.StringVar(&depModelName, "depmn", "dep.b64", "Dependency Modelfile")
+	cmd.Flag.StringVar(&depFeaturesFile, "depf", "zhangnivre2011.yaml", "Dependency Features Configuration File")
+	cmd.Flag.StringVar(&depLabelsFile, "l", "hebtb.labels.conf", "Dependency Labels Configuration File")
+	cmd.Flag.IntVar(&DepBeamSize, "depb", 64, "Dependency Beam Size")
+	cmd.Flag.StringVar(&arcSystemStr, "a", "eager", "Optional - Arc System [standard, eager, swap, hybrid, covington]")
+	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
+	cmd.Flag.IntVar(&Workers, "workers", 1, "Number of sentences to parse concurrently (each with its own beam)")
+	cmd.Flag.IntVar(&limit, "limit", 0, "limit input set")
+	return cmd
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	. "yap/nlp/parser/dependency/transition"
+	nlp "yap/nlp/types"
+
+	"log"
+	"strings"
+)
+
+// pseudo-projective scheme of the training trees, empty if none
+var pseudoProjective string
+
+func VerifyPseudoProjective() {
+	if len(pseudoProjective) > 0 && !ValidPseudoProjectiveScheme(pseudoProjective) {
+		log.Fatalln("Unknown pseudo-projective scheme", pseudoProjective, "- expected one of", strings.Join(PSEUDO_PROJECTIVE_SCHEMES, ", "))
+	}
+}
+
+// ProjectivizeCorpus lifts the non-projective arcs of the gold graphs,
+// extends the relation (and the arc system's transition) enumerations with
+// the encoded labels and returns the labels added, in order of first
+// occurrence
+func ProjectivizeCorpus(graphs []interface{}, scheme, arcSystem string) []string {
+	var (
+		numLifted, numSents int
+		labels              []string
+		seen                = make(map[nlp.DepRel]bool)
+	)
+	for _, instance := range graphs {
+		graph := instance.(*BasicDepGraph)
+		lifted := Projectivize(graph, scheme)
+		if lifted == 0 {
+			continue
+		}
+		numLifted += lifted
+		numSents++
+		for _, arc := range graph.Arcs {
+			if arc == nil || seen[arc.RawRelation] {
+				continue
+			}
+			seen[arc.RawRelation] = true
+			if _, exists := ERel.IndexOf(arc.RawRelation); !exists {
+				labels = append(labels, string(arc.RawRelation))
+			}
+		}
+	}
+	log.Println("Pseudo-projective:\tlifted", numLifted, "arcs in", numSents, "of", len(graphs), "sentences")
+	if len(labels) > 0 {
+		log.Println("Pseudo-projective:\tadded", len(labels), "encoded labels")
+	}
+	ExtendRelationEnum(labels, arcSystem)
+	for _, instance := range graphs {
+		for _, arc := range instance.(*BasicDepGraph).Arcs {
+			if arc != nil {
+				arc.Relation, _ = ERel.IndexOf(arc.RawRelation)
+			}
+		}
+	}
+	return labels
+}
+
+// DeprojectivizeCorpus undoes the pseudo-projective encoding of parsed graphs
+func DeprojectivizeCorpus(graphs []interface{}, scheme string) []interface{} {
+	result := make([]interface{}, len(graphs))
+	for i, graph := range graphs {
+		result[i] = Deprojectivize(graph.(nlp.LabeledDependencyGraph), scheme, ERel)
+	}
+	return result
+}
+
+func DeprojectivizeStream(graphs chan interface{}, scheme string) chan interface{} {
+	out := make(chan interface{}, 2)
+	go func() {
+		for graph := range graphs {
+			out <- Deprojectivize(graph.(nlp.LabeledDependencyGraph), scheme, ERel)
+		}
+		close(out)
+	}()
+	return out
+}
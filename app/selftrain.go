@@ -0,0 +1,291 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+	"yap/nlp/format/mapping"
+	"yap/nlp/format/raw"
+	"yap/nlp/parser/joint"
+	nlp "yap/nlp/types"
+
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	selfTrainRounds int
+	selfTrainSelect int
+	selfTrainMargin float64
+	selfTrainOut    string
+)
+
+// A selfTrainCandidate is a sentence of the raw corpus parsed by the
+// current model, with the beam margin of its parse
+type selfTrainCandidate struct {
+	ID     int
+	Margin float64
+	Parsed *joint.JointConfig
+}
+
+type selfTrainCandidates []*selfTrainCandidate
+
+func (c selfTrainCandidates) Len() int           { return len(c) }
+func (c selfTrainCandidates) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c selfTrainCandidates) Less(i, j int) bool { return c[i].Margin > c[j].Margin }
+
+// BeamMargins returns the margin of the best parse of each sentence over the
+// second best distinct parse of the final beam, from the result parameters
+// of a k-best beam; the margin of a sentence with a single parse is
+// infinite
+func BeamMargins(params []interface{}, format KBestFormat) []float64 {
+	margins := make([]float64, len(params))
+	for i, sent := range KBestCorpus(params, format, 2) {
+		if len(sent.Parses) < 2 {
+			margins[i] = math.Inf(1)
+			continue
+		}
+		margins[i] = sent.Parses[0].Score - sent.Parses[1].Score
+	}
+	return margins
+}
+
+// selectCandidates returns the parsed sentences, of the given ids, whose
+// beam margin is at least minMargin, by descending margin and at most max
+// of them
+func selectCandidates(ids []int, margins []float64, parsed []interface{}, minMargin float64, max int) selfTrainCandidates {
+	candidates := make(selfTrainCandidates, 0, len(parsed))
+	for i, instance := range parsed {
+		if margins[i] >= minMargin {
+			candidates = append(candidates, &selfTrainCandidate{ids[i], margins[i], instance.(*joint.JointConfig)})
+		}
+	}
+	sort.Stable(candidates)
+	if len(candidates) > max {
+		candidates = candidates[:max]
+	}
+	return candidates
+}
+
+func SelfTrainConfigOut() {
+	log.Println("Configuration")
+	log.Printf("Heb Prefix:\t\t%s", prefixFile)
+	log.Printf("Heb Lexicon:\t\t%s", lexiconFile)
+	log.Printf("Initial Model:\t\t%s", modelFile)
+	log.Printf("Rounds:\t\t\t%d", selfTrainRounds)
+	log.Printf("Select (per round):\t%d", selfTrainSelect)
+	log.Printf("Min. Beam Margin:\t%v", selfTrainMargin)
+	log.Printf("Iterations:\t\t%d", Iterations)
+	log.Printf("Beam Size:\t\t%d", BeamSize)
+	log.Printf("Arc System:\t\t%s", arcSystemStr)
+	log.Printf("Parameter Func:\t%v", paramFuncName)
+	log.Printf("Joint Strategy:\t%s", JointStrategy)
+	log.Printf("Oracle Strategy:\t%s", OracleStrategy)
+	log.Printf("Features File:\t%s", featuresFile)
+	log.Printf("Labels File:\t\t%s", labelsFile)
+	log.Println()
+	log.Println("Data")
+	log.Printf("Raw Corpus:\t\t\t\t%s", inRawFile)
+	log.Printf("Train file (conll):\t\t\t%s", tConll)
+	log.Printf("Train file (disamb. lattice):\t%s", tLatDis)
+	log.Printf("Train file (ambig.  lattice):\t%s", tLatAmb)
+	log.Printf("Dev file   (ambig.  lattice):\t%s", input)
+	if len(inputGold) > 0 {
+		log.Printf("Dev file   (disamb. lattice):\t%s", inputGold)
+	}
+	log.Printf("Out prefix:\t\t\t\t%s", selfTrainOut)
+	log.Println()
+}
+
+// concatFiles writes the contents of a file followed by that of a writer
+// function to a new file
+func concatFiles(filename, base string, write func(io.Writer)) {
+	out, err := os.Create(filename)
+	if err != nil {
+		log.Fatalln("Failed creating", filename, err)
+	}
+	defer out.Close()
+	in, err := os.Open(base)
+	if err != nil {
+		log.Fatalln("Failed opening", base, err)
+	}
+	defer in.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		log.Fatalln("Failed copying", base, "to", filename, err)
+	}
+	write(out)
+}
+
+func SelfTrain(cmd *commander.Command, args []string) error {
+	REQUIRED_FLAGS := []string{"raw", "m", "tc", "td", "tl", "in", "f", "l", "out"}
+	VerifyFlags(cmd, REQUIRED_FLAGS)
+	var found bool
+	if prefixFile, found = locateFile(prefixFile, DEFAULT_DATA_DIRS); !found {
+		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "prefix")
+	}
+	if lexiconFile, found = locateFile(lexiconFile, DEFAULT_DATA_DIRS); !found {
+		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "lexicon")
+	}
+	VerifyFlags(cmd, REQUIRED_FLAGS)
+	if selfTrainRounds < 1 {
+		log.Fatalln("Self-training requires at least one round, got", selfTrainRounds)
+	}
+	if selfTrainSelect < 1 {
+		log.Fatalln("Self-training must select at least one sentence per round, got", selfTrainSelect)
+	}
+	for _, file := range []string{inRawFile, modelFile, tConll, tLatDis, tLatAmb, input} {
+		if !VerifyExists(file) {
+			os.Exit(1)
+		}
+	}
+	SelfTrainConfigOut()
+	// retraining parses the dev set with the best parse only
+	KBest = 1
+
+	maData := LoadHebMA(prefixFile, lexiconFile)
+	sents, err := raw.ReadFile(inRawFile, 0)
+	if err != nil {
+		log.Fatalln("Failed reading raw file", err)
+	}
+	log.Println("Analyzing", len(sents), "raw sentences")
+	// the lattices are kept as text, as converting them may modify them
+	pool := make([][]byte, len(sents))
+	for i, sent := range sents {
+		analyzed, _ := maData.Analyze(sent.Tokens())
+		var buf bytes.Buffer
+		lattice.Write(&buf, []lattice.Lattice{lattice.Sentence2Lattice(analyzed, nil)})
+		pool[i] = buf.Bytes()
+	}
+
+	var (
+		trainConll, trainDisLat, trainAmbLat = tConll, tLatDis, tLatAmb
+		curModel                             = modelFile
+		selected                             = make([]bool, len(sents))
+		selConll, selMappings                []interface{}
+		selAmbLat                            [][]byte
+	)
+	for round := 1; round <= selfTrainRounds; round++ {
+		log.Println("*** SELF-TRAINING ROUND", round, "***")
+		beam, e := LoadJointParser(curModel, featuresFile, labelsFile, BeamSize)
+		beam.KBest = beam.Size
+		ids := make([]int, 0, len(sents))
+		instances := make([]interface{}, 0, len(sents))
+		for i, latText := range pool {
+			if selected[i] {
+				continue
+			}
+			lats, err := lattice.Read(bytes.NewReader(latText), 0)
+			if err != nil || len(lats) != 1 {
+				log.Fatalln("Failed reading the lattice of raw sentence", i+1, err)
+			}
+			ids = append(ids, i)
+			instances = append(instances, lattice.Lattice2Sentence(lats[0], e.EWord, e.EPOS, e.EWPOS, e.EMorphProp, e.EMHost, e.EMSuffix))
+		}
+		log.Println("Parsing", len(instances), "remaining raw sentences")
+		parsed, params := ParseWithParams(instances, beam)
+		candidates := selectCandidates(ids, BeamMargins(params, JointKBestFormat), parsed, selfTrainMargin, selfTrainSelect)
+		if len(candidates) == 0 {
+			log.Println("No sentences left with a beam margin of at least", selfTrainMargin, "- stopping")
+			break
+		}
+		for _, candidate := range candidates {
+			selected[candidate.ID] = true
+			selConll = append(selConll, conll.MorphGraph2Conll(candidate.Parsed))
+			selMappings = append(selMappings, &candidate.Parsed.MDConfig)
+			selAmbLat = append(selAmbLat, pool[candidate.ID])
+		}
+		log.Println("Selected", len(candidates), "of", len(instances), "sentences, with beam margins from", candidates[len(candidates)-1].Margin, "to", candidates[0].Margin)
+		log.Println("Adding", len(selConll), "self-trained sentences in total to the training set")
+
+		out := fmt.Sprintf("%s.r%d", selfTrainOut, round)
+		tConll, tLatDis, tLatAmb = out+".train.conll", out+".train.dis.lat", out+".train.amb.lat"
+		concatFiles(tConll, trainConll, func(w io.Writer) { conll.Write(w, selConll) })
+		concatFiles(tLatDis, trainDisLat, func(w io.Writer) { mapping.Write(w, selMappings) })
+		concatFiles(tLatAmb, trainAmbLat, func(w io.Writer) {
+			for _, latText := range selAmbLat {
+				w.Write(latText)
+			}
+		})
+
+		// the convergence test writes its intermediate dev results named
+		// after the outputs
+		base := filepath.Base(out)
+		outConll, outMap, outSeg = base+".dev.conll", base+".dev.map", base+".dev.seg"
+		modelFile = out + ".model"
+		trained, err := JointRun(false)
+		if err != nil {
+			return err
+		}
+		if trained != modelFile {
+			if err := os.Rename(trained, modelFile); err != nil {
+				log.Fatalln("Failed moving the trained model to", modelFile, err)
+			}
+		}
+		log.Println("Round", round, "model written to", modelFile)
+		curModel = modelFile
+	}
+	log.Println("Self-trained model is", curModel)
+	return nil
+}
+
+func SelfTrainCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       SelfTrain,
+		UsageLine: "selftrain <file options> [arguments]",
+		Short:     "self-trains a joint model on confidently parsed sentences of a raw corpus",
+		Long: `
+self-trains a joint morpho-syntactic model on a raw corpus
+
+	$ ./yap selftrain -raw <raw file> -m <joint model> -tc <conll> -td <train disamb. lat> -tl <train amb. lat> -in <dev amb. lat> [-ing <dev disamb. lat>] -out <out prefix> -f <features> -l <labels> [options]
+
+Every round analyzes and parses the raw sentences not yet selected with the
+current model, selects the ones whose best parse has the largest beam
+margin over the second best, adds them to the training set and retrains.
+The training files and model of round N are written to <out>.rN.*; dev
+scores are logged every iteration of retraining if -ing is given.
+`,
+		Flag: *flag.NewFlagSet("selftrain", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&inRawFile, "raw", "", "Input raw (tokenized) corpus")
+	cmd.Flag.StringVar(&modelFile, "m", "", "Initial joint model file")
+	cmd.Flag.StringVar(&selfTrainOut, "out", "", "Prefix of the training files and model of each round")
+	cmd.Flag.IntVar(&selfTrainRounds, "rounds", 3, "Number of self-training rounds")
+	cmd.Flag.IntVar(&selfTrainSelect, "select", 1000, "Maximal number of sentences added per round")
+	cmd.Flag.Float64Var(&selfTrainMargin, "margin", 0, "Minimal beam margin of an added sentence")
+	cmd.Flag.StringVar(&tConll, "tc", "", "Training Conll File")
+	cmd.Flag.StringVar(&tLatDis, "td", "", "Training Disambiguated Lattices File")
+	cmd.Flag.StringVar(&tLatAmb, "tl", "", "Training Ambiguous Lattices File")
+	cmd.Flag.StringVar(&input, "in", "", "Dev Ambiguous Lattices File")
+	cmd.Flag.StringVar(&inputGold, "ing", "", "Optional - Gold Dev Lattices File (for convergence testing and dev scores)")
+	cmd.Flag.StringVar(&prefixFile, "prefix", "bgupreflex_withdef.utf8.hr", "Prefix file for morphological analyzer")
+	cmd.Flag.StringVar(&lexiconFile, "lexicon", "bgulex.utf8.hr", "Lexicon file for morphological analyzer")
+	cmd.Flag.BoolVar(&nnpnofeats, "addnnpnofeats", false, "Add NNP in lex but without features")
+	cmd.Flag.StringVar(&featuresFile, "f", "", "Features Configuration File")
+	cmd.Flag.StringVar(&labelsFile, "l", "", "Dependency Labels Configuration File")
+	cmd.Flag.IntVar(&Iterations, "it", 1, "Number of Perceptron Iterations of every round")
+	cmd.Flag.IntVar(&BeamSize, "b", 64, "Beam Size")
+	cmd.Flag.StringVar(&arcSystemStr, "a", "eager", "Optional - Arc System [standard, eager, swap, hybrid, covington]")
+	cmd.Flag.StringVar(&paramFuncName, "p", "Funcs_Main_POS_Both_Prop", "Param Func types: ["+nlp.AllParamFuncNames+"]")
+	cmd.Flag.StringVar(&JointStrategy, "jointstr", "MDFirst", "Joint Strategy: ["+joint.JointStrategies+"]")
+	cmd.Flag.StringVar(&OracleStrategy, "oraclestr", "MDFirst", "Oracle Strategy: ["+joint.OracleStrategies+"]")
+	cmd.Flag.BoolVar(&UsePOP, "pop", true, "Add POP operation to MD")
+	cmd.Flag.BoolVar(&lattice.IGNORE_LEMMA, "nolemma", false, "Ignore lemmas")
+	cmd.Flag.BoolVar(&noconverge, "noconverge", false, "don't test convergence (run -it number of iterations)")
+	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
+	cmd.Flag.IntVar(&Workers, "workers", 1, "Number of sentences to parse concurrently (each with its own beam)")
+	cmd.Flag.BoolVar(&Shuffle, "shuffle", false, "Shuffle the training instances every iteration")
+	cmd.Flag.Int64Var(&ShuffleSeed, "seed", 1, "Random seed for shuffling the training instances")
+	cmd.Flag.IntVar(&TrainShards, "shards", 1, "Number of training shards trained concurrently, mixing their weights every iteration (iterative parameter mixing)")
+	cmd.Flag.BoolVar(&CountUpdates, "countupdates", false, "Count the updates of each feature in the per-iteration models, for pruning rarely updated features (yap model prune -minupdates)")
+	cmd.Flag.StringVar(&ViolationStrategy, "update", search.EARLY_UPDATE, "Violation-fixing update strategy of beam training [early, max-violation, latest, full]")
+	return cmd
+}
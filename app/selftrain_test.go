@@ -0,0 +1,90 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/alg/transition"
+	dep "yap/nlp/parser/dependency/transition"
+	"yap/nlp/parser/joint"
+
+	"math"
+	"reflect"
+	"testing"
+)
+
+// kbestParams returns the result parameters of a sentence whose final beam
+// has parses of the given formats and scores, best first
+func kbestParams(formatted map[transition.Configuration][]string, parses []string, scores []int64) *search.ParseResultParameters {
+	param := &search.ParseResultParameters{}
+	for i, parse := range parses {
+		conf := &dep.SimpleConfiguration{}
+		formatted[conf] = []string{parse}
+		param.KBest = append(param.KBest, &search.ScoredConfiguration{
+			C:              conf,
+			InternalScores: search.ScoreState{{Total: scores[i]}},
+		})
+	}
+	return param
+}
+
+func TestBeamMargins(t *testing.T) {
+	formatted := make(map[transition.Configuration][]string)
+	format := func(conf transition.Configuration) ([]string, *APISentence) {
+		return formatted[conf], nil
+	}
+	params := []interface{}{
+		kbestParams(formatted, []string{"a", "b", "c"}, []int64{10, 7, 1}),
+		// the second candidate is the same parse as the best
+		kbestParams(formatted, []string{"a", "a", "b"}, []int64{10, 9, 4}),
+		kbestParams(formatted, []string{"a"}, []int64{10}),
+		kbestParams(formatted, []string{"a", "a"}, []int64{10, 9}),
+	}
+	margins := BeamMargins(params, format)
+	expected := []float64{3, 6, math.Inf(1), math.Inf(1)}
+	if !reflect.DeepEqual(margins, expected) {
+		t.Errorf("Got beam margins %v, expected %v", margins, expected)
+	}
+}
+
+func TestSelectCandidates(t *testing.T) {
+	ids := []int{2, 3, 5, 7, 11}
+	margins := []float64{1, 4, math.Inf(1), 0.5, 4}
+	parsed := make([]interface{}, len(ids))
+	for i := range parsed {
+		parsed[i] = &joint.JointConfig{}
+	}
+	candidateIDs := func(candidates selfTrainCandidates) []int {
+		result := make([]int, len(candidates))
+		for i, candidate := range candidates {
+			result[i] = candidate.ID
+		}
+		return result
+	}
+	tests := []struct {
+		name      string
+		minMargin float64
+		max       int
+		expected  []int
+	}{
+		// ties keep the order of the corpus
+		{"all", 0, 10, []int{5, 3, 11, 2, 7}},
+		{"min margin", 1, 10, []int{5, 3, 11, 2}},
+		{"max", 0, 2, []int{5, 3}},
+		{"min margin and max", 4, 1, []int{5}},
+		// a sentence of a single parse is always confident
+		{"single parse", 10, 10, []int{5}},
+		{"none", 1, 0, []int{}},
+	}
+	for _, test := range tests {
+		candidates := selectCandidates(ids, margins, parsed, test.minMargin, test.max)
+		if got := candidateIDs(candidates); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: selected %v, expected %v", test.name, got, test.expected)
+		}
+		for _, candidate := range candidates {
+			for i, id := range ids {
+				if id == candidate.ID && (candidate.Margin != margins[i] || candidate.Parsed != parsed[i]) {
+					t.Errorf("%s: got margin %v and parse %p of sentence %d, expected %v and %p", test.name, candidate.Margin, candidate.Parsed, id, margins[i], parsed[i])
+				}
+			}
+		}
+	}
+}
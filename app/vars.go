@@ -17,6 +17,7 @@ import (
 	nlp "yap/nlp/types"
 	"yap/util"
 
+	"yap/nlp/parser/dependency/mst"
 	dep "yap/nlp/parser/dependency/transition"
 	"yap/nlp/parser/dependency/transition/morph"
 
@@ -25,6 +26,7 @@ import (
 	"log"
 	"os"
 	// "runtime"
+	"sync"
 	"time"
 	// "strings"
 
@@ -49,6 +51,15 @@ var (
 	UsePOP               bool
 	limit                int
 	Stream               bool
+	Workers              int
+	Shuffle              bool
+	ShuffleSeed          int64
+	TrainShards          int
+	ViolationStrategy    string
+	DynamicOracle        bool
+	Explore              float64
+	ExploreAfter         int
+	CountUpdates         bool
 
 	// global enumerations
 	ERel, ETrans, EWord, EPOS, EWPOS, EMHost, EMSuffix *util.EnumSet
@@ -56,7 +67,7 @@ var (
 	EMorphProp                                         *util.EnumSet
 
 	// enumeration offsets of transitions
-	SH, RE, PR, LA, RA, IDLE, POP, MD transition.Transition
+	SH, RE, PR, LA, RA, SW, NA, IDLE, POP, MD transition.Transition
 
 	// file names
 	tConll           string
@@ -102,34 +113,6 @@ type Serialization struct {
 	ETokens                              *util.EnumSet
 }
 
-func WriteModel(file string, data *Serialization) {
-	fObj, err := os.Create(file)
-	if err != nil {
-		log.Fatalln("Failed creating model file", file, err)
-		return
-	}
-	defer fObj.Close()
-	writer := gob.NewEncoder(fObj)
-	err = writer.Encode(data)
-	if err != nil {
-		log.Fatalln("Failed writing model model to", file, err)
-		panic("Failed to write model")
-	}
-}
-
-func ReadModel(file string) *Serialization {
-	data := &Serialization{}
-	fObj, err := os.Open(file)
-	if err != nil {
-		log.Fatalln("Failed reading model from", file, err)
-		return nil
-	}
-	defer fObj.Close()
-	reader := gob.NewDecoder(fObj)
-	reader.Decode(data)
-	return data
-}
-
 func SetupRelationEnum(labels []string) {
 	if ERel != nil {
 		return
@@ -142,7 +125,22 @@ func SetupRelationEnum(labels []string) {
 	ERel.Frozen = true
 }
 
-func SetupTransEnum(relations []string) {
+// ExtendRelationEnum adds labels to the relation enumeration and rebuilds
+// the transition enumeration accordingly
+func ExtendRelationEnum(labels []string, arcSystem string) {
+	ERel.Frozen = false
+	for _, label := range labels {
+		ERel.Add(nlp.DepRel(label))
+	}
+	ERel.Frozen = true
+	relations := make([]string, 0, ERel.Len()-1)
+	for _, rel := range ERel.Index[1:] {
+		relations = append(relations, string(rel.(nlp.DepRel)))
+	}
+	SetupTransEnum(relations, arcSystem)
+}
+
+func SetupTransEnum(relations []string, arcSystem string) {
 	ETrans = util.NewEnumSet((len(relations)+1)*2+2, "ETrans")
 	_, _ = ETrans.Add("IDLE") // dummy no action transition for zpar equivalence
 	iSH, _ := ETrans.Add("SH")
@@ -163,9 +161,78 @@ func SetupTransEnum(relations []string) {
 	for _, transition := range relations {
 		ETrans.Add("RA-" + string(transition))
 	}
+	SetupSwapTransEnum(arcSystem)
+	SetupNoArcTransEnum(arcSystem)
+}
+
+// SetupSwapTransEnum adds the swap transition, only to the enumeration of
+// the swap arc system so that the other systems' models are unchanged
+func SetupSwapTransEnum(arcSystem string) {
+	if arcSystem != "swap" {
+		return
+	}
+	iSW, _ := ETrans.Add("SW")
+	SW = transition.ConstTransition(iSW)
 }
 
-func SetupMorphTransEnum(relations []string) {
+// SetupNoArcTransEnum adds the no arc transition, only to the enumeration
+// of the covington arc system so that the other systems' models are
+// unchanged
+func SetupNoArcTransEnum(arcSystem string) {
+	if arcSystem != "covington" {
+		return
+	}
+	iNA, _ := ETrans.Add("NA")
+	NA = transition.ConstTransition(iNA)
+}
+
+// NewArcSystem returns the arc system named name over the current
+// transition and relation enumerations, and its terminal stack size.
+// Before the enumerations are set up (e.g. for configuration output) its
+// transitions are all 0
+func NewArcSystem(name string) (transition.TransitionSystem, int) {
+	value := func(t transition.Transition) int {
+		if t == nil {
+			return 0
+		}
+		return t.Value()
+	}
+	arcStandard := dep.ArcStandard{
+		SHIFT:       value(SH),
+		LEFT:        value(LA),
+		RIGHT:       value(RA),
+		Relations:   ERel,
+		Transitions: ETrans,
+	}
+	switch name {
+	case "standard":
+		return &arcStandard, 1
+	case "eager":
+		return &dep.ArcEager{
+			ArcStandard: arcStandard,
+			REDUCE:      value(RE),
+			POPROOT:     value(PR),
+		}, 0
+	case "swap":
+		return &dep.ArcSwap{
+			ArcStandard: arcStandard,
+			SWAP:        value(SW),
+		}, 1
+	case "hybrid":
+		return &dep.ArcHybrid{
+			ArcStandard: arcStandard,
+		}, 1
+	case "covington":
+		return &dep.Covington{
+			ArcStandard: arcStandard,
+			NOARC:       value(NA),
+		}, -1
+	default:
+		panic("Unknown arc system")
+	}
+}
+
+func SetupMorphTransEnum(relations []string, arcSystem string) {
 	ETrans = util.NewEnumSet((len(relations)+1)*2+2+APPROX_MORPH_TRANSITIONS, "ETrans")
 	_, _ = ETrans.Add("NO") // dummy for 0 action
 	iSH, _ := ETrans.Add("SH")
@@ -189,6 +256,8 @@ func SetupMorphTransEnum(relations []string) {
 	for _, transition := range relations {
 		ETrans.Add("RA-" + string(transition))
 	}
+	SetupSwapTransEnum(arcSystem)
+	SetupNoArcTransEnum(arcSystem)
 	log.Println("ETrans Len is", ETrans.Len())
 	iPOP, _ := ETrans.Add("POP")
 	POP = &transition.TypedTransition{'P', iPOP}
@@ -393,6 +462,17 @@ func MorphEval(test, gold interface{}, metric string) *eval.Result {
 
 func Train(trainingSet []perceptron.DecodedInstance, Iterations int, filename string, paramModel perceptron.Model, decoder perceptron.EarlyUpdateInstanceDecoder, goldDecoder perceptron.InstanceDecoder, converge perceptron.StopCondition) *perceptron.LinearPerceptron {
 	updater := new(model.AveragedModelStrategy)
+	if converge == nil && (checkpointTraining || CountUpdates) {
+		// write the per-iteration models the eval stop conditions write
+		converge = func(curIteration, iterations, generations int, m perceptron.Model) bool {
+			retval := curIteration < iterations
+			serialize(m, curIteration, generations, &StopState{Continue: retval})
+			return retval
+		}
+	}
+	if resumeCheckpoint != nil {
+		converge = resumeCheckpoint.StopCondition(converge)
+	}
 
 	perceptron := &perceptron.LinearPerceptron{
 		Decoder:     decoder,
@@ -400,10 +480,31 @@ func Train(trainingSet []perceptron.DecodedInstance, Iterations int, filename st
 		Updater:     updater,
 		Continue:    converge,
 		Tempfile:    filename,
-		TempLines:   500}
+		TempLines:   500,
+		Shuffle:     Shuffle,
+		ShuffleSeed: ShuffleSeed}
 
 	perceptron.Iterations = Iterations
+	if beam, isBeam := decoder.(*search.Beam); isBeam && len(ViolationStrategy) > 0 {
+		if !search.ValidViolationStrategy(ViolationStrategy) {
+			log.Fatalln("Unknown update strategy", ViolationStrategy, "options are", search.VIOLATION_STRATEGIES)
+		}
+		beam.Violation = ViolationStrategy
+	}
+	if TrainShards > 1 {
+		log.Println("Training", TrainShards, "shards with iterative parameter mixing")
+		perceptron.ShardDecoders, perceptron.ShardGoldDecoders = shardDecoders(decoder, goldDecoder, TrainShards)
+	}
+	if CountUpdates {
+		paramModel.(*model.AvgMatrixSparse).CountUpdates()
+	}
+	if resumeCheckpoint != nil {
+		resumeCheckpoint.Restore(paramModel.(*model.AvgMatrixSparse))
+	}
 	perceptron.Init(paramModel)
+	if resumeCheckpoint != nil {
+		resumeCheckpoint.Resume(perceptron, paramModel.(*model.AvgMatrixSparse))
+	}
 	// perceptron.TempLoad("model.b64.i1")
 	perceptron.Log = true
 	// beam.Log = true
@@ -420,11 +521,150 @@ type Parser interface {
 	Parse(search.Problem) (transition.Configuration, interface{})
 }
 
+// WorkerParser copies a parser for use by a single decoding worker.
+// The copy shares the (read-only) model, transition system and feature
+// extractor, but not the per parse state. Returns nil if the parser
+// cannot be copied
+func WorkerParser(parser Parser) Parser {
+	switch p := parser.(type) {
+	case *search.Beam:
+		beam := &search.Beam{}
+		*beam = *p
+		beam.DurTotal = 0
+		return beam
+	case *search.Deterministic:
+		deterministic := &search.Deterministic{}
+		*deterministic = *p
+		return deterministic
+	case *mst.Parser:
+		mstParser := &mst.Parser{}
+		*mstParser = *p
+		return mstParser
+	default:
+		return nil
+	}
+}
+
+// shardDecoders returns copies of the training decoders for each shard of
+// iterative parameter mixing, or nil if they can not be copied
+func shardDecoders(decoder perceptron.EarlyUpdateInstanceDecoder, goldDecoder perceptron.InstanceDecoder, shards int) ([]perceptron.EarlyUpdateInstanceDecoder, []perceptron.InstanceDecoder) {
+	beam, beamOk := decoder.(*search.Beam)
+	deterministic, deterministicOk := goldDecoder.(*search.Deterministic)
+	latent, latentOk := goldDecoder.(*search.LatentGold)
+	if latentOk {
+		deterministic, deterministicOk = latent.Gold, true
+	}
+	if !beamOk || !deterministicOk {
+		log.Printf("Decoders of type %T, %T can not be copied, training serially", decoder, goldDecoder)
+		return nil, nil
+	}
+	decoders := make([]perceptron.EarlyUpdateInstanceDecoder, shards)
+	goldDecoders := make([]perceptron.InstanceDecoder, shards)
+	for i := range decoders {
+		shardBeam := &search.Beam{}
+		*shardBeam = *beam
+		shardBeam.DurTotal = 0
+		decoders[i] = shardBeam
+		shardDeterministic := &search.Deterministic{}
+		*shardDeterministic = *deterministic
+		goldDecoders[i] = shardDeterministic
+		if latentOk {
+			shardLatentBeam := &search.Beam{}
+			*shardLatentBeam = *latent.Beam
+			goldDecoders[i] = &search.LatentGold{Beam: shardLatentBeam, Gold: shardDeterministic}
+		}
+	}
+	return decoders, goldDecoders
+}
+
+func workerParsers(parser Parser, workers int) []Parser {
+	if workers < 2 {
+		return nil
+	}
+	parsers := make([]Parser, workers)
+	for i := range parsers {
+		if parsers[i] = WorkerParser(parser); parsers[i] == nil {
+			log.Printf("Parser of type %T can not be copied, parsing with a single worker", parser)
+			return nil
+		}
+	}
+	return parsers
+}
+
+type parseJob struct {
+	i        int
+	instance interface{}
+	result   chan *parseResult
+}
+
+type parseResult struct {
+	parsed, params interface{}
+}
+
+// parses instances concurrently, one parser per worker, writing the
+// results in input order
+func parseConcurrent(instances chan interface{}, writeStream chan interface{}, parsers []Parser) {
+	results := make(chan *parseResult, len(parsers))
+	go func() {
+		parseConcurrentParams(instances, results, parsers)
+		close(results)
+	}()
+	for result := range results {
+		writeStream <- result.parsed
+	}
+}
+
+// parseConcurrentParams parses like parseConcurrent, keeping the parsers'
+// result parameters
+func parseConcurrentParams(instances chan interface{}, writeStream chan *parseResult, parsers []Parser) {
+	var wg sync.WaitGroup
+	jobs := make(chan *parseJob, len(parsers))
+	// bounds the number of parsed instances waiting for an earlier one
+	ordered := make(chan *parseJob, 2*len(parsers))
+	for _, parser := range parsers {
+		wg.Add(1)
+		go func(parser Parser) {
+			defer wg.Done()
+			for job := range jobs {
+				log.Println("Parsing instance", job.i)
+				parsed, params := parser.Parse(job.instance)
+				job.result <- &parseResult{parsed, params}
+			}
+		}(parser)
+	}
+	go func() {
+		var i int
+		for instance := range instances {
+			job := &parseJob{i, instance, make(chan *parseResult, 1)}
+			ordered <- job
+			jobs <- job
+			i++
+		}
+		close(jobs)
+		close(ordered)
+	}()
+	for job := range ordered {
+		writeStream <- <-job.result
+	}
+	wg.Wait()
+}
+
 func ParseStream(instances chan interface{}, writeStream chan interface{}, parser Parser) {
 	// runtime.GOMAXPROCS(1)
 	// Search.AllOut = true
 	startTime := time.Now()
 
+	if parsers := workerParsers(parser, Workers); parsers != nil {
+		log.Println("Parsing with", len(parsers), "workers")
+		parseConcurrent(instances, writeStream, parsers)
+		if allOut {
+			parseTime := time.Since(startTime)
+			log.Println("PARSE Total Time:", parseTime)
+		}
+		close(writeStream)
+		return
+	}
+
 	// prevGC := debug.SetGCPercent(-1)
 	var i int
 	for instance := range instances {
@@ -448,12 +688,45 @@ func ParseStream(instances chan interface{}, writeStream chan interface{}, parse
 }
 
 func Parse(instances []interface{}, parser Parser) []interface{} {
+	parsed, _ := ParseWithParams(instances, parser)
+	return parsed
+}
+
+// ParseWithParams parses like Parse, also returning the result parameters
+// of each instance
+func ParseWithParams(instances []interface{}, parser Parser) ([]interface{}, []interface{}) {
 	// runtime.GOMAXPROCS(1)
 	// Search.AllOut = true
 	startTime := time.Now()
 
 	// prevGC := debug.SetGCPercent(-1)
 	parsed := make([]interface{}, len(instances))
+	params := make([]interface{}, len(instances))
+	if parsers := workerParsers(parser, Workers); parsers != nil {
+		log.Println("Parsing with", len(parsers), "workers")
+		instanceStream := make(chan interface{}, len(parsers))
+		parsedStream := make(chan *parseResult, len(parsers))
+		go func() {
+			for _, instance := range instances {
+				instanceStream <- instance
+			}
+			close(instanceStream)
+		}()
+		go func() {
+			parseConcurrentParams(instanceStream, parsedStream, parsers)
+			close(parsedStream)
+		}()
+		var i int
+		for result := range parsedStream {
+			parsed[i], params[i] = result.parsed, result.params
+			i++
+		}
+		if allOut {
+			parseTime := time.Since(startTime)
+			log.Println("PARSE Total Time:", parseTime)
+		}
+		return parsed, params
+	}
 	for i, instance := range instances {
 		// if i%50 == 0 {
 		// 	debug.SetGCPercent(100)
@@ -462,15 +735,14 @@ func Parse(instances []interface{}, parser Parser) []interface{} {
 		// }
 		log.Println("Parsing instance", i) //, "len", len(sent.Tokens()))
 		// }
-		result, _ := parser.Parse(instance)
-		parsed[i] = result
+		parsed[i], params[i] = parser.Parse(instance)
 	}
 	if allOut {
 		parseTime := time.Since(startTime)
 		log.Println("PARSE Total Time:", parseTime)
 	}
 	// debug.SetGCPercent(prevGC)
-	return parsed
+	return parsed, params
 }
 
 func GetMDConfigAsLattices(instance interface{}) util.Equaler {
@@ -518,13 +790,8 @@ func GetInstances(instances []interface{}, getFunc InstanceFunc) []interface{} {
 }
 
 func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interface{}, testInstances []interface{}, testGoldInstances []interface{}, parser Parser, goldDecoder perceptron.InstanceDecoder, beamSize int) perceptron.StopCondition {
-	var (
-		equalIterations int
-		prevResult      float64
-	)
+	state := resumedStopState()
 	return func(curIteration, iterations, generations int, model perceptron.Model) bool {
-		// first write current model
-		serialize(model, curIteration, generations)
 		// log.Println("Eval starting for iteration", curIteration)
 		var total = &eval.Total{
 			Results: make([]*eval.Result, 0, len(instances)),
@@ -534,6 +801,8 @@ func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interfa
 		}
 		// Don't test before initial run
 		if curIteration == 0 {
+			state.Continue = true
+			serialize(model, curIteration, generations, state)
 			return true
 		}
 		var curResult float64
@@ -561,10 +830,10 @@ func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interfa
 		curResult = total.F1()
 		curPosResult = posonlytotal.F1()
 		// Break out of edge case where result remains the same
-		if curResult == prevResult {
-			equalIterations += 1
+		if curResult == state.PrevResult {
+			state.EqualIterations += 1
 		}
-		retval := (curIteration >= iterations) && (curResult < prevResult || equalIterations > 2)
+		retval := (curIteration >= iterations) && (curResult < state.PrevResult || state.EqualIterations > 2)
 		// retval := curIteration >= iterations
 		log.Println("Result (F1): ", curResult, "Exact:", total.Exact, "TruePos:", total.TP, "in", total.Population, "POS F1:", curPosResult)
 		if retval {
@@ -572,7 +841,10 @@ func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interfa
 		} else {
 			log.Println("Continuing")
 		}
-		prevResult = curResult
+		state.PrevResult = curResult
+		// write the current model along with the updated state
+		state.Continue = !retval
+		serialize(model, curIteration, generations, state)
 		log.Println("Writing interm results to", fmt.Sprintf("interm.i%v.b%v.%v", curIteration, beamSize, outMap))
 		mapping.WriteFile(fmt.Sprintf("interm.i%v.b%v.%v", curIteration, beamSize, outMap), parsed)
 		if testInstances != nil {
@@ -616,14 +888,8 @@ func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interfa
 }
 
 func MakeDepEvalStopCondition(instances []interface{}, goldInstances []interface{}, testInstances []interface{}, parser Parser, goldDecoder perceptron.InstanceDecoder, beamSize int) perceptron.StopCondition {
-	var (
-		equalIterations     int
-		prevResult          float64
-		continuousDecreases int
-	)
+	state := resumedStopState()
 	return func(curIteration, iterations, generations int, model perceptron.Model) bool {
-		// first write current model
-		serialize(model, curIteration, generations)
 		// log.Println("Eval starting for iteration", curIteration)
 		var total = &eval.Total{
 			Results: make([]*eval.Result, 0, len(instances)),
@@ -633,6 +899,8 @@ func MakeDepEvalStopCondition(instances []interface{}, goldInstances []interface
 		}
 		// Don't test before initial run
 		if curIteration == 0 {
+			state.Continue = true
+			serialize(model, curIteration, generations, state)
 			return true
 		}
 		var curResult float64
@@ -660,10 +928,10 @@ func MakeDepEvalStopCondition(instances []interface{}, goldInstances []interface
 		}
 		curResult = total.Precision()
 		// Break out of edge case where result remains the same
-		if curResult == prevResult {
-			equalIterations += 1
+		if curResult == state.PrevResult {
+			state.EqualIterations += 1
 		}
-		retval := (Iterations < curIteration) && ((continuousDecreases > 1 && curResult < prevResult) || equalIterations > 3)
+		retval := (Iterations < curIteration) && ((state.ContinuousDecreases > 1 && curResult < state.PrevResult) || state.EqualIterations > 3)
 		// retval := curIteration >= iterations
 		log.Println("Result (UAS, LAS, UEM #, UEM %): ", utotal.Precision(), total.Precision(), utotal.Exact, float64(utotal.Exact)/float64(total.Population), "TruePos:", total.TP, "in", total.Population)
 		if retval {
@@ -671,12 +939,15 @@ func MakeDepEvalStopCondition(instances []interface{}, goldInstances []interface
 		} else {
 			log.Println("Continuing")
 		}
-		if curResult < prevResult {
-			continuousDecreases += 1
+		if curResult < state.PrevResult {
+			state.ContinuousDecreases += 1
 		} else {
-			continuousDecreases = 0
+			state.ContinuousDecreases = 0
 		}
-		prevResult = curResult
+		state.PrevResult = curResult
+		// write the current model along with the updated state
+		state.Continue = !retval
+		serialize(model, curIteration, generations, state)
 		graphs := conll.Graph2ConllCorpus(parsed, EMHost, EMSuffix)
 		conll.WriteFile(fmt.Sprintf("interm.i%v.b%v.%v", curIteration, beamSize, outConll), graphs)
 		if testInstances != nil {
@@ -690,14 +961,7 @@ func MakeDepEvalStopCondition(instances []interface{}, goldInstances []interface
 	}
 }
 
-func MakeJointEvalStopCondition(instances []interface{}, goldInstances []interface{}, testInstances []interface{}, testGoldInstances []interface{}, parser Parser, goldDecoder perceptron.InstanceDecoder, beamSize int) perceptron.StopCondition {
-	var (
-		equalIterations             int
-		prevResult, bestResult      float64
-		continuousDecreases         int
-		bestIteration               int
-		bestModelFile, curModelFile string
-	)
+func MakeJointEvalStopCondition(instances []interface{}, goldInstances []interface{}, testInstances []interface{}, testGoldInstances []interface{}, parser Parser, goldDecoder perceptron.InstanceDecoder, beamSize int, state *StopState) perceptron.StopCondition {
 	return func(curIteration, iterations, generations int, model perceptron.Model) bool {
 		// log.Println("Eval starting for iteration", curIteration)
 		var total = &eval.Total{
@@ -710,7 +974,7 @@ func MakeJointEvalStopCondition(instances []interface{}, goldInstances []interfa
 		if curIteration == 0 {
 			return true
 		}
-		curModelFile = serialize(model, curIteration, generations)
+		curModelFile := tempModelFile(curIteration)
 		var curResult float64
 		var curPosResult float64
 		// TODO: fix this leaky abstraction :(
@@ -736,39 +1000,42 @@ func MakeJointEvalStopCondition(instances []interface{}, goldInstances []interfa
 		curResult = total.F1()
 		curPosResult = posonlytotal.F1()
 		// Break out of edge case where result remains the same
-		if curResult == prevResult {
-			equalIterations += 1
+		if curResult == state.PrevResult {
+			state.EqualIterations += 1
 		}
-		if curResult < prevResult {
-			continuousDecreases += 1
+		if curResult < state.PrevResult {
+			state.ContinuousDecreases += 1
 		} else {
-			continuousDecreases = 0
+			state.ContinuousDecreases = 0
 		}
-		if bestResult < curResult {
-			bestResult = curResult
-			bestIteration = curIteration
-			bestModelFile = curModelFile
+		if state.BestResult < curResult {
+			state.BestResult = curResult
+			state.BestIteration = curIteration
+			state.BestModelFile = curModelFile
 		}
-		retval := (Iterations < curIteration) && ((continuousDecreases > 1 && curResult < prevResult) || equalIterations > 3)
-		log.Println("It", Iterations, "CurIt", curIteration, "Continuous", continuousDecreases, "CurResult", curResult, "PrevResult", prevResult, "Comp", curResult < prevResult, "Retval", retval)
+		retval := (Iterations < curIteration) && ((state.ContinuousDecreases > 1 && curResult < state.PrevResult) || state.EqualIterations > 3)
+		log.Println("It", Iterations, "CurIt", curIteration, "Continuous", state.ContinuousDecreases, "CurResult", curResult, "PrevResult", state.PrevResult, "Comp", curResult < state.PrevResult, "Retval", retval)
 		// retval := curIteration >= iterations
 		log.Println("Result (F1): ", curResult, "Exact:", total.Exact, "TruePos:", total.TP, "in", total.Population, "POS F1:", curPosResult)
 		if retval {
 			log.Println("Stopping")
-			log.Println("Best iteration was", bestIteration)
-			log.Println("Best model file", bestModelFile)
+			log.Println("Best iteration was", state.BestIteration)
+			log.Println("Best model file", state.BestModelFile)
 
 			file, err := os.Create("bestmodelname")
 			defer file.Close()
 			if err != nil {
 				log.Println("Failed to write name of best model:", err)
 			} else {
-				file.Write([]byte(bestModelFile))
+				file.Write([]byte(state.BestModelFile))
 			}
 		} else {
 			log.Println("Continuing")
 		}
-		prevResult = curResult
+		state.PrevResult = curResult
+		// write the current model along with the updated state
+		state.Continue = !retval
+		serialize(model, curIteration, generations, state)
 		graphs := conll.MorphGraph2ConllCorpus(parsedGraphs)
 		log.Println("Writing interm results to conll:", fmt.Sprintf("interm.i%v.b%v.%v", curIteration, beamSize, outConll))
 		conll.WriteFile(fmt.Sprintf("interm.i%v.b%v.%v", curIteration, beamSize, outConll), graphs)
@@ -823,13 +1090,37 @@ func writeEnums(enums []*util.EnumSet) {
 		}
 	}
 }
-func serialize(perceptronModel perceptron.Model, iteration, generations int) string {
+
+// tempModelFile is the name of the model written after the given number
+// of training iterations
+func tempModelFile(iteration int) string {
+	return fmt.Sprintf("model.temp.i%d", iteration)
+}
+
+func serialize(perceptronModel perceptron.Model, iteration, generations int, stop *StopState) string {
+	avgModel := perceptronModel.(*model.AvgMatrixSparse)
 	serialization := &Serialization{
-		perceptronModel.(*model.AvgMatrixSparse).Serialize(generations),
+		avgModel.Serialize(generations),
 		EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
 	}
+	if checkpointTraining {
+		// the averaging state makes the temporary model a checkpoint
+		// training can be resumed from
+		avgModel.SerializeHistory(serialization.WeightModel)
+	}
+	avgModel.SerializeUpdates(serialization.WeightModel)
 	// writeEnums([]*util.EnumSet{EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens, ERel})
-	modelFile := fmt.Sprintf("model.temp.i%d", iteration)
-	WriteModel(modelFile, serialization)
+	var header *ModelHeader
+	if trainingHeader != nil {
+		header = &ModelHeader{}
+		*header = *trainingHeader
+		header.TrainIterations = iteration
+		if checkpointTraining && stop != nil {
+			state := *stop
+			header.Stop = &state
+		}
+	}
+	modelFile := tempModelFile(iteration)
+	WriteModel(modelFile, header, serialization)
 	return modelFile
 }
@@ -111,7 +111,7 @@ func (t *Total) ExactMatch() float64 {
 }
 
 func (t *Total) Errors() Errors {
-	retval := make([]Error, t.Incorrect())
+	retval := make([]Error, 0, t.Incorrect())
 	for _, v := range t.Results {
 		if v.Errors != nil {
 			retval = append(retval, v.Errors...)
@@ -0,0 +1,24 @@
+package eval
+
+import "testing"
+
+type testError string
+
+func (e testError) String() string { return string(e) }
+func (e testError) Class() string  { return "test" }
+
+func TestTotalErrors(t *testing.T) {
+	total := &Total{Results: make([]*Result, 0, 3)}
+	total.Add(&Result{TP: 3})
+	total.Add(&Result{TP: 1, FP: 2, Errors: Errors{testError("a"), testError("b")}})
+	total.Add(&Result{TP: 2, FN: 1, Errors: Errors{testError("c")}})
+	errors := total.Errors()
+	if len(errors) != 3 {
+		t.Fatalf("Expected 3 errors, got %d: %v", len(errors), errors)
+	}
+	for i, expected := range []string{"a", "b", "c"} {
+		if errors[i] == nil || errors[i].String() != expected {
+			t.Errorf("Expected error %d to be %s, got %v", i, expected, errors[i])
+		}
+	}
+}
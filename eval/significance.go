@@ -0,0 +1,136 @@
+package eval
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// A Score computes a score (e.g. precision or F1) of a result
+type Score func(r *Result) float64
+
+func PrecisionScore(r *Result) float64 {
+	if r.TP == 0 {
+		return 0
+	}
+	return r.Precision()
+}
+
+func F1Score(r *Result) float64 {
+	if r.TP == 0 {
+		return 0
+	}
+	return r.F1()
+}
+
+// Sum returns the result summing results, of all if indices is nil
+func Sum(results []*Result, indices []int) *Result {
+	sum := &Result{}
+	add := func(r *Result) {
+		sum.TP += r.TP
+		sum.FP += r.FP
+		sum.TN += r.TN
+		sum.FN += r.FN
+	}
+	if indices == nil {
+		for _, r := range results {
+			add(r)
+		}
+	} else {
+		for _, i := range indices {
+			add(results[i])
+		}
+	}
+	return sum
+}
+
+// An Interval is a confidence interval
+type Interval struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+// Significance is the comparison of the scores of two systems (A and B) on
+// the same sentences
+type Significance struct {
+	A          float64  `json:"a"`
+	B          float64  `json:"b"`
+	Diff       float64  `json:"diff"`
+	ACI        Interval `json:"a_ci"`
+	BCI        Interval `json:"b_ci"`
+	DiffCI     Interval `json:"diff_ci"`
+	BootstrapP float64  `json:"bootstrap_p"`
+	RandP      float64  `json:"randomization_p"`
+}
+
+func percentileInterval(samples []float64, confidence float64) Interval {
+	sort.Float64s(samples)
+	tail := (1 - confidence) / 2
+	low := int(math.Floor(tail * float64(len(samples)-1)))
+	high := int(math.Ceil((1 - tail) * float64(len(samples)-1)))
+	return Interval{samples[low], samples[high]}
+}
+
+// PairedBootstrap resamples the sentences of two systems' paired results
+// with replacement, filling in the confidence intervals of a significance
+// at a confidence level (e.g. 0.95) and the two sided p-value of their
+// difference: the share of samples whose difference is further from the
+// observed difference than it is from zero
+func PairedBootstrap(sig *Significance, a, b []*Result, score Score, samples int, confidence float64, rng *rand.Rand) {
+	aScores, bScores, diffs := make([]float64, samples), make([]float64, samples), make([]float64, samples)
+	indices := make([]int, len(a))
+	var extreme int
+	for s := 0; s < samples; s++ {
+		for i := range indices {
+			indices[i] = rng.Intn(len(a))
+		}
+		aScores[s], bScores[s] = score(Sum(a, indices)), score(Sum(b, indices))
+		diffs[s] = aScores[s] - bScores[s]
+		if math.Abs(diffs[s]-sig.Diff) >= math.Abs(sig.Diff) {
+			extreme++
+		}
+	}
+	sig.ACI = percentileInterval(aScores, confidence)
+	sig.BCI = percentileInterval(bScores, confidence)
+	sig.DiffCI = percentileInterval(diffs, confidence)
+	sig.BootstrapP = float64(extreme+1) / float64(samples+1)
+}
+
+// ApproximateRandomization returns the two sided p-value of the difference
+// between two systems' scores by randomly swapping their paired results of
+// each sentence: the share of shuffles with a difference at least as large
+// as the observed one
+func ApproximateRandomization(a, b []*Result, score Score, samples int, rng *rand.Rand) float64 {
+	observed := math.Abs(score(Sum(a, nil)) - score(Sum(b, nil)))
+	shuffledA, shuffledB := make([]*Result, len(a)), make([]*Result, len(b))
+	var extreme int
+	for s := 0; s < samples; s++ {
+		for i := range a {
+			if rng.Intn(2) == 0 {
+				shuffledA[i], shuffledB[i] = a[i], b[i]
+			} else {
+				shuffledA[i], shuffledB[i] = b[i], a[i]
+			}
+		}
+		if math.Abs(score(Sum(shuffledA, nil))-score(Sum(shuffledB, nil))) >= observed {
+			extreme++
+		}
+	}
+	return float64(extreme+1) / float64(samples+1)
+}
+
+// Compare compares the scores of two systems' paired sentence results by
+// paired bootstrap resampling and approximate randomization
+func Compare(a, b []*Result, score Score, samples int, confidence float64, rng *rand.Rand) *Significance {
+	if len(a) != len(b) {
+		panic("Compared results must be paired")
+	}
+	sig := &Significance{A: score(Sum(a, nil)), B: score(Sum(b, nil))}
+	sig.Diff = sig.A - sig.B
+	if len(a) == 0 {
+		return sig
+	}
+	PairedBootstrap(sig, a, b, score, samples, confidence, rng)
+	sig.RandP = ApproximateRandomization(a, b, score, samples, rng)
+	return sig
+}
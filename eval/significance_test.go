@@ -0,0 +1,61 @@
+package eval
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// sentenceResults returns the results of sentences of 10 words, correct
+// words as TP and incorrect ones as FP
+func sentenceResults(correct []int) []*Result {
+	results := make([]*Result, len(correct))
+	for i, c := range correct {
+		results[i] = &Result{TP: c, FP: 10 - c}
+	}
+	return results
+}
+
+func TestCompareIdentical(t *testing.T) {
+	a := sentenceResults([]int{7, 9, 10, 5, 8, 6, 9, 10, 4, 8})
+	sig := Compare(a, a, PrecisionScore, 1000, 0.95, rand.New(rand.NewSource(1)))
+	if sig.A != 0.76 || sig.B != sig.A || sig.Diff != 0 {
+		t.Errorf("Got scores %v and %v (diff %v), expected 0.76 for both", sig.A, sig.B, sig.Diff)
+	}
+	if sig.DiffCI != (Interval{0, 0}) {
+		t.Errorf("Got difference interval %v, expected [0, 0]", sig.DiffCI)
+	}
+	if sig.BootstrapP != 1 || sig.RandP != 1 {
+		t.Errorf("Got p-values %v (bootstrap) %v (randomization), expected 1", sig.BootstrapP, sig.RandP)
+	}
+	if sig.ACI.Low > sig.A || sig.ACI.High < sig.A || sig.ACI != sig.BCI {
+		t.Errorf("Got intervals %v and %v, expected the same interval of %v", sig.ACI, sig.BCI, sig.A)
+	}
+}
+
+func TestCompareDifferent(t *testing.T) {
+	var correctA, correctB []int
+	for i := 0; i < 30; i++ {
+		correctA = append(correctA, 8+i%3)
+		correctB = append(correctB, 4+i%3)
+	}
+	a, b := sentenceResults(correctA), sentenceResults(correctB)
+	const samples = 1000
+	sig := Compare(a, b, PrecisionScore, samples, 0.95, rand.New(rand.NewSource(1)))
+	if sig.Diff < 0.39 || sig.Diff > 0.41 {
+		t.Errorf("Got difference %v, expected 0.4", sig.Diff)
+	}
+	if sig.DiffCI.Low <= 0 || sig.DiffCI.Low > sig.Diff || sig.DiffCI.High < sig.Diff {
+		t.Errorf("Got difference interval %v, expected it above 0 around %v", sig.DiffCI, sig.Diff)
+	}
+	if sig.ACI.Low <= sig.BCI.High {
+		t.Errorf("Got overlapping intervals %v and %v", sig.ACI, sig.BCI)
+	}
+	// no resample nor shuffle is as extreme as the observed difference
+	if expected := 1.0 / (samples + 1); sig.BootstrapP != expected || sig.RandP != expected {
+		t.Errorf("Got p-values %v (bootstrap) %v (randomization), expected %v", sig.BootstrapP, sig.RandP, expected)
+	}
+	// the comparison is antisymmetric
+	if reversed := Compare(b, a, PrecisionScore, samples, 0.95, rand.New(rand.NewSource(1))); reversed.Diff != -sig.Diff {
+		t.Errorf("Got reversed difference %v, expected %v", reversed.Diff, -sig.Diff)
+	}
+}
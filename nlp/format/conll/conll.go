@@ -108,9 +108,49 @@ func (r Row) String() string {
 	return strings.Join(fields, "\t")
 }
 
+type JSONRow struct {
+	ID      int    `json:"id"`
+	Form    string `json:"form"`
+	Lemma   string `json:"lemma,omitempty"`
+	CPosTag string `json:"cpostag"`
+	PosTag  string `json:"postag,omitempty"`
+	Feats   string `json:"feats,omitempty"`
+	Head    int    `json:"head"`
+	DepRel  string `json:"deprel"`
+}
+
+func (r Row) JSON() JSONRow {
+	jsonRow := JSONRow{
+		ID:      r.ID,
+		Form:    r.Form,
+		CPosTag: r.CPosTag,
+		Head:    r.Head,
+		DepRel:  r.DepRel,
+	}
+	if r.Lemma != "_" {
+		jsonRow.Lemma = r.Lemma
+	}
+	if r.PosTag != "_" {
+		jsonRow.PosTag = r.PosTag
+	}
+	if r.FeatStr != "_" {
+		jsonRow.Feats = r.FeatStr
+	}
+	return jsonRow
+}
+
 // A Sentence is a map of Rows using their ids
 type Sentence map[int]Row
 
+// JSON returns the rows of the sentence ordered by id
+func (s Sentence) JSON() []JSONRow {
+	rows := make([]JSONRow, 0, len(s))
+	for i := 1; i <= len(s); i++ {
+		rows = append(rows, s[i].JSON())
+	}
+	return rows
+}
+
 type Sentences []Sentence
 
 func ParseInt(value string) (int, error) {
@@ -197,11 +237,9 @@ func ParseRow(record []string) (Row, error) {
 	}
 	row.Head = head
 
-	deprel := ParseString(record[7])
-	if deprel == "" {
-		return row, errors.New("Empty DEPREL field")
-	}
-	row.DepRel = deprel
+	// an empty DEPREL is unannotated, as the HEAD of partially annotated
+	// input may be
+	row.DepRel = ParseString(record[7])
 
 	// phead, err := ParseInt(record[8])
 	// if err != nil {
@@ -334,6 +372,64 @@ func ReadFile(filename string, limit int) ([]Sentence, error) {
 	return Read(file, limit)
 }
 
+// An Arc is the head and label of a row as annotated, if at all: a row
+// has a head unless its HEAD is _, and an unannotated label is empty
+type Arc struct {
+	Head    int
+	HasHead bool
+	DepRel  string
+}
+
+// ReadArcs reads the annotated arcs of the words of CoNLL or CoNLL-U
+// sentences, in order, skipping CoNLL-U multiword token and empty node rows
+func ReadArcs(reader io.Reader, limit int) ([][]Arc, error) {
+	var (
+		sentences   [][]Arc
+		currentSent []Arc
+		bufReader   = bufio.NewReaderSize(reader, 16384)
+	)
+	for curLine, isPrefix, err := bufReader.ReadLine(); err == nil; curLine, isPrefix, err = bufReader.ReadLine() {
+		if isPrefix {
+			panic("Buffer not large enough, fix me :(")
+		}
+		if len(curLine) == 0 {
+			sentences = append(sentences, currentSent)
+			if limit > 0 && len(sentences) >= limit {
+				break
+			}
+			currentSent = nil
+			continue
+		}
+		record := strings.Split(string(curLine), "\t")
+		if record[0][0] == '#' || strings.ContainsAny(record[0], "-.") {
+			continue
+		}
+		if len(record) < 8 {
+			return nil, errors.New(fmt.Sprintf("Error processing record at statement %d: expected at least 8 fields, got %d", len(sentences), len(record)))
+		}
+		arc := Arc{HasHead: record[6] != "_", DepRel: ParseString(record[7])}
+		if arc.HasHead {
+			head, err := ParseInt(record[6])
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Error parsing HEAD field (%s) at statement %d: %s", record[6], len(sentences), err.Error()))
+			}
+			arc.Head = head
+		}
+		currentSent = append(currentSent, arc)
+	}
+	return sentences, nil
+}
+
+func ReadArcsFile(filename string, limit int) ([][]Arc, error) {
+	file, err := os.Open(filename)
+	defer file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadArcs(file, limit)
+}
+
 func ReadFileAsStream(filename string, limit int) (chan Sentence, error) {
 	file, err := os.Open(filename)
 	defer file.Close()
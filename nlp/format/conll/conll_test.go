@@ -81,3 +81,24 @@ func TestParseRowWithRepeatingParams(t *testing.T) {
 		t.Error("Failure concatenating multiple features: should be F,M got " + parsed.Feats["suf_gen"])
 	}
 }
+
+func TestReadArcs(t *testing.T) {
+	input := "# sent_id = 1\n" +
+		"1-2\tBCLM\t_\t_\t_\t_\t_\t_\t_\t_\n" +
+		"1\tB\t_\tIN\tIN\t_\t0\tROOT\t_\t_\n" +
+		"2\tCLM\t_\tNN\tNN\t_\t_\t_\t_\t_\n" +
+		"3\tFL\t_\tPOS\tPOS\t_\t2\t_\t_\t_\n\n"
+	sents, err := ReadArcs(strings.NewReader(input), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(sents) != 1 || len(sents[0]) != 3 {
+		t.Fatalf("Expected 1 sentence of 3 words, got %v", sents)
+	}
+	expected := []Arc{{0, true, "ROOT"}, {0, false, ""}, {2, true, ""}}
+	for i, arc := range sents[0] {
+		if arc != expected[i] {
+			t.Errorf("Expected arc %v of word %d, got %v", expected[i], i+1, arc)
+		}
+	}
+}
@@ -24,6 +24,9 @@ import (
 
 const (
 	PRONOMINAL_CLITIC_POS = "S_PRN"
+	// value of the optional column after TOKEN of an edge on a fixed path,
+	// one its disambiguation must go through
+	FIXED = "fixed"
 )
 
 var (
@@ -114,6 +117,7 @@ type Edge struct {
 	Token    int
 	Id       int
 	TokenStr string
+	Fixed    bool
 }
 
 type EdgeSlice []Edge
@@ -422,6 +426,7 @@ func ParseEdge(record []string) (*Edge, error) {
 		return row, errors.New(fmt.Sprintf("Error parsing TOKEN field (%s): %s", record[7], err.Error()))
 	}
 	row.Token = token
+	row.Fixed = len(record) > 8 && record[8] == FIXED
 
 	if IGNORE_NNP_FEATS && cpostag == "NNP" {
 		record[6] = "_"
@@ -907,61 +912,65 @@ func UDWrite(writer io.Writer, lattices []Lattice, comments [][]string, oovVecto
 	return nil
 }
 
-func UDWriteJSON(writer io.Writer, lattices []Lattice) error {
-	for _, lattice := range lattices {
-		var (
-			max, lastToken int
-			jsonEdge       *JSONEdge
-			jsonLat        JSONLattice
-		)
+// Lattice2JSON splits a lattice into a JSONLattice per token
+func Lattice2JSON(lattice Lattice) []JSONLattice {
+	var (
+		max, lastToken int
+		jsonEdge       *JSONEdge
+		jsonLat        JSONLattice
+		jsonLats       []JSONLattice
+	)
 
-		for k, _ := range lattice {
-			if k > max {
-				max = k
-			}
+	for k, _ := range lattice {
+		if k > max {
+			max = k
 		}
-		for i := 0; i <= max; i++ {
-			if row, exists := lattice[i]; exists {
-				if len(row) > 0 && row[0].Token > lastToken {
-					lastToken = row[0].Token
-					if jsonLat != nil {
-						marshalled, err := json.Marshal(jsonLat)
-						if err != nil {
-							panic(fmt.Sprintf("Failure marshalling %v", err))
-						}
-						writer.Write(marshalled)
-						writer.Write([]byte{'\n'})
-					}
-					jsonLat = make(JSONLattice)
+	}
+	for i := 0; i <= max; i++ {
+		if row, exists := lattice[i]; exists {
+			if len(row) > 0 && row[0].Token > lastToken {
+				lastToken = row[0].Token
+				if jsonLat != nil {
+					jsonLats = append(jsonLats, jsonLat)
 				}
-				for _, edge := range row {
-					jsonEdge = &JSONEdge{
-						Next:    fmt.Sprint(edge.End),
-						Form:    edge.Word,
-						UPOSTag: edge.CPosTag,
-					}
-					if edge.Lemma != "_" {
-						jsonEdge.Lemma = edge.Lemma
-					}
-					if edge.FeatStr != "_" {
-						jsonEdge.Feats = edge.FeatStr
-					}
-					if edge.PosTag != "_" {
-						jsonEdge.XPOSTag = edge.PosTag
-					}
-					startStr := fmt.Sprint(edge.Start)
-					if outEdges, edgesExist := jsonLat[startStr]; edgesExist {
-						outEdges = append(outEdges, *jsonEdge)
-						jsonLat[startStr] = outEdges
-					} else {
-						newList := make([]JSONEdge, 1, 2)
-						newList[0] = *jsonEdge
-						jsonLat[startStr] = newList
-					}
+				jsonLat = make(JSONLattice)
+			}
+			for _, edge := range row {
+				jsonEdge = &JSONEdge{
+					Next:    fmt.Sprint(edge.End),
+					Form:    edge.Word,
+					UPOSTag: edge.CPosTag,
+				}
+				if edge.Lemma != "_" {
+					jsonEdge.Lemma = edge.Lemma
+				}
+				if edge.FeatStr != "_" {
+					jsonEdge.Feats = edge.FeatStr
+				}
+				if edge.PosTag != "_" {
+					jsonEdge.XPOSTag = edge.PosTag
+				}
+				startStr := fmt.Sprint(edge.Start)
+				if outEdges, edgesExist := jsonLat[startStr]; edgesExist {
+					outEdges = append(outEdges, *jsonEdge)
+					jsonLat[startStr] = outEdges
+				} else {
+					newList := make([]JSONEdge, 1, 2)
+					newList[0] = *jsonEdge
+					jsonLat[startStr] = newList
 				}
 			}
 		}
-		if jsonLat != nil {
+	}
+	if jsonLat != nil {
+		jsonLats = append(jsonLats, jsonLat)
+	}
+	return jsonLats
+}
+
+func UDWriteJSON(writer io.Writer, lattices []Lattice) error {
+	for _, lattice := range lattices {
+		for _, jsonLat := range Lattice2JSON(lattice) {
 			marshalled, err := json.Marshal(jsonLat)
 			if err != nil {
 				panic(fmt.Sprintf("Failure marshalling %v", err))
@@ -1291,6 +1300,29 @@ func Lattice2Sentence(lattice Lattice, eWord, ePOS, eWPOS, eMorphFeat, eMHost, e
 	return sent
 }
 
+// FixedMorphemes returns the ids of the morphemes of each lattice of a
+// sentence converted from a lattice whose edges are fixed
+func FixedMorphemes(lattice Lattice, sent nlp.LatticeSentence) []map[int]bool {
+	fixed := make([]map[int]bool, len(sent))
+	for _, edges := range lattice {
+		for _, edge := range edges {
+			if !edge.Fixed || edge.Token < 1 || edge.Token > len(sent) {
+				continue
+			}
+			for _, morph := range sent[edge.Token-1].Morphemes {
+				if morph.From() == edge.Start && morph.To() == edge.End && morph.Form == edge.Word &&
+					morph.CPOS == edge.CPosTag && morph.FeatureStr == edge.FeatStr {
+					if fixed[edge.Token-1] == nil {
+						fixed[edge.Token-1] = make(map[int]bool)
+					}
+					fixed[edge.Token-1][morph.ID()] = true
+				}
+			}
+		}
+	}
+	return fixed
+}
+
 func Lattice2SentenceCorpus(corpus Lattices, eWord, ePOS, eWPOS, eMorphFeat, eMHost, eMSuffix *util.EnumSet) []interface{} {
 	graphCorpus := make([]interface{}, len(corpus))
 	prefix := log.Prefix()
@@ -1346,6 +1378,7 @@ func Sentence2Lattice(lattice nlp.LatticeSentence, xliter8or xliter8.Interface)
 				m.TokenID,
 				m.ID(),
 				string(sentlat.Token),
+				false,
 			}
 			if len(m.FeatureStr) == 0 {
 				e.FeatStr = "_"
@@ -3,6 +3,7 @@ package lattice
 import (
 	"strings"
 	"testing"
+	"yap/util"
 )
 
 func TestParseEdgeWithParams(t *testing.T) {
@@ -41,3 +42,39 @@ func TestParseEdgeWithRepeatingParams(t *testing.T) {
 		t.Error("Failure concatenating multiple features: should be F,M got " + parsed.Feats["suf_gen"])
 	}
 }
+
+func TestLattice2JSON(t *testing.T) {
+	lat, err := Read(strings.NewReader("0	1	H	_	DEF	DEF	_	1\n0	2	HBIT	_	NN	NN	gen=M|num=S	1\n1	2	BIT	_	NN	NN	gen=M|num=S	1\n2	3	GDWL	_	JJ	JJ	gen=M|num=S	2\n\n"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	jsonLats := Lattice2JSON(lat[0])
+	if len(jsonLats) != 2 {
+		t.Fatalf("Expected 2 token lattices, got %d", len(jsonLats))
+	}
+	if len(jsonLats[0]["0"]) != 2 || len(jsonLats[0]["1"]) != 1 {
+		t.Errorf("Wrong edges for first token: %v", jsonLats[0])
+	}
+	if edge := jsonLats[1]["2"][0]; edge.Next != "3" || edge.Form != "GDWL" || edge.Feats != "gen=M|num=S" {
+		t.Errorf("Wrong edge for second token: %v", edge)
+	}
+}
+
+func TestFixedMorphemes(t *testing.T) {
+	lat, err := Read(strings.NewReader("0	1	H	_	DEF	DEF	_	1	fixed\n0	2	HBIT	_	NN	NN	gen=M|num=S	1\n1	2	BIT	_	NN	NN	gen=M|num=S	1\n2	3	GDWL	_	JJ	JJ	gen=M|num=S	2\n\n"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	eWord, ePOS, eWPOS := util.NewEnumSet(10, "EWord"), util.NewEnumSet(10, "EPOS"), util.NewEnumSet(10, "EWPOS")
+	eMorphFeat, eMHost, eMSuffix := util.NewEnumSet(10, "EMorphFeat"), util.NewEnumSet(10, "EMHost"), util.NewEnumSet(10, "EMSuffix")
+	sent := Lattice2Sentence(lat[0], eWord, ePOS, eWPOS, eMorphFeat, eMHost, eMSuffix)
+	fixed := FixedMorphemes(lat[0], sent)
+	if len(fixed) != 2 || len(fixed[0]) != 1 || fixed[1] != nil {
+		t.Fatalf("Expected a fixed morpheme of the first token only, got %v", fixed)
+	}
+	for id := range fixed[0] {
+		if morph := sent[0].Morphemes[id]; morph.Form != "H" {
+			t.Errorf("Expected fixed morpheme H, got %v", morph.Form)
+		}
+	}
+}
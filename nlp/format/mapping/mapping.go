@@ -11,6 +11,11 @@ import (
 )
 
 func WriteMorph(writer io.Writer, morph *nlp.EMorpheme, curMorph, curToken int) {
+	writeMorph(writer, morph, curMorph, curToken)
+	writer.Write([]byte{'\n'})
+}
+
+func writeMorph(writer io.Writer, morph *nlp.EMorpheme, curMorph, curToken int) {
 	writer.Write([]byte(fmt.Sprintf("%d\t%d\t", curMorph, curMorph+1)))
 	writer.Write([]byte(morph.Form))
 	writer.Write([]byte{'\t'})
@@ -30,7 +35,7 @@ func WriteMorph(writer io.Writer, morph *nlp.EMorpheme, curMorph, curToken int)
 		writer.Write([]byte(morph.FeatureStr))
 	}
 	writer.Write([]byte{'\t'})
-	writer.Write([]byte(fmt.Sprintf("%d\n", curToken+1)))
+	writer.Write([]byte(fmt.Sprintf("%d", curToken+1)))
 }
 
 func Write(writer io.Writer, mappedSents []interface{}) {
@@ -61,6 +66,29 @@ func Write(writer io.Writer, mappedSents []interface{}) {
 	}
 }
 
+// WriteConfidence writes like Write, adding a column of the confidence of
+// each morpheme's spellout, given per sentence for each of its mappings
+func WriteConfidence(writer io.Writer, mappedSents []interface{}, confidences [][]float64) {
+	var curMorph int
+	for j, mappedSent := range mappedSents {
+		curMorph = 0
+		for i, mapping := range mappedSent.(*disambig.MDConfig).Mappings {
+			if mapping.Token == nlp.ROOT_TOKEN {
+				continue
+			}
+			for _, morph := range mapping.Spellout {
+				if morph == nil {
+					continue
+				}
+				writeMorph(writer, morph, curMorph, i)
+				writer.Write([]byte(fmt.Sprintf("\t%.4f\n", confidences[j][i])))
+				curMorph++
+			}
+		}
+		writer.Write([]byte{'\n'})
+	}
+}
+
 func WriteStream(writer *os.File, mappedSents chan interface{}) {
 	var curMorph int
 	var i int
@@ -102,6 +130,16 @@ func WriteFile(filename string, mappedSents []interface{}) error {
 	return nil
 }
 
+func WriteConfidenceFile(filename string, mappedSents []interface{}, confidences [][]float64) error {
+	file, err := os.Create(filename)
+	defer file.Close()
+	if err != nil {
+		return err
+	}
+	WriteConfidence(file, mappedSents, confidences)
+	return nil
+}
+
 func WriteStreamToFile(filename string, mappedSents chan interface{}) error {
 	file, err := os.Create(filename)
 	if err != nil {
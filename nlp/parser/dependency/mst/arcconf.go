@@ -0,0 +1,196 @@
+package mst
+
+import (
+	"fmt"
+	"yap/alg/transition"
+	nlp "yap/nlp/types"
+	"yap/util"
+)
+
+// ArcConfiguration is a candidate arc (head, modifier) of a sentence, and
+// for second order features the head's previous modifier on the same side
+// (sibling), presented as a configuration to the generic feature extractor.
+// The head of a root arc is -1, and an arc without a sibling has the head
+// as its sibling. Feature addresses are:
+//
+//	H	the head, Hi the i'th word from it
+//	M	the modifier, Mi the i'th word from it
+//	S	the sibling
+//	B	the words between the head and modifier (a generator)
+type ArcConfiguration struct {
+	Tokens                  []nlp.EnumTaggedToken
+	Head, Modifier, Sibling int
+}
+
+// value of the attributes of the root and missing siblings
+const (
+	ROOT_VALUE       = -1
+	NO_SIBLING_VALUE = -2
+)
+
+var _ transition.Configuration = &ArcConfiguration{}
+
+func (c *ArcConfiguration) Init(abstractSentence interface{}) {
+	c.Tokens = abstractSentence.(nlp.EnumTaggedSentence).EnumTaggedTokens()
+}
+
+func (c *ArcConfiguration) Terminal() bool {
+	return true
+}
+
+func (c *ArcConfiguration) Copy() transition.Configuration {
+	newConf := new(ArcConfiguration)
+	c.CopyTo(newConf)
+	return newConf
+}
+
+func (c *ArcConfiguration) CopyTo(target transition.Configuration) {
+	newConf, ok := target.(*ArcConfiguration)
+	if !ok {
+		panic("Can't copy into non *ArcConfiguration")
+	}
+	*newConf = *c
+}
+
+func (c *ArcConfiguration) Clear() {
+}
+
+func (c *ArcConfiguration) Len() int {
+	return len(c.Tokens)
+}
+
+func (c *ArcConfiguration) Previous() transition.Configuration {
+	return nil
+}
+
+func (c *ArcConfiguration) SetPrevious(prev transition.Configuration) {
+}
+
+func (c *ArcConfiguration) GetSequence() transition.ConfigurationSequence {
+	return transition.ConfigurationSequence{c}
+}
+
+func (c *ArcConfiguration) SetLastTransition(t transition.Transition) {
+}
+
+func (c *ArcConfiguration) GetLastTransition() transition.Transition {
+	return transition.ConstTransition(0)
+}
+
+func (c *ArcConfiguration) String() string {
+	return fmt.Sprintf("(%d,%d,%d)", c.Head, c.Sibling, c.Modifier)
+}
+
+func (c *ArcConfiguration) Equal(otherEq util.Equaler) bool {
+	other, ok := otherEq.(*ArcConfiguration)
+	return ok && c.Head == other.Head && c.Modifier == other.Modifier && c.Sibling == other.Sibling
+}
+
+func (c *ArcConfiguration) Assignment() uint16 {
+	return 0
+}
+
+func (c *ArcConfiguration) State() byte {
+	return 'A'
+}
+
+func (c *ArcConfiguration) Address(location []byte, offset int) (int, bool, bool) {
+	var nodeID int
+	switch location[0] {
+	case 'H':
+		if c.Head < 0 {
+			// only the root itself
+			return ROOT_VALUE, offset == 0, false
+		}
+		nodeID = c.Head + offset
+	case 'M':
+		nodeID = c.Modifier + offset
+	case 'S':
+		if c.Sibling == c.Head {
+			return NO_SIBLING_VALUE, offset == 0, false
+		}
+		nodeID = c.Sibling + offset
+	case 'B':
+		from, to := c.between()
+		return from, from < to, true
+	default:
+		return 0, false, false
+	}
+	return nodeID, nodeID >= 0 && nodeID < len(c.Tokens), false
+}
+
+// between returns the range of words between the head and the modifier
+func (c *ArcConfiguration) between() (int, int) {
+	if c.Head < 0 {
+		return 0, 0
+	}
+	if c.Head < c.Modifier {
+		return c.Head + 1, c.Modifier
+	}
+	return c.Modifier + 1, c.Head
+}
+
+func (c *ArcConfiguration) GenerateAddresses(nodeID int, location []byte) (nodeIDs []int) {
+	if location[0] != 'B' {
+		return
+	}
+	from, to := c.between()
+	return util.RangeInt(to)[from:]
+}
+
+func (c *ArcConfiguration) Attribute(source byte, nodeID int, attribute []byte, transitions []int) (att interface{}, exists bool, isGen bool) {
+	exists = true
+	if attribute[0] == 'd' {
+		att = c.Distance()
+		return
+	}
+	if nodeID < 0 {
+		// root or missing sibling
+		att = nodeID
+		return
+	}
+	if nodeID >= len(c.Tokens) {
+		return 0, false, false
+	}
+	token := c.Tokens[nodeID]
+	switch attribute[0] {
+	case 'w':
+		if len(attribute) > 1 && attribute[1] == 'p' {
+			att = token.ETPOS
+		} else {
+			att = token.EToken
+		}
+	case 'p':
+		att = token.EPOS
+	case 'h':
+		att = token.EMHost
+	case 'x':
+		att = token.EMSuffix
+	default:
+		return 0, false, false
+	}
+	return
+}
+
+// Distance is the direction and distance of the arc, "normalized" as in
+// the transition configurations (6-10 is 5, over 10 is 6), negative for
+// modifiers left of their head and 0 for root arcs
+func (c *ArcConfiguration) Distance() int {
+	if c.Head < 0 {
+		return 0
+	}
+	dist := c.Modifier - c.Head
+	sign := 1
+	if dist < 0 {
+		dist, sign = -dist, -1
+	}
+	// 0 1 2 3 4 5 ... 10 ...
+	// 0 1 2 3 4 ---5--  --- 6 ---
+	switch {
+	case dist > 10:
+		dist = 6
+	case dist > 5:
+		dist = 5
+	}
+	return sign * dist
+}
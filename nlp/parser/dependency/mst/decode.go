@@ -0,0 +1,330 @@
+package mst
+
+import (
+	"math"
+)
+
+// The decoders find the maximum spanning tree of a sentence of n words
+// given the scores of its arcs as an (n+1)x(n+1) matrix indexed by
+// [head][modifier], where node 0 is the root and node i the i'th word.
+// They return the head of every node, -1 for the root node, allowing the
+// root any number of modifiers. Arcs that are not allowed are scored
+// math.Inf(-1)
+
+var negInf = math.Inf(-1)
+
+const (
+	LEFT  = 0 // modifier left of its head
+	RIGHT = 1 // modifier right of its head
+)
+
+// Eisner is the first order projective decoder (Eisner 1996)
+func Eisner(scores [][]float64) []int {
+	n := len(scores) - 1
+	// complete and incomplete spans [s][t][direction], and their split
+	var (
+		complete, incomplete           = spans3(n + 1), spans3(n + 1)
+		completeSplit, incompleteSplit = splits3(n + 1), splits3(n + 1)
+	)
+	for k := 1; k <= n; k++ {
+		for s := 0; s+k <= n; s++ {
+			t := s + k
+			best, bestSplit := negInf, -1
+			for r := s; r < t; r++ {
+				if score := complete[s][r][RIGHT] + complete[r+1][t][LEFT]; score > best {
+					best, bestSplit = score, r
+				}
+			}
+			incomplete[s][t][LEFT] = best + scores[t][s]
+			incomplete[s][t][RIGHT] = best + scores[s][t]
+			incompleteSplit[s][t][LEFT], incompleteSplit[s][t][RIGHT] = bestSplit, bestSplit
+
+			best, bestSplit = negInf, -1
+			for r := s; r < t; r++ {
+				if score := complete[s][r][LEFT] + incomplete[r][t][LEFT]; score > best {
+					best, bestSplit = score, r
+				}
+			}
+			complete[s][t][LEFT], completeSplit[s][t][LEFT] = best, bestSplit
+
+			best, bestSplit = negInf, -1
+			for r := s + 1; r <= t; r++ {
+				if score := incomplete[s][r][RIGHT] + complete[r][t][RIGHT]; score > best {
+					best, bestSplit = score, r
+				}
+			}
+			complete[s][t][RIGHT], completeSplit[s][t][RIGHT] = best, bestSplit
+		}
+	}
+	heads := make([]int, n+1)
+	heads[0] = -1
+	var backtrackComplete, backtrackIncomplete func(s, t, d int)
+	backtrackComplete = func(s, t, d int) {
+		if s == t {
+			return
+		}
+		r := completeSplit[s][t][d]
+		if d == LEFT {
+			backtrackComplete(s, r, LEFT)
+			backtrackIncomplete(r, t, LEFT)
+		} else {
+			backtrackIncomplete(s, r, RIGHT)
+			backtrackComplete(r, t, RIGHT)
+		}
+	}
+	backtrackIncomplete = func(s, t, d int) {
+		if d == LEFT {
+			heads[s] = t
+		} else {
+			heads[t] = s
+		}
+		r := incompleteSplit[s][t][d]
+		backtrackComplete(s, r, RIGHT)
+		backtrackComplete(r+1, t, LEFT)
+	}
+	backtrackComplete(0, n, RIGHT)
+	return heads
+}
+
+// A SiblingScorer scores the head's modifier given the previous modifier
+// on its side (between the two), or the head itself if there is none
+type SiblingScorer func(head, sibling, modifier int) float64
+
+// Eisner2 is the second order (sibling) projective decoder (McDonald &
+// Pereira 2006), adding the sibling scores to the arc scores
+func Eisner2(scores [][]float64, siblings SiblingScorer) []int {
+	n := len(scores) - 1
+	// complete and incomplete spans as in Eisner, and sibling spans [s][t]
+	// of adjacent modifiers of the same head; an incomplete span's split is
+	// the previous modifier, -1 if there is none
+	var (
+		complete, incomplete           = spans3(n + 1), spans3(n + 1)
+		completeSplit, incompleteSplit = splits3(n + 1), splits3(n + 1)
+		sibling                        = make([][]float64, n+1)
+		siblingSplit                   = make([][]int, n+1)
+	)
+	for s := range sibling {
+		sibling[s], siblingSplit[s] = make([]float64, n+1), make([]int, n+1)
+	}
+	for k := 1; k <= n; k++ {
+		for s := 0; s+k <= n; s++ {
+			t := s + k
+			// the root is not a modifier, nor a sibling
+			if s > 0 {
+				best, bestSplit := negInf, -1
+				for r := s; r < t; r++ {
+					if score := complete[s][r][RIGHT] + complete[r+1][t][LEFT]; score > best {
+						best, bestSplit = score, r
+					}
+				}
+				sibling[s][t], siblingSplit[s][t] = best, bestSplit
+
+				best, bestSplit = complete[s][t-1][RIGHT]+siblings(t, t, s), -1
+				for r := s + 1; r < t; r++ {
+					if score := sibling[s][r] + incomplete[r][t][LEFT] + siblings(t, r, s); score > best {
+						best, bestSplit = score, r
+					}
+				}
+				incomplete[s][t][LEFT], incompleteSplit[s][t][LEFT] = best+scores[t][s], bestSplit
+			} else {
+				incomplete[s][t][LEFT] = negInf
+			}
+
+			best, bestSplit := complete[s+1][t][LEFT]+siblings(s, s, t), -1
+			for r := s + 1; r < t; r++ {
+				if score := incomplete[s][r][RIGHT] + sibling[r][t] + siblings(s, r, t); score > best {
+					best, bestSplit = score, r
+				}
+			}
+			incomplete[s][t][RIGHT], incompleteSplit[s][t][RIGHT] = best+scores[s][t], bestSplit
+
+			best, bestSplit = negInf, -1
+			for r := s; r < t; r++ {
+				if score := complete[s][r][LEFT] + incomplete[r][t][LEFT]; score > best {
+					best, bestSplit = score, r
+				}
+			}
+			complete[s][t][LEFT], completeSplit[s][t][LEFT] = best, bestSplit
+
+			best, bestSplit = negInf, -1
+			for r := s + 1; r <= t; r++ {
+				if score := incomplete[s][r][RIGHT] + complete[r][t][RIGHT]; score > best {
+					best, bestSplit = score, r
+				}
+			}
+			complete[s][t][RIGHT], completeSplit[s][t][RIGHT] = best, bestSplit
+		}
+	}
+	heads := make([]int, n+1)
+	heads[0] = -1
+	var (
+		backtrackComplete, backtrackIncomplete func(s, t, d int)
+		backtrackSibling                       func(s, t int)
+	)
+	backtrackComplete = func(s, t, d int) {
+		if s == t {
+			return
+		}
+		r := completeSplit[s][t][d]
+		if d == LEFT {
+			backtrackComplete(s, r, LEFT)
+			backtrackIncomplete(r, t, LEFT)
+		} else {
+			backtrackIncomplete(s, r, RIGHT)
+			backtrackComplete(r, t, RIGHT)
+		}
+	}
+	backtrackIncomplete = func(s, t, d int) {
+		r := incompleteSplit[s][t][d]
+		if d == LEFT {
+			heads[s] = t
+			if r < 0 {
+				backtrackComplete(s, t-1, RIGHT)
+			} else {
+				backtrackSibling(s, r)
+				backtrackIncomplete(r, t, LEFT)
+			}
+		} else {
+			heads[t] = s
+			if r < 0 {
+				backtrackComplete(s+1, t, LEFT)
+			} else {
+				backtrackIncomplete(s, r, RIGHT)
+				backtrackSibling(r, t)
+			}
+		}
+	}
+	backtrackSibling = func(s, t int) {
+		r := siblingSplit[s][t]
+		backtrackComplete(s, r, RIGHT)
+		backtrackComplete(r+1, t, LEFT)
+	}
+	backtrackComplete(0, n, RIGHT)
+	return heads
+}
+
+// ChuLiuEdmonds is the first order non-projective decoder (Chu & Liu 1965,
+// Edmonds 1967), contracting the cycles of the best incoming arcs
+func ChuLiuEdmonds(scores [][]float64) []int {
+	n := len(scores)
+	heads := make([]int, n)
+	heads[0] = -1
+	for m := 1; m < n; m++ {
+		heads[m] = 0
+		for h := range scores {
+			if h != m && scores[h][m] > scores[heads[m]][m] {
+				heads[m] = h
+			}
+		}
+	}
+	cycle := findCycle(heads)
+	if cycle == nil {
+		return heads
+	}
+	// contract the cycle into a single node c, the last of the contracted
+	// graph, keeping for each arc entering (leaving) it the cycle node it
+	// enters (leaves)
+	var (
+		inCycle    = make([]bool, n)
+		contracted = make([]int, 0, n) // contracted node -> node
+		cycleScore float64
+	)
+	for _, node := range cycle {
+		inCycle[node] = true
+		cycleScore += scores[heads[node]][node]
+	}
+	for node := 0; node < n; node++ {
+		if !inCycle[node] {
+			contracted = append(contracted, node)
+		}
+	}
+	c := len(contracted)
+	var (
+		cScores = make([][]float64, c+1)
+		enters  = make([]int, c) // contracted head -> cycle node its arc enters
+		leaves  = make([]int, c) // contracted modifier -> cycle node its arc leaves
+	)
+	for i := range cScores {
+		cScores[i] = make([]float64, c+1)
+	}
+	for i, h := range contracted {
+		for j, m := range contracted {
+			cScores[i][j] = scores[h][m]
+		}
+		cScores[i][c], cScores[c][i] = negInf, negInf
+		for _, node := range cycle {
+			// breaking the cycle at node
+			if score := cycleScore - scores[heads[node]][node] + scores[h][node]; score > cScores[i][c] {
+				cScores[i][c], enters[i] = score, node
+			}
+			if score := scores[node][h]; score > cScores[c][i] {
+				cScores[c][i], leaves[i] = score, node
+			}
+		}
+	}
+	cScores[c][c] = negInf
+	cHeads := ChuLiuEdmonds(cScores)
+	// expand the cycle
+	for j := 1; j < c; j++ {
+		m := contracted[j]
+		if h := cHeads[j]; h == c {
+			heads[m] = leaves[j]
+		} else {
+			heads[m] = contracted[h]
+		}
+	}
+	entering := cHeads[c]
+	heads[enters[entering]] = contracted[entering]
+	return heads
+}
+
+// findCycle returns the nodes of a cycle of the heads, or nil if none
+func findCycle(heads []int) []int {
+	// visited[node] is the node from which its path of heads was followed
+	visited := make([]int, len(heads))
+	for i := range visited {
+		visited[i] = -1
+	}
+	for start := range heads {
+		node := start
+		for node >= 0 && visited[node] < 0 {
+			visited[node] = start
+			node = heads[node]
+		}
+		if node >= 0 && visited[node] == start {
+			cycle := []int{node}
+			for next := heads[node]; next != node; next = heads[next] {
+				cycle = append(cycle, next)
+			}
+			return cycle
+		}
+	}
+	return nil
+}
+
+// TreeScore sums the scores of the arcs of the heads
+func TreeScore(scores [][]float64, heads []int) float64 {
+	var score float64
+	for m, h := range heads {
+		if h >= 0 {
+			score += scores[h][m]
+		}
+	}
+	return score
+}
+
+func spans3(n int) [][][2]float64 {
+	spans := make([][][2]float64, n)
+	for i := range spans {
+		spans[i] = make([][2]float64, n)
+	}
+	return spans
+}
+
+func splits3(n int) [][][2]int {
+	splits := make([][][2]int, n)
+	for i := range splits {
+		splits[i] = make([][2]int, n)
+	}
+	return splits
+}
@@ -0,0 +1,141 @@
+package mst
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func randomScores(r *rand.Rand, n int) [][]float64 {
+	scores := make([][]float64, n+1)
+	for h := range scores {
+		scores[h] = make([]float64, n+1)
+		for m := range scores[h] {
+			if m == 0 || m == h {
+				scores[h][m] = negInf
+			} else {
+				scores[h][m] = math.Floor(r.Float64()*200 - 100)
+			}
+		}
+	}
+	return scores
+}
+
+func isTree(heads []int) bool {
+	if heads[0] != -1 {
+		return false
+	}
+	for m := range heads[1:] {
+		node := m + 1
+		for steps := 0; node != 0; steps++ {
+			if steps > len(heads) || heads[node] < 0 {
+				return false
+			}
+			node = heads[node]
+		}
+	}
+	return true
+}
+
+func isProjective(heads []int) bool {
+	for m, h := range heads {
+		if h < 0 {
+			continue
+		}
+		from, to := h, m
+		if from > to {
+			from, to = to, from
+		}
+		for k := from + 1; k < to; k++ {
+			// every word under the arc is dominated by its head
+			node := k
+			for node != h && node != 0 {
+				node = heads[node]
+			}
+			if node != h {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// siblingOf is the modifier of the head between it and the modifier,
+// closest to the modifier, or the head if there is none
+func siblingOf(heads []int, m int) int {
+	h := heads[m]
+	sibling := h
+	for k := range heads {
+		if k != m && heads[k] == h && (h < k && k < m || m < k && k < h) {
+			if sibling == h || (h < m && k > sibling) || (m < h && k < sibling) {
+				sibling = k
+			}
+		}
+	}
+	return sibling
+}
+
+// bestTree finds the best (projective) tree by enumerating all heads
+func bestTree(n int, projective bool, score func([]int) float64) float64 {
+	heads := make([]int, n+1)
+	heads[0] = -1
+	best := negInf
+	var enumerate func(m int)
+	enumerate = func(m int) {
+		if m > n {
+			if isTree(heads) && (!projective || isProjective(heads)) {
+				best = math.Max(best, score(heads))
+			}
+			return
+		}
+		for h := 0; h <= n; h++ {
+			if h != m {
+				heads[m] = h
+				enumerate(m + 1)
+			}
+		}
+	}
+	enumerate(1)
+	return best
+}
+
+func TestDecoders(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 200; trial++ {
+		n := 1 + trial%5
+		scores := randomScores(r, n)
+		firstOrder := func(heads []int) float64 {
+			return TreeScore(scores, heads)
+		}
+		nonProjective := bestTree(n, false, firstOrder)
+		projective := bestTree(n, true, firstOrder)
+		if heads := ChuLiuEdmonds(scores); !isTree(heads) || TreeScore(scores, heads) != nonProjective {
+			t.Fatalf("Chu-Liu/Edmonds got %v scoring %v, expected %v for %v", heads, TreeScore(scores, heads), nonProjective, scores)
+		}
+		if heads := Eisner(scores); !isTree(heads) || !isProjective(heads) || TreeScore(scores, heads) != projective {
+			t.Fatalf("Eisner got %v scoring %v, expected %v for %v", heads, TreeScore(scores, heads), projective, scores)
+		}
+
+		siblingScores := make(map[[3]int]float64)
+		siblings := func(h, s, m int) float64 {
+			key := [3]int{h, s, m}
+			if _, exists := siblingScores[key]; !exists {
+				siblingScores[key] = math.Floor(r.Float64()*100 - 50)
+			}
+			return siblingScores[key]
+		}
+		secondOrder := func(heads []int) float64 {
+			score := TreeScore(scores, heads)
+			for m, h := range heads {
+				if h >= 0 {
+					score += siblings(h, siblingOf(heads, m), m)
+				}
+			}
+			return score
+		}
+		heads := Eisner2(scores, siblings)
+		if expected := bestTree(n, true, secondOrder); !isTree(heads) || !isProjective(heads) || secondOrder(heads) != expected {
+			t.Fatalf("Eisner2 got %v scoring %v, expected %v", heads, secondOrder(heads), expected)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package mst
+
+import (
+	"fmt"
+	. "yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/search"
+	"yap/alg/transition"
+	"yap/alg/transition/model"
+	dep "yap/nlp/parser/dependency/transition"
+	nlp "yap/nlp/types"
+	"yap/util"
+)
+
+// Feature group (transition) types of arc and sibling features
+const (
+	ARC_FEATURES     byte = 'A'
+	SIBLING_FEATURES byte = 'S'
+)
+
+// Parser is a graph-based dependency parser (McDonald et al. 2005), finding
+// the maximum spanning tree of labeled arcs scored by a linear model, and
+// with second order features, of arcs and their siblings (McDonald &
+// Pereira 2006). An arc's score is that of its best label, root arcs being
+// labeled ROOT. The labels are the model's classes of arc features, and
+// the class after them that of sibling features.
+// As a perceptron decoder its instances are sentences decoded to a *Tree
+type Parser struct {
+	Model         *model.AvgMatrixSparse
+	FeatExtractor perceptron.FeatureExtractor
+	Base          *dep.SimpleConfiguration
+	Relations     *util.EnumSet
+	Projective    bool // decode with Eisner instead of Chu-Liu/Edmonds
+	SecondOrder   bool // projective only
+}
+
+var _ perceptron.InstanceDecoder = &Parser{}
+var _ perceptron.EarlyUpdateInstanceDecoder = &Parser{}
+
+// Tree is a dependency tree as the heads and labels (relation indices) of
+// its words, the head of a root being -1
+type Tree struct {
+	Heads, Labels []int
+}
+
+var _ util.Equaler = &Tree{}
+
+func (t *Tree) Equal(otherEq util.Equaler) bool {
+	other, ok := otherEq.(*Tree)
+	if !ok || len(t.Heads) != len(other.Heads) {
+		return false
+	}
+	for i, head := range t.Heads {
+		if head != other.Heads[i] || t.Labels[i] != other.Labels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Sibling returns the modifier of the head of m between the two closest to
+// m, or the head if there is none
+func (t *Tree) Sibling(m int) int {
+	head := t.Heads[m]
+	sibling := head
+	if head < m {
+		for k := m - 1; k > head && sibling == head; k-- {
+			if t.Heads[k] == head {
+				sibling = k
+			}
+		}
+	} else {
+		for k := m + 1; k < head && sibling == head; k++ {
+			if t.Heads[k] == head {
+				sibling = k
+			}
+		}
+	}
+	return sibling
+}
+
+func (t *Tree) String() string {
+	return fmt.Sprintf("%v %v", t.Heads, t.Labels)
+}
+
+// GraphTree returns the tree of a labeled dependency graph, where the
+// modifiers of arcs labeled ROOT are roots
+func GraphTree(graph nlp.LabeledDependencyGraph, relations *util.EnumSet) *Tree {
+	numNodes := graph.NumberOfNodes()
+	tree := &Tree{Heads: make([]int, numNodes), Labels: make([]int, numNodes)}
+	for i := range tree.Heads {
+		tree.Heads[i] = -1
+	}
+	for _, arcID := range graph.GetEdges() {
+		arc := graph.GetLabeledArc(arcID)
+		if arc == nil {
+			continue
+		}
+		label, exists := relations.IndexOf(arc.GetRelation())
+		if !exists {
+			panic(fmt.Sprintf("Unknown relation %v", arc.GetRelation()))
+		}
+		modifier := arc.GetModifier()
+		tree.Heads[modifier], tree.Labels[modifier] = arc.GetHead(), label
+		if arc.GetRelation() == nlp.DepRel(nlp.ROOT_LABEL) {
+			tree.Heads[modifier] = -1
+		}
+	}
+	return tree
+}
+
+func (p *Parser) arcFeatures(tokens []nlp.EnumTaggedToken, head, modifier int) []Feature {
+	conf := &ArcConfiguration{Tokens: tokens, Head: head, Modifier: modifier, Sibling: head}
+	return p.FeatExtractor.Features(conf, false, ARC_FEATURES, nil)
+}
+
+func (p *Parser) siblingFeatures(tokens []nlp.EnumTaggedToken, head, sibling, modifier int) []Feature {
+	conf := &ArcConfiguration{Tokens: tokens, Head: head, Modifier: modifier, Sibling: sibling}
+	return p.FeatExtractor.Features(conf, false, SIBLING_FEATURES, nil)
+}
+
+func (p *Parser) siblingClass() transition.ConstTransition {
+	return transition.ConstTransition(p.Relations.Len())
+}
+
+// scores returns the score matrix of the arcs of a sentence, as the
+// decoders expect it, and their best labels
+func (p *Parser) scores(tokens []nlp.EnumTaggedToken, weights *model.AvgMatrixSparse) ([][]float64, [][]int) {
+	var (
+		n         = len(tokens)
+		scores    = make([][]float64, n+1)
+		labels    = make([][]int, n+1)
+		store     = &ArrayStore{}
+		rootLabel = p.rootLabel()
+	)
+	store.Init()
+	store.SetTransitions(util.RangeInt(p.Relations.Len()))
+	for h := range scores {
+		scores[h], labels[h] = make([]float64, n+1), make([]int, n+1)
+		for m := range scores[h] {
+			scores[h][m] = negInf
+			if m == 0 || m == h {
+				continue
+			}
+			store.Clear()
+			weights.SetTransitionScores(p.arcFeatures(tokens, h-1, m-1), store, false)
+			if h == 0 {
+				score, _ := store.Get(rootLabel)
+				scores[h][m], labels[h][m] = float64(score), rootLabel
+				continue
+			}
+			for label := 0; label < store.Len(); label++ {
+				if score, _ := store.Get(label); label != rootLabel && float64(score) > scores[h][m] {
+					scores[h][m], labels[h][m] = float64(score), label
+				}
+			}
+		}
+	}
+	return scores, labels
+}
+
+func (p *Parser) siblingScore(tokens []nlp.EnumTaggedToken, weights *model.AvgMatrixSparse, head, sibling, modifier int) float64 {
+	return float64(weights.TransitionScore(p.siblingClass(), p.siblingFeatures(tokens, head, sibling, modifier)))
+}
+
+func (p *Parser) rootLabel() int {
+	rootLabel, exists := p.Relations.IndexOf(nlp.DepRel(nlp.ROOT_LABEL))
+	if !exists {
+		panic("Relations do not include " + nlp.ROOT_LABEL)
+	}
+	return rootLabel
+}
+
+// decode returns the best tree of a sentence and its score
+func (p *Parser) decode(tokens []nlp.EnumTaggedToken, weights *model.AvgMatrixSparse) (*Tree, float64) {
+	scores, labels := p.scores(tokens, weights)
+	var heads []int
+	switch {
+	case p.SecondOrder:
+		if !p.Projective {
+			panic("Second order decoding is projective only")
+		}
+		heads = Eisner2(scores, func(head, sibling, modifier int) float64 {
+			return p.siblingScore(tokens, weights, head-1, sibling-1, modifier-1)
+		})
+	case p.Projective:
+		heads = Eisner(scores)
+	default:
+		heads = ChuLiuEdmonds(scores)
+	}
+	tree := &Tree{Heads: make([]int, len(tokens)), Labels: make([]int, len(tokens))}
+	for i := range tree.Heads {
+		tree.Heads[i], tree.Labels[i] = heads[i+1]-1, labels[heads[i+1]][i+1]
+	}
+	score := TreeScore(scores, heads)
+	if p.SecondOrder {
+		for modifier, head := range tree.Heads {
+			score += p.siblingScore(tokens, weights, head, tree.Sibling(modifier), modifier)
+		}
+	}
+	return tree, score
+}
+
+// Configuration returns the tree of a sentence as a parsed configuration,
+// whose root arcs are headed by the first word as those of the arc eager
+// system
+func (p *Parser) Configuration(sent nlp.EnumTaggedSentence, tree *Tree) *dep.SimpleConfiguration {
+	conf := p.Base.Copy().(*dep.SimpleConfiguration)
+	conf.Clear()
+	conf.Init(sent)
+	for modifier, head := range tree.Heads {
+		label := tree.Labels[modifier]
+		if head < 0 {
+			head = 0
+		}
+		conf.AddArc(&dep.BasicDepArc{Head: head, Relation: label, Modifier: modifier, RawRelation: p.Relations.ValueOf(label).(nlp.DepRel)})
+	}
+	return conf
+}
+
+func (p *Parser) Parse(problem search.Problem) (transition.Configuration, interface{}) {
+	sent := problem.(nlp.EnumTaggedSentence)
+	tree, _ := p.decode(sent.EnumTaggedTokens(), p.Model)
+	return p.Configuration(sent, tree), nil
+}
+
+func (p *Parser) Decode(instance perceptron.Instance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
+	tree, _ := p.decode(instance.(nlp.EnumTaggedSentence).EnumTaggedTokens(), m.(*model.AvgMatrixSparse))
+	return &perceptron.Decoded{InstanceVal: instance, DecodedVal: tree}, nil
+}
+
+// DecodeGold converts the gold graph of an instance to a tree
+func (p *Parser) DecodeGold(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
+	graph, ok := goldInstance.Decoded().(nlp.LabeledDependencyGraph)
+	if !ok {
+		panic("Gold is not a labeled dependency graph")
+	}
+	return &perceptron.Decoded{InstanceVal: goldInstance.Instance(), DecodedVal: GraphTree(graph, p.Relations)}, nil
+}
+
+// DecodeEarlyUpdate decodes the best tree, returning the features of its
+// parts (labeled arcs and sibling arcs) differing from those of the gold
+// tree and of the corresponding gold parts. A tree has a part of each kind
+// per word, so the two have the same number of parts, as the model's
+// updates expect
+func (p *Parser) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}, interface{}, int, int, float64) {
+	var (
+		tokens                       = goldInstance.Instance().(nlp.EnumTaggedSentence).EnumTaggedTokens()
+		gold                         = goldInstance.Decoded().(*Tree)
+		parsed, score                = p.decode(tokens, m.(*model.AvgMatrixSparse))
+		parsedFeatures, goldFeatures [][]Feature
+		parsedClasses, goldClasses   []transition.Transition
+	)
+	for modifier := range tokens {
+		parsedHead, goldHead := parsed.Heads[modifier], gold.Heads[modifier]
+		parsedLabel, goldLabel := parsed.Labels[modifier], gold.Labels[modifier]
+		if parsedHead != goldHead || parsedLabel != goldLabel {
+			parsedFeatures = append(parsedFeatures, p.arcFeatures(tokens, parsedHead, modifier))
+			parsedClasses = append(parsedClasses, transition.ConstTransition(parsedLabel))
+			goldFeatures = append(goldFeatures, p.arcFeatures(tokens, goldHead, modifier))
+			goldClasses = append(goldClasses, transition.ConstTransition(goldLabel))
+		}
+		if !p.SecondOrder {
+			continue
+		}
+		parsedSibling, goldSibling := parsed.Sibling(modifier), gold.Sibling(modifier)
+		if parsedHead != goldHead || parsedSibling != goldSibling {
+			parsedFeatures = append(parsedFeatures, p.siblingFeatures(tokens, parsedHead, parsedSibling, modifier))
+			parsedClasses = append(parsedClasses, p.siblingClass())
+			goldFeatures = append(goldFeatures, p.siblingFeatures(tokens, goldHead, goldSibling, modifier))
+			goldClasses = append(goldClasses, p.siblingClass())
+		}
+	}
+	decoded := &perceptron.Decoded{InstanceVal: goldInstance.Instance(), DecodedVal: parsed}
+	return decoded, featuresChain(parsedFeatures, parsedClasses), featuresChain(goldFeatures, goldClasses), -1, len(tokens), score
+}
+
+// featuresChain links the features of parts with their classes as the
+// model's updates expect: each link's class goes with the features of the
+// link preceding it
+func featuresChain(features [][]Feature, classes []transition.Transition) *transition.FeaturesList {
+	chain := &transition.FeaturesList{Transition: transition.ConstTransition(0)}
+	for i, class := range classes {
+		chain.Features = features[i]
+		chain = &transition.FeaturesList{Transition: class, Previous: chain}
+	}
+	return chain
+}
@@ -418,3 +418,165 @@ func (o *ZparArcEagerOracle) Transition(conf Configuration) Transition {
 func (o *ZparArcEagerOracle) Name() string {
 	return "Zpar Arc Eager Oracle (zpar acl '11) [a.k.a. ArcZEager]"
 }
+
+func (a *ArcEager) AddDynamicOracle() {
+	a.oracle = Oracle(a.dynamicOracle())
+}
+
+func (a *ArcEager) dynamicOracle() *ArcEagerDynamicOracle {
+	return &ArcEagerDynamicOracle{
+		ZparArcEagerOracle: ZparArcEagerOracle{Transitions: a.Transitions, LA: a.LEFT, RA: a.RIGHT},
+		REDUCE:             a.REDUCE,
+		SHIFT:              a.SHIFT,
+		POPROOT:            a.POPROOT,
+	}
+}
+
+func (a *ArcEager) PartialOracle(heads []int, rels []DepRel) DynamicOracle {
+	oracle := a.dynamicOracle()
+	oracle.heads, oracle.rels = heads, rels
+	return oracle
+}
+
+// ArcEagerDynamicOracle is the dynamic oracle of arc eager (Goldberg &
+// Nivre 2012), adapted to the zpar variant in which the root is the word
+// left at the bottom of the stack (see POPROOT). Its transitions are those
+// of the static oracle
+type ArcEagerDynamicOracle struct {
+	ZparArcEagerOracle
+	REDUCE, SHIFT, POPROOT int
+	heads                  []int
+	rels                   []DepRel
+}
+
+var _ DynamicOracle = &ArcEagerDynamicOracle{}
+
+// SetGold accepts any labeled dependency graph, e.g. the terminal
+// configuration of a gold sequence, whose arcs are not indexed by modifier
+func (o *ArcEagerDynamicOracle) SetGold(g interface{}) {
+	var gold *BasicDepGraph
+	gold, o.heads, o.rels = goldHeads(g)
+	o.ZparArcEagerOracle.SetGold(gold)
+}
+
+// goldHeads indexes the arcs of a labeled dependency graph by modifier,
+// returning the indexed graph and its heads and labels; the modifiers of
+// arcs labeled ROOT are roots
+func goldHeads(g interface{}) (*BasicDepGraph, []int, []DepRel) {
+	graph, ok := g.(LabeledDependencyGraph)
+	if !ok {
+		panic("Gold is not a labeled dependency graph")
+	}
+	numNodes := graph.NumberOfNodes()
+	gold := &BasicDepGraph{Nodes: make([]DepNode, numNodes), Arcs: make([]*BasicDepArc, numNodes)}
+	heads, rels := make([]int, numNodes), make([]DepRel, numNodes)
+	for i := 0; i < numNodes; i++ {
+		gold.Nodes[i] = graph.GetNode(i)
+		heads[i] = -1
+	}
+	for _, arcID := range graph.GetEdges() {
+		arc := graph.GetLabeledArc(arcID)
+		if arc == nil {
+			continue
+		}
+		head, modifier := arc.GetHead(), arc.GetModifier()
+		if arc.GetRelation() == DepRel(ROOT_LABEL) {
+			head = -1
+		}
+		heads[modifier], rels[modifier] = head, arc.GetRelation()
+		gold.Arcs[modifier] = &BasicDepArc{Head: head, Modifier: modifier, RawRelation: arc.GetRelation()}
+	}
+	return gold, heads, rels
+}
+
+func (o *ArcEagerDynamicOracle) Cost(conf Configuration, t Transition) int {
+	c := conf.(*SimpleConfiguration)
+	if o.heads == nil {
+		panic("Oracle needs gold reference, use SetGold")
+	}
+	// Given gold heads h and labels l, c = (S|s, b|B, A), where any arc
+	// labeled ROOT makes its modifier the root (as does PR), the arcs lost
+	// by each transition are:
+	// LA-r	(b,s) if r != l(s); (h(s),s) if h(s) in B; (s,k) for k in b|B
+	// RA-r	(s,b) if r != l(b); (h(b),b) if h(b) in S or B; (b,k) for
+	// 		headless k in S
+	// RE	(s,k) for k in b|B
+	// SH	(h(b),b) if h(b) in S; (b,k) for headless k in S
+	// PR	none
+	numNodes := len(o.heads)
+	b, bExists := c.Queue().Peek()
+	if !bExists {
+		b = numNodes
+	}
+	s, sExists := c.Stack().Peek()
+	inStack := make([]bool, numNodes)
+	for i := 0; i < c.Stack().Size(); i++ {
+		k, _ := c.Stack().Index(i)
+		inStack[k] = true
+	}
+	// modifiers of head with a gold head in b|B
+	bufferModifiers := func(head int) int {
+		var cost int
+		for k := b; k < numNodes; k++ {
+			if o.heads[k] == head {
+				cost++
+			}
+		}
+		return cost
+	}
+	// headless modifiers of head in S
+	stackModifiers := func(head int) int {
+		var cost int
+		for k, exists := range inStack {
+			if exists && o.heads[k] == head && !c.Arcs().HasHead(k) {
+				cost++
+			}
+		}
+		return cost
+	}
+	// the cost of the arc (head,r,modifier) to the modifier's gold arc,
+	// if it is still reachable
+	attachCost := func(head, modifier int, rel string, reachable bool) int {
+		switch goldHead := o.heads[modifier]; {
+		case goldHead == UNKNOWN_HEAD:
+		case goldHead < 0:
+			if rel != ROOT_LABEL {
+				return 1
+			}
+		case goldHead == head:
+			if len(o.rels[modifier]) > 0 && string(o.rels[modifier]) != rel {
+				return 1
+			}
+		case reachable:
+			return 1
+		}
+		return 0
+	}
+	var cost int
+	value := t.Value()
+	switch {
+	case value == o.POPROOT:
+	case value == o.REDUCE:
+		if sExists {
+			cost += bufferModifiers(s)
+		}
+	case value == o.SHIFT:
+		if head := o.heads[b]; head >= 0 && inStack[head] {
+			cost++
+		}
+		cost += stackModifiers(b)
+	case value >= o.LA && value < o.RA:
+		rel := o.Transitions.ValueOf(value).(string)[3:]
+		cost += attachCost(b, s, rel, o.heads[s] > b)
+		cost += bufferModifiers(s)
+	case value >= o.RA:
+		rel := o.Transitions.ValueOf(value).(string)[3:]
+		cost += attachCost(s, b, rel, o.heads[b] > b || (o.heads[b] >= 0 && inStack[o.heads[b]]))
+		cost += stackModifiers(b)
+	}
+	return cost
+}
+
+func (o *ArcEagerDynamicOracle) Name() string {
+	return "Arc Eager Dynamic Oracle (Goldberg & Nivre 2012)"
+}
@@ -4,8 +4,9 @@ import (
 	. "yap/alg/transition"
 	nlp "yap/nlp/types"
 	"yap/util"
+
+	"testing"
 	// "reflect"
-	// "testing"
 )
 
 var (
@@ -32,26 +33,106 @@ var (
 )
 
 func SetupEagerTransEnum() {
-	TRANSITIONS_ENUM = util.NewEnumSet(len(TEST_RELATIONS)*2 + 2)
+	TRANSITIONS_ENUM = util.NewEnumSet(len(TEST_RELATIONS)*2+2, "ETrans")
 	_, _ = TRANSITIONS_ENUM.Add("NO")
 	iSH, _ := TRANSITIONS_ENUM.Add("SH")
 	iRE, _ := TRANSITIONS_ENUM.Add("RE")
 	iPR, _ := TRANSITIONS_ENUM.Add("PR")
-	SH = Transition(iSH)
-	RE = Transition(iRE)
-	PR = Transition(iPR)
-	LA = PR + 1
+	SH = ConstTransition(iSH)
+	RE = ConstTransition(iRE)
+	PR = ConstTransition(iPR)
+	LA = ConstTransition(iPR + 1)
 	for _, transition := range TEST_RELATIONS {
 		TRANSITIONS_ENUM.Add(string("LA-" + transition))
 	}
-	RA = Transition(TRANSITIONS_ENUM.Len())
+	RA = ConstTransition(TRANSITIONS_ENUM.Len())
 	for _, transition := range TEST_RELATIONS {
 		TRANSITIONS_ENUM.Add(string("RA-" + transition))
 	}
 	TEST_EAGER_ENUM_TRANSITIONS = make([]Transition, len(TEST_EAGER_TRANSITIONS))
 	for i, transition := range TEST_EAGER_TRANSITIONS {
 		index, _ := TRANSITIONS_ENUM.IndexOf(string(transition))
-		TEST_EAGER_ENUM_TRANSITIONS[i] = Transition(index)
+		TEST_EAGER_ENUM_TRANSITIONS[i] = ConstTransition(index)
+	}
+}
+
+func newTestArcEager() *ArcEager {
+	SetupTestEnum()
+	SetupEagerTransEnum()
+	return &ArcEager{
+		ArcStandard: ArcStandard{
+			SHIFT:       SH.Value(),
+			LEFT:        LA.Value(),
+			RIGHT:       RA.Value(),
+			Relations:   TEST_ENUM_RELATIONS,
+			Transitions: TRANSITIONS_ENUM,
+		},
+		REDUCE:  RE.Value(),
+		POPROOT: PR.Value(),
+	}
+}
+
+// oracleCostTest is the cost of a transition after a prefix of a gold
+// sequence
+type oracleCostTest struct {
+	prefix     int
+	transition string
+	cost       int
+}
+
+func TestArcEagerDynamicOracleCost(t *testing.T) {
+	arcEag := newTestArcEager()
+	arcEag.AddDynamicOracle()
+	oracle := arcEag.Oracle().(*ArcEagerDynamicOracle)
+	oracle.SetGold(GetTestDepGraph())
+
+	// [Economic news had little effect on financial markets .]
+	//     0      1    2    3      4    5      6       7     8
+	tests := []oracleCostTest{
+		{0, "SH", 0},
+		// S=[Economic] B=[news ...]
+		{1, "LA-ATT", 0},
+		{1, "LA-SBJ", 1}, // wrong label
+		{1, "RA-ATT", 2}, // (had,news) and (news,Economic)
+		{1, "SH", 1},     // (news,Economic)
+		{3, "LA-SBJ", 0}, // S=[news] B=[had ...]
+		{3, "SH", 1},     // (had,news)
+		{3, "RA-SBJ", 2}, // had is the root, (had,news)
+		{6, "LA-ATT", 0}, // S=[had little] B=[effect ...]
+		{6, "RA-OBJ", 2}, // (had,effect) and (effect,little)
+		{6, "SH", 2},     // (had,effect) and (effect,little)
+		{7, "RA-OBJ", 0}, // S=[had] B=[effect ...]
+		{7, "RA-ATT", 1}, // wrong label
+		{7, "SH", 1},     // (had,effect)
+		{8, "RA-ATT", 0}, // S=[had effect] B=[on ...]
+		{8, "RE", 1},     // (effect,on)
+		{12, "RE", 0},    // S=[had effect on markets] B=[.]
+		{12, "RA-PU", 1}, // (had,.)
+		{len(TEST_EAGER_TRANSITIONS) - 1, "PR", 0}, // S=[had] B=[]
+	}
+	// the transitions of the gold sequence are free
+	for i, transition := range TEST_EAGER_TRANSITIONS {
+		tests = append(tests, oracleCostTest{i, string(transition), 0})
+	}
+	for _, test := range tests {
+		var c Configuration = &SimpleConfiguration{
+			EWord:  EWord,
+			EPOS:   EPOS,
+			EWPOS:  EWPOS,
+			ERel:   TEST_ENUM_RELATIONS,
+			ETrans: TRANSITIONS_ENUM,
+		}
+		c.Init(TEST_SENT)
+		for _, transition := range TEST_EAGER_ENUM_TRANSITIONS[:test.prefix] {
+			c = arcEag.Transition(c, transition)
+		}
+		index, exists := TRANSITIONS_ENUM.IndexOf(test.transition)
+		if !exists {
+			t.Fatal("Can't find transition", test.transition)
+		}
+		if cost := oracle.Cost(c, ConstTransition(index)); cost != test.cost {
+			t.Errorf("Got cost %d of %s after %v, expected %d", cost, test.transition, TEST_EAGER_TRANSITIONS[:test.prefix], test.cost)
+		}
 	}
 }
 
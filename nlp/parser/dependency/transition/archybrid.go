@@ -0,0 +1,267 @@
+package transition
+
+import (
+	"fmt"
+	. "yap/alg/transition"
+	. "yap/nlp/types"
+)
+
+// ArcHybrid takes left arcs from the buffer as arc eager does and right
+// arcs within the stack as arc standard does (Kuhlmann et al. 2011)
+type ArcHybrid struct {
+	ArcStandard
+}
+
+// Verify that ArcHybrid is a TransitionSystem
+var _ TransitionSystem = &ArcHybrid{}
+
+func (a *ArcHybrid) Transition(from Configuration, rawTransition Transition) Configuration {
+	transition := rawTransition.Value()
+	if transition < a.RIGHT {
+		return a.ArcStandard.Transition(from, rawTransition)
+	}
+	conf, ok := from.Copy().(*SimpleConfiguration)
+	if !ok {
+		panic("Got wrong configuration type")
+	}
+	// Transition System (LA and SH as in arc standard):
+	// RA-r	(S|wi|wj,	B,	A) => (S|wi,	B,	A+{(wi,r,wj)})
+	wj, wjExists := conf.Stack().Pop()
+	wi, wiExists := conf.Stack().Peek()
+	if !(wiExists && wjExists) {
+		panic(fmt.Sprintf("Can't RA, Stack has less than two elements: %v", conf))
+	}
+	rel := int(transition - a.RIGHT)
+	relValue := a.Relations.ValueOf(rel).(DepRel)
+	newArc := &BasicDepArc{wi, rel, wj, relValue}
+	conf.AddArc(newArc)
+	conf.Assign(uint16(conf.Nodes[newArc.Modifier].ID()))
+	conf.NumHeadStack--
+	conf.SetLastTransition(rawTransition)
+	return conf
+}
+
+func (a *ArcHybrid) possibleTransitions(from Configuration, transitions chan int) {
+	conf, ok := from.(*SimpleConfiguration)
+	if !ok {
+		panic("Got wrong configuration type")
+	}
+	_, qExists := conf.Queue().Peek()
+	_, sExists := conf.Stack().Peek()
+	if qExists {
+		transitions <- a.SHIFT
+	}
+	if sExists && qExists {
+		for rel, _ := range a.Relations.Index {
+			transitions <- a.LEFT + rel
+		}
+	}
+	if conf.Stack().Size() > 1 {
+		for rel, _ := range a.Relations.Index {
+			transitions <- a.RIGHT + rel
+		}
+	}
+	close(transitions)
+}
+
+func (a *ArcHybrid) GetTransitions(from Configuration) (byte, []int) {
+	retval := make([]int, 0, 10)
+	tType, transitions := a.YieldTransitions(from)
+	for transition := range transitions {
+		retval = append(retval, transition)
+	}
+	return tType, retval
+}
+
+func (a *ArcHybrid) YieldTransitions(from Configuration) (byte, chan int) {
+	transitions := make(chan int)
+	go a.possibleTransitions(from, transitions)
+	return TransitionType, transitions
+}
+
+func (a *ArcHybrid) AddDefaultOracle() {
+	a.oracle = Oracle(&ArcHybridOracle{
+		ArcStandardOracle: ArcStandardOracle{Transitions: a.Transitions, LA: a.LEFT, RA: a.RIGHT},
+	})
+}
+
+func (a *ArcHybrid) AddDynamicOracle() {
+	a.oracle = Oracle(a.dynamicOracle())
+}
+
+func (a *ArcHybrid) dynamicOracle() *ArcHybridDynamicOracle {
+	return &ArcHybridDynamicOracle{
+		ArcHybridOracle: ArcHybridOracle{
+			ArcStandardOracle: ArcStandardOracle{Transitions: a.Transitions, LA: a.LEFT, RA: a.RIGHT},
+		},
+		SHIFT: a.SHIFT,
+	}
+}
+
+func (a *ArcHybrid) PartialOracle(heads []int, rels []DepRel) DynamicOracle {
+	oracle := a.dynamicOracle()
+	oracle.heads, oracle.rels = heads, rels
+	return oracle
+}
+
+func (a *ArcHybrid) Name() string {
+	return "Arc Hybrid (Kuhlmann et al. 2011)"
+}
+
+// ArcHybridOracle is the static oracle of arc hybrid
+type ArcHybridOracle struct {
+	ArcStandardOracle
+}
+
+var _ Decision = &ArcHybridOracle{}
+
+func (o *ArcHybridOracle) Transition(conf Configuration) Transition {
+	c := conf.(*SimpleConfiguration)
+
+	if o.gold == nil {
+		panic("Oracle needs gold reference, use SetGold")
+	}
+	// Given Gd=(Vd,Ad) # gold dependencies
+	// o(c = (S|s1|s0,B,A)) =
+	// LA-r	if	(B[0],r,s0) in Ad
+	// RA-r	if	(s1,r,s0) in Ad; and for all w,r', if (s0,r',w) in Ad then (s0,r',w) in A
+	// SH	otherwise
+	bTop, bExists := c.Queue().Peek()
+	s0, sExists := c.Stack().Peek()
+	var index int
+	if sExists {
+		if bExists {
+			arcs := o.arcSet.Get(&BasicDepArc{bTop, -1, s0, DepRel("")})
+			if len(arcs) > 0 {
+				index, _ = o.Transitions.IndexOf("LA-" + string(arcs[0].GetRelation()))
+				return &TypedTransition{TransitionType, index}
+			}
+		}
+		if s1, s1Exists := c.Stack().Index(1); s1Exists {
+			arcs := o.arcSet.Get(&BasicDepArc{s1, -1, s0, DepRel("")})
+			if len(arcs) > 0 && o.hasAllModifiers(c, s0) {
+				index, _ = o.Transitions.IndexOf("RA-" + string(arcs[0].GetRelation()))
+				return &TypedTransition{TransitionType, index}
+			}
+		}
+	}
+	if bExists {
+		index, _ = o.Transitions.IndexOf("SH")
+		return &TypedTransition{TransitionType, index}
+	}
+	panic(fmt.Sprintf("Oracle cannot reach the gold graph from configuration %v", c))
+}
+
+// hasAllModifiers is true if all gold modifiers of head are attached
+func (o *ArcHybridOracle) hasAllModifiers(c *SimpleConfiguration, head int) bool {
+	for _, arc := range o.arcSet.Get(&BasicDepArc{head, -1, -1, DepRel("")}) {
+		if len(c.Arcs().Get(arc)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *ArcHybridOracle) Name() string {
+	return "Arc Hybrid (static)"
+}
+
+// ArcHybridDynamicOracle is the dynamic oracle of arc hybrid (Goldberg &
+// Nivre 2013), where the gold root is the word left on the stack. Its
+// transitions are those of the static oracle
+type ArcHybridDynamicOracle struct {
+	ArcHybridOracle
+	SHIFT int
+	heads []int
+	rels  []DepRel
+}
+
+var _ DynamicOracle = &ArcHybridDynamicOracle{}
+
+// SetGold accepts any labeled dependency graph, e.g. the terminal
+// configuration of a gold sequence, whose arcs are not indexed by modifier
+func (o *ArcHybridDynamicOracle) SetGold(g interface{}) {
+	var gold *BasicDepGraph
+	gold, o.heads, o.rels = goldHeads(g)
+	o.ArcHybridOracle.SetGold(gold)
+}
+
+func (o *ArcHybridDynamicOracle) Cost(conf Configuration, t Transition) int {
+	c := conf.(*SimpleConfiguration)
+	if o.heads == nil {
+		panic("Oracle needs gold reference, use SetGold")
+	}
+	// Given gold heads h and labels l, c = (S|s1|s0, b|B, A), the arcs
+	// lost by each transition are as follows, where the gold root is lost
+	// once attached or shifted onto a non-empty stack (it can't be left
+	// alone on the stack):
+	// LA-r	(b,s0) if r != l(s0); (h(s0),s0) if h(s0) in s1|B; (s0,k) for k in b|B
+	// RA-r	(s1,s0) if r != l(s0); (h(s0),s0) if h(s0) in b|B; (s0,k) for k in b|B
+	// SH	(h(b),b) if h(b) in S or b is the root; (b,k) for k in S|s1|s0
+	numNodes := len(o.heads)
+	b, bExists := c.Queue().Peek()
+	if !bExists {
+		b = numNodes
+	}
+	s0, sExists := c.Stack().Peek()
+	s1, s1Exists := c.Stack().Index(1)
+	inStack := make([]bool, numNodes)
+	for i := 0; i < c.Stack().Size(); i++ {
+		k, _ := c.Stack().Index(i)
+		inStack[k] = true
+	}
+	// modifiers of head with a gold head in b|B
+	bufferModifiers := func(head int) int {
+		var cost int
+		for k := b; k < numNodes; k++ {
+			if o.heads[k] == head {
+				cost++
+			}
+		}
+		return cost
+	}
+	// the cost of the arc (head,r,modifier) to the modifier's gold arc,
+	// if it is still reachable
+	attachCost := func(head, modifier int, rel string, reachable bool) int {
+		switch goldHead := o.heads[modifier]; {
+		case goldHead == UNKNOWN_HEAD:
+		case goldHead < 0:
+			if c.Stack().Size() == 1 {
+				return 1
+			}
+		case goldHead == head:
+			if len(o.rels[modifier]) > 0 && string(o.rels[modifier]) != rel {
+				return 1
+			}
+		case reachable:
+			return 1
+		}
+		return 0
+	}
+	var cost int
+	value := t.Value()
+	switch {
+	case value == o.SHIFT:
+		if head := o.heads[b]; (head == -1 && sExists) || (head >= 0 && head != s0 && inStack[head]) {
+			cost++
+		}
+		for k, exists := range inStack {
+			if exists && o.heads[k] == b {
+				cost++
+			}
+		}
+	case value >= o.LA && value < o.RA:
+		rel := o.Transitions.ValueOf(value).(string)[3:]
+		cost += attachCost(b, s0, rel, (s1Exists && o.heads[s0] == s1) || o.heads[s0] > b)
+		cost += bufferModifiers(s0)
+	case value >= o.RA:
+		rel := o.Transitions.ValueOf(value).(string)[3:]
+		cost += attachCost(s1, s0, rel, o.heads[s0] >= b)
+		cost += bufferModifiers(s0)
+	}
+	return cost
+}
+
+func (o *ArcHybridDynamicOracle) Name() string {
+	return "Arc Hybrid Dynamic Oracle (Goldberg & Nivre 2013)"
+}
@@ -0,0 +1,67 @@
+package transition
+
+import (
+	. "yap/alg/transition"
+
+	"testing"
+)
+
+func newTestArcHybrid() *ArcHybrid {
+	SetupTestEnum()
+	SetupEagerTransEnum()
+	return &ArcHybrid{
+		ArcStandard: ArcStandard{
+			SHIFT:       SH.Value(),
+			LEFT:        LA.Value(),
+			RIGHT:       RA.Value(),
+			Relations:   TEST_ENUM_RELATIONS,
+			Transitions: TRANSITIONS_ENUM,
+		},
+	}
+}
+
+func TestArcHybridOracle(t *testing.T) {
+	oracleParse(t, newTestArcHybrid(), TEST_SENT, GetTestDepGraph(), 1)
+}
+
+func TestArcHybridDynamicOracleCost(t *testing.T) {
+	arcHybrid := newTestArcHybrid()
+	arcHybrid.AddDefaultOracle()
+	static := arcHybrid.Oracle()
+	static.SetGold(GetTestDepGraph())
+	dynamic := arcHybrid.dynamicOracle()
+	dynamic.SetGold(GetTestDepGraph())
+
+	var c Configuration = &SimpleConfiguration{
+		EWord:         EWord,
+		EPOS:          EPOS,
+		EWPOS:         EWPOS,
+		ERel:          TEST_ENUM_RELATIONS,
+		ETrans:        TRANSITIONS_ENUM,
+		TerminalStack: 1,
+	}
+	c.Init(TEST_SENT)
+	// [Economic news had little effect on financial markets .]
+	//     0      1    2    3      4    5      6       7     8
+	// after SH: S=[Economic] B=[news ...]
+	c = arcHybrid.Transition(c, SH)
+	for transition, expected := range map[string]int{
+		"LA-ATT": 0,
+		"LA-SBJ": 1, // wrong label
+		"SH":     1, // (news,Economic)
+	} {
+		index, _ := TRANSITIONS_ENUM.IndexOf(transition)
+		if cost := dynamic.Cost(c, ConstTransition(index)); cost != expected {
+			t.Errorf("Got cost %d of %s after SH, expected %d", cost, transition, expected)
+		}
+	}
+	// the transitions of the static oracle are free
+	c = arcHybrid.Transition(c, static.Transition(c))
+	for !c.Terminal() {
+		transition := static.Transition(c)
+		if cost := dynamic.Cost(c, transition); cost != 0 {
+			t.Errorf("Got cost %d of static oracle transition %v at %v", cost, TRANSITIONS_ENUM.ValueOf(transition.Value()), c)
+		}
+		c = arcHybrid.Transition(c, transition)
+	}
+}
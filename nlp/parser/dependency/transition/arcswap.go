@@ -0,0 +1,258 @@
+package transition
+
+import (
+	"fmt"
+	. "yap/alg/transition"
+	. "yap/nlp/types"
+)
+
+// ArcSwap is arc standard extended with a SWAP transition reordering the
+// input, allowing non-projective trees (Nivre 2009)
+type ArcSwap struct {
+	ArcStandard
+	SWAP int
+}
+
+// Verify that ArcSwap is a TransitionSystem
+var _ TransitionSystem = &ArcSwap{}
+
+func (a *ArcSwap) Transition(from Configuration, rawTransition Transition) Configuration {
+	transition := rawTransition.Value()
+	if transition != a.SWAP {
+		return a.ArcStandard.Transition(from, rawTransition)
+	}
+	conf, ok := from.Copy().(*SimpleConfiguration)
+	if !ok {
+		panic("Got wrong configuration type")
+	}
+	// Transition System (in addition to arc standard):
+	// SW	(S|wi,	wj|B,	A) => (S   ,	wj|wi|B,	A)	if: i < j
+	wi, wiExists := conf.Stack().Pop()
+	wj, wjExists := conf.Queue().Pop()
+	if !(wiExists && wjExists) {
+		panic(fmt.Sprintf("Can't SW, Stack and/or Queue are/is empty: %v", conf))
+	}
+	if wi >= wj {
+		panic(fmt.Sprintf("Can't SW %d and %d, they are already swapped", wi, wj))
+	}
+	conf.Queue().Push(wi)
+	conf.Queue().Push(wj)
+	conf.Assign(uint16(conf.Nodes[wi].ID()))
+	// the swapped element is shifted again later on
+	conf.NumHeadStack--
+	conf.SetLastTransition(rawTransition)
+	return conf
+}
+
+func (a *ArcSwap) possibleTransitions(from Configuration, transitions chan int) {
+	conf, ok := from.(*SimpleConfiguration)
+	if !ok {
+		panic("Got wrong configuration type")
+	}
+	qPeek, qExists := conf.Queue().Peek()
+	sPeek, sExists := conf.Stack().Peek()
+	// shifting the last element onto a non-empty stack is a dead end
+	if qExists && (!sExists || conf.Queue().Size() > 1) {
+		transitions <- a.SHIFT
+	}
+	if sExists && qExists {
+		for rel, _ := range a.Relations.Index {
+			transitions <- a.LEFT + rel
+		}
+		for rel, _ := range a.Relations.Index {
+			transitions <- a.RIGHT + rel
+		}
+		if sPeek < qPeek {
+			transitions <- a.SWAP
+		}
+	}
+	close(transitions)
+}
+
+func (a *ArcSwap) GetTransitions(from Configuration) (byte, []int) {
+	retval := make([]int, 0, 10)
+	tType, transitions := a.YieldTransitions(from)
+	for transition := range transitions {
+		retval = append(retval, transition)
+	}
+	return tType, retval
+}
+
+func (a *ArcSwap) YieldTransitions(from Configuration) (byte, chan int) {
+	transitions := make(chan int)
+	go a.possibleTransitions(from, transitions)
+	return TransitionType, transitions
+}
+
+func (a *ArcSwap) TransitionTypes() []string {
+	return append(a.ArcStandard.TransitionTypes(), "SW")
+}
+
+func (a *ArcSwap) Projective() bool {
+	return false
+}
+
+func (a *ArcSwap) AddDefaultOracle() {
+	a.oracle = Oracle(&ArcSwapOracle{
+		ArcStandardOracle: ArcStandardOracle{Transitions: a.Transitions, LA: a.LEFT, RA: a.RIGHT},
+	})
+}
+
+func (a *ArcSwap) Name() string {
+	return "Arc Standard with Swap (Nivre 2009)"
+}
+
+// ArcSwapOracle is the static lazy swap oracle (Nivre et al. 2009): attach
+// as in arc standard once the dependent has all its modifiers, swap when
+// the two candidates are out of the gold tree's projective order and the
+// swap is needed to join maximal projective components, otherwise shift
+type ArcSwapOracle struct {
+	ArcStandardOracle
+	order, components []int
+}
+
+var _ Decision = &ArcSwapOracle{}
+
+func (o *ArcSwapOracle) SetGold(g interface{}) {
+	o.ArcStandardOracle.SetGold(g)
+	o.order = ProjectiveOrder(o.gold)
+	o.components = ProjectiveComponents(o.gold)
+}
+
+// ProjectiveOrder is the position of each node in the inorder traversal
+// of a dependency graph (the word order in which the graph is projective)
+func ProjectiveOrder(graph LabeledDependencyGraph) []int {
+	numNodes := graph.NumberOfNodes()
+	// modifiers by head, offset by one for the root's head (-1)
+	modifiers := make([][]int, numNodes+1)
+	for i := 0; i < numNodes; i++ {
+		head := -1
+		if arc := graph.GetLabeledArc(i); arc != nil {
+			head = arc.GetHead()
+		}
+		modifiers[head+1] = append(modifiers[head+1], i)
+	}
+	order := make([]int, numNodes)
+	var (
+		next  int
+		visit func(node int)
+	)
+	visit = func(node int) {
+		for _, modifier := range modifiers[node+1] {
+			if modifier < node {
+				visit(modifier)
+			}
+		}
+		order[node] = next
+		next++
+		for _, modifier := range modifiers[node+1] {
+			if modifier > node {
+				visit(modifier)
+			}
+		}
+	}
+	for _, root := range modifiers[0] {
+		visit(root)
+	}
+	return order
+}
+
+// ProjectiveComponents is the root of the maximal projective component of
+// each node of a dependency graph, found by attaching arcs bottom up in
+// word order without reordering (as arc standard would)
+func ProjectiveComponents(graph LabeledDependencyGraph) []int {
+	numNodes := graph.NumberOfNodes()
+	heads := make([]int, numNodes)
+	missing := make([]int, numNodes)
+	for i := 0; i < numNodes; i++ {
+		heads[i] = -1
+		if arc := graph.GetLabeledArc(i); arc != nil {
+			heads[i] = arc.GetHead()
+		}
+		if heads[i] >= 0 {
+			missing[heads[i]]++
+		}
+	}
+	attached := make([]int, numNodes)
+	stack := make([]int, 0, numNodes)
+	for i := 0; i < numNodes; i++ {
+		attached[i] = -1
+		stack = append(stack, i)
+		for len(stack) > 1 {
+			wi, wj := stack[len(stack)-2], stack[len(stack)-1]
+			if heads[wi] == wj && missing[wi] == 0 {
+				attached[wi] = wj
+				missing[wj]--
+				stack[len(stack)-2] = wj
+			} else if heads[wj] == wi && missing[wj] == 0 {
+				attached[wj] = wi
+				missing[wi]--
+			} else {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	components := make([]int, numNodes)
+	for i := 0; i < numNodes; i++ {
+		root := i
+		for attached[root] >= 0 {
+			root = attached[root]
+		}
+		components[i] = root
+	}
+	return components
+}
+
+// hasAllModifiers is true if all gold modifiers of head are attached
+func (o *ArcSwapOracle) hasAllModifiers(c *SimpleConfiguration, head int) bool {
+	for _, arc := range o.arcSet.Get(&BasicDepArc{head, -1, -1, DepRel("")}) {
+		if len(c.Arcs().Get(arc)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *ArcSwapOracle) Transition(conf Configuration) Transition {
+	c := conf.(*SimpleConfiguration)
+
+	if o.gold == nil {
+		panic("Oracle needs gold reference, use SetGold")
+	}
+	// Given Gd=(Vd,Ad) # gold dependencies, <o the projective order of Gd
+	// o(c = (S,B,A)) =
+	// LA-r	if	(B[0],r,S[0]) in Ad; and all modifiers of S[0] in A
+	// RA-r	if	(S[0],r,B[0]) in Ad; and all modifiers of B[0] in A
+	// SW	if	B[0] <o S[0]; and B[0], B[1] are in different maximal projective components
+	// SH	otherwise
+	bTop, bExists := c.Queue().Peek()
+	sTop, sExists := c.Stack().Peek()
+	var index int
+	if bExists {
+		if sExists {
+			arcs := o.arcSet.Get(&BasicDepArc{bTop, -1, sTop, DepRel("")})
+			if len(arcs) > 0 && o.hasAllModifiers(c, sTop) {
+				index, _ = o.Transitions.IndexOf("LA-" + string(arcs[0].GetRelation()))
+				return &TypedTransition{TransitionType, index}
+			}
+			arcs = o.arcSet.Get(&BasicDepArc{sTop, -1, bTop, DepRel("")})
+			if len(arcs) > 0 && o.hasAllModifiers(c, bTop) {
+				index, _ = o.Transitions.IndexOf("RA-" + string(arcs[0].GetRelation()))
+				return &TypedTransition{TransitionType, index}
+			}
+			bNext, bNextExists := c.Queue().Index(1)
+			if o.order[bTop] < o.order[sTop] && (!bNextExists || o.components[bTop] != o.components[bNext]) {
+				index, _ = o.Transitions.IndexOf("SW")
+				return &TypedTransition{TransitionType, index}
+			}
+		}
+		index, _ = o.Transitions.IndexOf("SH")
+		return &TypedTransition{TransitionType, index}
+	}
+	panic(fmt.Sprintf("Got empty configuration %v", c))
+}
+
+func (o *ArcSwapOracle) Name() string {
+	return "Arc Standard with Swap (static)"
+}
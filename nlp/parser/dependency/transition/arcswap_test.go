@@ -0,0 +1,40 @@
+package transition
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProjectiveOrder(t *testing.T) {
+	// A hearing on the issue is scheduled today .
+	expected := []int{0, 1, 5, 6, 2, 3, 4, 7, 8}
+	if order := ProjectiveOrder(GetTestNonProjectiveGraph()); !reflect.DeepEqual(order, expected) {
+		t.Errorf("Got projective order %v, expected %v", order, expected)
+	}
+	// a projective graph is in order
+	graph := GetTestDepGraph()
+	order := ProjectiveOrder(graph)
+	for i := range order {
+		if order[i] != i {
+			t.Errorf("Got projective order %v of a projective graph", order)
+			break
+		}
+	}
+}
+
+func TestProjectiveComponents(t *testing.T) {
+	// "A hearing" and "on the issue" are attached without reordering, the
+	// others are their own components
+	expected := []int{1, 1, 2, 3, 4, 4, 4, 7, 8}
+	if components := ProjectiveComponents(GetTestNonProjectiveGraph()); !reflect.DeepEqual(components, expected) {
+		t.Errorf("Got projective components %v, expected %v", components, expected)
+	}
+	// a projective graph is a single component
+	components := ProjectiveComponents(GetTestDepGraph())
+	for i := range components {
+		if components[i] != 2 {
+			t.Errorf("Got projective components %v of a projective graph, expected all 2", components)
+			break
+		}
+	}
+}
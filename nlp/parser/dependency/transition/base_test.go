@@ -4,18 +4,20 @@ import (
 	AbstractTransition "yap/alg/transition"
 	nlp "yap/nlp/types"
 	"yap/util"
+
+	"testing"
 )
 
 var rawTestSent nlp.BasicETaggedSentence = nlp.BasicETaggedSentence{
-	{TaggedToken: nlp.TaggedToken{"Economic", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"news", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"had", "VB"}},
-	{TaggedToken: nlp.TaggedToken{"little", "ADJ"}},
-	{TaggedToken: nlp.TaggedToken{"effect", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"on", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"financial", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"markets", "NN"}},
-	{TaggedToken: nlp.TaggedToken{".", "yyDOT"}}}
+	{TaggedToken: nlp.TaggedToken{Token: "Economic", POS: "NN"}},
+	{TaggedToken: nlp.TaggedToken{Token: "news", POS: "NN"}},
+	{TaggedToken: nlp.TaggedToken{Token: "had", POS: "VB"}},
+	{TaggedToken: nlp.TaggedToken{Token: "little", POS: "ADJ"}},
+	{TaggedToken: nlp.TaggedToken{Token: "effect", POS: "NN"}},
+	{TaggedToken: nlp.TaggedToken{Token: "on", POS: "NN"}},
+	{TaggedToken: nlp.TaggedToken{Token: "financial", POS: "NN"}},
+	{TaggedToken: nlp.TaggedToken{Token: "markets", POS: "NN"}},
+	{TaggedToken: nlp.TaggedToken{Token: ".", POS: "yyDOT"}}}
 
 var TEST_SENT nlp.TaggedSentence
 
@@ -42,11 +44,12 @@ var rawArcs []BasicDepArc = []BasicDepArc{
 	{Head: 2, RawRelation: nlp.DepRel("PU"), Modifier: 8}}
 
 var (
-	TEST_RELATIONS      []nlp.DepRel = []nlp.DepRel{"ATT", "SBJ", "PC", "OBJ", "PU", "PRED", nlp.ROOT_LABEL}
+	TEST_RELATIONS      []nlp.DepRel = []nlp.DepRel{"ATT", "SBJ", "PC", "OBJ", "PU", "PRED", "VC", "TMP", nlp.ROOT_LABEL}
 	TRANSITIONS_ENUM    *util.EnumSet
 	TEST_ENUM_RELATIONS *util.EnumSet
 	EWord, EPOS, EWPOS  *util.EnumSet
 	SH, RE, PR, LA, RA  AbstractTransition.Transition
+	NA                  AbstractTransition.Transition
 )
 
 //ALL RICH FEATURES
@@ -250,7 +253,7 @@ func SetupRelationEnum() {
 	if TEST_ENUM_RELATIONS != nil {
 		return
 	}
-	TEST_ENUM_RELATIONS = util.NewEnumSet(len(TEST_RELATIONS))
+	TEST_ENUM_RELATIONS = util.NewEnumSet(len(TEST_RELATIONS), "ERel")
 	for _, label := range TEST_RELATIONS {
 		TEST_ENUM_RELATIONS.Add(label)
 	}
@@ -258,9 +261,9 @@ func SetupRelationEnum() {
 
 func SetupSentEnum() {
 	EWord, EPOS, EWPOS =
-		util.NewEnumSet(len(rawNodes)),
-		util.NewEnumSet(5), // 4 POS + ROOT
-		util.NewEnumSet(len(rawNodes))
+		util.NewEnumSet(len(rawNodes), "EWord"),
+		util.NewEnumSet(5, "EPOS"), // 4 POS + ROOT
+		util.NewEnumSet(len(rawNodes), "EWPOS")
 	var (
 		// val   int
 		node  *TaggedDepNode
@@ -314,6 +317,80 @@ func GetTestDepGraph() nlp.LabeledDependencyGraph {
 	return nlp.LabeledDependencyGraph(&BasicDepGraph{nodes, arcs})
 }
 
+// "A hearing is scheduled on the issue today ." (Nivre 2009), the arc of
+// "on" to "hearing" crosses the arc of "hearing" to "is", and the arc of
+// "today" to "scheduled" spans "on the issue"
+var (
+	nonProjectiveTokens    = []string{"A", "hearing", "is", "scheduled", "on", "the", "issue", "today", "."}
+	nonProjectiveHeads     = []int{1, 2, -1, 2, 1, 6, 4, 3, 2}
+	nonProjectiveRelations = []nlp.DepRel{"ATT", "SBJ", nlp.ROOT_LABEL, "VC", "ATT", "ATT", "PC", "TMP", "PU"}
+)
+
+func newTestGraph(tokens []string, heads []int, relations []nlp.DepRel) *BasicDepGraph {
+	graph := &BasicDepGraph{
+		Nodes: make([]nlp.DepNode, len(tokens)),
+		Arcs:  make([]*BasicDepArc, len(tokens)),
+	}
+	for i, token := range tokens {
+		graph.Nodes[i] = &TaggedDepNode{Id: i, RawToken: token}
+		graph.Arcs[i] = &BasicDepArc{Head: heads[i], Modifier: i, RawRelation: relations[i]}
+	}
+	return graph
+}
+
+func GetTestNonProjectiveGraph() *BasicDepGraph {
+	return newTestGraph(nonProjectiveTokens, nonProjectiveHeads, nonProjectiveRelations)
+}
+
+func GetTestNonProjectiveSentence() nlp.BasicETaggedSentence {
+	sent := make(nlp.BasicETaggedSentence, len(nonProjectiveTokens))
+	for i, token := range nonProjectiveTokens {
+		sent[i].Token = token
+	}
+	return sent
+}
+
+// oracleParse parses sent with the static oracle of arcSystem for gold,
+// verifying that it reaches a terminal configuration of gold's arcs (the
+// root is left headless)
+func oracleParse(t *testing.T, arcSystem AbstractTransition.TransitionSystem, sent interface{}, gold nlp.LabeledDependencyGraph, terminalStack int) {
+	conf := &SimpleConfiguration{
+		EWord:         EWord,
+		EPOS:          EPOS,
+		EWPOS:         EWPOS,
+		ERel:          TEST_ENUM_RELATIONS,
+		ETrans:        TRANSITIONS_ENUM,
+		TerminalStack: terminalStack,
+	}
+	conf.Init(sent)
+	arcSystem.AddDefaultOracle()
+	oracle := arcSystem.Oracle()
+	oracle.SetGold(gold)
+	var c AbstractTransition.Configuration = conf
+	for i := 0; !c.Terminal(); i++ {
+		if i > 4*gold.NumberOfNodes()*gold.NumberOfNodes() {
+			t.Fatalf("%s did not reach a terminal configuration: %v", arcSystem.Name(), c)
+		}
+		c = arcSystem.Transition(c, oracle.Transition(c))
+	}
+	arcs := c.(*SimpleConfiguration).Arcs()
+	var numArcs int
+	for _, arcID := range gold.GetEdges() {
+		arc := gold.GetLabeledArc(arcID)
+		if arc.GetRelation() == nlp.DepRel(nlp.ROOT_LABEL) {
+			continue
+		}
+		numArcs++
+		found := arcs.Get(&BasicDepArc{arc.GetHead(), -1, arc.GetModifier(), nlp.DepRel("")})
+		if len(found) != 1 || found[0].GetRelation() != arc.GetRelation() {
+			t.Errorf("%s: gold arc %v not found in %v", arcSystem.Name(), arc, arcs)
+		}
+	}
+	if arcs.Size() != numArcs {
+		t.Errorf("%s: got %d arcs, expected %d", arcSystem.Name(), arcs.Size(), numArcs)
+	}
+}
+
 // func GetTestConfiguration() *SimpleConfiguration {
 // 	SetupTestEnum()
 // 	SetupEagerTransEnum() // default trans is eager
@@ -0,0 +1,56 @@
+package transition
+
+import (
+	. "yap/alg/transition"
+	. "yap/nlp/types"
+)
+
+// UNKNOWN_HEAD is the head of a word of a partial gold graph whose head is
+// not known
+const UNKNOWN_HEAD = -2
+
+// A PartialOracleSystem has dynamic oracles of partial gold graphs, given
+// by the heads and labels of their words: the head of a root is -1, an
+// unknown head is UNKNOWN_HEAD and an unknown label is empty. The label of
+// a word is known only with its head
+type PartialOracleSystem interface {
+	TransitionSystem
+	PartialOracle(heads []int, rels []DepRel) DynamicOracle
+}
+
+var _ PartialOracleSystem = &ArcEager{}
+var _ PartialOracleSystem = &ArcHybrid{}
+
+// ArcConstraints restrict the transitions of an arc system to those
+// consistent with a partial gold graph, by its dynamic oracle: those losing
+// no gold arc, or if every transition does (the known arcs are not
+// reachable together), those losing the fewest
+type ArcConstraints struct {
+	Oracle DynamicOracle
+}
+
+var _ Constraints = &ArcConstraints{}
+
+func NewArcConstraints(system PartialOracleSystem, heads []int, rels []DepRel) *ArcConstraints {
+	return &ArcConstraints{Oracle: system.PartialOracle(heads, rels)}
+}
+
+func (a *ArcConstraints) Allowed(conf Configuration, transType byte, transitions []int) []int {
+	var (
+		costs   = make([]int, len(transitions))
+		minCost = -1
+	)
+	for i, t := range transitions {
+		costs[i] = a.Oracle.Cost(conf, &TypedTransition{transType, t})
+		if minCost < 0 || costs[i] < minCost {
+			minCost = costs[i]
+		}
+	}
+	allowed := make([]int, 0, len(transitions))
+	for i, t := range transitions {
+		if costs[i] == minCost {
+			allowed = append(allowed, t)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,199 @@
+package transition
+
+import (
+	"yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/search"
+	. "yap/alg/transition"
+	TransitionModel "yap/alg/transition/model"
+	nlp "yap/nlp/types"
+
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// partialHeads returns the heads and labels of the words of the test graph
+// given by annotated, the other words unannotated
+func partialHeads(annotated ...int) ([]int, []nlp.DepRel) {
+	heads, rels := make([]int, len(rawArcs)), make([]nlp.DepRel, len(rawArcs))
+	for i := range heads {
+		heads[i] = UNKNOWN_HEAD
+	}
+	for _, word := range annotated {
+		heads[word], rels[word] = rawArcs[word].Head, rawArcs[word].RawRelation
+	}
+	return heads, rels
+}
+
+func transitionNames(transitions []int) []string {
+	names := make([]string, len(transitions))
+	for i, t := range transitions {
+		names[i] = TRANSITIONS_ENUM.ValueOf(t).(string)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestArcConstraintsAllowed(t *testing.T) {
+	arcEag := newTestArcEager()
+	var c Configuration = &SimpleConfiguration{
+		EWord:  EWord,
+		EPOS:   EPOS,
+		EWPOS:  EWPOS,
+		ERel:   TEST_ENUM_RELATIONS,
+		ETrans: TRANSITIONS_ENUM,
+	}
+	c.Init(TEST_SENT)
+	// S=[Economic] B=[news ...]
+	c = arcEag.Transition(c, TEST_EAGER_ENUM_TRANSITIONS[0])
+	transType, transitions := arcEag.GetTransitions(c)
+	var leftArcs []string
+	for _, rel := range TEST_RELATIONS {
+		leftArcs = append(leftArcs, "LA-"+string(rel))
+	}
+	sort.Strings(leftArcs)
+
+	heads, rels := partialHeads(0)
+	unlabeledHeads, _ := partialHeads(0)
+	newsHeads, newsRels := partialHeads(1)
+	unknownHeads, unknownRels := partialHeads()
+	tests := []struct {
+		name     string
+		heads    []int
+		rels     []nlp.DepRel
+		expected []string
+	}{
+		{"annotated arc", heads, rels, []string{"LA-ATT"}},
+		{"annotated head", unlabeledHeads, make([]nlp.DepRel, len(heads)), leftArcs},
+		// news is attached to had, Economic is free
+		{"free word", newsHeads, newsRels, append([]string{"SH"}, leftArcs...)},
+		{"no annotation", unknownHeads, unknownRels, transitionNames(transitions)},
+	}
+	for _, test := range tests {
+		constraints := NewArcConstraints(arcEag, test.heads, test.rels)
+		allowed := transitionNames(constraints.Allowed(c, transType, transitions))
+		sort.Strings(test.expected)
+		if !reflect.DeepEqual(allowed, test.expected) {
+			t.Errorf("%s: allowed %v, expected %v", test.name, allowed, test.expected)
+		}
+	}
+}
+
+// constExtractor extracts a single constant feature, so that the score of
+// a transition is its weight
+type constExtractor struct{}
+
+func (e *constExtractor) Features(instance perceptron.Instance, flag bool, transType byte, transitions []int) []featurevector.Feature {
+	return []featurevector.Feature{"f"}
+}
+
+func (e *constExtractor) EstimatedNumberOfFeatures() int {
+	return 1
+}
+
+func (e *constExtractor) SetLog(bool) {
+}
+
+// constBeam is an arc eager beam preferring right arcs labeled TMP, which
+// the test graph does not have, then shifts
+func constBeam() *search.Beam {
+	arcEag := newTestArcEager()
+	model := TransitionModel.NewAvgMatrixSparse(1, nil, false)
+	tmp, _ := TRANSITIONS_ENUM.IndexOf("RA-TMP")
+	for t, weight := range map[int]int64{tmp: 3, SH.Value(): 2} {
+		features := &FeaturesList{
+			Transition: ConstTransition(t),
+			Previous:   &FeaturesList{Features: []featurevector.Feature{"f"}},
+		}
+		model.AddSubtract(features, features, weight)
+	}
+	return &search.Beam{
+		Base: &SimpleConfiguration{
+			EWord:  EWord,
+			EPOS:   EPOS,
+			EWPOS:  EWPOS,
+			ERel:   TEST_ENUM_RELATIONS,
+			ETrans: TRANSITIONS_ENUM,
+		},
+		TransFunc:     arcEag,
+		FeatExtractor: &constExtractor{},
+		Model:         model,
+		Size:          4,
+	}
+}
+
+// parsedHeads returns the heads and labels of the words of a parse, the
+// root's head -1 (popping the root adds an arc labeled ROOT)
+func parsedHeads(conf *SimpleConfiguration) ([]int, []nlp.DepRel) {
+	heads, rels := make([]int, len(rawArcs)), make([]nlp.DepRel, len(rawArcs))
+	for i := range heads {
+		heads[i] = -1
+		rels[i] = nlp.ROOT_LABEL
+	}
+	arcs := conf.Arcs()
+	for i := 0; i < arcs.Size(); i++ {
+		arc := arcs.Index(i)
+		if arc.GetRelation() == nlp.ROOT_LABEL {
+			continue
+		}
+		heads[arc.GetModifier()], rels[arc.GetModifier()] = arc.GetHead(), arc.GetRelation()
+	}
+	return heads, rels
+}
+
+// constrainedParse parses the test sentence constrained by the annotation
+// of words of the test graph
+func constrainedParse(b *search.Beam, annotated ...int) ([]int, []nlp.DepRel) {
+	heads, rels := partialHeads(annotated...)
+	problem := &search.ConstrainedProblem{
+		Problem:     TEST_SENT,
+		Constraints: NewArcConstraints(b.TransFunc.(PartialOracleSystem), heads, rels),
+	}
+	return parsedHeads(search.Search(b, problem, b.Size).(*search.ScoredConfiguration).C.(*SimpleConfiguration))
+}
+
+func TestConstrainedBeamParse(t *testing.T) {
+	defer func(allOut bool) { search.AllOut = allOut }(search.AllOut)
+	search.AllOut = false
+	b := constBeam()
+	freeHeads, freeRels := parsedHeads(search.Search(b, TEST_SENT, b.Size).(*search.ScoredConfiguration).C.(*SimpleConfiguration))
+	if freeRels[1] != "TMP" {
+		t.Fatalf("Got unconstrained labels %v, expected news labeled TMP", freeRels)
+	}
+
+	// the annotated heads and labels are kept
+	annotated := []int{1, 4, 7}
+	heads, rels := constrainedParse(b, annotated...)
+	for _, word := range annotated {
+		if heads[word] != rawArcs[word].Head || rels[word] != rawArcs[word].RawRelation {
+			t.Errorf("Got head %d label %s of annotated word %d, expected %d %s", heads[word], rels[word], word, rawArcs[word].Head, rawArcs[word].RawRelation)
+		}
+	}
+	// an unannotated word is free, here attached as the model prefers
+	var free bool
+	for word, rel := range rels {
+		free = free || rel == "TMP" && rawArcs[word].RawRelation != "TMP"
+	}
+	if !free {
+		t.Errorf("Got labels %v of a partially constrained parse, expected unannotated words labeled TMP", rels)
+	}
+
+	// a fully annotated sentence is parsed as annotated
+	all := make([]int, len(rawArcs))
+	for i := range all {
+		all[i] = i
+	}
+	heads, rels = constrainedParse(b, all...)
+	for word, arc := range rawArcs {
+		if heads[word] != arc.Head || rels[word] != arc.RawRelation {
+			t.Errorf("Got head %d label %s of word %d, expected %d %s", heads[word], rels[word], word, arc.Head, arc.RawRelation)
+		}
+	}
+
+	// without annotations, the parse is unconstrained
+	heads, rels = constrainedParse(b)
+	if !reflect.DeepEqual(heads, freeHeads) || !reflect.DeepEqual(rels, freeRels) {
+		t.Errorf("Got parse %v %v without annotations, expected the unconstrained %v %v", heads, rels, freeHeads, freeRels)
+	}
+}
@@ -0,0 +1,197 @@
+package transition
+
+import (
+	"fmt"
+	. "yap/alg/transition"
+	. "yap/nlp/types"
+)
+
+// Covington is the non-projective list based transition system (Covington
+// 2001, Nivre 2008). Its configurations (L1|wi, L2, wj|B, A) are kept as
+// (S|wi, wj|B, A): L1 is the stack and L2, the words between wi and wj
+// which were passed over, is pushed back onto the stack on SH
+type Covington struct {
+	ArcStandard
+	NOARC int
+}
+
+// Verify that Covington is a TransitionSystem
+var _ TransitionSystem = &Covington{}
+
+func (a *Covington) Transition(from Configuration, rawTransition Transition) Configuration {
+	conf, ok := from.Copy().(*SimpleConfiguration)
+	if !ok {
+		panic("Got wrong configuration type")
+	}
+	transition := rawTransition.Value()
+	// Transition System:
+	// LA-r	(L1|wi,	L2,	wj|B,	A) => (L1,	wi|L2,	wj|B,	A+{(wj,r,wi)})
+	// RA-r	(L1|wi,	L2,	wj|B,	A) => (L1,	wi|L2,	wj|B,	A+{(wi,r,wj)})
+	// NA	(L1|wi,	L2,	wj|B,	A) => (L1,	wi|L2,	wj|B,	A)
+	// SH	(L1,	L2,	wj|B,	A) => (L1.L2|wj,	[],	B,	A)
+	switch {
+	case transition == a.SHIFT:
+		wj, wjExists := conf.Queue().Pop()
+		if !wjExists {
+			panic("Can't shift, queue is empty")
+		}
+		wi, wiExists := conf.Stack().Peek()
+		if !wiExists {
+			wi = -1
+		}
+		for k := wi + 1; k <= wj; k++ {
+			conf.Stack().Push(k)
+		}
+		conf.Assign(uint16(conf.Nodes[wj].ID()))
+	case transition == a.NOARC:
+		wi, wiExists := conf.Stack().Pop()
+		if !wiExists {
+			panic("Can't NA, stack is empty")
+		}
+		conf.Assign(uint16(conf.Nodes[wi].ID()))
+	case transition >= a.LEFT:
+		wi, wiExists := conf.Stack().Pop()
+		wj, wjExists := conf.Queue().Peek()
+		if !(wiExists && wjExists) {
+			panic(fmt.Sprintf("Can't attach, Stack and/or Queue are/is empty: %v", conf))
+		}
+		var newArc *BasicDepArc
+		if transition < a.RIGHT {
+			rel := int(transition - a.LEFT)
+			newArc = &BasicDepArc{wj, rel, wi, a.Relations.ValueOf(rel).(DepRel)}
+		} else {
+			rel := int(transition - a.RIGHT)
+			newArc = &BasicDepArc{wi, rel, wj, a.Relations.ValueOf(rel).(DepRel)}
+		}
+		conf.AddArc(newArc)
+		conf.Assign(uint16(conf.Nodes[newArc.Modifier].ID()))
+	default:
+		panic(fmt.Sprintf("Unknown transition %v SHIFT is %v", transition, a.SHIFT))
+	}
+	conf.SetLastTransition(rawTransition)
+	return conf
+}
+
+// dominates is true if head is an ancestor of node in the configuration
+func (a *Covington) dominates(conf *SimpleConfiguration, head, node int) bool {
+	for arc := conf.GetLabeledArc(node); arc != nil; arc = conf.GetLabeledArc(node) {
+		node = arc.GetHead()
+		if node == head {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Covington) possibleTransitions(from Configuration, transitions chan int) {
+	conf, ok := from.(*SimpleConfiguration)
+	if !ok {
+		panic("Got wrong configuration type")
+	}
+	wj, qExists := conf.Queue().Peek()
+	wi, sExists := conf.Stack().Peek()
+	if qExists {
+		transitions <- a.SHIFT
+	}
+	if sExists && qExists {
+		transitions <- a.NOARC
+		if !conf.Arcs().HasHead(wi) && !a.dominates(conf, wi, wj) {
+			for rel, _ := range a.Relations.Index {
+				transitions <- a.LEFT + rel
+			}
+		}
+		if !conf.Arcs().HasHead(wj) && !a.dominates(conf, wj, wi) {
+			for rel, _ := range a.Relations.Index {
+				transitions <- a.RIGHT + rel
+			}
+		}
+	}
+	close(transitions)
+}
+
+func (a *Covington) GetTransitions(from Configuration) (byte, []int) {
+	retval := make([]int, 0, 10)
+	tType, transitions := a.YieldTransitions(from)
+	for transition := range transitions {
+		retval = append(retval, transition)
+	}
+	return tType, retval
+}
+
+func (a *Covington) YieldTransitions(from Configuration) (byte, chan int) {
+	transitions := make(chan int)
+	go a.possibleTransitions(from, transitions)
+	return TransitionType, transitions
+}
+
+func (a *Covington) TransitionTypes() []string {
+	return []string{"LA-*", "RA-*", "SH", "NA"}
+}
+
+func (a *Covington) Projective() bool {
+	return false
+}
+
+func (a *Covington) AddDefaultOracle() {
+	a.oracle = Oracle(&CovingtonOracle{
+		ArcStandardOracle: ArcStandardOracle{Transitions: a.Transitions, LA: a.LEFT, RA: a.RIGHT},
+		NOARC:             a.NOARC,
+	})
+}
+
+func (a *Covington) Name() string {
+	return "Covington (Nivre 2008)"
+}
+
+// CovingtonOracle is the static oracle of the Covington system (Nivre 2008)
+type CovingtonOracle struct {
+	ArcStandardOracle
+	NOARC int
+}
+
+var _ Decision = &CovingtonOracle{}
+
+func (o *CovingtonOracle) Transition(conf Configuration) Transition {
+	c := conf.(*SimpleConfiguration)
+
+	if o.gold == nil {
+		panic("Oracle needs gold reference, use SetGold")
+	}
+	// Given Gd=(Vd,Ad) # gold dependencies
+	// o(c = (L1|wi,L2,wj|B,A)) =
+	// LA-r	if	(wj,r,wi) in Ad
+	// RA-r	if	(wi,r,wj) in Ad
+	// NA	if	(wj,r,wk) or (wk,r,wj) in Ad for some wk in L1
+	// SH	otherwise
+	wj, bExists := c.Queue().Peek()
+	wi, sExists := c.Stack().Peek()
+	var index int
+	if !bExists {
+		panic(fmt.Sprintf("Got terminal configuration %v", c))
+	}
+	if sExists {
+		arcs := o.arcSet.Get(&BasicDepArc{wj, -1, wi, DepRel("")})
+		if len(arcs) > 0 {
+			index, _ = o.Transitions.IndexOf("LA-" + string(arcs[0].GetRelation()))
+			return &TypedTransition{TransitionType, index}
+		}
+		arcs = o.arcSet.Get(&BasicDepArc{wi, -1, wj, DepRel("")})
+		if len(arcs) > 0 {
+			index, _ = o.Transitions.IndexOf("RA-" + string(arcs[0].GetRelation()))
+			return &TypedTransition{TransitionType, index}
+		}
+		for i := 1; i < c.Stack().Size(); i++ {
+			wk, _ := c.Stack().Index(i)
+			if len(o.arcSet.Get(&BasicDepArc{wj, -1, wk, DepRel("")})) > 0 ||
+				len(o.arcSet.Get(&BasicDepArc{wk, -1, wj, DepRel("")})) > 0 {
+				return &TypedTransition{TransitionType, o.NOARC}
+			}
+		}
+	}
+	index, _ = o.Transitions.IndexOf("SH")
+	return &TypedTransition{TransitionType, index}
+}
+
+func (o *CovingtonOracle) Name() string {
+	return "Covington (static)"
+}
@@ -0,0 +1,58 @@
+package transition
+
+import (
+	. "yap/alg/transition"
+
+	"testing"
+)
+
+func SetupCovingtonTransEnum() {
+	SetupEagerTransEnum()
+	iNA, _ := TRANSITIONS_ENUM.Add("NA")
+	NA = ConstTransition(iNA)
+}
+
+func newTestCovington() *Covington {
+	SetupTestEnum()
+	SetupCovingtonTransEnum()
+	return &Covington{
+		ArcStandard: ArcStandard{
+			SHIFT:       SH.Value(),
+			LEFT:        LA.Value(),
+			RIGHT:       RA.Value(),
+			Relations:   TEST_ENUM_RELATIONS,
+			Transitions: TRANSITIONS_ENUM,
+		},
+		NOARC: NA.Value(),
+	}
+}
+
+func TestCovingtonOracle(t *testing.T) {
+	oracleParse(t, newTestCovington(), TEST_SENT, GetTestDepGraph(), -1)
+	oracleParse(t, newTestCovington(), GetTestNonProjectiveSentence(), GetTestNonProjectiveGraph(), -1)
+}
+
+func TestCovingtonNoArc(t *testing.T) {
+	covington := newTestCovington()
+	if covington.NOARC == RE.Value() {
+		t.Fatal("NA is the transition of RE")
+	}
+	conf := &SimpleConfiguration{
+		EWord:  EWord,
+		EPOS:   EPOS,
+		EWPOS:  EWPOS,
+		ERel:   TEST_ENUM_RELATIONS,
+		ETrans: TRANSITIONS_ENUM,
+	}
+	conf.Init(TEST_SENT)
+	// (L1=[Economic], L2=[], news|B) => (L1=[], L2=[Economic], news|B)
+	c := covington.Transition(covington.Transition(conf, SH), NA).(*SimpleConfiguration)
+	if c.Stack().Size() != 0 || c.Queue().Size() != len(rawTestSent)-1 || c.Arcs().Size() != 0 {
+		t.Errorf("Got configuration %v after SH NA", c)
+	}
+	// SH pushes back L2 and the shifted word
+	c = covington.Transition(c, SH).(*SimpleConfiguration)
+	if s0, _ := c.Stack().Peek(); c.Stack().Size() != 2 || s0 != 1 {
+		t.Errorf("Got configuration %v after SH NA SH", c)
+	}
+}
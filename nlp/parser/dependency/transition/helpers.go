@@ -37,13 +37,16 @@ func (s *ArcSetSimple) Len() int {
 	return s.Size()
 }
 
+// ValueComp compares arcs in the (head, modifier) order of sorted arc sets:
+// 0 if equal, negative if the arc of s precedes the other's or differs from
+// it only in relation, positive if it follows it
 func (s *ArcSetSimple) ValueComp(i, j int, other *ArcSetSimple) int {
 	left := s.Arcs[i]
 	right := other.Arcs[j]
 	if reflect.DeepEqual(left, right) {
 		return 0
 	}
-	if left.GetModifier() < right.GetModifier() {
+	if left.GetHead() > right.GetHead() || (left.GetHead() == right.GetHead() && left.GetModifier() > right.GetModifier()) {
 		return 1
 	}
 	return -1
@@ -97,6 +100,12 @@ func (s *ArcSetSimple) Diff(other ArcSet) (ArcSet, ArcSet) {
 			j++
 		}
 	}
+	for ; i < copyThis.Len(); i++ {
+		leftOnly.Add(copyThis.Arcs[i])
+	}
+	for ; j < copyOther.Len(); j++ {
+		rightOnly.Add(copyOther.Arcs[j])
+	}
 	return leftOnly, rightOnly
 }
 
@@ -169,7 +178,7 @@ func (s *ArcSetSimple) Last() LabeledDepArc {
 func (s *ArcSetSimple) String() string {
 	arcs := make([]string, s.Size())
 	for i, arc := range s.Arcs {
-		arcs[i] = fmt.Sprintf("%d %d %v", i, arc.ID, arc.String())
+		arcs[i] = fmt.Sprintf("%d %d %v", i, arc.ID(), arc.String())
 	}
 	return strings.Join(arcs, "\n")
 }
@@ -1,12 +1,14 @@
 package transition
 
 import (
+	"yap/alg"
 	. "yap/nlp/types"
+
 	"testing"
 )
 
 type StackArrayTest struct {
-	stack *StackArray
+	stack *alg.StackArray
 	t     *testing.T
 }
 
@@ -116,7 +118,7 @@ func (s *StackArrayTest) Copy() {
 	s.stack.Push(4)
 	s.stack.Push(3)
 	s.stack.Push(2)
-	newStack := s.stack.Copy().(*StackArray)
+	newStack := s.stack.Copy().(*alg.StackArray)
 	if len(newStack.Array) != len(s.stack.Array) {
 		s.t.Error("Stack copy failed to produce copy of same length")
 	}
@@ -143,7 +145,7 @@ func (test *StackArrayTest) All() {
 
 func TestStackArray(t *testing.T) {
 	const CAPACITY = 5
-	stack := NewStackArray(CAPACITY)
+	stack := alg.NewStackArray(CAPACITY)
 	if cap(stack.Array) != CAPACITY {
 		t.Error("NewStackArray has wrong capacity")
 	}
@@ -354,3 +356,49 @@ func TestArcSetSimple(t *testing.T) {
 	test := ArcSetSimpleTest{arcSet, t}
 	test.All()
 }
+
+func TestArcSetSimpleDiff(t *testing.T) {
+	arc := func(head, modifier int, rel string) LabeledDepArc {
+		return &BasicDepArc{Head: head, Modifier: modifier, RawRelation: DepRel(rel)}
+	}
+	arcSet := func(arcs ...LabeledDepArc) *ArcSetSimple {
+		set := NewArcSetSimple(len(arcs))
+		for _, a := range arcs {
+			set.Add(a)
+		}
+		return set
+	}
+	tests := []struct {
+		name                string
+		left, right         *ArcSetSimple
+		leftOnly, rightOnly *ArcSetSimple
+	}{
+		{"equal",
+			arcSet(arc(0, 2, "ROOT"), arc(2, 1, "subj")), arcSet(arc(2, 1, "subj"), arc(0, 2, "ROOT")),
+			arcSet(), arcSet()},
+		{"relation",
+			arcSet(arc(0, 2, "ROOT"), arc(2, 1, "subj")), arcSet(arc(0, 2, "ROOT"), arc(2, 1, "obj")),
+			arcSet(arc(2, 1, "subj")), arcSet(arc(2, 1, "obj"))},
+		{"smaller head and modifier",
+			arcSet(arc(1, 2, "a"), arc(2, 3, "b")), arcSet(arc(2, 3, "b")),
+			arcSet(arc(1, 2, "a")), arcSet()},
+		{"left tail",
+			arcSet(arc(1, 3, "a"), arc(2, 1, "b")), arcSet(arc(1, 3, "a")),
+			arcSet(arc(2, 1, "b")), arcSet()},
+		{"right tail",
+			arcSet(arc(1, 3, "a")), arcSet(arc(1, 3, "a"), arc(2, 1, "b"), arc(3, 4, "c")),
+			arcSet(), arcSet(arc(2, 1, "b"), arc(3, 4, "c"))},
+		{"disjoint",
+			arcSet(arc(2, 1, "a")), arcSet(arc(1, 3, "b")),
+			arcSet(arc(2, 1, "a")), arcSet(arc(1, 3, "b"))},
+	}
+	for _, test := range tests {
+		leftOnly, rightOnly := test.left.Diff(test.right)
+		if !leftOnly.Equal(test.leftOnly) {
+			t.Errorf("%s: expected left only %v, got %v", test.name, test.leftOnly, leftOnly)
+		}
+		if !rightOnly.Equal(test.rightOnly) {
+			t.Errorf("%s: expected right only %v, got %v", test.name, test.rightOnly, rightOnly)
+		}
+	}
+}
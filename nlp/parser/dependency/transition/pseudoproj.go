@@ -0,0 +1,232 @@
+package transition
+
+import (
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"strings"
+)
+
+// Pseudo-projective encoding schemes of lifted arcs (Nivre & Nilsson 2005)
+const (
+	// the label of a lifted arc encodes the label of its syntactic head
+	PSEUDO_PROJECTIVE_HEAD = "head"
+	// the label of a lifted arc is marked, as are the arcs of the path
+	// from its linear head down to its syntactic head
+	PSEUDO_PROJECTIVE_PATH = "path"
+
+	LIFT_MARK = "↑"
+	PATH_MARK = "↓"
+)
+
+var PSEUDO_PROJECTIVE_SCHEMES = []string{PSEUDO_PROJECTIVE_HEAD, PSEUDO_PROJECTIVE_PATH}
+
+func ValidPseudoProjectiveScheme(scheme string) bool {
+	for _, valid := range PSEUDO_PROJECTIVE_SCHEMES {
+		if scheme == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// splitLiftedLabel decodes a pseudo-projective label into the original
+// label, the encoded syntactic head label (head scheme), whether the arc
+// was lifted and whether it is on the path of a lifted arc (path scheme)
+func splitLiftedLabel(label nlp.DepRel) (rel, headRel string, lifted, onPath bool) {
+	rel = string(label)
+	onPath = strings.HasSuffix(rel, PATH_MARK)
+	rel = strings.TrimSuffix(rel, PATH_MARK)
+	if i := strings.Index(rel, LIFT_MARK); i >= 0 {
+		rel, headRel, lifted = rel[:i], rel[i+len(LIFT_MARK):], true
+	}
+	return
+}
+
+// arcHead is the head of an arc, -1 for a missing or root arc (which may
+// point at node 0, see ArcEager's POPROOT)
+func arcHead(arc *BasicDepArc) int {
+	if arc == nil || arc.RawRelation == nlp.DepRel(nlp.ROOT_LABEL) {
+		return -1
+	}
+	return arc.Head
+}
+
+func graphHeads(arcs []*BasicDepArc) []int {
+	heads := make([]int, len(arcs))
+	for i, arc := range arcs {
+		heads[i] = arcHead(arc)
+	}
+	return heads
+}
+
+func dominates(heads []int, head, node int) bool {
+	for steps := 0; node >= 0 && steps <= len(heads); steps++ {
+		if node == head {
+			return true
+		}
+		node = heads[node]
+	}
+	return false
+}
+
+// smallestNonProjective returns the modifier of the shortest (leftmost
+// among equals) non-projective arc that can be lifted, or -1 if none
+func smallestNonProjective(heads []int) int {
+	result, resultSpan := -1, len(heads)+1
+	for modifier, head := range heads {
+		if head < 0 || heads[head] < 0 {
+			continue
+		}
+		left, right := util.Min(head, modifier), util.Max(head, modifier)
+		if right-left >= resultSpan {
+			continue
+		}
+		for k := left + 1; k < right; k++ {
+			if !dominates(heads, head, k) {
+				result, resultSpan = modifier, right-left
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Projectivize lifts the non-projective arcs of a graph (the shortest
+// first) to the head of their head until the graph is projective,
+// encoding the lifts in the arcs' raw relations by the given scheme.
+// Relation indices of changed arcs are left for the caller to update.
+// Returns the number of lifted arcs
+func Projectivize(graph *BasicDepGraph, scheme string) int {
+	heads := graphHeads(graph.Arcs)
+	lifted := make([]bool, len(heads))
+	var numLifted int
+	for modifier := smallestNonProjective(heads); modifier >= 0; modifier = smallestNonProjective(heads) {
+		arc := graph.Arcs[modifier]
+		headArc := graph.Arcs[arc.Head]
+		if !lifted[modifier] {
+			lifted[modifier] = true
+			numLifted++
+			switch scheme {
+			case PSEUDO_PROJECTIVE_HEAD:
+				headRel, _, _, _ := splitLiftedLabel(headArc.RawRelation)
+				arc.RawRelation = nlp.DepRel(string(arc.RawRelation) + LIFT_MARK + headRel)
+			case PSEUDO_PROJECTIVE_PATH:
+				arc.RawRelation = nlp.DepRel(string(arc.RawRelation) + LIFT_MARK)
+			default:
+				panic("Unknown pseudo-projective scheme " + scheme)
+			}
+		}
+		if scheme == PSEUDO_PROJECTIVE_PATH && !strings.HasSuffix(string(headArc.RawRelation), PATH_MARK) {
+			headArc.RawRelation = nlp.DepRel(string(headArc.RawRelation) + PATH_MARK)
+		}
+		arc.Head = headArc.Head
+		heads[modifier] = arc.Head
+	}
+	return numLifted
+}
+
+// findSyntacticHead searches breadth first, left to right, below the
+// linear head of a lifted arc (not descending into the lifted subtree) for
+// the syntactic head: by its label for the head scheme, or the first node
+// ending the path of marked arcs for the path scheme. Returns the linear
+// head if not found
+func findSyntacticHead(arcs []*BasicDepArc, head, modifier int, scheme, headRel string) int {
+	children := func(node int) []int {
+		var result []int
+		for i, arc := range arcs {
+			if i == modifier || arcHead(arc) != node {
+				continue
+			}
+			if scheme == PSEUDO_PROJECTIVE_PATH {
+				if _, _, _, onPath := splitLiftedLabel(arc.RawRelation); !onPath {
+					continue
+				}
+			}
+			result = append(result, i)
+		}
+		return result
+	}
+	queue := children(head)
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		next := children(node)
+		switch scheme {
+		case PSEUDO_PROJECTIVE_HEAD:
+			if rel, _, _, _ := splitLiftedLabel(arcs[node].RawRelation); rel == headRel {
+				return node
+			}
+		case PSEUDO_PROJECTIVE_PATH:
+			if len(next) == 0 {
+				return node
+			}
+		}
+		queue = append(queue, next...)
+	}
+	return head
+}
+
+// Deprojectivize returns a copy of a parsed graph with its lifted arcs
+// attached back to their syntactic heads (when found) and the
+// pseudo-projective encodings removed from the labels
+func Deprojectivize(graph nlp.LabeledDependencyGraph, scheme string, eRel *util.EnumSet) *BasicDepGraph {
+	numNodes := graph.NumberOfNodes()
+	result := &BasicDepGraph{
+		Nodes: make([]nlp.DepNode, numNodes),
+		Arcs:  make([]*BasicDepArc, numNodes),
+	}
+	for i := 0; i < numNodes; i++ {
+		result.Nodes[i] = graph.GetNode(i)
+	}
+	// edges of a parsed configuration are in transition order
+	for _, arcID := range graph.GetEdges() {
+		if arc := graph.GetLabeledArc(arcID); arc != nil {
+			modifier := arc.GetModifier()
+			result.Arcs[modifier] = &BasicDepArc{Head: arc.GetHead(), Modifier: modifier, RawRelation: arc.GetRelation()}
+		}
+	}
+	// reattach top down, so lifted arcs are searched for in the final tree
+	heads := graphHeads(result.Arcs)
+	order := make([]int, 0, numNodes)
+	for i, head := range heads {
+		if head < 0 {
+			order = append(order, i)
+		}
+	}
+	for i := 0; i < len(order); i++ {
+		for modifier, head := range heads {
+			if head == order[i] {
+				order = append(order, modifier)
+			}
+		}
+	}
+	for _, modifier := range order {
+		arc := result.Arcs[modifier]
+		if arc == nil {
+			continue
+		}
+		rel, headRel, lifted, onPath := splitLiftedLabel(arc.RawRelation)
+		if !lifted {
+			continue
+		}
+		arc.Head = findSyntacticHead(result.Arcs, arc.Head, modifier, scheme, headRel)
+		if onPath {
+			rel += PATH_MARK
+		}
+		arc.RawRelation = nlp.DepRel(rel)
+	}
+	for _, arc := range result.Arcs {
+		if arc == nil {
+			continue
+		}
+		rel, _, _, _ := splitLiftedLabel(arc.RawRelation)
+		arc.RawRelation = nlp.DepRel(rel)
+		if index, exists := eRel.IndexOf(arc.RawRelation); exists {
+			arc.Relation = index
+		} else {
+			arc.Relation = -1
+		}
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+package transition
+
+import (
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"testing"
+)
+
+func TestPseudoProjectiveRoundTrip(t *testing.T) {
+	eRel := util.NewEnumSet(len(nonProjectiveRelations), "ERel")
+	for _, rel := range nonProjectiveRelations {
+		eRel.Add(rel)
+	}
+	// with "today" attached to "is" only the arc of "on" is non-projective
+	singleHeads := append([]int(nil), nonProjectiveHeads...)
+	singleHeads[7] = 2
+	for _, test := range []struct {
+		name, scheme   string
+		heads          []int
+		lifted         int
+		labels         map[int]nlp.DepRel // of the projectivized graph
+		projectiveHead map[int]int
+	}{
+		{"single lift", PSEUDO_PROJECTIVE_HEAD, singleHeads, 1,
+			map[int]nlp.DepRel{4: "ATT" + LIFT_MARK + "SBJ", 1: "SBJ"}, map[int]int{4: 2}},
+		{"single lift", PSEUDO_PROJECTIVE_PATH, singleHeads, 1,
+			map[int]nlp.DepRel{4: "ATT" + LIFT_MARK, 1: "SBJ" + PATH_MARK}, map[int]int{4: 2}},
+		// the paths of both lifts start at "is", the path scheme can't tell
+		// which one ends at "scheduled"
+		{"double lift", PSEUDO_PROJECTIVE_HEAD, nonProjectiveHeads, 2,
+			map[int]nlp.DepRel{4: "ATT" + LIFT_MARK + "SBJ", 7: "TMP" + LIFT_MARK + "VC"}, map[int]int{4: 2, 7: 2}},
+		{"projective", PSEUDO_PROJECTIVE_HEAD, []int{1, 2, -1, 2, 3, 6, 4, 3, 2}, 0, nil, nil},
+		{"projective", PSEUDO_PROJECTIVE_PATH, []int{1, 2, -1, 2, 3, 6, 4, 3, 2}, 0, nil, nil},
+	} {
+		graph := newTestGraph(nonProjectiveTokens, test.heads, nonProjectiveRelations)
+		if lifted := Projectivize(graph, test.scheme); lifted != test.lifted {
+			t.Errorf("%s %s: lifted %d arcs, expected %d", test.name, test.scheme, lifted, test.lifted)
+		}
+		if modifier := smallestNonProjective(graphHeads(graph.Arcs)); modifier >= 0 {
+			t.Errorf("%s %s: arc of %d is still non-projective", test.name, test.scheme, modifier)
+		}
+		for modifier, label := range test.labels {
+			if arc := graph.Arcs[modifier]; arc.RawRelation != label {
+				t.Errorf("%s %s: got projectivized arc %v, expected label %s", test.name, test.scheme, arc, label)
+			}
+		}
+		for modifier, head := range test.projectiveHead {
+			if arc := graph.Arcs[modifier]; arc.Head != head {
+				t.Errorf("%s %s: got projectivized arc %v, expected head %d", test.name, test.scheme, arc, head)
+			}
+		}
+
+		restored := Deprojectivize(graph, test.scheme, eRel)
+		for i, arc := range restored.Arcs {
+			if arc.Head != test.heads[i] || arc.RawRelation != nonProjectiveRelations[i] {
+				t.Errorf("%s %s: deprojectivized arc %v, expected head %d and label %s", test.name, test.scheme, arc, test.heads[i], nonProjectiveRelations[i])
+			}
+			if index, _ := eRel.IndexOf(nonProjectiveRelations[i]); arc.Relation != index {
+				t.Errorf("%s %s: deprojectivized arc %v has relation index %d, expected %d", test.name, test.scheme, arc, arc.Relation, index)
+			}
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package dependency
 
 import (
+	"yap/alg/transition"
 	TransitionModel "yap/alg/transition/model"
 	. "yap/nlp/types"
 )
 
-type ConstraintModel interface{}
+// A ConstraintModel restricts parsing to the transitions consistent with
+// known parts of a parse, e.g. annotated heads and labels
+type ConstraintModel interface {
+	transition.Constraints
+}
 
 type ParameterModelValue interface {
 	// Increment(interface{})
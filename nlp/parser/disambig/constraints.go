@@ -0,0 +1,89 @@
+package disambig
+
+import (
+	. "yap/alg/transition"
+	nlp "yap/nlp/types"
+)
+
+// FixedPaths restrict the disambiguation of a sentence's lattices to paths
+// through their fixed morphemes, given by the ids of the fixed morphemes of
+// each lattice. A lattice without fixed morphemes is not restricted, nor is
+// one whose fixed morphemes are on no path together
+type FixedPaths []map[int]bool
+
+var _ Constraints = FixedPaths{}
+
+// onPath is true if a path through a morpheme can go through all fixed
+// morphemes of its lattice: no other morpheme is fixed where it starts, and
+// none starts within it
+func (f FixedPaths) onPath(lat *nlp.Lattice, fixed map[int]bool, morph *nlp.EMorpheme) bool {
+	for id := range fixed {
+		start := lat.Morphemes[id].From()
+		if (start == morph.From() && !fixed[morph.ID()]) || (start > morph.From() && start < morph.To()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f FixedPaths) Allowed(conf Configuration, transType byte, transitions []int) []int {
+	c := conf.(*MDConfig)
+	qTop, qExists := c.LatticeQueue.Peek()
+	if transType != 'M' || !qExists || qTop >= len(f) || len(f[qTop]) == 0 {
+		return transitions
+	}
+	lat := &c.Lattices[qTop]
+	onPath := make(map[string]bool)
+	for _, next := range lat.Next[c.CurrentLatNode] {
+		if morph := lat.Morphemes[next]; f.onPath(lat, f[qTop], morph) {
+			onPath[c.ParamFunc(morph)] = true
+		}
+	}
+	allowed := make([]int, 0, len(transitions))
+	for _, t := range transitions {
+		if paramStr, ok := c.Transitions.ValueOf(t).(string); ok && onPath[paramStr] {
+			allowed = append(allowed, t)
+		}
+	}
+	if len(allowed) == 0 {
+		return transitions
+	}
+	return allowed
+}
+
+// MappingPaths returns the fixed paths of a sentence's lattices spelling out
+// the spellouts of its mappings, e.g. the annotated tokens of a partially
+// disambiguated sentence. A lattice without a mapping, or without a path
+// spelling out its mapping, is not restricted
+func MappingPaths(lattices nlp.LatticeSentence, mappings []*nlp.Mapping) FixedPaths {
+	fixed := make(FixedPaths, len(lattices))
+	for i, mapping := range mappings {
+		if i >= len(lattices) || mapping == nil {
+			continue
+		}
+		fixed[i] = spelloutPath(&lattices[i], lattices[i].Bottom(), mapping.Spellout)
+	}
+	return fixed
+}
+
+// spelloutPath returns the ids of the morphemes of a path of a lattice from
+// a node to its top spelling out a spellout, or nil if there is none
+func spelloutPath(lat *nlp.Lattice, node int, spellout nlp.Spellout) map[int]bool {
+	if len(spellout) == 0 {
+		if node != lat.Top() {
+			return nil
+		}
+		return make(map[int]bool)
+	}
+	for _, next := range lat.Next[node] {
+		morph := lat.Morphemes[next]
+		if !morph.Equal(spellout[0]) {
+			continue
+		}
+		if path := spelloutPath(lat, morph.To(), spellout[1:]); path != nil {
+			path[morph.ID()] = true
+			return path
+		}
+	}
+	return nil
+}
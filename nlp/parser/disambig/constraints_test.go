@@ -0,0 +1,124 @@
+package disambig
+
+import (
+	"yap/nlp/format/lattice"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// two tokens, the first either H BIT or HBIT
+const testLattice = "0	1	H	_	DEF	DEF	_	1\n" +
+	"0	2	HBIT	_	NN	NN	gen=M|num=S	1\n" +
+	"1	2	BIT	_	NN	NN	gen=M|num=S	1\n" +
+	"2	3	GDWL	_	JJ	JJ	gen=M|num=S	2\n\n"
+
+func testSentence(t *testing.T) nlp.LatticeSentence {
+	lat, err := lattice.Read(strings.NewReader(testLattice), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	eWord, ePOS, eWPOS := util.NewEnumSet(10, "EWord"), util.NewEnumSet(10, "EPOS"), util.NewEnumSet(10, "EWPOS")
+	eMorphFeat, eMHost, eMSuffix := util.NewEnumSet(10, "EMorphFeat"), util.NewEnumSet(10, "EMHost"), util.NewEnumSet(10, "EMSuffix")
+	return lattice.Lattice2Sentence(lat[0], eWord, ePOS, eWPOS, eMorphFeat, eMHost, eMSuffix)
+}
+
+// morphIDs returns the ids of the morphemes of a lattice with the forms
+func morphIDs(lat *nlp.Lattice, forms ...string) map[int]bool {
+	ids := make(map[int]bool)
+	for _, morph := range lat.Morphemes {
+		for _, form := range forms {
+			if morph.Form == form {
+				ids[morph.ID()] = true
+			}
+		}
+	}
+	return ids
+}
+
+// testConfig returns the initial configuration of the sentence and its
+// disambiguation transitions, by form
+func testConfig(sent nlp.LatticeSentence) (*MDConfig, []int) {
+	conf := &MDConfig{
+		Transitions: util.NewEnumSet(4, "ETrans"),
+		ParamFunc:   nlp.Form,
+	}
+	conf.Init(sent)
+	var transitions []int
+	for _, next := range sent[0].Next[sent[0].Bottom()] {
+		t, _ := conf.Transitions.Add(nlp.Form(sent[0].Morphemes[next]))
+		transitions = append(transitions, t)
+	}
+	return conf, transitions
+}
+
+func transitionForms(conf *MDConfig, transitions []int) []string {
+	forms := make([]string, len(transitions))
+	for i, t := range transitions {
+		forms[i] = conf.Transitions.ValueOf(t).(string)
+	}
+	sort.Strings(forms)
+	return forms
+}
+
+func TestFixedPathsAllowed(t *testing.T) {
+	sent := testSentence(t)
+	conf, transitions := testConfig(sent)
+	tests := []struct {
+		name      string
+		fixed     FixedPaths
+		transType byte
+		expected  []string
+	}{
+		{"fixed morpheme", FixedPaths{morphIDs(&sent[0], "H"), nil}, 'M', []string{"H"}},
+		// HBIT spans the start of the fixed BIT
+		{"fixed later morpheme", FixedPaths{morphIDs(&sent[0], "BIT"), nil}, 'M', []string{"H"}},
+		{"fixed whole token", FixedPaths{morphIDs(&sent[0], "HBIT"), nil}, 'M', []string{"HBIT"}},
+		{"other lattice fixed", FixedPaths{nil, morphIDs(&sent[1], "GDWL")}, 'M', []string{"H", "HBIT"}},
+		{"no fixed paths", FixedPaths{}, 'M', []string{"H", "HBIT"}},
+		{"not disambiguating", FixedPaths{morphIDs(&sent[0], "H"), nil}, 'P', []string{"H", "HBIT"}},
+		// no path goes through both, the lattice is not restricted
+		{"conflicting", FixedPaths{morphIDs(&sent[0], "H", "HBIT"), nil}, 'M', []string{"H", "HBIT"}},
+	}
+	for _, test := range tests {
+		if allowed := transitionForms(conf, test.fixed.Allowed(conf, test.transType, transitions)); !reflect.DeepEqual(allowed, test.expected) {
+			t.Errorf("%s: allowed %v, expected %v", test.name, allowed, test.expected)
+		}
+	}
+}
+
+func TestMappingPaths(t *testing.T) {
+	sent := testSentence(t)
+	spellout := func(lat *nlp.Lattice, forms ...string) nlp.Spellout {
+		var result nlp.Spellout
+		for _, form := range forms {
+			for _, morph := range lat.Morphemes {
+				if morph.Form == form {
+					result = append(result, morph)
+				}
+			}
+		}
+		return result
+	}
+	tests := []struct {
+		name     string
+		mappings []*nlp.Mapping
+		expected FixedPaths
+	}{
+		{"segmented", []*nlp.Mapping{{Token: "HBIT", Spellout: spellout(&sent[0], "H", "BIT")}}, FixedPaths{morphIDs(&sent[0], "H", "BIT"), nil}},
+		{"both tokens", []*nlp.Mapping{{Token: "HBIT", Spellout: spellout(&sent[0], "HBIT")}, {Token: "GDWL", Spellout: spellout(&sent[1], "GDWL")}},
+			FixedPaths{morphIDs(&sent[0], "HBIT"), morphIDs(&sent[1], "GDWL")}},
+		// a missing mapping, and a spellout short of the top of its lattice
+		{"unannotated", []*nlp.Mapping{nil, {Token: "GDWL"}}, FixedPaths{nil, nil}},
+		{"not in lattice", []*nlp.Mapping{{Token: "HBIT", Spellout: spellout(&sent[1], "GDWL")}}, FixedPaths{nil, nil}},
+	}
+	for _, test := range tests {
+		if fixed := MappingPaths(sent, test.mappings); !reflect.DeepEqual(fixed, test.expected) {
+			t.Errorf("%s: got fixed paths %v, expected %v", test.name, fixed, test.expected)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package joint
+
+import (
+	. "yap/alg/transition"
+)
+
+// MDConstraints restrict the disambiguation transitions of joint
+// configurations by constraints of their MD configurations, e.g. to fixed
+// lattice paths
+type MDConstraints struct {
+	MD Constraints
+}
+
+var _ Constraints = &MDConstraints{}
+
+func (m *MDConstraints) Allowed(conf Configuration, transType byte, transitions []int) []int {
+	if transType != 'M' && transType != 'P' && transType != 'L' {
+		return transitions
+	}
+	return m.MD.Allowed(&conf.(*JointConfig).MDConfig, transType, transitions)
+}
@@ -0,0 +1,43 @@
+package joint
+
+import (
+	"yap/alg/transition"
+	"yap/nlp/parser/disambig"
+
+	"reflect"
+	"testing"
+)
+
+// firstAllowed allows the first transition, recording the configuration
+// it restricted
+type firstAllowed struct {
+	conf transition.Configuration
+}
+
+func (f *firstAllowed) Allowed(conf transition.Configuration, transType byte, transitions []int) []int {
+	f.conf = conf
+	return transitions[:1]
+}
+
+func TestMDConstraintsAllowed(t *testing.T) {
+	conf := &JointConfig{}
+	transitions := []int{3, 4, 5}
+	for _, transType := range []byte{'M', 'P', 'L', 'A'} {
+		md := &firstAllowed{}
+		constraints := &MDConstraints{MD: md}
+		allowed := constraints.Allowed(conf, transType, transitions)
+		if transType == 'A' {
+			// dependency transitions are not restricted
+			if !reflect.DeepEqual(allowed, transitions) || md.conf != nil {
+				t.Errorf("Got %v allowed of dependency transitions %v, expected all", allowed, transitions)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(allowed, transitions[:1]) {
+			t.Errorf("Got %v allowed of %c transitions, expected %v", allowed, transType, transitions[:1])
+		}
+		if mdConf, ok := md.conf.(*disambig.MDConfig); !ok || mdConf != &conf.MDConfig {
+			t.Errorf("The %c transitions were restricted by %v, expected the MD configuration", transType, md.conf)
+		}
+	}
+}